@@ -0,0 +1,309 @@
+package main
+
+import "crypto/subtle"
+import "encoding/json"
+import "errors"
+import "fmt"
+import "io"
+import "net/http"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+
+// Largest request body accepted by the API
+const MaxRequestSize = 1 << 20
+
+// Header holding the caller's API key
+const ApiKeyHeader = "X-Api-Key"
+
+// Number of Redis connections shared by the requests
+const ApiConnections = 8
+
+// REST API for the counters:
+//
+//	GET    /v1/keys/{key}
+//	PUT    /v1/keys/{key}                        {"value": 123}
+//	POST   /v1/keys/{key}/increment              {"amount": 1}
+//	DELETE /v1/keys/{key}
+//	GET    /v1/hashes/{key}/{field}
+//	PUT    /v1/hashes/{key}/{field}              {"value": 123}
+//	POST   /v1/hashes/{key}/{field}/increment    {"amount": 1}
+//	DELETE /v1/hashes/{key}/{field}
+//	POST   /v1/batch/get                         {"keys": ["a", "b"]}
+//	POST   /v1/batch/set                         {"key": "h", "fields": ["a", "b"], "value": 123}
+//	POST   /v1/batch/increment                   {"keys": ["a", "b"], "amount": 1}
+//	POST   /v1/batch/delete                      {"keys": ["a", "b"]}
+//
+// Counters are int64 unless the request has "?type=float". A GET of a
+// missing counter returns 404; the batch get returns 0 for it. A counter
+// holding something other than a number returns 409, an increment that
+// would overflow 422, and an unavailable Redis 503. A connection
+// can't be used by two requests at once, so each request takes one of
+// ApiConnections connections to the Redis server at Redis.Url; the
+// counter operations themselves are atomic in Redis.
+type Api struct {
+	Redis   *dog_pool.RedisConnection
+	ApiKeys []string
+
+	connections chan *dog_pool.RedisConnection
+}
+
+// Body of the single and batch requests
+type CounterRequest struct {
+	Key    string      `json:"key,omitempty"`
+	Keys   []string    `json:"keys,omitempty"`
+	Fields []string    `json:"fields,omitempty"`
+	Value  json.Number `json:"value,omitempty"`
+	Amount json.Number `json:"amount,omitempty"`
+}
+
+// Body of every successful response
+type CounterResponse struct {
+	Key   string      `json:"key"`
+	Field string      `json:"field,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// Body of every failed response
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Make a new instance of Api
+func MakeApi(redis *dog_pool.RedisConnection, api_keys ...string) (*Api, error) {
+	switch {
+	case nil == redis:
+		return nil, fmt.Errorf("Nil redis connection")
+	case len(api_keys) == 0:
+		return nil, fmt.Errorf("Empty api keys")
+	default:
+		for i, api_key := range api_keys {
+			if len(api_key) == 0 {
+				return nil, fmt.Errorf("Empty api key[%d]", i)
+			}
+		}
+
+		connections := make(chan *dog_pool.RedisConnection, ApiConnections)
+		connections <- redis
+		for i := 1; i < ApiConnections; i++ {
+			connections <- &dog_pool.RedisConnection{Url: redis.Url, Logger: redis.Logger}
+		}
+		return &Api{Redis: redis, ApiKeys: api_keys, connections: connections}, nil
+	}
+}
+
+// Routes for the API, requiring a valid API key
+func (p *Api) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/keys/{key}", p.handleCounter(opGet, false))
+	mux.HandleFunc("PUT /v1/keys/{key}", p.handleCounter(opSet, false))
+	mux.HandleFunc("POST /v1/keys/{key}/increment", p.handleCounter(opAdd, false))
+	mux.HandleFunc("DELETE /v1/keys/{key}", p.handleCounter(opDelete, false))
+	mux.HandleFunc("GET /v1/hashes/{key}/{field}", p.handleCounter(opGet, true))
+	mux.HandleFunc("PUT /v1/hashes/{key}/{field}", p.handleCounter(opSet, true))
+	mux.HandleFunc("POST /v1/hashes/{key}/{field}/increment", p.handleCounter(opAdd, true))
+	mux.HandleFunc("DELETE /v1/hashes/{key}/{field}", p.handleCounter(opDelete, true))
+	mux.HandleFunc("POST /v1/batch/get", p.handleBatch(opGet))
+	mux.HandleFunc("POST /v1/batch/set", p.handleBatch(opSet))
+	mux.HandleFunc("POST /v1/batch/increment", p.handleBatch(opAdd))
+	mux.HandleFunc("POST /v1/batch/delete", p.handleBatch(opDelete))
+	return p.authenticate(mux)
+}
+
+//
+// Internal Helpers:
+//
+
+const (
+	opGet = iota
+	opSet
+	opAdd
+	opDelete
+)
+
+// Errors caused by the request rather than by Redis
+type badRequestError struct {
+	message string
+}
+
+func (e *badRequestError) Error() string {
+	return e.message
+}
+
+func badRequest(err error) error {
+	return &badRequestError{err.Error()}
+}
+
+func invalidNumber(amount json.Number) error {
+	return &badRequestError{fmt.Sprintf("Invalid number: %s", amount)}
+}
+
+// Returned by a single counter's get when the counter doesn't exist
+var errNotFound = errors.New("Counter not found")
+
+func (p *Api) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api_key := []byte(r.Header.Get(ApiKeyHeader))
+		for _, valid := range p.ApiKeys {
+			if subtle.ConstantTimeCompare(api_key, []byte(valid)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		writeJSON(w, http.StatusUnauthorized, ErrorResponse{"Invalid api key"})
+	})
+}
+
+func (p *Api) handleCounter(op int, hash bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, err := readRequest(w, r, op)
+		if nil != err {
+			writeError(w, err)
+			return
+		}
+
+		key := r.PathValue("key")
+		var field *string
+		if hash {
+			path_field := r.PathValue("field")
+			field = &path_field
+		}
+
+		redis := <-p.connections
+		defer func() { p.connections <- redis }()
+
+		counter, err := makeApiCounter(redis, isFloat(r), key, field)
+		if nil != err {
+			writeError(w, badRequest(err))
+			return
+		}
+
+		var value interface{}
+		switch op {
+		case opGet:
+			value, err = counter.get()
+		case opSet:
+			value, err = counter.set(request.Value)
+		case opAdd:
+			value, err = counter.add(request.Amount)
+		case opDelete:
+			err = counter.delete()
+		}
+		if nil != err {
+			writeError(w, err)
+			return
+		}
+
+		response := CounterResponse{Key: key, Value: value}
+		if nil != field {
+			response.Field = *field
+		}
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+func (p *Api) handleBatch(op int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		request, err := readRequest(w, r, op)
+		if nil != err {
+			writeError(w, err)
+			return
+		}
+
+		names := request.Keys
+		if len(request.Key) > 0 {
+			names = request.Fields
+		}
+
+		redis := <-p.connections
+		defer func() { p.connections <- redis }()
+
+		counter, err := makeApiMCounter(redis, isFloat(r), request.Key, names)
+		if nil != err {
+			writeError(w, badRequest(err))
+			return
+		}
+
+		var values interface{}
+		switch op {
+		case opGet:
+			values, err = counter.get()
+		case opSet:
+			values, err = counter.set(request.Value)
+		case opAdd:
+			values, err = counter.add(request.Amount)
+		case opDelete:
+			err = counter.delete()
+		}
+		if nil != err {
+			writeError(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusOK, batchResponse(request.Key, names, values))
+	}
+}
+
+func readRequest(w http.ResponseWriter, r *http.Request, op int) (*CounterRequest, error) {
+	request := &CounterRequest{Amount: "1"}
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, MaxRequestSize))
+	decoder.UseNumber()
+
+	// An empty body uses the defaults:
+	if err := decoder.Decode(request); nil != err && io.EOF != err {
+		return nil, &badRequestError{fmt.Sprintf("Invalid request: %s", err)}
+	}
+
+	if op == opSet && len(request.Value) == 0 {
+		return nil, &badRequestError{"Missing value"}
+	}
+	return request, nil
+}
+
+func isFloat(r *http.Request) bool {
+	return r.URL.Query().Get("type") == "float"
+}
+
+func batchResponse(key string, names []string, values interface{}) []CounterResponse {
+	responses := make([]CounterResponse, len(names))
+	for i, name := range names {
+		switch {
+		case len(key) > 0:
+			responses[i] = CounterResponse{Key: key, Field: name}
+		default:
+			responses[i] = CounterResponse{Key: name}
+		}
+
+		switch values := values.(type) {
+		case []int64:
+			responses[i].Value = values[i]
+		case []float64:
+			responses[i].Value = values[i]
+		}
+	}
+	return responses
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	var bad_request *badRequestError
+	var not_a_number *redis_counter.ErrNotANumber
+	switch {
+	case errors.As(err, &bad_request):
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{err.Error()})
+	case errors.Is(err, errNotFound):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{err.Error()})
+	case errors.As(err, &not_a_number), errors.Is(err, redis_counter.ErrWrongType):
+		writeJSON(w, http.StatusConflict, ErrorResponse{err.Error()})
+	case errors.Is(err, redis_counter.ErrOverflow):
+		writeJSON(w, http.StatusUnprocessableEntity, ErrorResponse{err.Error()})
+	case redis_counter.IsRetryable(err):
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{err.Error()})
+	default:
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{err.Error()})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
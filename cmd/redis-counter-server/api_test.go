@@ -0,0 +1,231 @@
+package main
+
+import "net/http"
+import "net/http/httptest"
+import "strings"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestApiSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(ApiSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+// Send a request with the test API key; returns the status and body
+func serveRequest(handler http.Handler, method, url, body string) (int, string) {
+	request := httptest.NewRequest(method, url, strings.NewReader(body))
+	request.Header.Set(ApiKeyHeader, "secret")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder.Code, strings.TrimSpace(recorder.Body.String())
+}
+
+func ApiSpecs(c gospec.Context) {
+
+	c.Specify("[Api][Make] Makes new instance", func() {
+		value, err := MakeApi(nil, "secret")
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeApi(&dog_pool.RedisConnection{})
+		c.Expect(err.Error(), gospec.Equals, "Empty api keys")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeApi(&dog_pool.RedisConnection{}, "secret", "")
+		c.Expect(err.Error(), gospec.Equals, "Empty api key[1]")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeApi(&dog_pool.RedisConnection{}, "secret")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[Api][Authenticate] Requires an api key", func() {
+		value, _ := MakeApi(&dog_pool.RedisConnection{}, "secret")
+
+		request := httptest.NewRequest("GET", "/v1/keys/Bob", nil)
+		recorder := httptest.NewRecorder()
+		value.Handler().ServeHTTP(recorder, request)
+		c.Expect(recorder.Code, gospec.Equals, http.StatusUnauthorized)
+
+		request.Header.Set(ApiKeyHeader, "Gary")
+		recorder = httptest.NewRecorder()
+		value.Handler().ServeHTTP(recorder, request)
+		c.Expect(recorder.Code, gospec.Equals, http.StatusUnauthorized)
+		c.Expect(strings.TrimSpace(recorder.Body.String()), gospec.Equals, `{"error":"Invalid api key"}`)
+	})
+
+	c.Specify("[Api][Request] Rejects bodies over MaxRequestSize", func() {
+		value, _ := MakeApi(&dog_pool.RedisConnection{}, "secret")
+
+		body := `{"value": 1, "keys": ["` + strings.Repeat("a", MaxRequestSize) + `"]}`
+		request := httptest.NewRequest("PUT", "/v1/keys/Bob", strings.NewReader(body))
+		request.Header.Set(ApiKeyHeader, "secret")
+		recorder := httptest.NewRecorder()
+		value.Handler().ServeHTTP(recorder, request)
+		c.Expect(recorder.Code, gospec.Equals, http.StatusBadRequest)
+		c.Expect(strings.TrimSpace(recorder.Body.String()), gospec.Equals, `{"error":"Invalid request: http: request body too large"}`)
+	})
+
+	c.Specify("[Api][Keys] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeApi(server.Connection(), "secret")
+		handler := value.Handler()
+
+		code, body := serveRequest(handler, "PUT", "/v1/keys/Bob", `{"value": 123}`)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `{"key":"Bob","value":123}`)
+
+		code, body = serveRequest(handler, "POST", "/v1/keys/Bob/increment", `{"amount": 555}`)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `{"key":"Bob","value":678}`)
+
+		code, body = serveRequest(handler, "POST", "/v1/keys/Bob/increment", ``)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `{"key":"Bob","value":679}`)
+
+		code, body = serveRequest(handler, "GET", "/v1/keys/Bob", ``)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `{"key":"Bob","value":679}`)
+
+		code, body = serveRequest(handler, "POST", "/v1/keys/Bob/increment?type=float", `{"amount": 0.5}`)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `{"key":"Bob","value":679.5}`)
+
+		code, body = serveRequest(handler, "DELETE", "/v1/keys/Bob", ``)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `{"key":"Bob","value":null}`)
+
+		ok, _ := server.Connection().Cmd("EXISTS", "Bob").Int()
+		c.Expect(ok, gospec.Equals, 0)
+
+		code, body = serveRequest(handler, "GET", "/v1/keys/Bob", ``)
+		c.Expect(code, gospec.Equals, http.StatusNotFound)
+		c.Expect(body, gospec.Equals, `{"error":"Counter not found"}`)
+
+		code, _ = serveRequest(handler, "GET", "/v1/hashes/Bob/Field", ``)
+		c.Expect(code, gospec.Equals, http.StatusNotFound)
+	})
+
+	c.Specify("[Api][Hashes] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeApi(server.Connection(), "secret")
+		handler := value.Handler()
+
+		code, body := serveRequest(handler, "POST", "/v1/hashes/Bob/Field/increment", `{"amount": 3}`)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `{"key":"Bob","field":"Field","value":3}`)
+
+		code, body = serveRequest(handler, "GET", "/v1/hashes/Bob/Field", ``)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `{"key":"Bob","field":"Field","value":3}`)
+
+		counter, _ := server.Connection().Cmd("HGET", "Bob", "Field").Int64()
+		c.Expect(counter, gospec.Equals, int64(3))
+	})
+
+	c.Specify("[Api][Batch] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeApi(server.Connection(), "secret")
+		handler := value.Handler()
+
+		code, body := serveRequest(handler, "POST", "/v1/batch/increment", `{"keys": ["Bob", "Gary"], "amount": 2}`)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `[{"key":"Bob","value":2},{"key":"Gary","value":2}]`)
+
+		code, body = serveRequest(handler, "POST", "/v1/batch/set", `{"key": "Hash", "fields": ["A", "B"], "value": 7}`)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `[{"key":"Hash","field":"A","value":7},{"key":"Hash","field":"B","value":7}]`)
+
+		code, body = serveRequest(handler, "POST", "/v1/batch/get", `{"keys": ["Bob", "Missing"]}`)
+		c.Expect(code, gospec.Equals, http.StatusOK)
+		c.Expect(body, gospec.Equals, `[{"key":"Bob","value":2},{"key":"Missing","value":0}]`)
+	})
+
+	c.Specify("[Api][Validation] Rejects invalid requests", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeApi(server.Connection(), "secret")
+		handler := value.Handler()
+
+		code, body := serveRequest(handler, "PUT", "/v1/keys/Bob", `{}`)
+		c.Expect(code, gospec.Equals, http.StatusBadRequest)
+		c.Expect(body, gospec.Equals, `{"error":"Missing value"}`)
+
+		code, body = serveRequest(handler, "PUT", "/v1/keys/Bob", `{"value": 1.5}`)
+		c.Expect(code, gospec.Equals, http.StatusBadRequest)
+		c.Expect(body, gospec.Equals, `{"error":"Invalid number: 1.5"}`)
+
+		code, body = serveRequest(handler, "PUT", "/v1/keys/Bob", `Gary`)
+		c.Expect(code, gospec.Equals, http.StatusBadRequest)
+
+		code, body = serveRequest(handler, "POST", "/v1/batch/get", `{"keys": ["Bob", ""]}`)
+		c.Expect(code, gospec.Equals, http.StatusBadRequest)
+		c.Expect(body, gospec.Equals, `{"error":"Empty redis key[1]"}`)
+
+		code, body = serveRequest(handler, "POST", "/v1/batch/get", `{"key": "Hash"}`)
+		c.Expect(code, gospec.Equals, http.StatusBadRequest)
+		c.Expect(body, gospec.Equals, `{"error":"Empty redis fields"}`)
+
+		// Counters that don't hold a number:
+		server.Connection().Cmd("SET", "Bob", "Gary")
+		code, _ = serveRequest(handler, "GET", "/v1/keys/Bob", ``)
+		c.Expect(code, gospec.Equals, http.StatusConflict)
+
+		server.Connection().Cmd("HSET", "Gary", "Field", "1")
+		code, _ = serveRequest(handler, "POST", "/v1/keys/Gary/increment", ``)
+		c.Expect(code, gospec.Equals, http.StatusConflict)
+
+		server.Connection().Cmd("SET", "Max", "9223372036854775807")
+		code, _ = serveRequest(handler, "POST", "/v1/keys/Max/increment", ``)
+		c.Expect(code, gospec.Equals, http.StatusUnprocessableEntity)
+	})
+}
+
+func Benchmark_Api_Increment(b *testing.B) {
+	logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+	server, err := dog_pool.StartRedisServer(&logger)
+	if nil != err {
+		panic(err)
+	}
+	defer server.Close()
+
+	value, _ := MakeApi(server.Connection(), "secret")
+	handler := value.Handler()
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		serveRequest(handler, "POST", "/v1/keys/Bob/increment", `{"amount": 1}`)
+	}
+}
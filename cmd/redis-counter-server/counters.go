@@ -0,0 +1,184 @@
+package main
+
+import "encoding/json"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+
+// Adapts the int64 and float64 counter types to JSON numbers, so the
+// handlers don't need a copy per type
+type apiCounter struct {
+	get    func() (interface{}, error)
+	set    func(amount json.Number) (interface{}, error)
+	add    func(amount json.Number) (interface{}, error)
+	delete func() error
+}
+
+type apiMCounter struct {
+	names  []string
+	get    func() (interface{}, error)
+	set    func(amount json.Number) (interface{}, error)
+	add    func(amount json.Number) (interface{}, error)
+	delete func() error
+}
+
+// Make the counter for a key, or a hash field when "field" is set
+func makeApiCounter(redis *dog_pool.RedisConnection, float bool, key string, field *string) (*apiCounter, error) {
+	switch {
+	case float && nil == field:
+		counter, err := redis_counter.MakeRedisKeyCounterFloat64(redis, key)
+		if nil != err {
+			return nil, err
+		}
+		return wrapFloat64(counter.GetOptional, counter.Set, counter.Add, counter.Delete), nil
+
+	case float:
+		counter, err := redis_counter.MakeRedisHashFieldCounterFloat64(redis, key, *field)
+		if nil != err {
+			return nil, err
+		}
+		return wrapFloat64(counter.GetOptional, counter.Set, counter.Add, counter.Delete), nil
+
+	case nil == field:
+		counter, err := redis_counter.MakeRedisKeyCounterInt64(redis, key)
+		if nil != err {
+			return nil, err
+		}
+		return wrapInt64(counter.GetOptional, counter.Set, counter.Add, counter.Delete), nil
+
+	default:
+		counter, err := redis_counter.MakeRedisHashFieldCounterInt64(redis, key, *field)
+		if nil != err {
+			return nil, err
+		}
+		return wrapInt64(counter.GetOptional, counter.Set, counter.Add, counter.Delete), nil
+	}
+}
+
+// Make the counter for many keys, or many fields of one hash when "key" is set
+func makeApiMCounter(redis *dog_pool.RedisConnection, float bool, key string, names []string) (*apiMCounter, error) {
+	switch {
+	case float && len(key) == 0:
+		counter, err := redis_counter.MakeRedisMKeysCounterFloat64(redis, names...)
+		if nil != err {
+			return nil, err
+		}
+		return wrapMFloat64(names, counter.MGet, counter.MSet, counter.MAdd, counter.MDelete), nil
+
+	case float:
+		counter, err := redis_counter.MakeRedisHashMFieldsCounterFloat64(redis, key, names...)
+		if nil != err {
+			return nil, err
+		}
+		return wrapMFloat64(names, counter.MGet, counter.MSet, counter.MAdd, counter.MDelete), nil
+
+	case len(key) == 0:
+		counter, err := redis_counter.MakeRedisMKeysCounterInt64(redis, names...)
+		if nil != err {
+			return nil, err
+		}
+		return wrapMInt64(names, counter.MGet, counter.MSet, counter.MAdd, counter.MDelete), nil
+
+	default:
+		counter, err := redis_counter.MakeRedisHashMFieldsCounterInt64(redis, key, names...)
+		if nil != err {
+			return nil, err
+		}
+		return wrapMInt64(names, counter.MGet, counter.MSet, counter.MAdd, counter.MDelete), nil
+	}
+}
+
+func wrapInt64(get func() (int64, bool, error), set, add func(int64) (int64, error), delete func() error) *apiCounter {
+	apply := func(op func(int64) (int64, error)) func(json.Number) (interface{}, error) {
+		return func(amount json.Number) (interface{}, error) {
+			value, err := amount.Int64()
+			if nil != err {
+				return nil, invalidNumber(amount)
+			}
+			return op(value)
+		}
+	}
+
+	return &apiCounter{
+		get: func() (interface{}, error) {
+			value, ok, err := get()
+			return foundValue(value, ok, err)
+		},
+		set:    apply(set),
+		add:    apply(add),
+		delete: delete,
+	}
+}
+
+func wrapFloat64(get func() (float64, bool, error), set, add func(float64) (float64, error), delete func() error) *apiCounter {
+	apply := func(op func(float64) (float64, error)) func(json.Number) (interface{}, error) {
+		return func(amount json.Number) (interface{}, error) {
+			value, err := amount.Float64()
+			if nil != err {
+				return nil, invalidNumber(amount)
+			}
+			return op(value)
+		}
+	}
+
+	return &apiCounter{
+		get: func() (interface{}, error) {
+			value, ok, err := get()
+			return foundValue(value, ok, err)
+		},
+		set:    apply(set),
+		add:    apply(add),
+		delete: delete,
+	}
+}
+
+func wrapMInt64(names []string, get func() ([]int64, error), set, add func(int64) ([]int64, error), delete func() error) *apiMCounter {
+	apply := func(op func(int64) ([]int64, error)) func(json.Number) (interface{}, error) {
+		return func(amount json.Number) (interface{}, error) {
+			value, err := amount.Int64()
+			if nil != err {
+				return nil, invalidNumber(amount)
+			}
+			return op(value)
+		}
+	}
+
+	return &apiMCounter{
+		names:  names,
+		get:    func() (interface{}, error) { return get() },
+		set:    apply(set),
+		add:    apply(add),
+		delete: delete,
+	}
+}
+
+func wrapMFloat64(names []string, get func() ([]float64, error), set, add func(float64) ([]float64, error), delete func() error) *apiMCounter {
+	apply := func(op func(float64) ([]float64, error)) func(json.Number) (interface{}, error) {
+		return func(amount json.Number) (interface{}, error) {
+			value, err := amount.Float64()
+			if nil != err {
+				return nil, invalidNumber(amount)
+			}
+			return op(value)
+		}
+	}
+
+	return &apiMCounter{
+		names:  names,
+		get:    func() (interface{}, error) { return get() },
+		set:    apply(set),
+		add:    apply(add),
+		delete: delete,
+	}
+}
+
+// The value of a single counter, or errNotFound when it doesn't exist
+func foundValue(value interface{}, ok bool, err error) (interface{}, error) {
+	switch {
+	case nil != err:
+		return nil, err
+	case !ok:
+		return nil, errNotFound
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,43 @@
+// HTTP server exposing the Redis counters as a REST API.
+//
+//	REDIS_COUNTER_API_KEYS=secret1,secret2 redis-counter-server -listen :8080 -redis 127.0.0.1:6379
+//
+// Every request must send one of the API keys in the X-Api-Key header.
+package main
+
+import "flag"
+import "net/http"
+import "os"
+import "strings"
+import "time"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+func main() {
+	listen := flag.String("listen", ":8080", "HTTP address to listen on")
+	redis_url := flag.String("redis", "127.0.0.1:6379", "Redis server address")
+	api_keys := flag.String("api-keys", os.Getenv("REDIS_COUNTER_API_KEYS"), "Comma separated API keys")
+	flag.Parse()
+
+	logger := log4go.NewDefaultLogger(log4go.INFO)
+	redis := &dog_pool.RedisConnection{Url: *redis_url, Logger: &logger}
+
+	api, err := MakeApi(redis, strings.Split(*api_keys, ",")...)
+	if nil != err {
+		logger.Critical("[Main] Failed to start server: %s", err)
+		os.Exit(1)
+	}
+
+	server := &http.Server{
+		Addr:         *listen,
+		Handler:      api.Handler(),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+	}
+
+	logger.Info("[Main] Listening on %s", *listen)
+	if err := server.ListenAndServe(); nil != err {
+		logger.Critical("[Main] Failed to serve: %s", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,219 @@
+package main
+
+import "errors"
+import "fmt"
+import "math"
+import "sort"
+import "sync"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+
+// Redis layout, relative to the aggregator's PREFIX:
+//
+//	PREFIX:NAME          untagged metrics, one key counter per metric
+//	PREFIX:NAME:tagged   tagged metrics, one hash field per tag set
+//
+// Timers are flushed as NAME.count, NAME.min, NAME.max, NAME.mean and
+// NAME.p90; sets are flushed as the number of unique values seen.
+type Aggregator struct {
+	PREFIX string
+
+	mutex    sync.Mutex
+	counters map[metricKey]float64
+	gauges   map[metricKey]*gaugeValue
+	timers   map[metricKey]*timerValues
+	sets     map[metricKey]map[string]bool
+}
+
+// Make a new instance of Aggregator
+func MakeAggregator(prefix string) *Aggregator {
+	p := &Aggregator{PREFIX: prefix}
+	p.reset()
+	return p
+}
+
+// Aggregate a metric until the next flush
+func (p *Aggregator) Add(metric *Metric) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	key := metricKey{metric.Name, metric.TagString()}
+	switch metric.Type {
+	case CounterType:
+		p.counters[key] += metric.Value / metric.SampleRate
+
+	case GaugeType:
+		gauge, ok := p.gauges[key]
+		if !ok {
+			gauge = &gaugeValue{relative: true}
+			p.gauges[key] = gauge
+		}
+		if metric.Relative {
+			gauge.value += metric.Value
+		} else {
+			gauge.value = metric.Value
+			gauge.relative = false
+		}
+
+	case TimerType, HistogramType, DistributionType:
+		timer, ok := p.timers[key]
+		if !ok {
+			timer = &timerValues{}
+			p.timers[key] = timer
+		}
+		timer.count += 1 / metric.SampleRate
+		timer.values = append(timer.values, metric.Value)
+
+	case SetType:
+		set, ok := p.sets[key]
+		if !ok {
+			set = make(map[string]bool)
+			p.sets[key] = set
+		}
+		set[metric.SetValue] = true
+	}
+}
+
+// Write the aggregated metrics to Redis and start a new interval; a
+// group that fails doesn't stop the others, and every error is returned
+func (p *Aggregator) Flush(redis *dog_pool.RedisConnection) error {
+	p.mutex.Lock()
+	counters, gauges, timers, sets := p.counters, p.gauges, p.timers, p.sets
+	p.reset()
+	p.mutex.Unlock()
+
+	adds := make(map[metricKey]float64)
+	replaces := make(map[metricKey]float64)
+
+	for key, value := range counters {
+		adds[key] = value
+	}
+
+	for key, gauge := range gauges {
+		if gauge.relative {
+			adds[key] = gauge.value
+		} else {
+			replaces[key] = gauge.value
+		}
+	}
+
+	for key, timer := range timers {
+		sort.Float64s(timer.values)
+		count := len(timer.values)
+		sum := 0.0
+		for _, value := range timer.values {
+			sum += value
+		}
+
+		adds[key.suffix(".count")] = timer.count
+		replaces[key.suffix(".min")] = timer.values[0]
+		replaces[key.suffix(".max")] = timer.values[count-1]
+		replaces[key.suffix(".mean")] = sum / float64(count)
+		replaces[key.suffix(".p90")] = timer.values[int(math.Ceil(0.9*float64(count)))-1]
+	}
+
+	for key, set := range sets {
+		replaces[key] = float64(len(set))
+	}
+
+	return errors.Join(p.write(redis, adds, false), p.write(redis, replaces, true))
+}
+
+//
+// Internal Helpers:
+//
+
+type metricKey struct {
+	name, tags string
+}
+
+func (k metricKey) suffix(suffix string) metricKey {
+	return metricKey{k.name + suffix, k.tags}
+}
+
+type gaugeValue struct {
+	value    float64
+	relative bool
+}
+
+type timerValues struct {
+	count  float64
+	values []float64
+}
+
+func (p *Aggregator) reset() {
+	p.counters = make(map[metricKey]float64)
+	p.gauges = make(map[metricKey]*gaugeValue)
+	p.timers = make(map[metricKey]*timerValues)
+	p.sets = make(map[metricKey]map[string]bool)
+}
+
+func (p *Aggregator) redisKey(name string) string {
+	if len(p.PREFIX) == 0 {
+		return name
+	}
+	return p.PREFIX + ":" + name
+}
+
+// Group the values by amount so each group is written with a single
+// multi-key or multi-field counter; returns the errors of every group
+func (p *Aggregator) write(redis *dog_pool.RedisConnection, values map[metricKey]float64, replace bool) error {
+	errs := []error{}
+	keys := make(map[float64][]string)
+	fields := make(map[string]map[float64][]string)
+	for key, amount := range values {
+		switch {
+		case math.IsNaN(amount) || math.IsInf(amount, 0):
+			// Sums past the float64 range:
+			errs = append(errs, fmt.Errorf("Invalid amount for %s: %g", key.name, amount))
+		case len(key.tags) == 0:
+			keys[amount] = append(keys[amount], p.redisKey(key.name))
+		default:
+			hash := p.redisKey(key.name) + ":tagged"
+			if _, ok := fields[hash]; !ok {
+				fields[hash] = make(map[float64][]string)
+			}
+			fields[hash][amount] = append(fields[hash][amount], key.tags)
+		}
+	}
+
+	for amount, names := range keys {
+		sort.Strings(names)
+		counter, err := redis_counter.MakeRedisMKeysCounterFloat64(redis, names...)
+		if nil != err {
+			errs = append(errs, err)
+			continue
+		}
+
+		if replace {
+			_, err = counter.MSet(amount)
+		} else {
+			_, err = counter.MAdd(amount)
+		}
+		if nil != err {
+			errs = append(errs, err)
+		}
+	}
+
+	for hash, groups := range fields {
+		for amount, names := range groups {
+			sort.Strings(names)
+			counter, err := redis_counter.MakeRedisHashMFieldsCounterFloat64(redis, hash, names...)
+			if nil != err {
+				errs = append(errs, err)
+				continue
+			}
+
+			if replace {
+				_, err = counter.MSet(amount)
+			} else {
+				_, err = counter.MAdd(amount)
+			}
+			if nil != err {
+				errs = append(errs, err)
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
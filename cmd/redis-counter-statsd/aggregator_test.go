@@ -0,0 +1,142 @@
+package main
+
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestAggregatorSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(AggregatorSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func addLines(aggregator *Aggregator, packet string) {
+	metrics, _ := ParsePacket([]byte(packet))
+	for _, metric := range metrics {
+		aggregator.Add(metric)
+	}
+}
+
+func AggregatorSpecs(c gospec.Context) {
+
+	c.Specify("[Aggregator][Flush] Counters", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value := MakeAggregator("stats")
+		addLines(value, "hits:1|c\nhits:2|c|@0.5\nhits:1|c|#env:prod,route:/\nhits:1|c|#route:/,env:prod")
+
+		err := value.Flush(server.Connection())
+		c.Expect(err, gospec.Equals, nil)
+
+		hits, _ := server.Connection().Cmd("GET", "stats:hits").Str()
+		c.Expect(hits, gospec.Equals, "5")
+
+		hits, _ = server.Connection().Cmd("HGET", "stats:hits:tagged", "env:prod,route:/").Str()
+		c.Expect(hits, gospec.Equals, "2")
+
+		// Counters accumulate across flushes:
+		addLines(value, "hits:1|c")
+		err = value.Flush(server.Connection())
+		c.Expect(err, gospec.Equals, nil)
+
+		hits, _ = server.Connection().Cmd("GET", "stats:hits").Str()
+		c.Expect(hits, gospec.Equals, "6")
+
+		// Empty flush:
+		err = value.Flush(server.Connection())
+		c.Expect(err, gospec.Equals, nil)
+	})
+
+	c.Specify("[Aggregator][Flush] Writes the other groups when one fails", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		server.Connection().Cmd("HSET", "stats:bad", "Field", "1")
+
+		value := MakeAggregator("stats")
+		addLines(value, "bad:1|c\nhits:2|c\nlatency:5|g")
+
+		err := value.Flush(server.Connection())
+		c.Expect(err, gospec.Satisfies, nil != err)
+
+		hits, _ := server.Connection().Cmd("GET", "stats:hits").Str()
+		c.Expect(hits, gospec.Equals, "2")
+
+		latency, _ := server.Connection().Cmd("GET", "stats:latency").Str()
+		c.Expect(latency, gospec.Equals, "5")
+	})
+
+	c.Specify("[Aggregator][Flush] Gauges", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value := MakeAggregator("stats")
+		addLines(value, "load:3|g\nload:1.5|g\nload:+1|g")
+		err := value.Flush(server.Connection())
+		c.Expect(err, gospec.Equals, nil)
+
+		load, _ := server.Connection().Cmd("GET", "stats:load").Str()
+		c.Expect(load, gospec.Equals, "2.5")
+
+		// Relative gauges modify the stored value:
+		addLines(value, "load:-1|g")
+		err = value.Flush(server.Connection())
+		c.Expect(err, gospec.Equals, nil)
+
+		load, _ = server.Connection().Cmd("GET", "stats:load").Str()
+		c.Expect(load, gospec.Equals, "1.5")
+	})
+
+	c.Specify("[Aggregator][Flush] Timers and sets", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value := MakeAggregator("stats")
+		addLines(value, "latency:10|ms\nlatency:30|ms\nlatency:20|ms|@0.5\nusers:Bob|s\nusers:Gary|s\nusers:Bob|s")
+		err := value.Flush(server.Connection())
+		c.Expect(err, gospec.Equals, nil)
+
+		expected := map[string]string{
+			"stats:latency.count": "4",
+			"stats:latency.min":   "10",
+			"stats:latency.max":   "30",
+			"stats:latency.mean":  "20",
+			"stats:latency.p90":   "30",
+			"stats:users":         "2",
+		}
+		for key, expected_value := range expected {
+			actual, _ := server.Connection().Cmd("GET", key).Str()
+			c.Expect(actual, gospec.Equals, expected_value)
+		}
+	})
+}
+
+func Benchmark_Aggregator_Add(b *testing.B) {
+	value := MakeAggregator("stats")
+	metric, _ := ParseMetric("hits:1|c|#route:/login")
+	for i := 0; i < b.N; i++ {
+		value.Add(metric)
+	}
+}
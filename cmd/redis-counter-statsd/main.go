@@ -0,0 +1,50 @@
+// StatsD/DogStatsD daemon that aggregates metrics received over UDP and
+// flushes them into Redis counters on an interval.
+//
+//	redis-counter-statsd -listen :8125 -redis 127.0.0.1:6379 -prefix stats -interval 10s
+package main
+
+import "flag"
+import "os"
+import "os/signal"
+import "syscall"
+import "time"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+func main() {
+	listen := flag.String("listen", ":8125", "UDP address to listen on")
+	redis_url := flag.String("redis", "127.0.0.1:6379", "Redis server address")
+	prefix := flag.String("prefix", "stats", "Prefix for every Redis key")
+	interval := flag.Duration("interval", 10*time.Second, "Interval between flushes to Redis")
+	flag.Parse()
+
+	logger := log4go.NewDefaultLogger(log4go.INFO)
+	redis := &dog_pool.RedisConnection{Url: *redis_url, Logger: &logger}
+
+	server, err := MakeServer(*listen, redis, *prefix, *interval, &logger)
+	if nil != err {
+		logger.Critical("[Main] Failed to start server: %s", err)
+		os.Exit(1)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	closed := make(chan error, 1)
+	go func() {
+		<-signals
+		closed <- server.Close()
+	}()
+
+	logger.Info("[Main] Listening on %s", server.Conn.LocalAddr())
+	if err := server.Serve(); nil != err {
+		logger.Critical("[Main] Failed to read packet: %s", err)
+		os.Exit(1)
+	}
+
+	// Wait for the final flush:
+	if err := <-closed; nil != err {
+		logger.Error("[Main] Failed to flush metrics: %s", err)
+		os.Exit(1)
+	}
+}
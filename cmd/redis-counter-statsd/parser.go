@@ -0,0 +1,113 @@
+package main
+
+import "fmt"
+import "math"
+import "sort"
+import "strconv"
+import "strings"
+
+// StatsD metric types
+const (
+	CounterType      = "c"
+	GaugeType        = "g"
+	TimerType        = "ms"
+	HistogramType    = "h"
+	DistributionType = "d"
+	SetType          = "s"
+)
+
+// One parsed StatsD/DogStatsD line:
+//
+//	name:value|type[|@sample_rate][|#tag1:value,tag2]
+type Metric struct {
+	Name       string
+	Type       string
+	Value      float64
+	SetValue   string
+	SampleRate float64
+	Relative   bool
+	Tags       []string
+}
+
+// Canonical form of the tags; sorted so the order sent by clients
+// doesn't create separate counters
+func (p *Metric) TagString() string {
+	return strings.Join(p.Tags, ",")
+}
+
+// Parse every newline separated metric in a UDP packet; lines that
+// fail to parse are returned as errors without dropping the others
+func ParsePacket(packet []byte) ([]*Metric, []error) {
+	metrics := []*Metric{}
+	errors := []error{}
+	for _, line := range strings.Split(string(packet), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		metric, err := ParseMetric(line)
+		if nil != err {
+			errors = append(errors, err)
+			continue
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, errors
+}
+
+// Parse a single metric line
+func ParseMetric(line string) (*Metric, error) {
+	colon := strings.Index(line, ":")
+	if colon <= 0 {
+		return nil, fmt.Errorf("Invalid metric name: %s", line)
+	}
+
+	parts := strings.Split(line[colon+1:], "|")
+	if len(parts) < 2 || len(parts[0]) == 0 {
+		return nil, fmt.Errorf("Invalid metric format: %s", line)
+	}
+
+	metric := &Metric{Name: line[:colon], Type: parts[1], SampleRate: 1}
+	value := parts[0]
+	switch metric.Type {
+	case SetType:
+		metric.SetValue = value
+
+	case CounterType, GaugeType, TimerType, HistogramType, DistributionType:
+		if metric.Type == GaugeType && (value[0] == '+' || value[0] == '-') {
+			metric.Relative = true
+		}
+
+		// ParseFloat accepts "nan" and "inf", which Redis can't store:
+		number, err := strconv.ParseFloat(value, 64)
+		if nil != err || math.IsNaN(number) || math.IsInf(number, 0) {
+			return nil, fmt.Errorf("Invalid metric value: %s", line)
+		}
+		metric.Value = number
+
+	default:
+		return nil, fmt.Errorf("Invalid metric type: %s", line)
+	}
+
+	for _, part := range parts[2:] {
+		switch {
+		case strings.HasPrefix(part, "@"):
+			rate, err := strconv.ParseFloat(part[1:], 64)
+			if nil != err || rate <= 0 || rate > 1 {
+				return nil, fmt.Errorf("Invalid metric sample rate: %s", line)
+			}
+			metric.SampleRate = rate
+
+		case strings.HasPrefix(part, "#"):
+			for _, tag := range strings.Split(part[1:], ",") {
+				if len(tag) > 0 {
+					metric.Tags = append(metric.Tags, tag)
+				}
+			}
+			sort.Strings(metric.Tags)
+		}
+	}
+
+	return metric, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestParserSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(ParserSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func ParserSpecs(c gospec.Context) {
+
+	c.Specify("[ParseMetric] Parses counters", func() {
+		metric, err := ParseMetric("api.hits:3|c|@0.5|#route:/login,env:prod")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(metric.Name, gospec.Equals, "api.hits")
+		c.Expect(metric.Type, gospec.Equals, CounterType)
+		c.Expect(metric.Value, gospec.Equals, float64(3))
+		c.Expect(metric.SampleRate, gospec.Equals, float64(0.5))
+		c.Expect(metric.TagString(), gospec.Equals, "env:prod,route:/login")
+	})
+
+	c.Specify("[ParseMetric] Parses gauges", func() {
+		metric, err := ParseMetric("api.load:1.5|g")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(metric.Value, gospec.Equals, float64(1.5))
+		c.Expect(metric.Relative, gospec.Equals, false)
+		c.Expect(metric.SampleRate, gospec.Equals, float64(1))
+		c.Expect(metric.TagString(), gospec.Equals, "")
+
+		metric, err = ParseMetric("api.load:-2|g")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(metric.Value, gospec.Equals, float64(-2))
+		c.Expect(metric.Relative, gospec.Equals, true)
+	})
+
+	c.Specify("[ParseMetric] Parses timers and sets", func() {
+		metric, err := ParseMetric("api.latency:320|ms")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(metric.Type, gospec.Equals, TimerType)
+		c.Expect(metric.Value, gospec.Equals, float64(320))
+
+		metric, err = ParseMetric("api.users:Bob|s")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(metric.Type, gospec.Equals, SetType)
+		c.Expect(metric.SetValue, gospec.Equals, "Bob")
+	})
+
+	c.Specify("[ParseMetric] Rejects invalid lines", func() {
+		_, err := ParseMetric(":1|c")
+		c.Expect(err.Error(), gospec.Equals, "Invalid metric name: :1|c")
+
+		_, err = ParseMetric("api.hits:1")
+		c.Expect(err.Error(), gospec.Equals, "Invalid metric format: api.hits:1")
+
+		_, err = ParseMetric("api.hits:Gary|c")
+		c.Expect(err.Error(), gospec.Equals, "Invalid metric value: api.hits:Gary|c")
+
+		_, err = ParseMetric("api.hits:nan|c")
+		c.Expect(err.Error(), gospec.Equals, "Invalid metric value: api.hits:nan|c")
+
+		_, err = ParseMetric("api.hits:-inf|g")
+		c.Expect(err.Error(), gospec.Equals, "Invalid metric value: api.hits:-inf|g")
+
+		_, err = ParseMetric("api.hits:1|x")
+		c.Expect(err.Error(), gospec.Equals, "Invalid metric type: api.hits:1|x")
+
+		_, err = ParseMetric("api.hits:1|c|@2")
+		c.Expect(err.Error(), gospec.Equals, "Invalid metric sample rate: api.hits:1|c|@2")
+	})
+
+	c.Specify("[ParsePacket] Parses every line", func() {
+		metrics, errors := ParsePacket([]byte("api.hits:1|c\napi.hits:Gary|c\n\napi.load:2|g\n"))
+		c.Expect(len(metrics), gospec.Equals, 2)
+		c.Expect(len(errors), gospec.Equals, 1)
+		c.Expect(metrics[0].Name, gospec.Equals, "api.hits")
+		c.Expect(metrics[1].Name, gospec.Equals, "api.load")
+	})
+}
+
+func Benchmark_ParsePacket(b *testing.B) {
+	packet := []byte("api.hits:1|c|@0.5|#route:/login\napi.load:2|g\napi.latency:320|ms")
+	for i := 0; i < b.N; i++ {
+		ParsePacket(packet)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import "fmt"
+import "net"
+import "sync"
+import "time"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Largest UDP payload accepted from clients
+const MaxPacketSize = 65535
+
+// Listens for StatsD packets on UDP and flushes them to Redis on an interval
+type Server struct {
+	Conn       *net.UDPConn
+	Redis      *dog_pool.RedisConnection
+	Aggregator *Aggregator
+	Interval   time.Duration
+	Logger     *log4go.Logger
+
+	flushing sync.Mutex
+	done     chan bool
+	stopped  sync.WaitGroup
+}
+
+// Make a new instance of Server listening on "addr"
+func MakeServer(addr string, redis *dog_pool.RedisConnection, prefix string, interval time.Duration, logger *log4go.Logger) (*Server, error) {
+	switch {
+	case nil == redis:
+		return nil, fmt.Errorf("Nil redis connection")
+	case interval <= 0:
+		return nil, fmt.Errorf("Invalid flush interval")
+	case nil == logger:
+		return nil, fmt.Errorf("Nil logger")
+	}
+
+	udp_addr, err := net.ResolveUDPAddr("udp", addr)
+	if nil != err {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", udp_addr)
+	if nil != err {
+		return nil, err
+	}
+
+	return &Server{
+		Conn:       conn,
+		Redis:      redis,
+		Aggregator: MakeAggregator(prefix),
+		Interval:   interval,
+		Logger:     logger,
+		done:       make(chan bool),
+	}, nil
+}
+
+// Read packets until the server is closed
+func (p *Server) Serve() error {
+	p.stopped.Add(1)
+	go p.flushLoop()
+
+	buffer := make([]byte, MaxPacketSize)
+	for {
+		n, _, err := p.Conn.ReadFromUDP(buffer)
+		if nil != err {
+			select {
+			case <-p.done:
+				return nil
+			default:
+				return err
+			}
+		}
+
+		metrics, errors := ParsePacket(buffer[:n])
+		for _, err := range errors {
+			p.Logger.Warn("[Server] %s", err)
+		}
+		for _, metric := range metrics {
+			p.Aggregator.Add(metric)
+		}
+	}
+}
+
+// Write the aggregated metrics to Redis
+func (p *Server) Flush() error {
+	p.flushing.Lock()
+	defer p.flushing.Unlock()
+
+	return p.Aggregator.Flush(p.Redis)
+}
+
+// Stop listening and flush the remaining metrics
+func (p *Server) Close() error {
+	close(p.done)
+	err := p.Conn.Close()
+	p.stopped.Wait()
+
+	if flush_err := p.Flush(); nil != flush_err {
+		return flush_err
+	}
+	return err
+}
+
+//
+// Internal Helpers:
+//
+
+func (p *Server) flushLoop() {
+	defer p.stopped.Done()
+
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+			if err := p.Flush(); nil != err {
+				p.Logger.Error("[Server] Failed to flush metrics: %s", err)
+			}
+		}
+	}
+}
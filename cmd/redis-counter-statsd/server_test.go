@@ -0,0 +1,82 @@
+package main
+
+import "net"
+import "time"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestServerSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(ServerSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func ServerSpecs(c gospec.Context) {
+
+	c.Specify("[Server][Make] Makes new instance", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+
+		value, err := MakeServer("127.0.0.1:0", nil, "stats", time.Second, &logger)
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeServer("127.0.0.1:0", &dog_pool.RedisConnection{}, "stats", 0, &logger)
+		c.Expect(err.Error(), gospec.Equals, "Invalid flush interval")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeServer("127.0.0.1:0", &dog_pool.RedisConnection{}, "stats", time.Second, nil)
+		c.Expect(err.Error(), gospec.Equals, "Nil logger")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeServer("127.0.0.1:0", &dog_pool.RedisConnection{}, "stats", time.Second, &logger)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+		value.Conn.Close()
+	})
+
+	c.Specify("[Server][Serve] Flushes UDP packets to Redis", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, err := MakeServer("127.0.0.1:0", server.Connection(), "stats", 10*time.Millisecond, &logger)
+		c.Expect(err, gospec.Equals, nil)
+
+		served := make(chan error, 1)
+		go func() {
+			served <- value.Serve()
+		}()
+
+		client, err := net.Dial("udp", value.Conn.LocalAddr().String())
+		c.Expect(err, gospec.Equals, nil)
+		defer client.Close()
+
+		client.Write([]byte("hits:1|c\nhits:Gary|c\nhits:2|c|#env:prod"))
+
+		// Wait for the flush interval:
+		hits := ""
+		for i := 0; i < 100 && hits != "1"; i++ {
+			time.Sleep(10 * time.Millisecond)
+			value.flushing.Lock()
+			hits, _ = server.Connection().Cmd("GET", "stats:hits").Str()
+			value.flushing.Unlock()
+		}
+		c.Expect(hits, gospec.Equals, "1")
+
+		err = value.Close()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(<-served, gospec.Equals, nil)
+
+		hits, _ = server.Connection().Cmd("HGET", "stats:hits:tagged", "env:prod").Str()
+		c.Expect(hits, gospec.Equals, "2")
+	})
+}
@@ -0,0 +1,241 @@
+package main
+
+import "flag"
+import "fmt"
+import "io"
+import "net"
+import "os"
+import "strconv"
+import "time"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+const usage = `Usage: redis-counter [connection flags] <command> [flags] NAME...
+
+Commands:
+  get     NAME           Get one counter
+  mget    NAME...        Get many counters in one pipeline
+  set     VALUE NAME...  Set the counters to VALUE
+  incr    NAME...        Increment the counters by -by
+  decr    NAME...        Decrement the counters by -by
+  del     NAME...        Delete the counters
+  exists  NAME...        Check if the counters exist
+  watch   NAME...        Print the counters when they change
+
+Names are keys, or fields of the hash given by -hash. Command flags go
+before the names; use "--" before a negative VALUE.
+
+Connection flags:
+`
+
+// Exit codes
+const (
+	ExitOk    = 0
+	ExitError = 1
+	ExitUsage = 2
+)
+
+// Errors in the command line rather than in Redis
+type usageError struct {
+	message string
+}
+
+func (e *usageError) Error() string {
+	return e.message
+}
+
+// One parsed subcommand
+type command struct {
+	name     string
+	hash     string
+	float    bool
+	format   string
+	by       string
+	value    string
+	interval time.Duration
+	count    int
+	names    []string
+}
+
+// Parse the flags, connect to Redis and run the command; returns the
+// exit code
+func Run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("redis-counter", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprint(stderr, usage)
+		flags.PrintDefaults()
+	}
+	host := flags.String("host", "127.0.0.1", "Redis server host")
+	port := flags.Int("port", 6379, "Redis server port")
+	password := flags.String("password", os.Getenv("REDIS_COUNTER_PASSWORD"), "Redis password")
+	db := flags.Int("db", 0, "Redis database number")
+	if err := flags.Parse(args); nil != err {
+		return ExitUsage
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return ExitUsage
+	}
+
+	cmd, err := parseCommand(flags.Args(), stderr)
+	if nil != err {
+		fmt.Fprintf(stderr, "redis-counter: %s\n", err)
+		return ExitUsage
+	}
+
+	logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+	redis := &dog_pool.RedisConnection{Url: net.JoinHostPort(*host, strconv.Itoa(*port)), Logger: &logger}
+	defer redis.Close()
+
+	err = connect(redis, *password, *db)
+	if nil == err {
+		err = cmd.run(redis, stdout)
+	}
+	switch err.(type) {
+	case nil:
+		return ExitOk
+	case *usageError:
+		fmt.Fprintf(stderr, "redis-counter: %s\n", err)
+		return ExitUsage
+	default:
+		fmt.Fprintf(stderr, "redis-counter: %s\n", err)
+		return ExitError
+	}
+}
+
+//
+// Internal Helpers:
+//
+
+func connect(redis *dog_pool.RedisConnection, password string, db int) error {
+	if len(password) > 0 {
+		if reply := redis.Cmd("AUTH", password); nil != reply.Err {
+			return reply.Err
+		}
+	}
+	if db != 0 {
+		if reply := redis.Cmd("SELECT", db); nil != reply.Err {
+			return reply.Err
+		}
+	}
+	return nil
+}
+
+func parseCommand(args []string, stderr io.Writer) (*command, error) {
+	p := &command{name: args[0]}
+	flags := flag.NewFlagSet(p.name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&p.hash, "hash", "", "Hash key; the names are fields of this hash")
+	flags.BoolVar(&p.float, "float", false, "Use float64 counters instead of int64")
+	flags.StringVar(&p.format, "format", FormatTable, "Output format: table, json or csv")
+
+	switch p.name {
+	case "get", "mget", "set", "del", "exists":
+	case "incr", "decr":
+		flags.StringVar(&p.by, "by", "1", "Amount to change the counters by")
+	case "watch":
+		flags.DurationVar(&p.interval, "interval", time.Second, "Time between polls")
+		flags.IntVar(&p.count, "count", 0, "Stop after this many polls; 0 polls forever")
+	default:
+		return nil, &usageError{fmt.Sprintf("Unknown command: %s", p.name)}
+	}
+
+	if err := flags.Parse(args[1:]); nil != err {
+		return nil, &usageError{err.Error()}
+	}
+	p.names = flags.Args()
+
+	if p.name == "set" {
+		if len(p.names) == 0 {
+			return nil, &usageError{"Missing value"}
+		}
+		p.value, p.names = p.names[0], p.names[1:]
+	}
+
+	switch {
+	case len(p.names) == 0:
+		return nil, &usageError{"Missing counter names"}
+	case p.name == "get" && len(p.names) > 1:
+		return nil, &usageError{"get takes one counter; use mget for many"}
+	case p.format != FormatTable && p.format != FormatJSON && p.format != FormatCSV:
+		return nil, &usageError{fmt.Sprintf("Invalid format: %s", p.format)}
+	case p.name == "watch" && p.interval <= 0:
+		return nil, &usageError{fmt.Sprintf("Invalid interval: %s", p.interval)}
+	}
+	return p, nil
+}
+
+func (p *command) run(redis *dog_pool.RedisConnection, stdout io.Writer) error {
+	counter, err := makeCliCounter(redis, p.float, p.hash, p.names, p.name == "mget")
+	if nil != err {
+		return &usageError{err.Error()}
+	}
+
+	out, err := makeOutput(stdout, p.format, len(p.hash) > 0)
+	if nil != err {
+		return &usageError{err.Error()}
+	}
+
+	var values []interface{}
+	switch p.name {
+	case "get", "mget":
+		values, err = counter.get()
+	case "set":
+		values, err = counter.set(p.value)
+	case "incr":
+		values, err = counter.add(p.by)
+	case "decr":
+		values, err = counter.sub(p.by)
+	case "del":
+		err = counter.delete()
+	case "exists":
+		var oks []bool
+		oks, err = counter.exists()
+		for _, ok := range oks {
+			values = append(values, ok)
+		}
+	case "watch":
+		return p.watch(counter, out)
+	}
+	if nil != err {
+		return err
+	}
+
+	return out.write(makeRows(counter, values))
+}
+
+// Poll the counters and print them whenever a value changes
+func (p *command) watch(counter *cliCounter, out *output) error {
+	var last []interface{}
+	for i := 0; p.count == 0 || i < p.count; i++ {
+		if i > 0 {
+			time.Sleep(p.interval)
+		}
+
+		values, err := counter.get()
+		if nil != err {
+			return err
+		}
+
+		if changed(last, values) {
+			if err := out.write(makeRows(counter, values)); nil != err {
+				return err
+			}
+		}
+		last = values
+	}
+	return nil
+}
+
+func changed(last, values []interface{}) bool {
+	if len(last) != len(values) {
+		return true
+	}
+	for i := range values {
+		if last[i] != values[i] {
+			return true
+		}
+	}
+	return false
+}
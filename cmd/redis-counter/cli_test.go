@@ -0,0 +1,163 @@
+package main
+
+import "bytes"
+import "io/ioutil"
+import "strings"
+import "time"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestCliSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(CliSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+// Parse and run a command; returns the output and the error
+func runCommand(redis *dog_pool.RedisConnection, args ...string) (string, error) {
+	cmd, err := parseCommand(args, ioutil.Discard)
+	if nil != err {
+		return "", err
+	}
+
+	var stdout bytes.Buffer
+	err = cmd.run(redis, &stdout)
+	return strings.TrimSpace(stdout.String()), err
+}
+
+func CliSpecs(c gospec.Context) {
+
+	c.Specify("[Cli][Parse] Rejects invalid commands", func() {
+		var stderr bytes.Buffer
+		c.Expect(Run([]string{}, ioutil.Discard, &stderr), gospec.Equals, ExitUsage)
+		c.Expect(stderr.String(), gospec.Satisfies, strings.HasPrefix(stderr.String(), "Usage: redis-counter"))
+
+		_, err := parseCommand([]string{"bob"}, ioutil.Discard)
+		c.Expect(err.Error(), gospec.Equals, "Unknown command: bob")
+
+		_, err = parseCommand([]string{"get"}, ioutil.Discard)
+		c.Expect(err.Error(), gospec.Equals, "Missing counter names")
+
+		_, err = parseCommand([]string{"get", "Bob", "Gary"}, ioutil.Discard)
+		c.Expect(err.Error(), gospec.Equals, "get takes one counter; use mget for many")
+
+		_, err = parseCommand([]string{"set"}, ioutil.Discard)
+		c.Expect(err.Error(), gospec.Equals, "Missing value")
+
+		_, err = parseCommand([]string{"get", "-format", "xml", "Bob"}, ioutil.Discard)
+		c.Expect(err.Error(), gospec.Equals, "Invalid format: xml")
+
+		cmd, err := parseCommand([]string{"set", "--", "-5", "Bob", "Gary"}, ioutil.Discard)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(cmd.value, gospec.Equals, "-5")
+		c.Expect(cmd.names, gospec.ContainsInOrder, gospec.Values("Bob", "Gary"))
+	})
+
+	c.Specify("[Cli][Keys] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+		redis := server.Connection()
+
+		out, err := runCommand(redis, "get", "-format", "json", "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, `[{"key":"Bob","value":null}]`)
+
+		out, err = runCommand(redis, "set", "-format", "json", "123", "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, `[{"key":"Bob","value":123}]`)
+
+		out, err = runCommand(redis, "incr", "-by", "7", "-format", "csv", "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, "key,value\nBob,130")
+
+		out, err = runCommand(redis, "decr", "-format", "csv", "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, "key,value\nBob,129")
+
+		out, err = runCommand(redis, "incr", "-float", "-by", "0.5", "-format", "json", "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, `[{"key":"Bob","value":129.5}]`)
+
+		out, err = runCommand(redis, "mget", "Bob", "Gary")
+		c.Expect(err, gospec.Satisfies, nil != err)
+
+		out, err = runCommand(redis, "mget", "-float", "Bob", "Gary")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, "KEY   VALUE\nBob   129.5\nGary  NaN")
+
+		out, err = runCommand(redis, "exists", "-format", "csv", "Bob", "Gary")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, "key,value\nBob,true\nGary,false")
+
+		out, err = runCommand(redis, "del", "-format", "json", "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, `[{"key":"Bob","value":null}]`)
+
+		ok, _ := redis.Cmd("EXISTS", "Bob").Int()
+		c.Expect(ok, gospec.Equals, 0)
+
+		_, err = runCommand(redis, "incr", "-by", "Gary", "Bob")
+		c.Expect(err.Error(), gospec.Equals, "Invalid number: Gary")
+	})
+
+	c.Specify("[Cli][Hashes] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+		redis := server.Connection()
+
+		out, err := runCommand(redis, "incr", "-hash", "Hash", "-by", "3", "-format", "json", "A")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, `[{"key":"Hash","field":"A","value":3}]`)
+
+		out, err = runCommand(redis, "set", "-hash", "Hash", "-format", "csv", "5", "A", "B")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, "key,field,value\nHash,A,5\nHash,B,5")
+
+		out, err = runCommand(redis, "mget", "-hash", "Hash", "A", "C")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, "KEY   FIELD  VALUE\nHash  A      5\nHash  C      NaN")
+
+		counter, _ := redis.Cmd("HGET", "Hash", "B").Int64()
+		c.Expect(counter, gospec.Equals, int64(5))
+	})
+
+	c.Specify("[Cli][Watch] Prints changes", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+		redis := server.Connection()
+		redis.Cmd("SET", "Bob", "1")
+
+		// Unchanged values are only printed once:
+		out, err := runCommand(redis, "watch", "-interval", "1ms", "-count", "3", "-format", "csv", "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(out, gospec.Equals, "key,value\nBob,1")
+
+		cmd, _ := parseCommand([]string{"watch", "-interval", "20ms", "-count", "3", "-format", "json", "Bob"}, ioutil.Discard)
+		var stdout bytes.Buffer
+		done := make(chan error, 1)
+		go func() { done <- cmd.run(redis, &stdout) }()
+		time.Sleep(10 * time.Millisecond)
+		redis.Cmd("INCRBY", "Bob", "2")
+
+		c.Expect(<-done, gospec.Equals, nil)
+		c.Expect(strings.TrimSpace(stdout.String()), gospec.Equals, "[{\"key\":\"Bob\",\"value\":1}]\n[{\"key\":\"Bob\",\"value\":3}]")
+	})
+}
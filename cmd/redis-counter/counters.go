@@ -0,0 +1,303 @@
+package main
+
+import "fmt"
+import "strconv"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+
+// Adapts the eight counter types to one shape, so the commands don't
+// need a copy per type. Values are int64 or float64, or nil when the
+// counter is missing.
+type cliCounter struct {
+	hash   string
+	names  []string
+	get    func() ([]interface{}, error)
+	set    func(amount string) ([]interface{}, error)
+	add    func(amount string) ([]interface{}, error)
+	sub    func(amount string) ([]interface{}, error)
+	exists func() ([]bool, error)
+	delete func() error
+}
+
+// Methods shared by RedisKeyCounterInt64 and RedisHashFieldCounterInt64
+type int64Counter interface {
+	Get() (int64, error)
+	Set(amount int64) (int64, error)
+	Add(amount int64) (int64, error)
+	Sub(amount int64) (int64, error)
+	Exists() (bool, error)
+	Delete() error
+}
+
+// Methods shared by RedisKeyCounterFloat64 and RedisHashFieldCounterFloat64
+type float64Counter interface {
+	Get() (float64, error)
+	Set(amount float64) (float64, error)
+	Add(amount float64) (float64, error)
+	Sub(amount float64) (float64, error)
+	Exists() (bool, error)
+	Delete() error
+}
+
+// Methods shared by RedisMKeysCounterInt64 and RedisHashMFieldsCounterInt64
+type mInt64Counter interface {
+	MGet() ([]int64, error)
+	MSet(amount int64) ([]int64, error)
+	MAdd(amount int64) ([]int64, error)
+	MSub(amount int64) ([]int64, error)
+	MExists() ([]bool, error)
+	MDelete() error
+}
+
+// Methods shared by RedisMKeysCounterFloat64 and RedisHashMFieldsCounterFloat64
+type mFloat64Counter interface {
+	MGet() ([]float64, error)
+	MSet(amount float64) ([]float64, error)
+	MAdd(amount float64) ([]float64, error)
+	MSub(amount float64) ([]float64, error)
+	MExists() ([]bool, error)
+	MDelete() error
+}
+
+// Make the counter for the names; they are fields of "hash" when it is
+// set, otherwise keys. One name makes a single counter unless "multi" is
+// set, more names always make a multi counter.
+func makeCliCounter(redis *dog_pool.RedisConnection, float bool, hash string, names []string, multi bool) (*cliCounter, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("Missing counter names")
+	}
+	multi = multi || len(names) > 1
+
+	var counter *cliCounter
+	switch {
+	case float && multi && len(hash) == 0:
+		value, err := redis_counter.MakeRedisMKeysCounterFloat64(redis, names...)
+		if nil != err {
+			return nil, err
+		}
+		counter = wrapMFloat64(value, func(name string) *float64 { return value.Cache.Value(name) }, names)
+
+	case float && multi:
+		value, err := redis_counter.MakeRedisHashMFieldsCounterFloat64(redis, hash, names...)
+		if nil != err {
+			return nil, err
+		}
+		counter = wrapMFloat64(value, func(name string) *float64 { return value.Cache.Value(name) }, names)
+
+	case float && len(hash) == 0:
+		value, err := redis_counter.MakeRedisKeyCounterFloat64(redis, names[0])
+		if nil != err {
+			return nil, err
+		}
+		counter = wrapFloat64(value, func() *float64 { return value.LastValue })
+
+	case float:
+		value, err := redis_counter.MakeRedisHashFieldCounterFloat64(redis, hash, names[0])
+		if nil != err {
+			return nil, err
+		}
+		counter = wrapFloat64(value, func() *float64 { return value.LastValue })
+
+	case multi && len(hash) == 0:
+		value, err := redis_counter.MakeRedisMKeysCounterInt64(redis, names...)
+		if nil != err {
+			return nil, err
+		}
+		counter = wrapMInt64(value, func(name string) *int64 { return value.Cache.Value(name) }, names)
+
+	case multi:
+		value, err := redis_counter.MakeRedisHashMFieldsCounterInt64(redis, hash, names...)
+		if nil != err {
+			return nil, err
+		}
+		counter = wrapMInt64(value, func(name string) *int64 { return value.Cache.Value(name) }, names)
+
+	case len(hash) == 0:
+		value, err := redis_counter.MakeRedisKeyCounterInt64(redis, names[0])
+		if nil != err {
+			return nil, err
+		}
+		counter = wrapInt64(value, func() *int64 { return value.LastValue })
+
+	default:
+		value, err := redis_counter.MakeRedisHashFieldCounterInt64(redis, hash, names[0])
+		if nil != err {
+			return nil, err
+		}
+		counter = wrapInt64(value, func() *int64 { return value.LastValue })
+	}
+
+	counter.hash = hash
+	counter.names = names
+	return counter, nil
+}
+
+//
+// Internal Helpers:
+//
+
+func invalidNumber(amount string) error {
+	return &usageError{fmt.Sprintf("Invalid number: %s", amount)}
+}
+
+func wrapInt64(counter int64Counter, last func() *int64) *cliCounter {
+	values := func(err error) ([]interface{}, error) {
+		switch ptr := last(); {
+		case nil != err:
+			return nil, err
+		case nil == ptr:
+			return []interface{}{nil}, nil
+		default:
+			return []interface{}{*ptr}, nil
+		}
+	}
+
+	apply := func(op func(int64) (int64, error)) func(string) ([]interface{}, error) {
+		return func(amount string) ([]interface{}, error) {
+			value, err := strconv.ParseInt(amount, 10, 64)
+			if nil != err {
+				return nil, invalidNumber(amount)
+			}
+			_, err = op(value)
+			return values(err)
+		}
+	}
+
+	return &cliCounter{
+		get: func() ([]interface{}, error) {
+			_, err := counter.Get()
+			return values(err)
+		},
+		set: apply(counter.Set),
+		add: apply(counter.Add),
+		sub: apply(counter.Sub),
+		exists: func() ([]bool, error) {
+			ok, err := counter.Exists()
+			if nil != err {
+				return nil, err
+			}
+			return []bool{ok}, nil
+		},
+		delete: counter.Delete,
+	}
+}
+
+func wrapFloat64(counter float64Counter, last func() *float64) *cliCounter {
+	values := func(err error) ([]interface{}, error) {
+		switch ptr := last(); {
+		case nil != err:
+			return nil, err
+		case nil == ptr:
+			return []interface{}{nil}, nil
+		default:
+			return []interface{}{*ptr}, nil
+		}
+	}
+
+	apply := func(op func(float64) (float64, error)) func(string) ([]interface{}, error) {
+		return func(amount string) ([]interface{}, error) {
+			value, err := strconv.ParseFloat(amount, 64)
+			if nil != err {
+				return nil, invalidNumber(amount)
+			}
+			_, err = op(value)
+			return values(err)
+		}
+	}
+
+	return &cliCounter{
+		get: func() ([]interface{}, error) {
+			_, err := counter.Get()
+			return values(err)
+		},
+		set: apply(counter.Set),
+		add: apply(counter.Add),
+		sub: apply(counter.Sub),
+		exists: func() ([]bool, error) {
+			ok, err := counter.Exists()
+			if nil != err {
+				return nil, err
+			}
+			return []bool{ok}, nil
+		},
+		delete: counter.Delete,
+	}
+}
+
+func wrapMInt64(counter mInt64Counter, cache func(name string) *int64, names []string) *cliCounter {
+	values := func(err error) ([]interface{}, error) {
+		if nil != err {
+			return nil, err
+		}
+
+		values := make([]interface{}, len(names))
+		for i, name := range names {
+			if ptr := cache(name); nil != ptr {
+				values[i] = *ptr
+			}
+		}
+		return values, nil
+	}
+
+	apply := func(op func(int64) ([]int64, error)) func(string) ([]interface{}, error) {
+		return func(amount string) ([]interface{}, error) {
+			value, err := strconv.ParseInt(amount, 10, 64)
+			if nil != err {
+				return nil, invalidNumber(amount)
+			}
+			_, err = op(value)
+			return values(err)
+		}
+	}
+
+	return &cliCounter{
+		get: func() ([]interface{}, error) {
+			_, err := counter.MGet()
+			return values(err)
+		},
+		set:    apply(counter.MSet),
+		add:    apply(counter.MAdd),
+		sub:    apply(counter.MSub),
+		exists: counter.MExists,
+		delete: counter.MDelete,
+	}
+}
+
+func wrapMFloat64(counter mFloat64Counter, cache func(name string) *float64, names []string) *cliCounter {
+	values := func(err error) ([]interface{}, error) {
+		if nil != err {
+			return nil, err
+		}
+
+		values := make([]interface{}, len(names))
+		for i, name := range names {
+			if ptr := cache(name); nil != ptr {
+				values[i] = *ptr
+			}
+		}
+		return values, nil
+	}
+
+	apply := func(op func(float64) ([]float64, error)) func(string) ([]interface{}, error) {
+		return func(amount string) ([]interface{}, error) {
+			value, err := strconv.ParseFloat(amount, 64)
+			if nil != err {
+				return nil, invalidNumber(amount)
+			}
+			_, err = op(value)
+			return values(err)
+		}
+	}
+
+	return &cliCounter{
+		get: func() ([]interface{}, error) {
+			_, err := counter.MGet()
+			return values(err)
+		},
+		set:    apply(counter.MSet),
+		add:    apply(counter.MAdd),
+		sub:    apply(counter.MSub),
+		exists: counter.MExists,
+		delete: counter.MDelete,
+	}
+}
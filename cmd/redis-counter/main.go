@@ -0,0 +1,15 @@
+// Command-line tool for reading and changing the Redis counters.
+//
+//	redis-counter -host 127.0.0.1 -port 6379 incr -by 5 page_views
+//	redis-counter get -float load_average
+//	redis-counter mget -format json -hash stats hits misses
+//	redis-counter watch -interval 5s -format csv jobs_queued jobs_done
+//
+// Run without arguments for the full usage.
+package main
+
+import "os"
+
+func main() {
+	os.Exit(Run(os.Args[1:], os.Stdout, os.Stderr))
+}
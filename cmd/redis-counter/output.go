@@ -0,0 +1,121 @@
+package main
+
+import "encoding/csv"
+import "encoding/json"
+import "fmt"
+import "io"
+import "strconv"
+import "strings"
+import "text/tabwriter"
+
+// Output formats
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// One counter in the output; Value is nil when the counter is missing
+type CounterRow struct {
+	Key   string      `json:"key"`
+	Field string      `json:"field,omitempty"`
+	Value interface{} `json:"value"`
+}
+
+// Writes rows in the chosen format. JSON writes one array per call, so
+// "watch" prints one line per poll; CSV writes the header only once.
+type output struct {
+	w      io.Writer
+	format string
+	hash   bool
+	header bool
+}
+
+func makeOutput(w io.Writer, format string, hash bool) (*output, error) {
+	switch format {
+	case FormatTable, FormatJSON, FormatCSV:
+		return &output{w: w, format: format, hash: hash}, nil
+	default:
+		return nil, fmt.Errorf("Invalid format: %s", format)
+	}
+}
+
+// Pair the counter's names with its values
+func makeRows(counter *cliCounter, values []interface{}) []CounterRow {
+	rows := make([]CounterRow, len(counter.names))
+	for i, name := range counter.names {
+		switch {
+		case len(counter.hash) > 0:
+			rows[i] = CounterRow{Key: counter.hash, Field: name}
+		default:
+			rows[i] = CounterRow{Key: name}
+		}
+
+		if i < len(values) {
+			rows[i].Value = values[i]
+		}
+	}
+	return rows
+}
+
+func (p *output) write(rows []CounterRow) error {
+	switch p.format {
+	case FormatJSON:
+		return json.NewEncoder(p.w).Encode(rows)
+	case FormatCSV:
+		return p.writeCSV(rows)
+	default:
+		return p.writeTable(rows)
+	}
+}
+
+//
+// Internal Helpers:
+//
+
+func (p *output) columns(row CounterRow, value string) []string {
+	switch {
+	case p.hash:
+		return []string{row.Key, row.Field, value}
+	default:
+		return []string{row.Key, value}
+	}
+}
+
+func (p *output) writeCSV(rows []CounterRow) error {
+	writer := csv.NewWriter(p.w)
+	if !p.header {
+		p.header = true
+		writer.Write(p.columns(CounterRow{Key: "key", Field: "field"}, "value"))
+	}
+	for _, row := range rows {
+		writer.Write(p.columns(row, formatValue(row.Value, "")))
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (p *output) writeTable(rows []CounterRow) error {
+	writer := tabwriter.NewWriter(p.w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(writer, strings.Join(p.columns(CounterRow{Key: "KEY", Field: "FIELD"}, "VALUE"), "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(writer, strings.Join(p.columns(row, formatValue(row.Value, "NaN")), "\t"))
+	}
+	return writer.Flush()
+}
+
+// Format a counter value; "missing" is used for nil
+func formatValue(value interface{}, missing string) string {
+	switch value := value.(type) {
+	case nil:
+		return missing
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	default:
+		return fmt.Sprint(value)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import "bytes"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestOutputSpecs(t *testing.T) {
+	r := gospec.NewRunner()
+	r.AddSpec(OutputSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func OutputSpecs(c gospec.Context) {
+	counter := &cliCounter{hash: "Hash", names: []string{"A", "B"}}
+	rows := makeRows(counter, []interface{}{int64(3), nil})
+
+	c.Specify("[Output][Make] Validates the format", func() {
+		value, err := makeOutput(nil, "xml", false)
+		c.Expect(err.Error(), gospec.Equals, "Invalid format: xml")
+		c.Expect(value, gospec.Satisfies, nil == value)
+	})
+
+	c.Specify("[Output][JSON] Writes one array per call", func() {
+		var buffer bytes.Buffer
+		value, _ := makeOutput(&buffer, FormatJSON, true)
+		value.write(rows)
+		value.write(rows[:1])
+		c.Expect(buffer.String(), gospec.Equals, `[{"key":"Hash","field":"A","value":3},{"key":"Hash","field":"B","value":null}]`+"\n"+`[{"key":"Hash","field":"A","value":3}]`+"\n")
+	})
+
+	c.Specify("[Output][CSV] Writes the header once", func() {
+		var buffer bytes.Buffer
+		value, _ := makeOutput(&buffer, FormatCSV, true)
+		value.write(rows)
+		value.write(rows[:1])
+		c.Expect(buffer.String(), gospec.Equals, "key,field,value\nHash,A,3\nHash,B,\nHash,A,3\n")
+	})
+
+	c.Specify("[Output][Table] Aligns the columns", func() {
+		var buffer bytes.Buffer
+		value, _ := makeOutput(&buffer, FormatTable, false)
+		value.write(makeRows(&cliCounter{names: []string{"Bob", "Gary"}}, []interface{}{1.5, true}))
+		c.Expect(buffer.String(), gospec.Equals, "KEY   VALUE\nBob   1.5\nGary  true\n")
+	})
+}
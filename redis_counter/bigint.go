@@ -0,0 +1,159 @@
+package redis_counter
+
+import "fmt"
+import "math/big"
+import "github.com/fzzy/radix/redis"
+
+//
+// Internal Helpers:
+//
+
+// Parse the reply into a big.Int; returns nil for a missing counter
+func toBigIntPtr(reply *redis.Reply, key, field string) (*big.Int, error) {
+	switch {
+	case nil != reply.Err:
+		return nil, redisError(reply.Err, key, field)
+	case redis.NilReply == reply.Type:
+		return nil, nil
+	}
+
+	raw, err := reply.Str()
+	if nil != err {
+		return nil, &ErrNotANumber{Key: key, Field: field, Err: err}
+	}
+
+	value, ok := new(big.Int).SetString(raw, 10)
+	if !ok {
+		return nil, &ErrNotANumber{Key: key, Field: field, Raw: raw, Err: fmt.Errorf("invalid integer")}
+	}
+	return value, nil
+}
+
+// Value of "ptr", or a new zero
+func valueOrZeroBigInt(ptr *big.Int) *big.Int {
+	if nil == ptr {
+		return new(big.Int)
+	}
+	return ptr
+}
+
+// Adds the decimal integer ARGV[2] to the decimal integer at KEYS[1], or
+// at the hash field ARGV[1] when it isn't empty, and returns the sum as a
+// string. Lua numbers are doubles, so the digits are split into limbs of
+// 7 digits, whose sums and differences stay exact.
+var bigIntAddScript = makeRedisScript(`
+local BASE = 10000000
+local WIDTH = 7
+
+-- Sign and little-endian limbs of a decimal integer, or nil
+local function parse(value)
+	local sign, digits = string.match(value, '^([+-]?)(%d+)$')
+	if not digits then
+		return nil
+	end
+	digits = string.gsub(digits, '^0+', '')
+
+	local limbs = {}
+	local i = #digits
+	while i > 0 do
+		local j = math.max(1, i - WIDTH + 1)
+		limbs[#limbs + 1] = tonumber(string.sub(digits, j, i))
+		i = j - 1
+	end
+
+	if sign == '-' and #limbs > 0 then
+		return -1, limbs
+	end
+	return 1, limbs
+end
+
+local function compare(a, b)
+	if #a ~= #b then
+		return #a < #b and -1 or 1
+	end
+	for i = #a, 1, -1 do
+		if a[i] ~= b[i] then
+			return a[i] < b[i] and -1 or 1
+		end
+	end
+	return 0
+end
+
+local function add(a, b)
+	local sum, carry = {}, 0
+	for i = 1, math.max(#a, #b) do
+		local limb = (a[i] or 0) + (b[i] or 0) + carry
+		carry = limb >= BASE and 1 or 0
+		sum[i] = limb - carry * BASE
+	end
+	if carry > 0 then
+		sum[#sum + 1] = carry
+	end
+	return sum
+end
+
+-- a - b, for a >= b
+local function sub(a, b)
+	local diff, borrow = {}, 0
+	for i = 1, #a do
+		local limb = a[i] - (b[i] or 0) - borrow
+		borrow = limb < 0 and 1 or 0
+		diff[i] = limb + borrow * BASE
+	end
+	while #diff > 0 and diff[#diff] == 0 do
+		diff[#diff] = nil
+	end
+	return diff
+end
+
+local function format(sign, limbs)
+	if #limbs == 0 then
+		return '0'
+	end
+
+	local parts = {tostring(limbs[#limbs])}
+	if sign < 0 then
+		parts[1] = '-' .. parts[1]
+	end
+	for i = #limbs - 1, 1, -1 do
+		parts[#parts + 1] = string.format('%07d', limbs[i])
+	end
+	return table.concat(parts)
+end
+
+local current
+if ARGV[1] == '' then
+	current = redis.call('GET', KEYS[1])
+else
+	current = redis.call('HGET', KEYS[1], ARGV[1])
+end
+
+local sign, limbs = parse(current or '0')
+if not sign then
+	return {err = 'ERR value is not an integer'}
+end
+local amount_sign, amount_limbs = parse(ARGV[2])
+if not amount_sign then
+	return {err = 'ERR increment is not an integer'}
+end
+
+if sign == amount_sign then
+	limbs = add(limbs, amount_limbs)
+elseif compare(limbs, amount_limbs) >= 0 then
+	limbs = sub(limbs, amount_limbs)
+else
+	sign, limbs = amount_sign, sub(amount_limbs, limbs)
+end
+
+local value = format(sign, limbs)
+if ARGV[1] == '' then
+	local ttl = redis.call('PTTL', KEYS[1])
+	redis.call('SET', KEYS[1], value)
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[1], ttl)
+	end
+else
+	redis.call('HSET', KEYS[1], ARGV[1], value)
+end
+return value
+`)
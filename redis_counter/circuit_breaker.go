@@ -0,0 +1,288 @@
+package redis_counter
+
+import "errors"
+import "fmt"
+import "strconv"
+import "sync"
+import "time"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// States of a CircuitBreaker
+const (
+	CircuitClosed = iota
+	CircuitOpen
+	CircuitHalfOpen
+)
+
+// Default settings of a CircuitBreaker
+const CircuitFailureThreshold = 5
+const CircuitOpenTimeout = 30 * time.Second
+const CircuitJournalSize = 10000
+
+// Returned for a write while the circuit is open and the journal is full;
+// the write is lost
+var ErrJournalFull = errors.New("Circuit open and journal full")
+
+// Runs operations on counters of any type, and stops calling Redis after
+// FailureThreshold consecutive failures:
+//
+//	breaker, _ := MakeCircuitBreaker(redis)
+//	err := breaker.AddInt64(hits, 1)
+//	stale, err := breaker.Get(hits)
+//
+// While the circuit is open, writes are recorded in a journal of up to
+// JournalSize operations, and Get leaves the counter's LastValue or Cache
+// at the last known value and reports it as stale. After OpenTimeout the
+// next operation is sent to Redis as a trial; when it succeeds the
+// circuit closes and the journal is replayed in order, one operation at a
+// time. Each operation is dropped once Redis applies it, so a replay that
+// fails mid-way resumes after the last applied one. An operation Redis
+// refused with a retryable error (e.g. LOADING) stays at the head of the
+// journal; like any failed write, an operation whose reply was lost may
+// have been applied and is only replayed again when it was never sent.
+//
+// Only errors from an unavailable Redis, as classified by IsRetryable,
+// count as failures. Operations are serialized: the breaker holds its
+// lock while it calls Redis, so callers block for the whole replay.
+type CircuitBreaker struct {
+	Redis            *dog_pool.RedisConnection
+	FailureThreshold int
+	OpenTimeout      time.Duration
+	JournalSize      int
+
+	mutex    sync.Mutex
+	state    int
+	failures int
+	opened   time.Time
+	journal  []*journalEntry
+}
+
+// Make a new instance of CircuitBreaker
+func MakeCircuitBreaker(redis *dog_pool.RedisConnection) (*CircuitBreaker, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	default:
+		return &CircuitBreaker{
+			Redis:            redis,
+			FailureThreshold: CircuitFailureThreshold,
+			OpenTimeout:      CircuitOpenTimeout,
+			JournalSize:      CircuitJournalSize,
+		}, nil
+	}
+}
+
+// CircuitClosed, CircuitOpen or CircuitHalfOpen
+func (b *CircuitBreaker) State() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == CircuitOpen && time.Since(b.opened) >= b.OpenTimeout {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// Number of journaled operations waiting to be replayed
+func (b *CircuitBreaker) JournalLen() int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return len(b.journal)
+}
+
+// Get the value of the counter into its LastValue or Cache; returns true
+// when the circuit is open and the value is the last known one
+func (b *CircuitBreaker) Get(counter TransactionCounter) (bool, error) {
+	return b.operationReads(counter)
+}
+
+func (b *CircuitBreaker) Delete(counter TransactionCounter) error {
+	return b.operationWrites(counter, opDelete, "")
+}
+
+func (b *CircuitBreaker) SetInt64(counter TransactionCounter, amount int64) error {
+	return b.operationWrites(counter, opSet, strconv.FormatInt(amount, 10))
+}
+
+func (b *CircuitBreaker) AddInt64(counter TransactionCounter, amount int64) error {
+	return b.operationWrites(counter, opAdd, strconv.FormatInt(amount, 10))
+}
+
+func (b *CircuitBreaker) SubInt64(counter TransactionCounter, amount int64) error {
+	return b.operationWrites(counter, opSub, strconv.FormatInt(amount, 10))
+}
+
+func (b *CircuitBreaker) SetFloat64(counter TransactionCounter, amount float64) error {
+	return b.operationWrites(counter, opSet, strconv.FormatFloat(amount, 'f', -1, 64))
+}
+
+func (b *CircuitBreaker) AddFloat64(counter TransactionCounter, amount float64) error {
+	return b.operationWrites(counter, opAdd, strconv.FormatFloat(amount, 'f', -1, 64))
+}
+
+func (b *CircuitBreaker) SubFloat64(counter TransactionCounter, amount float64) error {
+	return b.operationWrites(counter, opSub, strconv.FormatFloat(amount, 'f', -1, 64))
+}
+
+// Replay the journal now, unless the circuit is open; returns the first
+// error of the replayed operations. Called after every successful
+// operation, so it is only needed to flush the journal while idle.
+func (b *CircuitBreaker) Replay() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.allowed() {
+		return nil
+	}
+	_, err := b.replay()
+	return err
+}
+
+//
+// Internal Helpers:
+//
+
+// One journaled write
+type journalEntry struct {
+	counter TransactionCounter
+	op      int
+	amount  string
+}
+
+// Whether Redis should be called; moves an open circuit to half open
+// once OpenTimeout has passed
+func (b *CircuitBreaker) allowed() bool {
+	switch {
+	case b.state == CircuitOpen && time.Since(b.opened) >= b.OpenTimeout:
+		b.state = CircuitHalfOpen
+		return true
+	default:
+		return b.state != CircuitOpen
+	}
+}
+
+// Run one operation through a pipeline; returns whether Redis was
+// unavailable, and the error of the operation
+func (b *CircuitBreaker) run(counter TransactionCounter, op int, amount string) (bool, error) {
+	pipeline := &RedisPipeline{Redis: b.Redis}
+	result := pipeline.queue(counter, op, amount)
+	if err := pipeline.Exec(); nil != err {
+		return true, err
+	}
+
+	err := result.Err()
+	return nil != err && IsRetryable(err), err
+}
+
+// Count the result of a call to Redis, opening or closing the circuit
+func (b *CircuitBreaker) record(unavailable bool) {
+	switch {
+	case !unavailable:
+		b.state, b.failures = CircuitClosed, 0
+	case b.state == CircuitHalfOpen:
+		b.state, b.opened = CircuitOpen, time.Now()
+	default:
+		b.failures++
+		if b.failures >= b.FailureThreshold {
+			b.state, b.opened = CircuitOpen, time.Now()
+		}
+	}
+}
+
+func (b *CircuitBreaker) operationReads(counter TransactionCounter) (bool, error) {
+	if nil == counter {
+		return false, fmt.Errorf("Nil counter")
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if !b.allowed() {
+		return true, nil
+	}
+
+	unavailable, err := b.run(counter, opGet, "")
+	b.record(unavailable)
+	switch {
+	case unavailable && b.state == CircuitOpen:
+		return true, nil
+	case nil != err:
+		return false, err
+	default:
+		// Errors of the replayed writes are returned by Replay:
+		b.replay()
+		return false, nil
+	}
+}
+
+func (b *CircuitBreaker) operationWrites(counter TransactionCounter, op int, amount string) error {
+	if nil == counter {
+		return fmt.Errorf("Nil counter")
+	}
+	if _, err := counter.transactionOps(op, amount); nil != err {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	// Journal the write while the circuit is open, or while earlier writes
+	// can't be replayed, to keep the writes in order:
+	if !b.allowed() {
+		return b.append(counter, op, amount)
+	}
+	if sent, _ := b.replay(); !sent {
+		return b.append(counter, op, amount)
+	}
+
+	// Redis may have applied a write that failed, so it isn't journaled:
+	unavailable, err := b.run(counter, op, amount)
+	b.record(unavailable)
+	return err
+}
+
+func (b *CircuitBreaker) append(counter TransactionCounter, op int, amount string) error {
+	if len(b.journal) >= b.JournalSize {
+		return ErrJournalFull
+	}
+	b.journal = append(b.journal, &journalEntry{counter, op, amount})
+	return nil
+}
+
+// Send the journal in order, dropping each operation once Redis applied
+// it; stops at an operation Redis refused with a retryable error. Returns
+// whether it was all sent, and the first error of the replayed operations
+func (b *CircuitBreaker) replay() (bool, error) {
+	var first_err error
+	for len(b.journal) > 0 {
+		entry := b.journal[0]
+		pipeline := &RedisPipeline{Redis: b.Redis}
+		result := pipeline.queue(entry.counter, entry.op, entry.amount)
+
+		if err := pipeline.Exec(); nil != err {
+			// Redis may have applied it, unless it was never sent:
+			if !isUnsent(err) {
+				b.journal[0] = nil
+				b.journal = b.journal[1:]
+			}
+			b.record(true)
+			return false, err
+		}
+
+		// Not applied, so it is kept for the next replay:
+		err := result.Err()
+		if nil != err && IsRetryable(err) {
+			b.record(true)
+			return false, err
+		}
+		b.journal[0] = nil
+		b.journal = b.journal[1:]
+
+		if nil != err && nil == first_err {
+			first_err = err
+		}
+	}
+	b.journal = nil
+	return true, first_err
+}
@@ -0,0 +1,111 @@
+package redis_counter
+
+import "time"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestCircuitBreakerSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(CircuitBreakerSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func CircuitBreakerSpecs(c gospec.Context) {
+
+	c.Specify("[CircuitBreaker][Make] Makes new instance", func() {
+		value, err := MakeCircuitBreaker(nil)
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeCircuitBreaker(&dog_pool.RedisConnection{})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.State(), gospec.Equals, CircuitClosed)
+		c.Expect(value.FailureThreshold, gospec.Equals, CircuitFailureThreshold)
+	})
+
+	c.Specify("[CircuitBreaker][Closed] Runs operations on Redis", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		breaker, _ := MakeCircuitBreaker(server.Connection())
+		hits, _ := MakeRedisKeyCounterInt64(server.Connection(), "hits")
+
+		c.Expect(breaker.AddInt64(hits, 2), gospec.Equals, nil)
+		c.Expect(*hits.LastValue, gospec.Equals, int64(2))
+
+		stale, err := breaker.Get(hits)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(stale, gospec.Equals, false)
+		c.Expect(*hits.LastValue, gospec.Equals, int64(2))
+		c.Expect(breaker.JournalLen(), gospec.Equals, 0)
+	})
+
+	c.Specify("[CircuitBreaker][Open] Journals writes and replays them once Redis is back", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		down, down_err := dog_pool.StartRedisServer(&logger)
+		if nil != down_err {
+			panic(down_err)
+		}
+		up, up_err := dog_pool.StartRedisServer(&logger)
+		if nil != up_err {
+			panic(up_err)
+		}
+		defer up.Close()
+
+		breaker, _ := MakeCircuitBreaker(down.Connection())
+		breaker.FailureThreshold = 2
+		breaker.OpenTimeout = time.Hour
+
+		hits, _ := MakeRedisKeyCounterInt64(down.Connection(), "hits")
+		load, _ := MakeRedisHashMFieldsCounterFloat64(down.Connection(), "load", "web1", "web2")
+		c.Expect(breaker.AddInt64(hits, 5), gospec.Equals, nil)
+		down.Close()
+
+		// Failures up to the threshold are returned:
+		err := breaker.AddInt64(hits, 1)
+		c.Expect(err, gospec.Satisfies, nil != err)
+		c.Expect(breaker.State(), gospec.Equals, CircuitClosed)
+
+		err = breaker.AddInt64(hits, 1)
+		c.Expect(err, gospec.Satisfies, nil != err)
+		c.Expect(breaker.State(), gospec.Equals, CircuitOpen)
+
+		// Open: serves the last value and journals the writes:
+		stale, err := breaker.Get(hits)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(stale, gospec.Equals, true)
+		c.Expect(*hits.LastValue, gospec.Equals, int64(5))
+
+		c.Expect(breaker.AddInt64(hits, 3), gospec.Equals, nil)
+		c.Expect(breaker.AddFloat64(load, 0.5), gospec.Equals, nil)
+		c.Expect(breaker.JournalLen(), gospec.Equals, 2)
+
+		// The journal is bounded:
+		breaker.JournalSize = 2
+		c.Expect(breaker.SubInt64(hits, 1), gospec.Equals, ErrJournalFull)
+
+		// Half open: the trial succeeds and the journal is replayed:
+		breaker.Redis = up.Connection()
+		breaker.OpenTimeout = 0
+		stale, err = breaker.Get(hits)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(stale, gospec.Equals, false)
+		c.Expect(breaker.State(), gospec.Equals, CircuitClosed)
+		c.Expect(breaker.JournalLen(), gospec.Equals, 0)
+		c.Expect(*hits.LastValue, gospec.Equals, int64(3))
+		c.Expect(*load.Cache.Value("web2"), gospec.Equals, 0.5)
+
+		value, _ := up.Connection().Cmd("GET", "hits").Int64()
+		c.Expect(value, gospec.Equals, int64(3))
+	})
+}
@@ -0,0 +1,478 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.27.1
+// source: counter_service.proto
+
+package counter_service
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	durationpb "google.golang.org/protobuf/types/known/durationpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// A key counter, or a hash field counter when "field" is set
+type Counter struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           string                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Field         string                 `protobuf:"bytes,2,opt,name=field,proto3" json:"field,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Counter) Reset() {
+	*x = Counter{}
+	mi := &file_counter_service_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Counter) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Counter) ProtoMessage() {}
+
+func (x *Counter) ProtoReflect() protoreflect.Message {
+	mi := &file_counter_service_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Counter.ProtoReflect.Descriptor instead.
+func (*Counter) Descriptor() ([]byte, []int) {
+	return file_counter_service_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Counter) GetKey() string {
+	if x != nil {
+		return x.Key
+	}
+	return ""
+}
+
+func (x *Counter) GetField() string {
+	if x != nil {
+		return x.Field
+	}
+	return ""
+}
+
+type CounterValue struct {
+	state   protoimpl.MessageState `protogen:"open.v1"`
+	Counter *Counter               `protobuf:"bytes,1,opt,name=counter,proto3" json:"counter,omitempty"`
+	Value   int64                  `protobuf:"varint,2,opt,name=value,proto3" json:"value,omitempty"`
+	// False when the key or hash field doesn't exist
+	Found         bool `protobuf:"varint,3,opt,name=found,proto3" json:"found,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CounterValue) Reset() {
+	*x = CounterValue{}
+	mi := &file_counter_service_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CounterValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CounterValue) ProtoMessage() {}
+
+func (x *CounterValue) ProtoReflect() protoreflect.Message {
+	mi := &file_counter_service_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CounterValue.ProtoReflect.Descriptor instead.
+func (*CounterValue) Descriptor() ([]byte, []int) {
+	return file_counter_service_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CounterValue) GetCounter() *Counter {
+	if x != nil {
+		return x.Counter
+	}
+	return nil
+}
+
+func (x *CounterValue) GetValue() int64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *CounterValue) GetFound() bool {
+	if x != nil {
+		return x.Found
+	}
+	return false
+}
+
+type IncrementRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Counter       *Counter               `protobuf:"bytes,1,opt,name=counter,proto3" json:"counter,omitempty"`
+	Amount        int64                  `protobuf:"varint,2,opt,name=amount,proto3" json:"amount,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IncrementRequest) Reset() {
+	*x = IncrementRequest{}
+	mi := &file_counter_service_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IncrementRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IncrementRequest) ProtoMessage() {}
+
+func (x *IncrementRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_counter_service_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IncrementRequest.ProtoReflect.Descriptor instead.
+func (*IncrementRequest) Descriptor() ([]byte, []int) {
+	return file_counter_service_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *IncrementRequest) GetCounter() *Counter {
+	if x != nil {
+		return x.Counter
+	}
+	return nil
+}
+
+func (x *IncrementRequest) GetAmount() int64 {
+	if x != nil {
+		return x.Amount
+	}
+	return 0
+}
+
+type GetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Counter       *Counter               `protobuf:"bytes,1,opt,name=counter,proto3" json:"counter,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GetRequest) Reset() {
+	*x = GetRequest{}
+	mi := &file_counter_service_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetRequest) ProtoMessage() {}
+
+func (x *GetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_counter_service_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetRequest.ProtoReflect.Descriptor instead.
+func (*GetRequest) Descriptor() ([]byte, []int) {
+	return file_counter_service_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *GetRequest) GetCounter() *Counter {
+	if x != nil {
+		return x.Counter
+	}
+	return nil
+}
+
+type BatchGetRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Counters      []*Counter             `protobuf:"bytes,1,rep,name=counters,proto3" json:"counters,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchGetRequest) Reset() {
+	*x = BatchGetRequest{}
+	mi := &file_counter_service_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchGetRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetRequest) ProtoMessage() {}
+
+func (x *BatchGetRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_counter_service_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetRequest) Descriptor() ([]byte, []int) {
+	return file_counter_service_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *BatchGetRequest) GetCounters() []*Counter {
+	if x != nil {
+		return x.Counters
+	}
+	return nil
+}
+
+type BatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Values        []*CounterValue        `protobuf:"bytes,1,rep,name=values,proto3" json:"values,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchResponse) Reset() {
+	*x = BatchResponse{}
+	mi := &file_counter_service_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchResponse) ProtoMessage() {}
+
+func (x *BatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_counter_service_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchResponse.ProtoReflect.Descriptor instead.
+func (*BatchResponse) Descriptor() ([]byte, []int) {
+	return file_counter_service_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *BatchResponse) GetValues() []*CounterValue {
+	if x != nil {
+		return x.Values
+	}
+	return nil
+}
+
+type WatchRequest struct {
+	state    protoimpl.MessageState `protogen:"open.v1"`
+	Counters []*Counter             `protobuf:"bytes,1,rep,name=counters,proto3" json:"counters,omitempty"`
+	// Time between polls; defaults to one second
+	Interval      *durationpb.Duration `protobuf:"bytes,2,opt,name=interval,proto3" json:"interval,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *WatchRequest) Reset() {
+	*x = WatchRequest{}
+	mi := &file_counter_service_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *WatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*WatchRequest) ProtoMessage() {}
+
+func (x *WatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_counter_service_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use WatchRequest.ProtoReflect.Descriptor instead.
+func (*WatchRequest) Descriptor() ([]byte, []int) {
+	return file_counter_service_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *WatchRequest) GetCounters() []*Counter {
+	if x != nil {
+		return x.Counters
+	}
+	return nil
+}
+
+func (x *WatchRequest) GetInterval() *durationpb.Duration {
+	if x != nil {
+		return x.Interval
+	}
+	return nil
+}
+
+var File_counter_service_proto protoreflect.FileDescriptor
+
+const file_counter_service_proto_rawDesc = "" +
+	"\n" +
+	"\x15counter_service.proto\x12\x1dredis_counter.counter_service\x1a\x1egoogle/protobuf/duration.proto\"1\n" +
+	"\aCounter\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05field\x18\x02 \x01(\tR\x05field\"|\n" +
+	"\fCounterValue\x12@\n" +
+	"\acounter\x18\x01 \x01(\v2&.redis_counter.counter_service.CounterR\acounter\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\x03R\x05value\x12\x14\n" +
+	"\x05found\x18\x03 \x01(\bR\x05found\"l\n" +
+	"\x10IncrementRequest\x12@\n" +
+	"\acounter\x18\x01 \x01(\v2&.redis_counter.counter_service.CounterR\acounter\x12\x16\n" +
+	"\x06amount\x18\x02 \x01(\x03R\x06amount\"N\n" +
+	"\n" +
+	"GetRequest\x12@\n" +
+	"\acounter\x18\x01 \x01(\v2&.redis_counter.counter_service.CounterR\acounter\"U\n" +
+	"\x0fBatchGetRequest\x12B\n" +
+	"\bcounters\x18\x01 \x03(\v2&.redis_counter.counter_service.CounterR\bcounters\"T\n" +
+	"\rBatchResponse\x12C\n" +
+	"\x06values\x18\x01 \x03(\v2+.redis_counter.counter_service.CounterValueR\x06values\"\x89\x01\n" +
+	"\fWatchRequest\x12B\n" +
+	"\bcounters\x18\x01 \x03(\v2&.redis_counter.counter_service.CounterR\bcounters\x125\n" +
+	"\binterval\x18\x02 \x01(\v2\x19.google.protobuf.DurationR\binterval2\x9c\x04\n" +
+	"\x0eCounterService\x12i\n" +
+	"\tIncrement\x12/.redis_counter.counter_service.IncrementRequest\x1a+.redis_counter.counter_service.CounterValue\x12q\n" +
+	"\x0eBatchIncrement\x12/.redis_counter.counter_service.IncrementRequest\x1a,.redis_counter.counter_service.BatchResponse(\x01\x12]\n" +
+	"\x03Get\x12).redis_counter.counter_service.GetRequest\x1a+.redis_counter.counter_service.CounterValue\x12h\n" +
+	"\bBatchGet\x12..redis_counter.counter_service.BatchGetRequest\x1a,.redis_counter.counter_service.BatchResponse\x12c\n" +
+	"\x05Watch\x12+.redis_counter.counter_service.WatchRequest\x1a+.redis_counter.counter_service.CounterValue0\x01BBZ@github.com/gnagel/go_redis_counter/redis_counter/counter_serviceb\x06proto3"
+
+var (
+	file_counter_service_proto_rawDescOnce sync.Once
+	file_counter_service_proto_rawDescData []byte
+)
+
+func file_counter_service_proto_rawDescGZIP() []byte {
+	file_counter_service_proto_rawDescOnce.Do(func() {
+		file_counter_service_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_counter_service_proto_rawDesc), len(file_counter_service_proto_rawDesc)))
+	})
+	return file_counter_service_proto_rawDescData
+}
+
+var file_counter_service_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_counter_service_proto_goTypes = []any{
+	(*Counter)(nil),             // 0: redis_counter.counter_service.Counter
+	(*CounterValue)(nil),        // 1: redis_counter.counter_service.CounterValue
+	(*IncrementRequest)(nil),    // 2: redis_counter.counter_service.IncrementRequest
+	(*GetRequest)(nil),          // 3: redis_counter.counter_service.GetRequest
+	(*BatchGetRequest)(nil),     // 4: redis_counter.counter_service.BatchGetRequest
+	(*BatchResponse)(nil),       // 5: redis_counter.counter_service.BatchResponse
+	(*WatchRequest)(nil),        // 6: redis_counter.counter_service.WatchRequest
+	(*durationpb.Duration)(nil), // 7: google.protobuf.Duration
+}
+var file_counter_service_proto_depIdxs = []int32{
+	0,  // 0: redis_counter.counter_service.CounterValue.counter:type_name -> redis_counter.counter_service.Counter
+	0,  // 1: redis_counter.counter_service.IncrementRequest.counter:type_name -> redis_counter.counter_service.Counter
+	0,  // 2: redis_counter.counter_service.GetRequest.counter:type_name -> redis_counter.counter_service.Counter
+	0,  // 3: redis_counter.counter_service.BatchGetRequest.counters:type_name -> redis_counter.counter_service.Counter
+	1,  // 4: redis_counter.counter_service.BatchResponse.values:type_name -> redis_counter.counter_service.CounterValue
+	0,  // 5: redis_counter.counter_service.WatchRequest.counters:type_name -> redis_counter.counter_service.Counter
+	7,  // 6: redis_counter.counter_service.WatchRequest.interval:type_name -> google.protobuf.Duration
+	2,  // 7: redis_counter.counter_service.CounterService.Increment:input_type -> redis_counter.counter_service.IncrementRequest
+	2,  // 8: redis_counter.counter_service.CounterService.BatchIncrement:input_type -> redis_counter.counter_service.IncrementRequest
+	3,  // 9: redis_counter.counter_service.CounterService.Get:input_type -> redis_counter.counter_service.GetRequest
+	4,  // 10: redis_counter.counter_service.CounterService.BatchGet:input_type -> redis_counter.counter_service.BatchGetRequest
+	6,  // 11: redis_counter.counter_service.CounterService.Watch:input_type -> redis_counter.counter_service.WatchRequest
+	1,  // 12: redis_counter.counter_service.CounterService.Increment:output_type -> redis_counter.counter_service.CounterValue
+	5,  // 13: redis_counter.counter_service.CounterService.BatchIncrement:output_type -> redis_counter.counter_service.BatchResponse
+	1,  // 14: redis_counter.counter_service.CounterService.Get:output_type -> redis_counter.counter_service.CounterValue
+	5,  // 15: redis_counter.counter_service.CounterService.BatchGet:output_type -> redis_counter.counter_service.BatchResponse
+	1,  // 16: redis_counter.counter_service.CounterService.Watch:output_type -> redis_counter.counter_service.CounterValue
+	12, // [12:17] is the sub-list for method output_type
+	7,  // [7:12] is the sub-list for method input_type
+	7,  // [7:7] is the sub-list for extension type_name
+	7,  // [7:7] is the sub-list for extension extendee
+	0,  // [0:7] is the sub-list for field type_name
+}
+
+func init() { file_counter_service_proto_init() }
+func file_counter_service_proto_init() {
+	if File_counter_service_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_counter_service_proto_rawDesc), len(file_counter_service_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   7,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_counter_service_proto_goTypes,
+		DependencyIndexes: file_counter_service_proto_depIdxs,
+		MessageInfos:      file_counter_service_proto_msgTypes,
+	}.Build()
+	File_counter_service_proto = out.File
+	file_counter_service_proto_goTypes = nil
+	file_counter_service_proto_depIdxs = nil
+}
@@ -0,0 +1,290 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.27.1
+// source: counter_service.proto
+
+package counter_service
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	CounterService_Increment_FullMethodName      = "/redis_counter.counter_service.CounterService/Increment"
+	CounterService_BatchIncrement_FullMethodName = "/redis_counter.counter_service.CounterService/BatchIncrement"
+	CounterService_Get_FullMethodName            = "/redis_counter.counter_service.CounterService/Get"
+	CounterService_BatchGet_FullMethodName       = "/redis_counter.counter_service.CounterService/BatchGet"
+	CounterService_Watch_FullMethodName          = "/redis_counter.counter_service.CounterService/Watch"
+)
+
+// CounterServiceClient is the client API for CounterService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Counter service backed by the redis_counter types
+type CounterServiceClient interface {
+	// Add "amount" to a counter and return the new value
+	Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*CounterValue, error)
+	// Stream increments; deltas for the same counter are coalesced and
+	// written in one transaction when the stream is closed. A stream of
+	// more than MaxBatchSize counters is rejected before any is written.
+	BatchIncrement(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[IncrementRequest, BatchResponse], error)
+	// Get the value of a counter
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*CounterValue, error)
+	// Get the values of up to MaxBatchSize counters in one pipeline
+	BatchGet(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*BatchResponse, error)
+	// Poll up to MaxBatchSize counters and stream their values whenever
+	// they change
+	Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CounterValue], error)
+}
+
+type counterServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCounterServiceClient(cc grpc.ClientConnInterface) CounterServiceClient {
+	return &counterServiceClient{cc}
+}
+
+func (c *counterServiceClient) Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*CounterValue, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CounterValue)
+	err := c.cc.Invoke(ctx, CounterService_Increment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterServiceClient) BatchIncrement(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[IncrementRequest, BatchResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CounterService_ServiceDesc.Streams[0], CounterService_BatchIncrement_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[IncrementRequest, BatchResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CounterService_BatchIncrementClient = grpc.ClientStreamingClient[IncrementRequest, BatchResponse]
+
+func (c *counterServiceClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*CounterValue, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(CounterValue)
+	err := c.cc.Invoke(ctx, CounterService_Get_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterServiceClient) BatchGet(ctx context.Context, in *BatchGetRequest, opts ...grpc.CallOption) (*BatchResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(BatchResponse)
+	err := c.cc.Invoke(ctx, CounterService_BatchGet_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *counterServiceClient) Watch(ctx context.Context, in *WatchRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[CounterValue], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &CounterService_ServiceDesc.Streams[1], CounterService_Watch_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchRequest, CounterValue]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CounterService_WatchClient = grpc.ServerStreamingClient[CounterValue]
+
+// CounterServiceServer is the server API for CounterService service.
+// All implementations must embed UnimplementedCounterServiceServer
+// for forward compatibility.
+//
+// Counter service backed by the redis_counter types
+type CounterServiceServer interface {
+	// Add "amount" to a counter and return the new value
+	Increment(context.Context, *IncrementRequest) (*CounterValue, error)
+	// Stream increments; deltas for the same counter are coalesced and
+	// written in one transaction when the stream is closed. A stream of
+	// more than MaxBatchSize counters is rejected before any is written.
+	BatchIncrement(grpc.ClientStreamingServer[IncrementRequest, BatchResponse]) error
+	// Get the value of a counter
+	Get(context.Context, *GetRequest) (*CounterValue, error)
+	// Get the values of up to MaxBatchSize counters in one pipeline
+	BatchGet(context.Context, *BatchGetRequest) (*BatchResponse, error)
+	// Poll up to MaxBatchSize counters and stream their values whenever
+	// they change
+	Watch(*WatchRequest, grpc.ServerStreamingServer[CounterValue]) error
+	mustEmbedUnimplementedCounterServiceServer()
+}
+
+// UnimplementedCounterServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCounterServiceServer struct{}
+
+func (UnimplementedCounterServiceServer) Increment(context.Context, *IncrementRequest) (*CounterValue, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Increment not implemented")
+}
+func (UnimplementedCounterServiceServer) BatchIncrement(grpc.ClientStreamingServer[IncrementRequest, BatchResponse]) error {
+	return status.Errorf(codes.Unimplemented, "method BatchIncrement not implemented")
+}
+func (UnimplementedCounterServiceServer) Get(context.Context, *GetRequest) (*CounterValue, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (UnimplementedCounterServiceServer) BatchGet(context.Context, *BatchGetRequest) (*BatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGet not implemented")
+}
+func (UnimplementedCounterServiceServer) Watch(*WatchRequest, grpc.ServerStreamingServer[CounterValue]) error {
+	return status.Errorf(codes.Unimplemented, "method Watch not implemented")
+}
+func (UnimplementedCounterServiceServer) mustEmbedUnimplementedCounterServiceServer() {}
+func (UnimplementedCounterServiceServer) testEmbeddedByValue()                        {}
+
+// UnsafeCounterServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CounterServiceServer will
+// result in compilation errors.
+type UnsafeCounterServiceServer interface {
+	mustEmbedUnimplementedCounterServiceServer()
+}
+
+func RegisterCounterServiceServer(s grpc.ServiceRegistrar, srv CounterServiceServer) {
+	// If the following call pancis, it indicates UnimplementedCounterServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CounterService_ServiceDesc, srv)
+}
+
+func _CounterService_Increment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServiceServer).Increment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CounterService_Increment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServiceServer).Increment(ctx, req.(*IncrementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CounterService_BatchIncrement_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(CounterServiceServer).BatchIncrement(&grpc.GenericServerStream[IncrementRequest, BatchResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CounterService_BatchIncrementServer = grpc.ClientStreamingServer[IncrementRequest, BatchResponse]
+
+func _CounterService_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServiceServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CounterService_Get_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServiceServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CounterService_BatchGet_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CounterServiceServer).BatchGet(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CounterService_BatchGet_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CounterServiceServer).BatchGet(ctx, req.(*BatchGetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CounterService_Watch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(CounterServiceServer).Watch(m, &grpc.GenericServerStream[WatchRequest, CounterValue]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type CounterService_WatchServer = grpc.ServerStreamingServer[CounterValue]
+
+// CounterService_ServiceDesc is the grpc.ServiceDesc for CounterService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CounterService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "redis_counter.counter_service.CounterService",
+	HandlerType: (*CounterServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Increment",
+			Handler:    _CounterService_Increment_Handler,
+		},
+		{
+			MethodName: "Get",
+			Handler:    _CounterService_Get_Handler,
+		},
+		{
+			MethodName: "BatchGet",
+			Handler:    _CounterService_BatchGet_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "BatchIncrement",
+			Handler:       _CounterService_BatchIncrement_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Watch",
+			Handler:       _CounterService_Watch_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "counter_service.proto",
+}
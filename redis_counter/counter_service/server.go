@@ -0,0 +1,325 @@
+package counter_service
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative counter_service.proto
+
+import "context"
+import "fmt"
+import "io"
+import "math"
+import "sync"
+import "time"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+import "google.golang.org/grpc/codes"
+import "google.golang.org/grpc/status"
+
+// Largest number of distinct counters in one BatchIncrement stream, and
+// of counters in one BatchGet or Watch request; a larger stream is
+// rejected before anything is written to Redis
+const MaxBatchSize = 1000
+
+// Default time between polls for Watch
+const DefaultWatchInterval = time.Second
+
+// Shortest time between polls for Watch
+const MinWatchInterval = 10 * time.Millisecond
+
+// Implements CounterServiceServer with the redis_counter types
+type CounterServer struct {
+	UnimplementedCounterServiceServer
+
+	Redis *dog_pool.RedisConnection
+
+	mutex sync.Mutex
+}
+
+// Make a new instance of CounterServer
+func MakeCounterServer(redis *dog_pool.RedisConnection) (*CounterServer, error) {
+	switch {
+	case nil == redis:
+		return nil, fmt.Errorf("Nil redis connection")
+	default:
+		return &CounterServer{Redis: redis}, nil
+	}
+}
+
+func (p *CounterServer) Increment(ctx context.Context, request *IncrementRequest) (*CounterValue, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	counter := request.GetCounter()
+	switch {
+	case nil == counter:
+		return nil, status.Error(codes.InvalidArgument, "Nil counter")
+
+	case len(counter.GetField()) == 0:
+		value, err := redis_counter.MakeRedisKeyCounterInt64(p.Redis, counter.GetKey())
+		if nil != err {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		amount, err := value.Add(request.GetAmount())
+		if nil != err {
+			return nil, statusError(err)
+		}
+		return &CounterValue{Counter: counter, Value: amount, Found: true}, nil
+
+	default:
+		value, err := redis_counter.MakeRedisHashFieldCounterInt64(p.Redis, counter.GetKey(), counter.GetField())
+		if nil != err {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+
+		amount, err := value.Add(request.GetAmount())
+		if nil != err {
+			return nil, statusError(err)
+		}
+		return &CounterValue{Counter: counter, Value: amount, Found: true}, nil
+	}
+}
+
+// Coalesce the deltas of the stream, and apply them in one MULTI/EXEC
+// when it is closed. Nothing is applied when the stream fails; MULTI/EXEC
+// doesn't roll back, so a delta Redis rejects (e.g. for a key holding a
+// hash) fails the call while the others are applied.
+func (p *CounterServer) BatchIncrement(stream CounterService_BatchIncrementServer) error {
+	pending := makeCoalescedDeltas()
+	for {
+		request, err := stream.Recv()
+		switch {
+		case io.EOF == err:
+			values, err := p.applyDeltas(pending)
+			if nil != err {
+				return err
+			}
+			return stream.SendAndClose(&BatchResponse{Values: values})
+
+		case nil != err:
+			return err
+		}
+
+		if err := validateCounter(request.GetCounter()); nil != err {
+			return err
+		}
+		if err := pending.add(request.GetCounter(), request.GetAmount()); nil != err {
+			return err
+		}
+	}
+}
+
+func (p *CounterServer) Get(ctx context.Context, request *GetRequest) (*CounterValue, error) {
+	counter := request.GetCounter()
+	if err := validateCounter(counter); nil != err {
+		return nil, err
+	}
+
+	values, err := p.readCounters([]*Counter{counter})
+	if nil != err {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+func (p *CounterServer) BatchGet(ctx context.Context, request *BatchGetRequest) (*BatchResponse, error) {
+	counters := request.GetCounters()
+	if len(counters) > MaxBatchSize {
+		return nil, status.Errorf(codes.InvalidArgument, "More than %d counters in one batch", MaxBatchSize)
+	}
+	for _, counter := range counters {
+		if err := validateCounter(counter); nil != err {
+			return nil, err
+		}
+	}
+
+	values, err := p.readCounters(counters)
+	if nil != err {
+		return nil, err
+	}
+	return &BatchResponse{Values: values}, nil
+}
+
+func (p *CounterServer) Watch(request *WatchRequest, stream CounterService_WatchServer) error {
+	counters := request.GetCounters()
+	switch {
+	case len(counters) == 0:
+		return status.Error(codes.InvalidArgument, "Empty counters")
+	case len(counters) > MaxBatchSize:
+		return status.Errorf(codes.InvalidArgument, "More than %d counters in one batch", MaxBatchSize)
+	}
+	for _, counter := range counters {
+		if err := validateCounter(counter); nil != err {
+			return err
+		}
+	}
+
+	interval := DefaultWatchInterval
+	if nil != request.GetInterval() {
+		interval = request.GetInterval().AsDuration()
+	}
+	if interval < MinWatchInterval {
+		interval = MinWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := make([]*CounterValue, len(counters))
+	for {
+		values, err := p.readCounters(counters)
+		if nil != err {
+			return err
+		}
+
+		for i, value := range values {
+			if nil == last[i] || last[i].Value != value.Value || last[i].Found != value.Found {
+				if err := stream.Send(value); nil != err {
+					return err
+				}
+			}
+			last[i] = value
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+//
+// Internal Helpers:
+//
+
+type counterKey struct {
+	key, field string
+}
+
+// Deltas summed per counter, in the order the counters were first seen
+type coalescedDeltas struct {
+	counters []*Counter
+	amounts  map[counterKey]int64
+}
+
+func makeCoalescedDeltas() *coalescedDeltas {
+	return &coalescedDeltas{amounts: make(map[counterKey]int64)}
+}
+
+func (p *coalescedDeltas) add(counter *Counter, amount int64) error {
+	key := counterKey{counter.GetKey(), counter.GetField()}
+	sum, ok := p.amounts[key]
+	switch {
+	case !ok && len(p.counters) >= MaxBatchSize:
+		return status.Errorf(codes.InvalidArgument, "More than %d counters in one batch", MaxBatchSize)
+	case (amount > 0 && sum > math.MaxInt64-amount) || (amount < 0 && sum < math.MinInt64-amount):
+		return status.Errorf(codes.InvalidArgument, "Delta of %s[%s] would overflow", counter.GetKey(), counter.GetField())
+	case !ok:
+		p.counters = append(p.counters, counter)
+	}
+	p.amounts[key] = sum + amount
+	return nil
+}
+
+func validateCounter(counter *Counter) error {
+	switch {
+	case nil == counter:
+		return status.Error(codes.InvalidArgument, "Nil counter")
+	case len(counter.GetKey()) == 0:
+		return status.Error(codes.InvalidArgument, "Empty redis key")
+	default:
+		return nil
+	}
+}
+
+// Unavailable for the errors IsRetryable classifies as transient,
+// Internal for the others
+func statusError(err error) error {
+	if redis_counter.IsRetryable(err) {
+		return status.Error(codes.Unavailable, err.Error())
+	}
+	return status.Error(codes.Internal, err.Error())
+}
+
+// The key or hash field counter of "counter"; "last" returns its LastValue
+func (p *CounterServer) makeCounter(counter *Counter) (redis_counter.TransactionCounter, func() *int64, error) {
+	if len(counter.GetField()) == 0 {
+		value, err := redis_counter.MakeRedisKeyCounterInt64(p.Redis, counter.GetKey())
+		if nil != err {
+			return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return value, func() *int64 { return value.LastValue }, nil
+	}
+
+	value, err := redis_counter.MakeRedisHashFieldCounterInt64(p.Redis, counter.GetKey(), counter.GetField())
+	if nil != err {
+		return nil, nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return value, func() *int64 { return value.LastValue }, nil
+}
+
+func lastValue(counter *Counter, last func() *int64) *CounterValue {
+	ptr := last()
+	if nil == ptr {
+		return &CounterValue{Counter: counter}
+	}
+	return &CounterValue{Counter: counter, Value: *ptr, Found: true}
+}
+
+// Get every counter in one pipeline
+func (p *CounterServer) readCounters(counters []*Counter) ([]*CounterValue, error) {
+	pipeline := &redis_counter.RedisPipeline{Redis: p.Redis}
+	results := make([]*redis_counter.PipelineResult, len(counters))
+	lasts := make([]func() *int64, len(counters))
+	for i, counter := range counters {
+		value, last, err := p.makeCounter(counter)
+		if nil != err {
+			return nil, err
+		}
+		results[i], lasts[i] = pipeline.Get(value), last
+	}
+
+	p.mutex.Lock()
+	err := pipeline.Exec()
+	p.mutex.Unlock()
+	if nil != err {
+		return nil, statusError(err)
+	}
+
+	values := make([]*CounterValue, len(counters))
+	for i, counter := range counters {
+		if err := results[i].Err(); nil != err {
+			return nil, statusError(err)
+		}
+		values[i] = lastValue(counter, lasts[i])
+	}
+	return values, nil
+}
+
+// Add every pending delta in one transaction
+func (p *CounterServer) applyDeltas(pending *coalescedDeltas) ([]*CounterValue, error) {
+	tx := &redis_counter.RedisTransaction{Redis: p.Redis}
+	lasts := make([]func() *int64, len(pending.counters))
+	for i, counter := range pending.counters {
+		value, last, err := p.makeCounter(counter)
+		if nil != err {
+			return nil, err
+		}
+		if err := tx.AddInt64(value, pending.amounts[counterKey{counter.GetKey(), counter.GetField()}]); nil != err {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		lasts[i] = last
+	}
+
+	p.mutex.Lock()
+	err := tx.Exec()
+	p.mutex.Unlock()
+	if nil != err {
+		return nil, statusError(err)
+	}
+
+	values := make([]*CounterValue, len(pending.counters))
+	for i, counter := range pending.counters {
+		values[i] = lastValue(counter, lasts[i])
+	}
+	return values, nil
+}
@@ -0,0 +1,242 @@
+package counter_service
+
+import "context"
+import "net"
+import "strconv"
+import "time"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "google.golang.org/grpc"
+import "google.golang.org/grpc/codes"
+import "google.golang.org/grpc/credentials/insecure"
+import "google.golang.org/grpc/status"
+import "google.golang.org/grpc/test/bufconn"
+import "google.golang.org/protobuf/types/known/durationpb"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestCounterServerSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(CounterServerSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+// Serve the CounterServer over an in-memory listener; returns the
+// client and a function to stop the server
+func startCounterServer(redis *dog_pool.RedisConnection) (CounterServiceClient, func()) {
+	value, err := MakeCounterServer(redis)
+	if nil != err {
+		panic(err)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	RegisterCounterServiceServer(server, value)
+	go server.Serve(listener)
+
+	dialer := func(context.Context, string) (net.Conn, error) { return listener.Dial() }
+	conn, err := grpc.NewClient("passthrough:///bufnet", grpc.WithContextDialer(dialer), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if nil != err {
+		panic(err)
+	}
+
+	return NewCounterServiceClient(conn), func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func CounterServerSpecs(c gospec.Context) {
+
+	c.Specify("[CounterServer][Make] Makes new instance", func() {
+		value, err := MakeCounterServer(nil)
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeCounterServer(&dog_pool.RedisConnection{})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[CounterServer][Increment] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		client, stop := startCounterServer(server.Connection())
+		defer stop()
+		ctx := context.Background()
+
+		value, err := client.Increment(ctx, &IncrementRequest{Counter: &Counter{Key: "Bob"}, Amount: 5})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.GetValue(), gospec.Equals, int64(5))
+		c.Expect(value.GetFound(), gospec.Equals, true)
+
+		value, err = client.Increment(ctx, &IncrementRequest{Counter: &Counter{Key: "Hash", Field: "Field"}, Amount: -2})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.GetValue(), gospec.Equals, int64(-2))
+
+		counter, _ := server.Connection().Cmd("HGET", "Hash", "Field").Int64()
+		c.Expect(counter, gospec.Equals, int64(-2))
+
+		_, err = client.Increment(ctx, &IncrementRequest{Counter: &Counter{}})
+		c.Expect(status.Code(err), gospec.Equals, codes.InvalidArgument)
+
+		// Transient errors can be retried:
+		down, stop_down := startCounterServer(&dog_pool.RedisConnection{Url: "127.0.0.1:1", Logger: &logger})
+		defer stop_down()
+		_, err = down.Increment(ctx, &IncrementRequest{Counter: &Counter{Key: "Bob"}, Amount: 1})
+		c.Expect(status.Code(err), gospec.Equals, codes.Unavailable)
+	})
+
+	c.Specify("[CounterServer][BatchIncrement] Coalesces deltas", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		client, stop := startCounterServer(server.Connection())
+		defer stop()
+
+		stream, err := client.BatchIncrement(context.Background())
+		c.Expect(err, gospec.Equals, nil)
+		stream.Send(&IncrementRequest{Counter: &Counter{Key: "Bob"}, Amount: 1})
+		stream.Send(&IncrementRequest{Counter: &Counter{Key: "Hash", Field: "A"}, Amount: 10})
+		stream.Send(&IncrementRequest{Counter: &Counter{Key: "Bob"}, Amount: 2})
+		stream.Send(&IncrementRequest{Counter: &Counter{Key: "Hash", Field: "A"}, Amount: 20})
+
+		response, err := stream.CloseAndRecv()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(response.GetValues()), gospec.Equals, 2)
+		c.Expect(response.GetValues()[0].GetCounter().GetKey(), gospec.Equals, "Bob")
+		c.Expect(response.GetValues()[0].GetValue(), gospec.Equals, int64(3))
+		c.Expect(response.GetValues()[1].GetCounter().GetField(), gospec.Equals, "A")
+		c.Expect(response.GetValues()[1].GetValue(), gospec.Equals, int64(30))
+
+		counter, _ := server.Connection().Cmd("GET", "Bob").Int64()
+		c.Expect(counter, gospec.Equals, int64(3))
+	})
+
+	c.Specify("[CounterServer][BatchIncrement] Rejects large batches before writing", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		client, stop := startCounterServer(server.Connection())
+		defer stop()
+
+		stream, err := client.BatchIncrement(context.Background())
+		c.Expect(err, gospec.Equals, nil)
+		for i := 0; i <= MaxBatchSize; i++ {
+			stream.Send(&IncrementRequest{Counter: &Counter{Key: "Hash", Field: strconv.Itoa(i)}, Amount: 1})
+		}
+
+		_, err = stream.CloseAndRecv()
+		c.Expect(status.Code(err), gospec.Equals, codes.InvalidArgument)
+
+		exists, _ := server.Connection().Cmd("EXISTS", "Hash").Int()
+		c.Expect(exists, gospec.Equals, 0)
+	})
+
+	c.Specify("[CounterServer][BatchGet] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		client, stop := startCounterServer(server.Connection())
+		defer stop()
+		ctx := context.Background()
+
+		server.Connection().Cmd("SET", "Bob", "7")
+		server.Connection().Cmd("HSET", "Hash", "A", "9")
+
+		value, err := client.Get(ctx, &GetRequest{Counter: &Counter{Key: "Bob"}})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.GetValue(), gospec.Equals, int64(7))
+		c.Expect(value.GetFound(), gospec.Equals, true)
+
+		response, err := client.BatchGet(ctx, &BatchGetRequest{Counters: []*Counter{{Key: "Hash", Field: "A"}, {Key: "Missing"}}})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(response.GetValues()[0].GetValue(), gospec.Equals, int64(9))
+		c.Expect(response.GetValues()[0].GetFound(), gospec.Equals, true)
+		c.Expect(response.GetValues()[1].GetValue(), gospec.Equals, int64(0))
+		c.Expect(response.GetValues()[1].GetFound(), gospec.Equals, false)
+
+		// Parsing error:
+		server.Connection().Cmd("SET", "Bob", "Gary")
+		_, err = client.Get(ctx, &GetRequest{Counter: &Counter{Key: "Bob"}})
+		c.Expect(status.Code(err), gospec.Equals, codes.Internal)
+
+		// Too many counters:
+		counters := make([]*Counter, MaxBatchSize+1)
+		for i := range counters {
+			counters[i] = &Counter{Key: strconv.Itoa(i)}
+		}
+		_, err = client.BatchGet(ctx, &BatchGetRequest{Counters: counters})
+		c.Expect(status.Code(err), gospec.Equals, codes.InvalidArgument)
+	})
+
+	c.Specify("[CounterServer][Watch] Streams changes", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		client, stop := startCounterServer(server.Connection())
+		defer stop()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		stream, err := client.Watch(ctx, &WatchRequest{Counters: []*Counter{{Key: "Bob"}}, Interval: durationpb.New(10 * time.Millisecond)})
+		c.Expect(err, gospec.Equals, nil)
+
+		value, err := stream.Recv()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.GetFound(), gospec.Equals, false)
+
+		client.Increment(ctx, &IncrementRequest{Counter: &Counter{Key: "Bob"}, Amount: 4})
+
+		value, err = stream.Recv()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.GetFound(), gospec.Equals, true)
+		c.Expect(value.GetValue(), gospec.Equals, int64(4))
+	})
+}
+
+func Benchmark_CounterServer_Increment(b *testing.B) {
+	logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+	server, err := dog_pool.StartRedisServer(&logger)
+	if nil != err {
+		panic(err)
+	}
+	defer server.Close()
+
+	client, stop := startCounterServer(server.Connection())
+	defer stop()
+	ctx := context.Background()
+	request := &IncrementRequest{Counter: &Counter{Key: "Bob"}, Amount: 1}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		client.Increment(ctx, request)
+	}
+}
@@ -0,0 +1,110 @@
+package redis_counter
+
+import "fmt"
+import "strconv"
+import "strings"
+
+// Largest scale of a Decimal; 10^18 is the largest power of 10 in an int64
+const MaxDecimalScale = 18
+
+// Fixed-point number equal to Units / 10^Scale, e.g. {1250, 2} is 12.50;
+// decimal counters store Units in Redis, so money adds up exactly
+type Decimal struct {
+	Units int64
+	Scale int
+}
+
+// Make a new instance of Decimal
+func MakeDecimal(units int64, scale int) (Decimal, error) {
+	switch {
+	case scale < 0 || scale > MaxDecimalScale:
+		return Decimal{}, fmt.Errorf("Invalid decimal scale: %d", scale)
+	default:
+		return Decimal{units, scale}, nil
+	}
+}
+
+// Parse a number like "-12.50"; the scale is the number of digits after
+// the decimal point
+func ParseDecimal(value string) (Decimal, error) {
+	digits := strings.TrimLeft(value, "+-")
+	integer, fraction, _ := strings.Cut(digits, ".")
+
+	switch {
+	case len(digits) < len(value)-1, len(integer) == 0 && len(fraction) == 0:
+		return Decimal{}, fmt.Errorf("Invalid decimal: %q", value)
+	case strings.Trim(integer+fraction, "0123456789") != "":
+		return Decimal{}, fmt.Errorf("Invalid decimal: %q", value)
+	case len(fraction) > MaxDecimalScale:
+		return Decimal{}, fmt.Errorf("Invalid decimal: %q has more than %d decimal places", value, MaxDecimalScale)
+	}
+
+	units, err := strconv.ParseInt(value[:len(value)-len(digits)]+integer+fraction, 10, 64)
+	if nil != err {
+		return Decimal{}, fmt.Errorf("Invalid decimal: %q is out of range", value)
+	}
+	return Decimal{units, len(fraction)}, nil
+}
+
+// Format the number with Scale digits after the decimal point
+func (d Decimal) String() string {
+	digits := strconv.FormatInt(d.Units, 10)
+	sign := ""
+	if d.Units < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	if d.Scale <= 0 {
+		return sign + digits
+	}
+
+	if len(digits) <= d.Scale {
+		digits = strings.Repeat("0", d.Scale-len(digits)+1) + digits
+	}
+	point := len(digits) - d.Scale
+	return sign + digits[:point] + "." + digits[point:]
+}
+
+// Nearest float64; for display and ratios, not for arithmetic
+func (d Decimal) Float64() float64 {
+	value, _ := strconv.ParseFloat(d.String(), 64)
+	return value
+}
+
+// The same number with "scale" digits after the decimal point; fails when
+// that would drop non-zero digits or overflow the int64
+func (d Decimal) Rescale(scale int) (Decimal, error) {
+	switch {
+	case scale < 0 || scale > MaxDecimalScale:
+		return Decimal{}, fmt.Errorf("Invalid decimal scale: %d", scale)
+
+	case d.Scale < 0 || d.Scale > MaxDecimalScale:
+		return Decimal{}, fmt.Errorf("Invalid decimal scale: %d", d.Scale)
+
+	case scale < d.Scale:
+		factor := pow10[d.Scale-scale]
+		if 0 != d.Units%factor {
+			return Decimal{}, fmt.Errorf("Decimal %s has more than %d decimal places", d, scale)
+		}
+		return Decimal{d.Units / factor, scale}, nil
+
+	case scale > d.Scale:
+		factor := pow10[scale-d.Scale]
+		units := d.Units * factor
+		if units/factor != d.Units {
+			return Decimal{}, fmt.Errorf("Decimal %s is out of range at %d decimal places", d, scale)
+		}
+		return Decimal{units, scale}, nil
+
+	default:
+		return d, nil
+	}
+}
+
+//
+// Internal Helpers:
+//
+
+var pow10 = [MaxDecimalScale + 1]int64{
+	1, 1e1, 1e2, 1e3, 1e4, 1e5, 1e6, 1e7, 1e8, 1e9,
+	1e10, 1e11, 1e12, 1e13, 1e14, 1e15, 1e16, 1e17, 1e18,
+}
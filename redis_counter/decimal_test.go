@@ -0,0 +1,86 @@
+package redis_counter
+
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestDecimalSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(DecimalSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func DecimalSpecs(c gospec.Context) {
+
+	c.Specify("[Decimal][Make] Makes new instance", func() {
+		_, err := MakeDecimal(1, -1)
+		c.Expect(err.Error(), gospec.Equals, "Invalid decimal scale: -1")
+
+		_, err = MakeDecimal(1, MaxDecimalScale+1)
+		c.Expect(err.Error(), gospec.Equals, "Invalid decimal scale: 19")
+
+		value, err := MakeDecimal(1250, 2)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Equals, Decimal{1250, 2})
+	})
+
+	c.Specify("[Decimal][Parse] Parses numbers", func() {
+		value, err := ParseDecimal("12.50")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Equals, Decimal{1250, 2})
+
+		value, err = ParseDecimal("-0.05")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Equals, Decimal{-5, 2})
+
+		value, err = ParseDecimal("+7")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Equals, Decimal{7, 0})
+
+		value, err = ParseDecimal(".5")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Equals, Decimal{5, 1})
+
+		for _, invalid := range []string{"", ".", "-", "--1", "1.2.3", "1e3", "0x10", " 1"} {
+			_, err = ParseDecimal(invalid)
+			c.Expect(err, gospec.Satisfies, nil != err)
+		}
+
+		_, err = ParseDecimal("92233720368547758.08")
+		c.Expect(err.Error(), gospec.Equals, `Invalid decimal: "92233720368547758.08" is out of range`)
+	})
+
+	c.Specify("[Decimal][String] Formats string", func() {
+		c.Expect(Decimal{1250, 2}.String(), gospec.Equals, "12.50")
+		c.Expect(Decimal{-5, 2}.String(), gospec.Equals, "-0.05")
+		c.Expect(Decimal{0, 4}.String(), gospec.Equals, "0.0000")
+		c.Expect(Decimal{42, 0}.String(), gospec.Equals, "42")
+		c.Expect(Decimal{-9223372036854775808, 18}.String(), gospec.Equals, "-9.223372036854775808")
+		c.Expect(Decimal{1250, 2}.Float64(), gospec.Equals, 12.5)
+	})
+
+	c.Specify("[Decimal][Rescale] Changes the scale exactly", func() {
+		value, err := Decimal{125, 1}.Rescale(3)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Equals, Decimal{12500, 3})
+
+		value, err = Decimal{12500, 3}.Rescale(1)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Equals, Decimal{125, 1})
+
+		_, err = Decimal{12501, 3}.Rescale(1)
+		c.Expect(err.Error(), gospec.Equals, "Decimal 12.501 has more than 1 decimal places")
+
+		_, err = Decimal{9223372036854775807, 0}.Rescale(1)
+		c.Expect(err.Error(), gospec.Equals, "Decimal 9223372036854775807 is out of range at 1 decimal places")
+
+		_, err = Decimal{1, 25}.Rescale(2)
+		c.Expect(err.Error(), gospec.Equals, "Invalid decimal scale: 25")
+
+		_, err = Decimal{1, -1}.Rescale(2)
+		c.Expect(err.Error(), gospec.Equals, "Invalid decimal scale: -1")
+	})
+}
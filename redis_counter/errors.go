@@ -0,0 +1,173 @@
+package redis_counter
+
+import "errors"
+import "fmt"
+import "io"
+import "net"
+import "strings"
+import "syscall"
+import "github.com/fzzy/radix/redis"
+
+// Returned by the constructors for a nil redis connection
+var ErrNilConnection = errors.New("Nil redis connection")
+
+// Returned by the constructors for an empty list of keys or fields
+var ErrNoKeys = errors.New("Empty redis keys")
+var ErrNoFields = errors.New("Empty redis fields")
+
+// Redis refused an increment because the result would overflow; wraps
+// the Redis error reply
+var ErrOverflow = errors.New("Increment or decrement would overflow")
+
+// Redis refused a command because the key holds another type, e.g. a
+// hash for a key counter; wraps the Redis error reply
+var ErrWrongType = errors.New("Operation against a key holding the wrong kind of value")
+
+// Returned by the constructors for an empty key or hash field
+type ErrEmptyKey struct {
+	Index int  // Position in the list of keys or fields, or -1 for a single one
+	Field bool // Empty hash field rather than key
+}
+
+func (e *ErrEmptyKey) Error() string {
+	name := "key"
+	if e.Field {
+		name = "field"
+	}
+
+	switch {
+	case e.Index < 0:
+		return fmt.Sprintf("Empty redis %s", name)
+	default:
+		return fmt.Sprintf("Empty redis %s[%d]", name, e.Index)
+	}
+}
+
+// A counter holds a value that doesn't parse as a number, or Redis
+// refused to increment it for that reason
+type ErrNotANumber struct {
+	Key   string
+	Field string // Empty for a key counter
+	Raw   string // Value read from Redis; empty when Redis refused the command
+	Err   error  // Parse error, or the Redis error reply
+}
+
+func (e *ErrNotANumber) Error() string {
+	location := e.Key
+	if len(e.Field) > 0 {
+		location = fmt.Sprintf("%s[%s]", e.Key, e.Field)
+	}
+
+	switch {
+	case len(e.Raw) > 0 && len(location) > 0:
+		return fmt.Sprintf("Not a number: %s = %q", location, e.Raw)
+	case len(e.Raw) > 0:
+		return fmt.Sprintf("Not a number: %q", e.Raw)
+	case len(location) > 0:
+		return fmt.Sprintf("Not a number: %s: %s", location, e.Err)
+	default:
+		return fmt.Sprintf("Not a number: %s", e.Err)
+	}
+}
+
+func (e *ErrNotANumber) Unwrap() error {
+	return e.Err
+}
+
+// Redis couldn't be reached, or the connection failed during a command;
+// wraps the network error
+type ErrConnection struct {
+	Err error
+}
+
+func (e *ErrConnection) Error() string {
+	return fmt.Sprintf("Redis connection error: %s", e.Err)
+}
+
+func (e *ErrConnection) Unwrap() error {
+	return e.Err
+}
+
+// A float64 counter holds a value past ±MaxExactFloat64, where it can no
+// longer count by 1
+type ErrInexact struct {
+	Key   string
+	Field string // Empty for a key counter
+	Value float64
+}
+
+func (e *ErrInexact) Error() string {
+	location := e.Key
+	if len(e.Field) > 0 {
+		location = fmt.Sprintf("%s[%s]", e.Key, e.Field)
+	}
+	return fmt.Sprintf("Inexact float64 counter: %s = %v is past 2^53", location, e.Value)
+}
+
+//
+// Internal Helpers:
+//
+
+// A Redis error reply classified by one of the sentinel errors; keeps the
+// message of the reply
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// Classify an error returned by Redis or the connection; "key" and
+// "field" locate ErrNotANumber errors. Network errors are wrapped in
+// ErrConnection, other errors that aren't error replies are returned as is.
+func redisError(err error, key, field string) error {
+	var cmd_err *redis.CmdError
+	var conn_err *ErrConnection
+	var nan_err *ErrNotANumber
+
+	switch {
+	case nil == err:
+		return nil
+	case errors.As(err, &conn_err), errors.As(err, &nan_err):
+		return err
+	case errors.Is(err, ErrWrongType), errors.Is(err, ErrOverflow):
+		return err
+	case !errors.As(err, &cmd_err) && isNetworkError(err):
+		return &ErrConnection{err}
+	case !errors.As(err, &cmd_err):
+		return err
+	}
+
+	message := cmd_err.Error()
+	switch {
+	case strings.HasPrefix(message, "WRONGTYPE"):
+		return &classifiedError{ErrWrongType, err}
+	case strings.Contains(message, "would overflow"):
+		return &classifiedError{ErrOverflow, err}
+	case strings.Contains(message, "not an integer"), strings.Contains(message, "not a valid float"):
+		return &ErrNotANumber{Key: key, Field: field, Err: err}
+	default:
+		return err
+	}
+}
+
+// The connection failed, closed or timed out
+func isNetworkError(err error) bool {
+	var net_err net.Error
+	switch {
+	case errors.As(err, &net_err):
+		return true
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, net.ErrClosed):
+		return true
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.EPIPE):
+		return true
+	default:
+		return false
+	}
+}
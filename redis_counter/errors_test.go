@@ -0,0 +1,114 @@
+package redis_counter
+
+import "errors"
+import "io"
+import "github.com/alecthomas/log4go"
+import "github.com/fzzy/radix/redis"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestErrorsSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(ErrorsSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func ErrorsSpecs(c gospec.Context) {
+
+	c.Specify("[Errors][Make] Constructors return typed errors", func() {
+		_, err := MakeRedisKeyCounterFloat64(nil, "Bob")
+		c.Expect(errors.Is(err, ErrNilConnection), gospec.Equals, true)
+
+		_, err = MakeRedisHashMFieldsCounterInt64(&dog_pool.RedisConnection{}, "Bob")
+		c.Expect(errors.Is(err, ErrNoFields), gospec.Equals, true)
+
+		var empty *ErrEmptyKey
+		_, err = MakeRedisMKeysCounterInt64(&dog_pool.RedisConnection{}, "Bob", "")
+		c.Expect(errors.As(err, &empty), gospec.Equals, true)
+		c.Expect(empty.Index, gospec.Equals, 1)
+		c.Expect(empty.Field, gospec.Equals, false)
+
+		_, err = MakeRedisHashFieldCounterInt64(&dog_pool.RedisConnection{}, "Bob", "")
+		c.Expect(errors.As(err, &empty), gospec.Equals, true)
+		c.Expect(empty.Index, gospec.Equals, -1)
+		c.Expect(empty.Field, gospec.Equals, true)
+		c.Expect(err.Error(), gospec.Equals, "Empty redis field")
+	})
+
+	c.Specify("[Errors][ErrNotANumber] Formats the location and raw value", func() {
+		err := &ErrNotANumber{Key: "Bob", Field: "Gary", Raw: "abc", Err: io.EOF}
+		c.Expect(err.Error(), gospec.Equals, "Not a number: Bob[Gary] = \"abc\"")
+		c.Expect(errors.Is(err, io.EOF), gospec.Equals, true)
+
+		err = &ErrNotANumber{Key: "Bob", Err: io.EOF}
+		c.Expect(err.Error(), gospec.Equals, "Not a number: Bob: EOF")
+	})
+
+	c.Specify("[Errors][redisError] Classifies errors", func() {
+		c.Expect(redisError(nil, "Bob", ""), gospec.Equals, nil)
+
+		var conn_err *ErrConnection
+		err := redisError(io.EOF, "Bob", "")
+		c.Expect(errors.As(err, &conn_err), gospec.Equals, true)
+		c.Expect(errors.Is(err, io.EOF), gospec.Equals, true)
+		c.Expect(redisError(err, "Bob", ""), gospec.Equals, err)
+
+		other_err := errors.New("Nil amount")
+		c.Expect(redisError(other_err, "Bob", ""), gospec.Equals, other_err)
+
+		reply_err := &redis.CmdError{Err: errors.New("ERR syntax error")}
+		c.Expect(redisError(reply_err, "Bob", ""), gospec.Equals, reply_err)
+	})
+
+	c.Specify("[Errors][Redis] Wraps the Redis error replies", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		var nan_err *ErrNotANumber
+		var cmd_err *redis.CmdError
+
+		// Stored value isn't a number:
+		server.Connection().Cmd("SET", "Bob", "Gary")
+		key, _ := MakeRedisKeyCounterInt64(server.Connection(), "Bob")
+		_, err := key.Get()
+		c.Expect(errors.As(err, &nan_err), gospec.Equals, true)
+		c.Expect(nan_err.Key, gospec.Equals, "Bob")
+		c.Expect(nan_err.Raw, gospec.Equals, "Gary")
+
+		// Redis refuses to increment it:
+		_, err = key.Add(1)
+		c.Expect(errors.As(err, &nan_err), gospec.Equals, true)
+		c.Expect(nan_err.Raw, gospec.Equals, "")
+		c.Expect(errors.As(err, &cmd_err), gospec.Equals, true)
+
+		// Hash field:
+		server.Connection().Cmd("HSET", "Hash", "Gary", "abc")
+		field, _ := MakeRedisHashFieldCounterFloat64(server.Connection(), "Hash", "Gary")
+		_, err = field.Get()
+		c.Expect(errors.As(err, &nan_err), gospec.Equals, true)
+		c.Expect(nan_err.Key, gospec.Equals, "Hash")
+		c.Expect(nan_err.Field, gospec.Equals, "Gary")
+
+		// Key holds a hash:
+		wrong, _ := MakeRedisKeyCounterFloat64(server.Connection(), "Hash")
+		_, err = wrong.Get()
+		c.Expect(errors.Is(err, ErrWrongType), gospec.Equals, true)
+		c.Expect(errors.As(err, &cmd_err), gospec.Equals, true)
+
+		// Overflow:
+		server.Connection().Cmd("SET", "Max", "9223372036854775807")
+		keys, _ := MakeRedisMKeysCounterInt64(server.Connection(), "Max")
+		_, err = keys.MIncrement()
+		c.Expect(errors.Is(err, ErrOverflow), gospec.Equals, true)
+		c.Expect(errors.As(err, &cmd_err), gospec.Equals, true)
+	})
+}
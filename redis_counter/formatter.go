@@ -0,0 +1,136 @@
+package redis_counter
+
+import "encoding/json"
+import "fmt"
+import "math"
+import "strconv"
+import "strings"
+
+// How the String methods of the counters format their values; set a
+// counter's "Format" field, or leave it nil for DefaultFormatter:
+//
+//	value.Format, _ = MakeFormatter('g', -1, "-")
+//	value.String() // "Bob = 1.5e-07"
+//
+// With JSON set, the output is a JSON object and missing values are null:
+//
+//	{"Bob":1.5e-07}
+//	{"Key":{"Bob":1,"Gary":null}}
+type Formatter struct {
+	Float     byte   // 'f', 'e' or 'g', as in strconv.FormatFloat
+	Precision int    // As in strconv.FormatFloat; -1 for the fewest digits that read back exactly
+	Missing   string // Marker for a missing value
+	JSON      bool
+}
+
+// Formatter of the counters without one
+var DefaultFormatter = &Formatter{Float: 'f', Precision: 6, Missing: "NaN"}
+
+// Make a new instance of Formatter
+func MakeFormatter(float byte, precision int, missing string) (*Formatter, error) {
+	switch {
+	case float != 'f' && float != 'e' && float != 'g':
+		return nil, fmt.Errorf("Invalid float format: %q", float)
+	case precision < -1:
+		return nil, fmt.Errorf("Invalid precision: %d", precision)
+	default:
+		return &Formatter{Float: float, Precision: precision, Missing: missing}, nil
+	}
+}
+
+// Format an int64 value, or the missing marker for nil
+func (f *Formatter) Int64(value *int64) string {
+	switch {
+	case nil == value && f.JSON:
+		return "null"
+	case nil == value:
+		return f.Missing
+	default:
+		return strconv.FormatInt(*value, 10)
+	}
+}
+
+// Format a float64 value, or the missing marker for nil
+func (f *Formatter) Float64(value *float64) string {
+	switch {
+	case nil == value && f.JSON:
+		return "null"
+	case nil == value:
+		return f.Missing
+	case f.JSON && (math.IsNaN(*value) || math.IsInf(*value, 0)):
+		return "null"
+	default:
+		return strconv.FormatFloat(*value, f.Float, f.Precision, 64)
+	}
+}
+
+// Format a decimal value with its own scale, or the missing marker for nil
+func (f *Formatter) Decimal(value *Decimal) string {
+	switch {
+	case nil == value && f.JSON:
+		return "null"
+	case nil == value:
+		return f.Missing
+	default:
+		return value.String()
+	}
+}
+
+//
+// Internal Helpers:
+//
+
+// "f", or DefaultFormatter when nil
+func formatterOrDefault(f *Formatter) *Formatter {
+	if nil == f {
+		return DefaultFormatter
+	}
+	return f
+}
+
+// Format a counter at "key": "key = value"
+func (f *Formatter) formatKey(key, value string) string {
+	if f.JSON {
+		return fmt.Sprintf("{%s:%s}", jsonString(key), value)
+	}
+	return fmt.Sprintf("%s = %s", key, value)
+}
+
+// Format a counter at "field" of the hash at "key": "key[field] = value"
+func (f *Formatter) formatField(key, field, value string) string {
+	if f.JSON {
+		return fmt.Sprintf("{%s:%s}", jsonString(key), f.formatKey(field, value))
+	}
+	return fmt.Sprintf("%s[%s] = %s", key, field, value)
+}
+
+// Format counters at "names": "name = value, name = value"
+func (f *Formatter) formatKeys(names, values []string) string {
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		if f.JSON {
+			pairs[i] = fmt.Sprintf("%s:%s", jsonString(name), values[i])
+		} else {
+			pairs[i] = fmt.Sprintf("%s = %s", name, values[i])
+		}
+	}
+
+	if f.JSON {
+		return "{" + strings.Join(pairs, ",") + "}"
+	}
+	return strings.Join(pairs, ", ")
+}
+
+// Format counters at "fields" of the hash at "key":
+// "key[field = value, field = value]"
+func (f *Formatter) formatFields(key string, fields, values []string) string {
+	if f.JSON {
+		return fmt.Sprintf("{%s:%s}", jsonString(key), f.formatKeys(fields, values))
+	}
+	return fmt.Sprintf("%s[%s]", key, f.formatKeys(fields, values))
+}
+
+func jsonString(value string) string {
+	bytes, _ := json.Marshal(value)
+	return string(bytes)
+}
@@ -0,0 +1,139 @@
+package redis_counter
+
+import "math"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+import . "github.com/gnagel/go_map_to_ptrs/map_to_ptrs"
+
+func TestFormatterSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(FormatterSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func FormatterSpecs(c gospec.Context) {
+
+	c.Specify("[Formatter][Make] Makes new instance", func() {
+		_, err := MakeFormatter('x', 2, "NaN")
+		c.Expect(err.Error(), gospec.Equals, "Invalid float format: 'x'")
+
+		_, err = MakeFormatter('f', -2, "NaN")
+		c.Expect(err.Error(), gospec.Equals, "Invalid precision: -2")
+
+		value, err := MakeFormatter('g', -1, "-")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(*value, gospec.Equals, Formatter{Float: 'g', Precision: -1, Missing: "-"})
+	})
+
+	c.Specify("[Formatter][Int64] Formats int64", func() {
+		value := int64(-123)
+		f := &Formatter{Float: 'f', Precision: 2, Missing: "-"}
+		c.Expect(f.Int64(&value), gospec.Equals, "-123")
+		c.Expect(f.Int64(nil), gospec.Equals, "-")
+
+		f.JSON = true
+		c.Expect(f.Int64(&value), gospec.Equals, "-123")
+		c.Expect(f.Int64(nil), gospec.Equals, "null")
+	})
+
+	c.Specify("[Formatter][Float64] Formats float64", func() {
+		value := 0.00000015
+		c.Expect(DefaultFormatter.Float64(&value), gospec.Equals, "0.000000")
+		c.Expect((&Formatter{Float: 'f', Precision: 2}).Float64(&value), gospec.Equals, "0.00")
+		c.Expect((&Formatter{Float: 'e', Precision: 3}).Float64(&value), gospec.Equals, "1.500e-07")
+		c.Expect((&Formatter{Float: 'g', Precision: -1}).Float64(&value), gospec.Equals, "1.5e-07")
+		c.Expect((&Formatter{Missing: "?"}).Float64(nil), gospec.Equals, "?")
+
+		nan := math.NaN()
+		inf := math.Inf(-1)
+		f := &Formatter{Float: 'g', Precision: -1, JSON: true}
+		c.Expect(f.Float64(&value), gospec.Equals, "1.5e-07")
+		c.Expect(f.Float64(nil), gospec.Equals, "null")
+		c.Expect(f.Float64(&nan), gospec.Equals, "null")
+		c.Expect(f.Float64(&inf), gospec.Equals, "null")
+	})
+
+	c.Specify("[Formatter][String] Formats key counters", func() {
+		value := int64(123)
+		counter := &RedisKeyCounterInt64{KEY: "Bob"}
+		c.Expect(counter.String(), gospec.Equals, "Bob = NaN")
+
+		counter.LastValue = &value
+		counter.Format = &Formatter{JSON: true}
+		c.Expect(counter.String(), gospec.Equals, `{"Bob":123}`)
+
+		amount := 123.456
+		float_counter := &RedisKeyCounterFloat64{KEY: "Bob", LastValue: &amount}
+		c.Expect(float_counter.String(), gospec.Equals, "Bob = 123.456000")
+
+		float_counter.Format = &Formatter{Float: 'e', Precision: 2, Missing: "-"}
+		c.Expect(float_counter.String(), gospec.Equals, "Bob = 1.23e+02")
+
+		float_counter.LastValue = nil
+		c.Expect(float_counter.String(), gospec.Equals, "Bob = -")
+
+		decimal_counter := &RedisKeyCounterDecimal{KEY: "Bob", SCALE: 2, LastValue: &Decimal{1250, 2}}
+		decimal_counter.Format = &Formatter{JSON: true}
+		c.Expect(decimal_counter.String(), gospec.Equals, `{"Bob":12.50}`)
+	})
+
+	c.Specify("[Formatter][String] Formats hash field counters", func() {
+		value := int64(123)
+		counter := &RedisHashFieldCounterInt64{KEY: "Bob", FIELD: "Field", LastValue: &value}
+		c.Expect(counter.String(), gospec.Equals, "Bob[Field] = 123")
+
+		counter.Format = &Formatter{JSON: true}
+		c.Expect(counter.String(), gospec.Equals, `{"Bob":{"Field":123}}`)
+
+		amount := 1.5
+		float_counter := &RedisHashFieldCounterFloat64{KEY: "Bob", FIELD: "Field", LastValue: &amount}
+		float_counter.Format = &Formatter{Float: 'g', Precision: -1, JSON: true}
+		c.Expect(float_counter.String(), gospec.Equals, `{"Bob":{"Field":1.5}}`)
+
+		decimal_counter := &RedisHashFieldCounterDecimal{KEY: "Bob", FIELD: "Field", SCALE: 2}
+		decimal_counter.Format = &Formatter{Missing: "-"}
+		c.Expect(decimal_counter.String(), gospec.Equals, "Bob[Field] = -")
+
+		decimal_counter.LastValue = &Decimal{-1999, 2}
+		decimal_counter.Format.JSON = true
+		c.Expect(decimal_counter.String(), gospec.Equals, `{"Bob":{"Field":-19.99}}`)
+	})
+
+	c.Specify("[Formatter][String] Formats multi-key counters", func() {
+		value := int64(123)
+		counter := &RedisMKeysCounterInt64{KEYS: []string{"Bob", "Gary", "\"Quoted\""}, Cache: MakeMapStringToInt64Ptrs(3)}
+		counter.Cache.Set("Bob", &value)
+		c.Expect(counter.String(), gospec.Equals, `Bob = 123, Gary = NaN, "Quoted" = NaN`)
+
+		counter.Format = &Formatter{JSON: true}
+		c.Expect(counter.String(), gospec.Equals, `{"Bob":123,"Gary":null,"\"Quoted\"":null}`)
+
+		amount := 0.25
+		float_counter := &RedisHashMFieldsCounterFloat64{KEY: "Key", FIELDS: []string{"Bob", "Gary"}, Cache: MakeMapStringToFloat64Ptrs(2)}
+		float_counter.Cache.Set("Gary", &amount)
+		float_counter.Format = &Formatter{Float: 'f', Precision: 1, Missing: "-"}
+		c.Expect(float_counter.String(), gospec.Equals, "Key[Bob = -, Gary = 0.2]")
+
+		float_counter.Format = &Formatter{Float: 'g', Precision: -1, JSON: true}
+		c.Expect(float_counter.String(), gospec.Equals, `{"Key":{"Bob":null,"Gary":0.25}}`)
+	})
+
+	c.Specify("[Formatter][String] Formats hash counters in discovery order", func() {
+		one, two := int64(1), int64(2)
+		counter := &RedisHashCounterInt64{KEY: "Key", Cache: MakeMapStringToInt64Ptrs(0)}
+		counter.cacheSet("Gary", &two)
+		counter.cacheSet("Bob", &one)
+		counter.cacheSet("Gary", nil)
+		c.Expect(counter.String(), gospec.Equals, "Key[Gary = NaN, Bob = 1]")
+
+		counter.Format = &Formatter{JSON: true}
+		c.Expect(counter.String(), gospec.Equals, `{"Key":{"Gary":null,"Bob":1}}`)
+
+		counter.CacheReset()
+		c.Expect(counter.String(), gospec.Equals, `{"Key":{}}`)
+	})
+}
@@ -0,0 +1,330 @@
+package redis_counter
+
+import "fmt"
+import "github.com/fzzy/radix/redis"
+import "github.com/gnagel/dog_pool/dog_pool"
+import . "github.com/gnagel/go_map_to_ptrs/map_to_ptrs"
+
+// Counters in every field of a hash, for hashes with open-ended fields;
+// the fields are discovered with HGETALL or HSCAN
+type RedisHashCounterFloat64 struct {
+	Redis  dog_pool.RedisClientInterface
+	KEY    string
+	Cache  MapStringToFloat64Ptrs
+	Format *Formatter // nil for DefaultFormatter
+
+	fields []string // Cached fields, in the order they were discovered
+}
+
+// Make a new instance of RedisHashCounterFloat64
+func MakeRedisHashCounterFloat64(redis dog_pool.RedisClientInterface, key string) (*RedisHashCounterFloat64, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	case len(key) == 0:
+		return nil, &ErrEmptyKey{Index: -1}
+	default:
+		return &RedisHashCounterFloat64{
+			Redis: redis,
+			KEY:   key,
+			Cache: MakeMapStringToFloat64Ptrs(0),
+		}, nil
+	}
+}
+
+// Forget the discovered fields
+func (p *RedisHashCounterFloat64) CacheReset() {
+	p.Cache = MakeMapStringToFloat64Ptrs(0)
+	p.fields = nil
+}
+
+// Format the values as a string with "Format", in the order the fields
+// were discovered; uses the cached fields
+func (p *RedisHashCounterFloat64) String() string {
+	f := formatterOrDefault(p.Format)
+	values := make([]string, len(p.fields))
+	for i, field := range p.fields {
+		values[i] = f.Float64(p.Cache.Value(field))
+	}
+	return f.formatFields(p.KEY, p.fields, values)
+}
+
+// Counter for one field of the hash
+func (p *RedisHashCounterFloat64) Field(field string) (*RedisHashFieldCounterFloat64, error) {
+	return MakeRedisHashFieldCounterFloat64(p.Redis, p.KEY, field)
+}
+
+func (p *RedisHashCounterFloat64) Exists() (bool, error) {
+	reply := p.Redis.Cmd("EXISTS", p.KEY)
+	if nil != reply.Err {
+		return false, redisError(reply.Err, p.KEY, "")
+	}
+
+	ok, err := reply.Int()
+	if err != nil {
+		return false, err
+	}
+
+	return ok == 1, nil
+}
+
+func (p *RedisHashCounterFloat64) Delete() error {
+	p.CacheReset()
+	reply := p.Redis.Cmd("DEL", p.KEY)
+	return redisError(reply.Err, p.KEY, "")
+}
+
+// Number of fields in the hash
+func (p *RedisHashCounterFloat64) FieldCount() (int64, error) {
+	reply := p.Redis.Cmd("HLEN", p.KEY)
+	if nil != reply.Err {
+		return 0, redisError(reply.Err, p.KEY, "")
+	}
+	return reply.Int64()
+}
+
+// Get every field of the hash; replaces the cache with the fields
+func (p *RedisHashCounterFloat64) GetAll() (map[string]float64, error) {
+	p.CacheReset()
+
+	reply := p.Redis.Cmd("HGETALL", p.KEY)
+	if nil != reply.Err {
+		return nil, redisError(reply.Err, p.KEY, "")
+	}
+
+	values := make(map[string]float64, len(reply.Elems)/2)
+	err := p.cacheFields(reply.Elems, func(field string, value float64) error {
+		values[field] = value
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+// Get one page of fields matching "match" with HSCAN, starting from
+// "cursor" ("0" for the first page); returns the next cursor, which is
+// "0" after the last page. "count" is a hint for the page size, or 0 for
+// the Redis default. Saves the fields to the cache.
+func (p *RedisHashCounterFloat64) Scan(cursor, match string, count int) (string, map[string]float64, error) {
+	values := make(map[string]float64)
+	next, err := p.operationScansFields(cursor, match, count, func(field string, value float64) error {
+		values[field] = value
+		return nil
+	})
+	if nil != err {
+		return "", nil, err
+	}
+	return next, values, nil
+}
+
+// Call "fn" with every field matching "match", one HSCAN page at a time;
+// stops at the first error from "fn". A field may be passed more than
+// once if the hash changes during the scan. Saves the fields to the cache.
+func (p *RedisHashCounterFloat64) Iterate(match string, count int, fn func(field string, value float64) error) error {
+	cursor := "0"
+	for {
+		next, err := p.operationScansFields(cursor, match, count, fn)
+		switch {
+		case nil != err:
+			return err
+		case next == "0":
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// Delete every field matching "match"; returns the number of fields
+// deleted. Fields added during the scan may be missed.
+func (p *RedisHashCounterFloat64) DeleteMatching(match string) (int64, error) {
+	deleted := int64(0)
+	cursor := "0"
+	for {
+		next, fields, err := p.scanFields(cursor, match, 0)
+		if nil != err {
+			return deleted, err
+		}
+
+		if len(fields) > 0 {
+			reply := p.Redis.Cmd("HDEL", p.KEY, fields)
+			if nil != reply.Err {
+				return deleted, redisError(reply.Err, p.KEY, "")
+			}
+
+			count, err := reply.Int64()
+			if nil != err {
+				return deleted, err
+			}
+			deleted += count
+
+			for _, field := range fields {
+				p.cacheSet(field, nil)
+			}
+		}
+
+		if next == "0" {
+			return deleted, nil
+		}
+		cursor = next
+	}
+}
+
+// Encode the key and the cached fields as JSON; a missing counter is null
+func (p *RedisHashCounterFloat64) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key and the cached fields from JSON
+func (p *RedisHashCounterFloat64) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotFloat64, shapeFields)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisHashCounterFloat64) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key and the cached fields in the binary encoding
+func (p *RedisHashCounterFloat64) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key and the cached fields from the binary encoding
+func (p *RedisHashCounterFloat64) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotFloat64, shapeFields)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+//
+// Internal Helpers:
+//
+
+// Parse field/value pairs into the cache, calling "fn" for each field
+func (p *RedisHashCounterFloat64) cacheFields(elems []*redis.Reply, fn func(field string, value float64) error) error {
+	for i := 0; i+1 < len(elems); i += 2 {
+		field, err := elems[i].Str()
+		if nil != err {
+			return err
+		}
+
+		ptr, err := toFloat64Ptr(elems[i+1], p.KEY, field)
+		switch {
+		case nil != err:
+			return err
+		case nil == ptr:
+			continue
+		}
+
+		p.cacheSet(field, ptr)
+		if err := fn(field, *ptr); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *RedisHashCounterFloat64) hscan(cursor, match string, count int) (string, []*redis.Reply, error) {
+	args := []interface{}{p.KEY, cursor}
+	if len(match) > 0 {
+		args = append(args, "MATCH", match)
+	}
+	if count > 0 {
+		args = append(args, "COUNT", count)
+	}
+
+	reply := p.Redis.Cmd("HSCAN", args...)
+	switch {
+	case nil != reply.Err:
+		return "", nil, redisError(reply.Err, p.KEY, "")
+	case len(reply.Elems) != 2:
+		return "", nil, fmt.Errorf("Invalid HSCAN reply for %s", p.KEY)
+	}
+
+	next, err := reply.Elems[0].Str()
+	if nil != err {
+		return "", nil, err
+	}
+	return next, reply.Elems[1].Elems, nil
+}
+
+func (p *RedisHashCounterFloat64) operationScansFields(cursor, match string, count int, fn func(field string, value float64) error) (string, error) {
+	next, elems, err := p.hscan(cursor, match, count)
+	if nil != err {
+		return "", err
+	}
+
+	if err := p.cacheFields(elems, fn); nil != err {
+		return "", err
+	}
+	return next, nil
+}
+
+// Names of the fields in one HSCAN page, without parsing the values
+func (p *RedisHashCounterFloat64) scanFields(cursor, match string, count int) (string, []string, error) {
+	next, elems, err := p.hscan(cursor, match, count)
+	if nil != err {
+		return "", nil, err
+	}
+
+	fields := make([]string, 0, len(elems)/2)
+	for i := 0; i+1 < len(elems); i += 2 {
+		field, err := elems[i].Str()
+		if nil != err {
+			return "", nil, err
+		}
+		fields = append(fields, field)
+	}
+	return next, fields, nil
+}
+
+// Save "ptr" to the cache, remembering the order of new fields
+func (p *RedisHashCounterFloat64) cacheSet(field string, ptr *float64) {
+	size := p.Cache.Len()
+	p.Cache.Set(field, ptr)
+	if p.Cache.Len() > size {
+		p.fields = append(p.fields, field)
+	}
+}
+
+// Identity and cached fields of the counters, for their encodings
+func (p *RedisHashCounterFloat64) state() *counterState {
+	values := make([]*string, len(p.fields))
+	for i, field := range p.fields {
+		values[i] = float64Text(p.Cache.Value(field))
+	}
+	return &counterState{
+		Type:   SnapshotFloat64,
+		Shape:  shapeFields,
+		Key:    p.KEY,
+		Names:  p.fields,
+		Values: values,
+	}
+}
+
+func (p *RedisHashCounterFloat64) setState(s *counterState) error {
+	values := make([]*float64, len(s.Values))
+	for i, text := range s.Values {
+		value, err := textFloat64(text)
+		if nil != err {
+			return err
+		}
+		values[i] = value
+	}
+
+	p.KEY = s.Key
+	p.CacheReset()
+	for i, field := range s.Names {
+		p.cacheSet(field, values[i])
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package redis_counter
+
+import "sort"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisHashCounterInt64Specs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisHashCounterInt64Specs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisHashCounterInt64Specs(c gospec.Context) {
+
+	c.Specify("[RedisHashCounterInt64][Make] Makes new instance", func() {
+		value, err := MakeRedisHashCounterInt64(nil, "")
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisHashCounterInt64(&dog_pool.RedisConnection{}, "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisHashCounterInt64(&dog_pool.RedisConnection{}, "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[RedisHashCounterInt64][GetAll] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashCounterInt64(server.Connection(), "Bob")
+
+		values, err := value.GetAll()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(values), gospec.Equals, 0)
+
+		server.Connection().Cmd("HMSET", "Bob", "us", "1", "uk", "2", "fr", "3")
+		values, err = value.GetAll()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(values), gospec.Equals, 3)
+		c.Expect(values["us"], gospec.Equals, int64(1))
+		c.Expect(values["uk"], gospec.Equals, int64(2))
+		c.Expect(values["fr"], gospec.Equals, int64(3))
+		c.Expect(*value.Cache.Value("uk"), gospec.Equals, int64(2))
+
+		count, err := value.FieldCount()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(count, gospec.Equals, int64(3))
+
+		// Parsing error:
+		server.Connection().Cmd("HSET", "Bob", "de", "Gary")
+		_, err = value.GetAll()
+		c.Expect(err, gospec.Satisfies, nil != err)
+	})
+
+	c.Specify("[RedisHashCounterInt64][Iterate] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashCounterInt64(server.Connection(), "Bob")
+		server.Connection().Cmd("HMSET", "Bob", "api:get", "1", "api:put", "2", "web:get", "3")
+
+		fields := []string{}
+		err := value.Iterate("api:*", 1, func(field string, amount int64) error {
+			fields = append(fields, field)
+			return nil
+		})
+		c.Expect(err, gospec.Equals, nil)
+		sort.Strings(fields)
+		c.Expect(fields, gospec.ContainsInOrder, gospec.Values("api:get", "api:put"))
+		c.Expect(*value.Cache.Value("api:put"), gospec.Equals, int64(2))
+
+		next, values, err := value.Scan("0", "", 10)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(next, gospec.Equals, "0")
+		c.Expect(len(values), gospec.Equals, 3)
+		c.Expect(values["api:get"], gospec.Equals, int64(1))
+		c.Expect(values["api:put"], gospec.Equals, int64(2))
+		c.Expect(values["web:get"], gospec.Equals, int64(3))
+	})
+
+	c.Specify("[RedisHashCounterInt64][DeleteMatching] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashCounterInt64(server.Connection(), "Bob")
+		server.Connection().Cmd("HMSET", "Bob", "api:get", "1", "api:put", "2", "web:get", "3")
+		value.GetAll()
+
+		deleted, err := value.DeleteMatching("api:*")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(deleted, gospec.Equals, int64(2))
+		c.Expect(value.Cache.Value("api:get"), gospec.Satisfies, nil == value.Cache.Value("api:get"))
+
+		values, _ := value.GetAll()
+		c.Expect(len(values), gospec.Equals, 1)
+		c.Expect(values["web:get"], gospec.Equals, int64(3))
+
+		field, _ := value.Field("web:get")
+		amount, _ := field.Add(1)
+		c.Expect(amount, gospec.Equals, int64(4))
+
+		value.Delete()
+		ok, _ := value.Exists()
+		c.Expect(ok, gospec.Equals, false)
+	})
+}
@@ -0,0 +1,188 @@
+package redis_counter
+
+import "fmt"
+import "math/big"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Counter of unbounded integers in the hash FIELD, stored as a decimal
+// string and added to atomically by a Lua script; for totals that outgrow
+// an int64
+type RedisHashFieldCounterBigInt struct {
+	Redis      dog_pool.RedisClientInterface
+	KEY, FIELD string
+	LastValue  *big.Int
+}
+
+// Make a new instance of RedisHashFieldCounterBigInt
+func MakeRedisHashFieldCounterBigInt(redis dog_pool.RedisClientInterface, key, field string) (*RedisHashFieldCounterBigInt, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	case len(key) == 0:
+		return nil, &ErrEmptyKey{Index: -1}
+	case len(field) == 0:
+		return nil, &ErrEmptyKey{Index: -1, Field: true}
+	default:
+		return &RedisHashFieldCounterBigInt{redis, key, field, nil}, nil
+	}
+}
+
+// Format the value as a string; uses the cached "LastValue" field
+func (p *RedisHashFieldCounterBigInt) String() string {
+	switch p.LastValue {
+	case nil:
+		return fmt.Sprintf("%s[%s] = NaN", p.KEY, p.FIELD)
+	default:
+		return fmt.Sprintf("%s[%s] = %s", p.KEY, p.FIELD, p.LastValue)
+	}
+}
+
+// Get the value of the counter; saves the counter to "LastValue"
+func (p *RedisHashFieldCounterBigInt) BigInt() (*big.Int, error) {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("HGET", p.KEY, p.FIELD)
+	ptr, err := toBigIntPtr(reply, p.KEY, p.FIELD)
+	if nil != err {
+		return new(big.Int), err
+	}
+	p.LastValue = ptr
+	return valueOrZeroBigInt(ptr), nil
+}
+
+func (p *RedisHashFieldCounterBigInt) Exists() (bool, error) {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("HEXISTS", p.KEY, p.FIELD)
+	if nil != reply.Err {
+		return false, redisError(reply.Err, p.KEY, p.FIELD)
+	}
+
+	ok, err := reply.Int()
+	if err != nil {
+		return false, err
+	}
+
+	return ok == 1, nil
+}
+
+func (p *RedisHashFieldCounterBigInt) Delete() error {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("HDEL", p.KEY, p.FIELD)
+	return redisError(reply.Err, p.KEY, p.FIELD)
+}
+
+func (p *RedisHashFieldCounterBigInt) Get() (*big.Int, error) {
+	return p.BigInt()
+}
+
+// Get the value of the counter and whether it exists, so a missing
+// counter can be told from one holding 0; saves the counter to "LastValue"
+func (p *RedisHashFieldCounterBigInt) GetOptional() (*big.Int, bool, error) {
+	value, err := p.BigInt()
+	return value, nil == err && nil != p.LastValue, err
+}
+
+func (p *RedisHashFieldCounterBigInt) Set(amount *big.Int) (*big.Int, error) {
+	p.LastValue = nil
+	if nil == amount {
+		return new(big.Int), fmt.Errorf("Nil amount")
+	}
+
+	reply := p.Redis.Cmd("HSET", p.KEY, p.FIELD, amount.String())
+	if nil != reply.Err {
+		return new(big.Int), redisError(reply.Err, p.KEY, p.FIELD)
+	}
+	p.LastValue = new(big.Int).Set(amount)
+	return p.LastValue, nil
+}
+
+func (p *RedisHashFieldCounterBigInt) Add(amount *big.Int) (*big.Int, error) {
+	if nil == amount {
+		p.LastValue = nil
+		return new(big.Int), fmt.Errorf("Nil amount")
+	}
+	return p.operationModifiesAmount(amount.String())
+}
+
+func (p *RedisHashFieldCounterBigInt) Sub(amount *big.Int) (*big.Int, error) {
+	if nil == amount {
+		p.LastValue = nil
+		return new(big.Int), fmt.Errorf("Nil amount")
+	}
+	return p.operationModifiesAmount(new(big.Int).Neg(amount).String())
+}
+
+func (p *RedisHashFieldCounterBigInt) Increment() (*big.Int, error) {
+	return p.operationModifiesAmount("1")
+}
+
+func (p *RedisHashFieldCounterBigInt) Decrement() (*big.Int, error) {
+	return p.operationModifiesAmount("-1")
+}
+
+// Encode the key, field and "LastValue" as JSON; a missing counter is null
+func (p *RedisHashFieldCounterBigInt) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key, field and "LastValue" from JSON
+func (p *RedisHashFieldCounterBigInt) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotBigInt, shapeField)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisHashFieldCounterBigInt) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key, field and "LastValue" in the binary encoding
+func (p *RedisHashFieldCounterBigInt) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key, field and "LastValue" from the binary encoding
+func (p *RedisHashFieldCounterBigInt) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotBigInt, shapeField)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+//
+// Internal Helpers:
+//
+
+func (p *RedisHashFieldCounterBigInt) operationModifiesAmount(amount string) (*big.Int, error) {
+	p.LastValue = nil
+	reply := bigIntAddScript.eval(p.Redis, []string{p.KEY}, p.FIELD, amount)
+	ptr, err := toBigIntPtr(reply, p.KEY, p.FIELD)
+	if nil != err {
+		return new(big.Int), err
+	}
+	p.LastValue = ptr
+	return valueOrZeroBigInt(ptr), nil
+}
+
+// Identity and "LastValue" of the counter, for its encodings
+func (p *RedisHashFieldCounterBigInt) state() *counterState {
+	return &counterState{
+		Type:   SnapshotBigInt,
+		Shape:  shapeField,
+		Key:    p.KEY,
+		Names:  []string{p.FIELD},
+		Values: []*string{bigIntText(p.LastValue)},
+	}
+}
+
+func (p *RedisHashFieldCounterBigInt) setState(s *counterState) error {
+	value, err := textBigInt(s.Values[0])
+	if nil != err {
+		return err
+	}
+	p.KEY, p.FIELD, p.LastValue = s.Key, s.Names[0], value
+	return nil
+}
@@ -0,0 +1,71 @@
+package redis_counter
+
+import "math/big"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisHashFieldCounterBigIntSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisHashFieldCounterBigIntSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisHashFieldCounterBigIntSpecs(c gospec.Context) {
+
+	c.Specify("[RedisHashFieldCounterBigInt][Make] Makes new instance", func() {
+		value, err := MakeRedisHashFieldCounterBigInt(nil, "", "")
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisHashFieldCounterBigInt(&dog_pool.RedisConnection{}, "Hash", "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis field")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisHashFieldCounterBigInt(&dog_pool.RedisConnection{}, "Hash", "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.String(), gospec.Equals, "Hash[Bob] = NaN")
+	})
+
+	c.Specify("[RedisHashFieldCounterBigInt][Add] Adds past the int64 range", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		bob, _ := MakeRedisHashFieldCounterBigInt(server.Connection(), "Hash", "Bob")
+		gary, _ := MakeRedisHashFieldCounterBigInt(server.Connection(), "Hash", "Gary")
+
+		amount, _ := new(big.Int).SetString("-10000000000000000000000", 10)
+		counter, err := bob.Add(amount)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "-10000000000000000000000")
+
+		counter, err = bob.Sub(big.NewInt(1))
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "-10000000000000000000001")
+		c.Expect(bob.String(), gospec.Equals, "Hash[Bob] = -10000000000000000000001")
+
+		counter, err = gary.Set(big.NewInt(5))
+		c.Expect(err, gospec.Equals, nil)
+
+		stored, _ := server.Connection().Cmd("HGET", "Hash", "Bob").Str()
+		c.Expect(stored, gospec.Equals, "-10000000000000000000001")
+
+		counter, found, err := gary.GetOptional()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(found, gospec.Equals, true)
+		c.Expect(counter.Int64(), gospec.Equals, int64(5))
+
+		c.Expect(gary.Delete(), gospec.Equals, nil)
+		ok, _ := gary.Exists()
+		c.Expect(ok, gospec.Equals, false)
+	})
+}
@@ -0,0 +1,200 @@
+package redis_counter
+
+import "fmt"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Counter of decimals in the hash FIELD, with SCALE digits after the
+// decimal point, stored as an integer number of 10^-SCALE units; e.g. at
+// scale 2, 12.50 is stored as 1250 and incremented with HINCRBY, so it
+// never rounds
+type RedisHashFieldCounterDecimal struct {
+	Redis      dog_pool.RedisClientInterface
+	KEY, FIELD string
+	SCALE      int
+	LastValue  *Decimal
+	Format     *Formatter // nil for DefaultFormatter
+}
+
+// Make a new instance of RedisHashFieldCounterDecimal
+func MakeRedisHashFieldCounterDecimal(redis dog_pool.RedisClientInterface, key, field string, scale int) (*RedisHashFieldCounterDecimal, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	case len(key) == 0:
+		return nil, &ErrEmptyKey{Index: -1}
+	case len(field) == 0:
+		return nil, &ErrEmptyKey{Index: -1, Field: true}
+	case scale < 0 || scale > MaxDecimalScale:
+		return nil, fmt.Errorf("Invalid decimal scale: %d", scale)
+	default:
+		return &RedisHashFieldCounterDecimal{redis, key, field, scale, nil, nil}, nil
+	}
+}
+
+// Format the value as a string with "Format"; uses the cached "LastValue" field
+func (p *RedisHashFieldCounterDecimal) String() string {
+	f := formatterOrDefault(p.Format)
+	return f.formatField(p.KEY, p.FIELD, f.Decimal(p.LastValue))
+}
+
+// Get the value of the counter; saves the counter to "LastValue"
+func (p *RedisHashFieldCounterDecimal) Decimal() (Decimal, error) {
+	return p.operationReturnsAmount("HGET")
+}
+
+func (p *RedisHashFieldCounterDecimal) Exists() (bool, error) {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("HEXISTS", p.KEY, p.FIELD)
+	if nil != reply.Err {
+		return false, redisError(reply.Err, p.KEY, p.FIELD)
+	}
+
+	ok, err := reply.Int()
+	if err != nil {
+		return false, err
+	}
+
+	return ok == 1, nil
+}
+
+func (p *RedisHashFieldCounterDecimal) Delete() error {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("HDEL", p.KEY, p.FIELD)
+	return redisError(reply.Err, p.KEY, p.FIELD)
+}
+
+func (p *RedisHashFieldCounterDecimal) Get() (Decimal, error) {
+	return p.Decimal()
+}
+
+// Get the value of the counter and whether it exists, so a missing
+// counter can be told from one holding 0; saves the counter to "LastValue"
+func (p *RedisHashFieldCounterDecimal) GetOptional() (Decimal, bool, error) {
+	value, err := p.Decimal()
+	return value, nil == err && nil != p.LastValue, err
+}
+
+// Set the counter to "amount", which must fit in SCALE decimal places
+func (p *RedisHashFieldCounterDecimal) Set(amount Decimal) (Decimal, error) {
+	return p.operationReplacesAmount("HSET", amount)
+}
+
+// Add "amount", which must fit in SCALE decimal places
+func (p *RedisHashFieldCounterDecimal) Add(amount Decimal) (Decimal, error) {
+	return p.operationModifiesAmount("HINCRBY", amount)
+}
+
+// Subtract "amount", which must fit in SCALE decimal places
+func (p *RedisHashFieldCounterDecimal) Sub(amount Decimal) (Decimal, error) {
+	return p.Add(Decimal{-1 * amount.Units, amount.Scale})
+}
+
+// Encode the key, field and "LastValue" as JSON; a missing counter is null
+func (p *RedisHashFieldCounterDecimal) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key, field and "LastValue" from JSON
+func (p *RedisHashFieldCounterDecimal) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotDecimal, shapeField)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisHashFieldCounterDecimal) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key, field and "LastValue" in the binary encoding
+func (p *RedisHashFieldCounterDecimal) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key, field and "LastValue" from the binary encoding
+func (p *RedisHashFieldCounterDecimal) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotDecimal, shapeField)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+//
+// Internal Helpers:
+//
+
+func (p *RedisHashFieldCounterDecimal) operationReturnsAmount(cmd string) (Decimal, error) {
+	p.LastValue = nil
+	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELD)
+	ptr, err := toInt64Ptr(reply, p.KEY, p.FIELD)
+	switch {
+	case nil != err:
+		return Decimal{0, p.SCALE}, err
+	case nil != ptr:
+		p.LastValue = &Decimal{*ptr, p.SCALE}
+		return *p.LastValue, nil
+	default:
+		return Decimal{0, p.SCALE}, nil
+	}
+}
+
+func (p *RedisHashFieldCounterDecimal) operationModifiesAmount(cmd string, amount Decimal) (Decimal, error) {
+	p.LastValue = nil
+	amount, err := amount.Rescale(p.SCALE)
+	if nil != err {
+		return Decimal{0, p.SCALE}, err
+	}
+
+	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELD, amount.Units)
+	ptr, err := toInt64Ptr(reply, p.KEY, p.FIELD)
+	switch {
+	case nil != err:
+		return Decimal{0, p.SCALE}, err
+	case nil != ptr:
+		p.LastValue = &Decimal{*ptr, p.SCALE}
+		return *p.LastValue, nil
+	default:
+		return Decimal{0, p.SCALE}, nil
+	}
+}
+
+func (p *RedisHashFieldCounterDecimal) operationReplacesAmount(cmd string, amount Decimal) (Decimal, error) {
+	p.LastValue = nil
+	amount, err := amount.Rescale(p.SCALE)
+	if nil != err {
+		return Decimal{0, p.SCALE}, err
+	}
+
+	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELD, amount.Units)
+	switch {
+	case nil != reply.Err:
+		return Decimal{0, p.SCALE}, redisError(reply.Err, p.KEY, p.FIELD)
+	default:
+		p.LastValue = &amount
+		return amount, nil
+	}
+}
+
+// Identity and "LastValue" of the counter, for its encodings
+func (p *RedisHashFieldCounterDecimal) state() *counterState {
+	return &counterState{
+		Type:   SnapshotDecimal,
+		Shape:  shapeField,
+		Key:    p.KEY,
+		Names:  []string{p.FIELD},
+		Scale:  p.SCALE,
+		Values: []*string{decimalText(p.LastValue)},
+	}
+}
+
+func (p *RedisHashFieldCounterDecimal) setState(s *counterState) error {
+	value, err := textDecimal(s.Values[0], s.Scale)
+	if nil != err {
+		return err
+	}
+	p.KEY, p.FIELD, p.SCALE, p.LastValue = s.Key, s.Names[0], s.Scale, value
+	return nil
+}
@@ -0,0 +1,80 @@
+package redis_counter
+
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisHashFieldCounterDecimalSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisHashFieldCounterDecimalSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisHashFieldCounterDecimalSpecs(c gospec.Context) {
+
+	c.Specify("[RedisHashFieldCounterDecimal][Make] Makes new instance", func() {
+		value, err := MakeRedisHashFieldCounterDecimal(nil, "", "", 2)
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisHashFieldCounterDecimal(&dog_pool.RedisConnection{}, "Hash", "", 2)
+		c.Expect(err.Error(), gospec.Equals, "Empty redis field")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisHashFieldCounterDecimal(&dog_pool.RedisConnection{}, "Hash", "Bob", -1)
+		c.Expect(err.Error(), gospec.Equals, "Invalid decimal scale: -1")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisHashFieldCounterDecimal(&dog_pool.RedisConnection{}, "Hash", "Bob", 2)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[RedisHashFieldCounterDecimal][String] Formats string", func() {
+		value, _ := MakeRedisHashFieldCounterDecimal(&dog_pool.RedisConnection{}, "Hash", "Bob", 2)
+		c.Expect(value.String(), gospec.Equals, "Hash[Bob] = NaN")
+
+		value.LastValue = &Decimal{-1999, 2}
+		c.Expect(value.String(), gospec.Equals, "Hash[Bob] = -19.99")
+	})
+
+	c.Specify("[RedisHashFieldCounterDecimal][Add] Adds exactly", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashFieldCounterDecimal(server.Connection(), "Hash", "Bob", 2)
+
+		counter, err := value.Set(Decimal{1999, 2})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, Decimal{1999, 2})
+
+		counter, err = value.Add(Decimal{1, 2})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "20.00")
+
+		counter, err = value.Sub(Decimal{205, 1})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "-0.50")
+
+		stored, _ := server.Connection().Cmd("HGET", "Hash", "Bob").Str()
+		c.Expect(stored, gospec.Equals, "-50")
+
+		counter, found, err := value.GetOptional()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(found, gospec.Equals, true)
+		c.Expect(counter, gospec.Equals, Decimal{-50, 2})
+
+		c.Expect(value.Delete(), gospec.Equals, nil)
+		ok, _ := value.Exists()
+		c.Expect(ok, gospec.Equals, false)
+	})
+}
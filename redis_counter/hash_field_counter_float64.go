@@ -1,36 +1,33 @@
 package redis_counter
 
-import "fmt"
 import "github.com/gnagel/dog_pool/dog_pool"
 
 type RedisHashFieldCounterFloat64 struct {
 	Redis      dog_pool.RedisClientInterface
 	KEY, FIELD string
 	LastValue  *float64
+	Precision  int        // PrecisionIgnore, PrecisionWarn or PrecisionError
+	Format     *Formatter // nil for DefaultFormatter
 }
 
 // Make a new instance of RedisHashFieldCounterFloat64
 func MakeRedisHashFieldCounterFloat64(redis dog_pool.RedisClientInterface, key, field string) (*RedisHashFieldCounterFloat64, error) {
 	switch {
 	case nil == redis:
-		return nil, fmt.Errorf("Nil redis connection")
+		return nil, ErrNilConnection
 	case len(key) == 0:
-		return nil, fmt.Errorf("Empty redis key")
+		return nil, &ErrEmptyKey{Index: -1}
 	case len(field) == 0:
-		return nil, fmt.Errorf("Empty redis field")
+		return nil, &ErrEmptyKey{Index: -1, Field: true}
 	default:
-		return &RedisHashFieldCounterFloat64{redis, key, field, nil}, nil
+		return &RedisHashFieldCounterFloat64{redis, key, field, nil, PrecisionIgnore, nil}, nil
 	}
 }
 
-// Format the value as a string; uses the cached "LastValue" field
+// Format the value as a string with "Format"; uses the cached "LastValue" field
 func (p *RedisHashFieldCounterFloat64) String() string {
-	switch p.LastValue {
-	case nil:
-		return fmt.Sprintf("%s[%s] = NaN", p.KEY, p.FIELD)
-	default:
-		return fmt.Sprintf("%s[%s] = %0.6f", p.KEY, p.FIELD, *p.LastValue)
-	}
+	f := formatterOrDefault(p.Format)
+	return f.formatField(p.KEY, p.FIELD, f.Float64(p.LastValue))
 }
 
 // Get the value of the counter; saves the counter to "LastValue"
@@ -42,7 +39,7 @@ func (p *RedisHashFieldCounterFloat64) Exists() (bool, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd("HEXISTS", p.KEY, p.FIELD)
 	if nil != reply.Err {
-		return false, reply.Err
+		return false, redisError(reply.Err, p.KEY, p.FIELD)
 	}
 
 	ok, err := reply.Int()
@@ -56,13 +53,20 @@ func (p *RedisHashFieldCounterFloat64) Exists() (bool, error) {
 func (p *RedisHashFieldCounterFloat64) Delete() error {
 	p.LastValue = nil
 	reply := p.Redis.Cmd("HDEL", p.KEY, p.FIELD)
-	return reply.Err
+	return redisError(reply.Err, p.KEY, p.FIELD)
 }
 
 func (p *RedisHashFieldCounterFloat64) Get() (float64, error) {
 	return p.Float64()
 }
 
+// Get the value of the counter and whether it exists, so a missing
+// counter can be told from one holding 0; saves the counter to "LastValue"
+func (p *RedisHashFieldCounterFloat64) GetOptional() (float64, bool, error) {
+	value, err := p.Float64()
+	return value, nil == err && nil != p.LastValue, err
+}
+
 func (p *RedisHashFieldCounterFloat64) Set(amount float64) (float64, error) {
 	return p.operationReplacesAmount("HSET", amount)
 }
@@ -83,6 +87,39 @@ func (p *RedisHashFieldCounterFloat64) Decrement() (float64, error) {
 	return p.Sub(1)
 }
 
+// Encode the key, field and "LastValue" as JSON; a missing counter is null
+func (p *RedisHashFieldCounterFloat64) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key, field and "LastValue" from JSON
+func (p *RedisHashFieldCounterFloat64) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotFloat64, shapeField)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisHashFieldCounterFloat64) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key, field and "LastValue" in the binary encoding
+func (p *RedisHashFieldCounterFloat64) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key, field and "LastValue" from the binary encoding
+func (p *RedisHashFieldCounterFloat64) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotFloat64, shapeField)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
 //
 // Internal Helpers:
 //
@@ -90,13 +127,13 @@ func (p *RedisHashFieldCounterFloat64) Decrement() (float64, error) {
 func (p *RedisHashFieldCounterFloat64) operationReturnsAmount(cmd string) (float64, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELD)
-	ptr, err := toFloat64Ptr(reply)
+	ptr, err := toFloat64Ptr(reply, p.KEY, p.FIELD)
 	switch {
 	case nil != err:
 		return 0, err
 	case nil != ptr:
 		p.LastValue = ptr
-		return *ptr, nil
+		return *ptr, checkPrecision(p.Precision, p.KEY, p.FIELD, *ptr)
 	default:
 		return 0, nil
 	}
@@ -105,13 +142,13 @@ func (p *RedisHashFieldCounterFloat64) operationReturnsAmount(cmd string) (float
 func (p *RedisHashFieldCounterFloat64) operationModifiesAmount(cmd string, amount float64) (float64, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELD, amount)
-	ptr, err := toFloat64Ptr(reply)
+	ptr, err := toFloat64Ptr(reply, p.KEY, p.FIELD)
 	switch {
 	case nil != err:
 		return 0, err
 	case nil != ptr:
 		p.LastValue = ptr
-		return *ptr, nil
+		return *ptr, checkPrecision(p.Precision, p.KEY, p.FIELD, *ptr)
 	default:
 		return 0, nil
 	}
@@ -122,9 +159,29 @@ func (p *RedisHashFieldCounterFloat64) operationReplacesAmount(cmd string, amoun
 	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELD, amount)
 	switch {
 	case nil != reply.Err:
-		return 0, reply.Err
+		return 0, redisError(reply.Err, p.KEY, p.FIELD)
 	default:
 		p.LastValue = &amount
-		return amount, nil
+		return amount, checkPrecision(p.Precision, p.KEY, p.FIELD, amount)
+	}
+}
+
+// Identity and "LastValue" of the counter, for its encodings
+func (p *RedisHashFieldCounterFloat64) state() *counterState {
+	return &counterState{
+		Type:   SnapshotFloat64,
+		Shape:  shapeField,
+		Key:    p.KEY,
+		Names:  []string{p.FIELD},
+		Values: []*string{float64Text(p.LastValue)},
+	}
+}
+
+func (p *RedisHashFieldCounterFloat64) setState(s *counterState) error {
+	value, err := textFloat64(s.Values[0])
+	if nil != err {
+		return err
 	}
+	p.KEY, p.FIELD, p.LastValue = s.Key, s.Names[0], value
+	return nil
 }
@@ -264,6 +264,27 @@ func RedisHashFieldCounterFloat64Specs(c gospec.Context) {
 		c.Expect(str, gospec.Equals, "122.456")
 	})
 
+	c.Specify("[RedisHashFieldCounterFloat64][GetOptional] Tells missing from zero", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashFieldCounterFloat64(server.Connection(), "Hash", "Bob")
+
+		counter, found, err := value.GetOptional()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, 0.0)
+		c.Expect(found, gospec.Equals, false)
+
+		server.Connection().Cmd("HSET", "Hash", "Bob", "0")
+		counter, found, err = value.GetOptional()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, 0.0)
+		c.Expect(found, gospec.Equals, true)
+	})
 }
 
 func Benchmark_RedisHashFieldCounterFloat64_Make(b *testing.B) {
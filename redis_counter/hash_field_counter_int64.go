@@ -1,36 +1,33 @@
 package redis_counter
 
-import "fmt"
 import "github.com/gnagel/dog_pool/dog_pool"
 
 type RedisHashFieldCounterInt64 struct {
 	Redis      dog_pool.RedisClientInterface
 	KEY, FIELD string
 	LastValue  *int64
+	Overflow   int        // OverflowError, OverflowSaturate or OverflowWrap, for Add and Sub
+	Format     *Formatter // nil for DefaultFormatter
 }
 
 // Make a new instance of RedisHashFieldCounterInt64
 func MakeRedisHashFieldCounterInt64(redis dog_pool.RedisClientInterface, key, field string) (*RedisHashFieldCounterInt64, error) {
 	switch {
 	case nil == redis:
-		return nil, fmt.Errorf("Nil redis connection")
+		return nil, ErrNilConnection
 	case len(key) == 0:
-		return nil, fmt.Errorf("Empty redis key")
+		return nil, &ErrEmptyKey{Index: -1}
 	case len(field) == 0:
-		return nil, fmt.Errorf("Empty redis field")
+		return nil, &ErrEmptyKey{Index: -1, Field: true}
 	default:
-		return &RedisHashFieldCounterInt64{redis, key, field, nil}, nil
+		return &RedisHashFieldCounterInt64{redis, key, field, nil, OverflowError, nil}, nil
 	}
 }
 
-// Format the value as a string; uses the cached "LastValue" field
+// Format the value as a string with "Format"; uses the cached "LastValue" field
 func (p *RedisHashFieldCounterInt64) String() string {
-	switch p.LastValue {
-	case nil:
-		return fmt.Sprintf("%s[%s] = NaN", p.KEY, p.FIELD)
-	default:
-		return fmt.Sprintf("%s[%s] = %d", p.KEY, p.FIELD, *p.LastValue)
-	}
+	f := formatterOrDefault(p.Format)
+	return f.formatField(p.KEY, p.FIELD, f.Int64(p.LastValue))
 }
 
 // Get the value of the counter; saves the counter to "LastValue"
@@ -42,7 +39,7 @@ func (p *RedisHashFieldCounterInt64) Exists() (bool, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd("HEXISTS", p.KEY, p.FIELD)
 	if nil != reply.Err {
-		return false, reply.Err
+		return false, redisError(reply.Err, p.KEY, p.FIELD)
 	}
 
 	ok, err := reply.Int()
@@ -56,22 +53,35 @@ func (p *RedisHashFieldCounterInt64) Exists() (bool, error) {
 func (p *RedisHashFieldCounterInt64) Delete() error {
 	p.LastValue = nil
 	reply := p.Redis.Cmd("HDEL", p.KEY, p.FIELD)
-	return reply.Err
+	return redisError(reply.Err, p.KEY, p.FIELD)
 }
 
 func (p *RedisHashFieldCounterInt64) Get() (int64, error) {
 	return p.Int64()
 }
 
+// Get the value of the counter and whether it exists, so a missing
+// counter can be told from one holding 0; saves the counter to "LastValue"
+func (p *RedisHashFieldCounterInt64) GetOptional() (int64, bool, error) {
+	value, err := p.Int64()
+	return value, nil == err && nil != p.LastValue, err
+}
+
 func (p *RedisHashFieldCounterInt64) Set(amount int64) (int64, error) {
 	return p.operationReplacesAmount("HSET", amount)
 }
 
 func (p *RedisHashFieldCounterInt64) Add(amount int64) (int64, error) {
+	if OverflowError != p.Overflow {
+		return p.operationOverflows(amount)
+	}
 	return p.operationModifiesAmount("HINCRBY", amount)
 }
 
 func (p *RedisHashFieldCounterInt64) Sub(amount int64) (int64, error) {
+	if OverflowError != p.Overflow {
+		return p.operationOverflows(negateInt64(amount)...)
+	}
 	return p.Add(-1 * amount)
 }
 
@@ -83,6 +93,39 @@ func (p *RedisHashFieldCounterInt64) Decrement() (int64, error) {
 	return p.Sub(1)
 }
 
+// Encode the key, field and "LastValue" as JSON; a missing counter is null
+func (p *RedisHashFieldCounterInt64) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key, field and "LastValue" from JSON
+func (p *RedisHashFieldCounterInt64) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotInt64, shapeField)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisHashFieldCounterInt64) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key, field and "LastValue" in the binary encoding
+func (p *RedisHashFieldCounterInt64) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key, field and "LastValue" from the binary encoding
+func (p *RedisHashFieldCounterInt64) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotInt64, shapeField)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
 //
 // Internal Helpers:
 //
@@ -90,7 +133,7 @@ func (p *RedisHashFieldCounterInt64) Decrement() (int64, error) {
 func (p *RedisHashFieldCounterInt64) operationReturnsAmount(cmd string) (int64, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELD)
-	ptr, err := toInt64Ptr(reply)
+	ptr, err := toInt64Ptr(reply, p.KEY, p.FIELD)
 	switch {
 	case nil != err:
 		return 0, err
@@ -105,7 +148,22 @@ func (p *RedisHashFieldCounterInt64) operationReturnsAmount(cmd string) (int64,
 func (p *RedisHashFieldCounterInt64) operationModifiesAmount(cmd string, amount int64) (int64, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELD, amount)
-	ptr, err := toInt64Ptr(reply)
+	ptr, err := toInt64Ptr(reply, p.KEY, p.FIELD)
+	switch {
+	case nil != err:
+		return 0, err
+	case nil != ptr:
+		p.LastValue = ptr
+		return *ptr, nil
+	default:
+		return 0, nil
+	}
+}
+
+func (p *RedisHashFieldCounterInt64) operationOverflows(amounts ...int64) (int64, error) {
+	p.LastValue = nil
+	reply := overflowIncrement(p.Redis, p.Overflow, p.KEY, p.FIELD, amounts...)
+	ptr, err := toInt64Ptr(reply, p.KEY, p.FIELD)
 	switch {
 	case nil != err:
 		return 0, err
@@ -122,9 +180,29 @@ func (p *RedisHashFieldCounterInt64) operationReplacesAmount(cmd string, amount
 	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELD, amount)
 	switch {
 	case nil != reply.Err:
-		return 0, reply.Err
+		return 0, redisError(reply.Err, p.KEY, p.FIELD)
 	default:
 		p.LastValue = &amount
 		return amount, nil
 	}
 }
+
+// Identity and "LastValue" of the counter, for its encodings
+func (p *RedisHashFieldCounterInt64) state() *counterState {
+	return &counterState{
+		Type:   SnapshotInt64,
+		Shape:  shapeField,
+		Key:    p.KEY,
+		Names:  []string{p.FIELD},
+		Values: []*string{int64Text(p.LastValue)},
+	}
+}
+
+func (p *RedisHashFieldCounterInt64) setState(s *counterState) error {
+	value, err := textInt64(s.Values[0])
+	if nil != err {
+		return err
+	}
+	p.KEY, p.FIELD, p.LastValue = s.Key, s.Names[0], value
+	return nil
+}
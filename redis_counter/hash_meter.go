@@ -0,0 +1,190 @@
+package redis_counter
+
+import "fmt"
+import "github.com/fzzy/radix/redis"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Seconds between each decay of the moving averages
+const MeterTickInterval = 5
+
+// Rates of a meter in events per second
+type MeterRates struct {
+	Count                          int64
+	Rate1, Rate5, Rate15, RateMean float64
+}
+
+// Exponentially weighted 1/5/15-minute rates stored in a Redis hash.
+// The rates are decayed by a Lua script using the Redis server clock,
+// so every process marking the same KEY sees the same meter.
+type RedisHashMeter struct {
+	Redis     dog_pool.RedisClientInterface
+	KEY       string
+	LastValue *MeterRates
+}
+
+// Make a new instance of RedisHashMeter
+func MakeRedisHashMeter(redis dog_pool.RedisClientInterface, key string) (*RedisHashMeter, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	case len(key) == 0:
+		return nil, &ErrEmptyKey{Index: -1}
+	default:
+		return &RedisHashMeter{redis, key, nil}, nil
+	}
+}
+
+// Format the value as a string; uses the cached "LastValue" field
+func (p *RedisHashMeter) String() string {
+	switch p.LastValue {
+	case nil:
+		return fmt.Sprintf("%s = NaN", p.KEY)
+	default:
+		v := p.LastValue
+		return fmt.Sprintf("%s = count=%d m1=%0.6f m5=%0.6f m15=%0.6f mean=%0.6f", p.KEY, v.Count, v.Rate1, v.Rate5, v.Rate15, v.RateMean)
+	}
+}
+
+// Counter for the total number of events marked on the meter
+func (p *RedisHashMeter) Counter() *RedisHashFieldCounterInt64 {
+	return &RedisHashFieldCounterInt64{p.Redis, p.KEY, "count", nil, OverflowError, nil}
+}
+
+func (p *RedisHashMeter) Exists() (bool, error) {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("EXISTS", p.KEY)
+	if nil != reply.Err {
+		return false, redisError(reply.Err, p.KEY, "")
+	}
+
+	ok, err := reply.Int()
+	if err != nil {
+		return false, err
+	}
+
+	return ok == 1, nil
+}
+
+func (p *RedisHashMeter) Delete() error {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("DEL", p.KEY)
+	return redisError(reply.Err, p.KEY, "")
+}
+
+func (p *RedisHashMeter) Count() (int64, error) {
+	return p.Counter().Get()
+}
+
+// Get the current rates; saves the rates to "LastValue"
+func (p *RedisHashMeter) Rates() (MeterRates, error) {
+	return p.operationMarksAmount(0)
+}
+
+// Record "amount" events and return the current rates
+func (p *RedisHashMeter) Mark(amount int64) (MeterRates, error) {
+	return p.operationMarksAmount(amount)
+}
+
+//
+// Internal Helpers:
+//
+
+// Decays the rates for every elapsed tick, then adds "amount" to the
+// uncounted events. Returns nil when reading a meter that was never marked.
+var meterMarkScript = makeRedisScript(`
+local key = KEYS[1]
+local amount = tonumber(ARGV[1])
+local interval = tonumber(ARGV[2])
+
+if amount == 0 and redis.call('EXISTS', key) == 0 then
+	return nil
+end
+
+if redis.replicate_commands then
+	redis.replicate_commands()
+end
+local time = redis.call('TIME')
+local now = tonumber(time[1]) + tonumber(time[2]) / 1000000
+
+local start = tonumber(redis.call('HGET', key, 'start'))
+if not start then
+	start = now
+	redis.call('HSET', key, 'start', string.format('%.6f', now))
+	redis.call('HSET', key, 'tick', math.floor(now))
+end
+
+local last = tonumber(redis.call('HGET', key, 'tick'))
+local ticks = math.floor((now - last) / interval)
+if ticks > 0 then
+	local instant = tonumber(redis.call('HGET', key, 'uncounted') or '0') / interval
+	local windows = {m1 = 1, m5 = 5, m15 = 15}
+	for field, minutes in pairs(windows) do
+		local alpha = 1 - math.exp(-interval / 60 / minutes)
+		local rate = tonumber(redis.call('HGET', key, field))
+		if rate then
+			rate = rate + alpha * (instant - rate)
+		else
+			rate = instant
+		end
+		rate = rate * math.pow(1 - alpha, ticks - 1)
+		redis.call('HSET', key, field, string.format('%.17g', rate))
+	end
+	redis.call('HSET', key, 'tick', last + ticks * interval)
+	redis.call('HSET', key, 'uncounted', 0)
+end
+
+if amount ~= 0 then
+	redis.call('HINCRBY', key, 'uncounted', amount)
+	redis.call('HINCRBY', key, 'count', amount)
+end
+
+local count = tonumber(redis.call('HGET', key, 'count') or '0')
+local mean = 0
+if now > start then
+	mean = count / (now - start)
+end
+
+return {
+	count,
+	redis.call('HGET', key, 'm1') or '0',
+	redis.call('HGET', key, 'm5') or '0',
+	redis.call('HGET', key, 'm15') or '0',
+	string.format('%.17g', mean)
+}
+`)
+
+func (p *RedisHashMeter) operationMarksAmount(amount int64) (MeterRates, error) {
+	p.LastValue = nil
+	reply := meterMarkScript.eval(p.Redis, []string{p.KEY}, amount, MeterTickInterval)
+	switch {
+	case nil != reply.Err:
+		return MeterRates{}, redisError(reply.Err, p.KEY, "")
+	case redis.NilReply == reply.Type:
+		return MeterRates{}, nil
+	case len(reply.Elems) != 5:
+		return MeterRates{}, fmt.Errorf("Invalid meter reply for %s", p.KEY)
+	}
+
+	count, err := toInt64Ptr(reply.Elems[0], p.KEY, "")
+	if nil != err {
+		return MeterRates{}, err
+	}
+
+	rates := make([]float64, 4)
+	for i := range rates {
+		ptr, err := toFloat64Ptr(reply.Elems[i+1], p.KEY, "")
+		switch {
+		case nil != err:
+			return MeterRates{}, err
+		case nil != ptr:
+			rates[i] = *ptr
+		}
+	}
+
+	value := MeterRates{Rate1: rates[0], Rate5: rates[1], Rate15: rates[2], RateMean: rates[3]}
+	if nil != count {
+		value.Count = *count
+	}
+	p.LastValue = &value
+	return value, nil
+}
@@ -0,0 +1,169 @@
+package redis_counter
+
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisHashMeterSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisHashMeterSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisHashMeterSpecs(c gospec.Context) {
+
+	c.Specify("[RedisHashMeter][Make] Makes new instance", func() {
+		value, err := MakeRedisHashMeter(nil, "")
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisHashMeter(&dog_pool.RedisConnection{}, "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisHashMeter(&dog_pool.RedisConnection{}, "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[RedisHashMeter][String] Formats string", func() {
+		value, _ := MakeRedisHashMeter(&dog_pool.RedisConnection{}, "Bob")
+		value.LastValue = nil
+		c.Expect(value.String(), gospec.Equals, "Bob = NaN")
+
+		value.LastValue = &MeterRates{Count: 10, Rate1: 2, Rate5: 1.5, Rate15: 1, RateMean: 0.5}
+		c.Expect(value.String(), gospec.Equals, "Bob = count=10 m1=2.000000 m5=1.500000 m15=1.000000 mean=0.500000")
+	})
+
+	c.Specify("[RedisHashMeter][Rates] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashMeter(server.Connection(), "Bob")
+
+		// Cache Miss
+		rates, err := value.Rates()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(rates, gospec.Equals, MeterRates{})
+		c.Expect(value.LastValue, gospec.Satisfies, nil == value.LastValue)
+
+		ok, _ := server.Connection().Cmd("EXISTS", "Bob").Int()
+		c.Expect(ok, gospec.Equals, 0)
+
+		// Parsing error:
+		server.Connection().Cmd("HSET", "Bob", "count", "Gary")
+		rates, err = value.Rates()
+		c.Expect(err, gospec.Satisfies, nil != err)
+		c.Expect(value.LastValue, gospec.Satisfies, nil == value.LastValue)
+	})
+
+	c.Specify("[RedisHashMeter][Mark] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashMeter(server.Connection(), "Bob")
+		other, _ := MakeRedisHashMeter(server.Connection(), "Bob")
+
+		// Rates are zero until the first tick:
+		rates, err := value.Mark(10)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(rates.Count, gospec.Equals, int64(10))
+		c.Expect(rates.Rate1, gospec.Equals, float64(0))
+		c.Expect(value.LastValue, gospec.Satisfies, nil != value.LastValue)
+
+		count, err := value.Count()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(count, gospec.Equals, int64(10))
+
+		// Rewind the clock by one tick:
+		server.Connection().Cmd("HINCRBY", "Bob", "tick", -MeterTickInterval)
+
+		// The first tick starts every window at the instant rate:
+		rates, err = other.Rates()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(rates.Count, gospec.Equals, int64(10))
+		c.Expect(rates.Rate1, gospec.Equals, float64(2))
+		c.Expect(rates.Rate5, gospec.Equals, float64(2))
+		c.Expect(rates.Rate15, gospec.Equals, float64(2))
+		c.Expect(rates.RateMean, gospec.Satisfies, rates.RateMean > 0)
+		c.Expect(*other.LastValue, gospec.Equals, rates)
+
+		// Marks from both instances update the same meter:
+		rates, err = value.Mark(5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(rates.Count, gospec.Equals, int64(15))
+		c.Expect(rates.Rate1, gospec.Equals, float64(2))
+	})
+
+	c.Specify("[RedisHashMeter][Delete] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashMeter(server.Connection(), "Bob")
+		value.Mark(10)
+
+		ok, err := value.Exists()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(ok, gospec.Equals, true)
+
+		err = value.Delete()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.LastValue, gospec.Satisfies, nil == value.LastValue)
+
+		ok, err = value.Exists()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(ok, gospec.Equals, false)
+	})
+}
+
+func Benchmark_RedisHashMeter_Mark(b *testing.B) {
+	logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+	server, err := dog_pool.StartRedisServer(&logger)
+	if nil != err {
+		panic(err)
+	}
+	defer server.Close()
+
+	value, _ := MakeRedisHashMeter(server.Connection(), "Bob")
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		value.Mark(1)
+	}
+}
+
+func Benchmark_RedisHashMeter_Rates(b *testing.B) {
+	logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+	server, err := dog_pool.StartRedisServer(&logger)
+	if nil != err {
+		panic(err)
+	}
+	defer server.Close()
+
+	value, _ := MakeRedisHashMeter(server.Connection(), "Bob")
+	value.Mark(1)
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		value.Rates()
+	}
+}
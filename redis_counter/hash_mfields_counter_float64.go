@@ -1,6 +1,7 @@
 package redis_counter
 
-import "fmt"
+import "strconv"
+import "github.com/fzzy/radix/redis"
 import "github.com/gnagel/dog_pool/dog_pool"
 import . "github.com/gnagel/go_map_to_ptrs/map_to_ptrs"
 
@@ -9,21 +10,22 @@ type RedisHashMFieldsCounterFloat64 struct {
 	KEY    string
 	FIELDS []string
 	Cache  MapStringToFloat64Ptrs
+	Format *Formatter // nil for DefaultFormatter
 }
 
 // Make a new instance of RedisHashMFieldsCounterFloat64
 func MakeRedisHashMFieldsCounterFloat64(redis *dog_pool.RedisConnection, key string, fields ...string) (*RedisHashMFieldsCounterFloat64, error) {
 	switch {
 	case nil == redis:
-		return nil, fmt.Errorf("Nil redis connection")
+		return nil, ErrNilConnection
 	case len(key) == 0:
-		return nil, fmt.Errorf("Empty redis key")
+		return nil, &ErrEmptyKey{Index: -1}
 	case len(fields) == 0:
-		return nil, fmt.Errorf("Empty redis fields")
+		return nil, ErrNoFields
 	default:
 		for i, field := range fields {
 			if len(field) == 0 {
-				return nil, fmt.Errorf("Empty redis field[%d]", i)
+				return nil, &ErrEmptyKey{Index: i, Field: true}
 			}
 		}
 
@@ -43,39 +45,53 @@ func (p *RedisHashMFieldsCounterFloat64) CacheReset() {
 	}
 }
 
-// Format the values as a string; uses the cached "LastValues" field
+// Format the values as a string with "Format", in the order of FIELDS;
+// uses the cached "LastValues" field
 func (p *RedisHashMFieldsCounterFloat64) String() string {
-	return fmt.Sprintf("%s[%s]", p.KEY, p.Cache.String())
+	f := formatterOrDefault(p.Format)
+	values := make([]string, len(p.FIELDS))
+	for i, field := range p.FIELDS {
+		values[i] = f.Float64(p.Cache.Value(field))
+	}
+	return f.formatFields(p.KEY, p.FIELDS, values)
 }
 
 // Get the value of the counters; saves the counter to "LastValues"
 func (p *RedisHashMFieldsCounterFloat64) MFloat64() ([]float64, error) {
-	return p.operationReturnsAmounts("HMGET")
+	return valuesOfResultsFloat64(p.operationReturnsAmounts("HMGET"))
 }
 
 func (p *RedisHashMFieldsCounterFloat64) MExists() ([]bool, error) {
 	p.CacheReset()
 
-	return p.Redis.HashFieldsExist(p.KEY, p.FIELDS...)
+	values, err := p.Redis.HashFieldsExist(p.KEY, p.FIELDS...)
+	return values, redisError(err, p.KEY, "")
 }
 
 func (p *RedisHashMFieldsCounterFloat64) MDelete() error {
 	p.CacheReset()
 
 	reply := p.Redis.Cmd("HDEL", p.KEY, p.FIELDS)
-	return reply.Err
+	return redisError(reply.Err, p.KEY, "")
 }
 
 func (p *RedisHashMFieldsCounterFloat64) MGet() ([]float64, error) {
 	return p.MFloat64()
 }
 
+// Get the value of the counters and whether each field exists, so a
+// missing counter can be told from one holding 0; saves the counters to
+// "Cache"
+func (p *RedisHashMFieldsCounterFloat64) MGetOptional() ([]float64, []bool, error) {
+	return optionalsOfResultsFloat64(p.operationReturnsAmounts("HMGET"))
+}
+
 func (p *RedisHashMFieldsCounterFloat64) MSet(amount float64) ([]float64, error) {
 	return p.operationReplacesAmounts(amount)
 }
 
 func (p *RedisHashMFieldsCounterFloat64) MAdd(amount float64) ([]float64, error) {
-	return p.operationModifiesAmounts("HINCRBY", amount)
+	return valuesOfResultsFloat64(p.operationModifiesAmounts("HINCRBYFLOAT", amount))
 }
 
 func (p *RedisHashMFieldsCounterFloat64) MSub(amount float64) ([]float64, error) {
@@ -90,36 +106,77 @@ func (p *RedisHashMFieldsCounterFloat64) MDecrement() ([]float64, error) {
 	return p.MAdd(-1)
 }
 
+// Get the value of each counter, with a result per field instead of
+// failing on the first error; the error is only set when Redis can't be
+// reached
+func (p *RedisHashMFieldsCounterFloat64) MGetResults() ([]ResultFloat64, error) {
+	return p.operationReturnsAmounts("HMGET")
+}
+
+func (p *RedisHashMFieldsCounterFloat64) MAddResults(amount float64) ([]ResultFloat64, error) {
+	return p.operationModifiesAmounts("HINCRBYFLOAT", amount)
+}
+
+func (p *RedisHashMFieldsCounterFloat64) MSubResults(amount float64) ([]ResultFloat64, error) {
+	return p.MAddResults(-1 * amount)
+}
+
+// Encode the key, fields and "Cache" as JSON; a missing counter is null
+func (p *RedisHashMFieldsCounterFloat64) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key, fields and "Cache" from JSON
+func (p *RedisHashMFieldsCounterFloat64) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotFloat64, shapeFields)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisHashMFieldsCounterFloat64) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key, fields and "Cache" in the binary encoding
+func (p *RedisHashMFieldsCounterFloat64) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key, fields and "Cache" from the binary encoding
+func (p *RedisHashMFieldsCounterFloat64) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotFloat64, shapeFields)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
 //
 // Internal Helpers:
 //
 
-func (p *RedisHashMFieldsCounterFloat64) operationReturnsAmounts(cmd string) ([]float64, error) {
+func (p *RedisHashMFieldsCounterFloat64) operationReturnsAmounts(cmd string) ([]ResultFloat64, error) {
 	p.CacheReset()
 
 	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELDS)
 	switch {
 	case nil != reply.Err:
-		return nil, reply.Err
+		return nil, redisError(reply.Err, p.KEY, "")
 	default:
 		count := len(p.FIELDS)
-		values := make([]float64, count)
+		results := make([]ResultFloat64, count)
 		for i, field := range p.FIELDS {
-			ptr, err := toFloat64Ptr(reply.Elems[i])
-			switch {
-			case nil != err:
-				return nil, err
-			case nil != ptr:
-				p.Cache.Set(field, ptr)
-				values[i] = *ptr
-			}
+			results[i] = p.cacheResult(field, reply.Elems[i])
 		}
 
-		return values, nil
+		return results, nil
 	}
 }
 
-func (p *RedisHashMFieldsCounterFloat64) operationReturnsAmount(cmd string) ([]float64, error) {
+func (p *RedisHashMFieldsCounterFloat64) operationReturnsAmount(cmd string) ([]ResultFloat64, error) {
 	p.CacheReset()
 
 	count := len(p.FIELDS)
@@ -132,29 +189,22 @@ func (p *RedisHashMFieldsCounterFloat64) operationReturnsAmount(cmd string) ([]f
 
 	err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(p.Redis)
 	if err != nil {
-		return nil, err
+		return nil, redisError(err, "", "")
 	}
 
-	values := make([]float64, count)
+	results := make([]ResultFloat64, count)
 	for i, field := range p.FIELDS {
-		ptr, err := toFloat64Ptr(commands[i].Reply())
-		switch {
-		case nil != err:
-			return nil, err
-		case nil != ptr:
-			p.Cache.Set(field, ptr)
-			values[i] = *ptr
-		}
+		results[i] = p.cacheResult(field, commands[i].Reply())
 	}
 
-	return values, nil
+	return results, nil
 }
 
-func (p *RedisHashMFieldsCounterFloat64) operationModifiesAmounts(cmd string, amount float64) ([]float64, error) {
+func (p *RedisHashMFieldsCounterFloat64) operationModifiesAmounts(cmd string, amount float64) ([]ResultFloat64, error) {
 	p.CacheReset()
 
 	count := len(p.FIELDS)
-	amount_bytes := []byte(fmt.Sprintf("%d", amount))
+	amount_bytes := []byte(strconv.FormatFloat(amount, 'f', -1, 64))
 	commands := make([]*dog_pool.RedisBatchCommand, count)
 	for i, field := range p.FIELDS {
 		commands[i] = dog_pool.MakeRedisBatchCommand(cmd)
@@ -165,29 +215,22 @@ func (p *RedisHashMFieldsCounterFloat64) operationModifiesAmounts(cmd string, am
 
 	err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(p.Redis)
 	if err != nil {
-		return nil, err
+		return nil, redisError(err, "", "")
 	}
 
-	values := make([]float64, count)
+	results := make([]ResultFloat64, count)
 	for i, field := range p.FIELDS {
-		ptr, err := toFloat64Ptr(commands[i].Reply())
-		switch {
-		case nil != err:
-			return nil, err
-		case nil != ptr:
-			p.Cache.Set(field, ptr)
-			values[i] = *ptr
-		}
+		results[i] = p.cacheResult(field, commands[i].Reply())
 	}
 
-	return values, nil
+	return results, nil
 }
 
 func (p *RedisHashMFieldsCounterFloat64) operationReplacesAmounts(amount float64) ([]float64, error) {
 	p.CacheReset()
 
 	count := len(p.FIELDS)
-	amount_bytes := []byte(fmt.Sprintf("%d", amount))
+	amount_bytes := []byte(strconv.FormatFloat(amount, 'f', -1, 64))
 	buffer := make([][]byte, len(p.FIELDS)*2)[0:0]
 	for _, field := range p.FIELDS {
 		buffer = append(buffer, []byte(field), amount_bytes)
@@ -195,7 +238,7 @@ func (p *RedisHashMFieldsCounterFloat64) operationReplacesAmounts(amount float64
 
 	reply := p.Redis.Cmd("HMSET", p.KEY, buffer)
 	if nil != reply.Err {
-		return nil, reply.Err
+		return nil, redisError(reply.Err, p.KEY, "")
 	}
 
 	values := make([]float64, count)
@@ -206,3 +249,42 @@ func (p *RedisHashMFieldsCounterFloat64) operationReplacesAmounts(amount float64
 
 	return values, nil
 }
+
+// Parse the reply for one field into the cache
+func (p *RedisHashMFieldsCounterFloat64) cacheResult(field string, reply *redis.Reply) ResultFloat64 {
+	ptr, err := toFloat64Ptr(reply, p.KEY, field)
+	p.Cache.Set(field, ptr)
+	return makeResultFloat64(field, ptr, err)
+}
+
+// Identity and "Cache" of the counters, for their encodings
+func (p *RedisHashMFieldsCounterFloat64) state() *counterState {
+	values := make([]*string, len(p.FIELDS))
+	for i, field := range p.FIELDS {
+		values[i] = float64Text(p.Cache.Value(field))
+	}
+	return &counterState{
+		Type:   SnapshotFloat64,
+		Shape:  shapeFields,
+		Key:    p.KEY,
+		Names:  p.FIELDS,
+		Values: values,
+	}
+}
+
+func (p *RedisHashMFieldsCounterFloat64) setState(s *counterState) error {
+	if len(s.Names) == 0 {
+		return ErrNoFields
+	}
+
+	cache := MakeMapStringToFloat64Ptrs(len(s.Names))
+	for i, field := range s.Names {
+		value, err := textFloat64(s.Values[i])
+		if nil != err {
+			return err
+		}
+		cache.Set(field, value)
+	}
+	p.KEY, p.FIELDS, p.Cache = s.Key, s.Names, cache
+	return nil
+}
@@ -377,6 +377,65 @@ func RedisHashMFieldsCounterFloat64Specs(c gospec.Context) {
 		}
 	})
 
+	c.Specify("[RedisHashMFieldsCounterFloat64][MAdd] Adds fractional amounts", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashMFieldsCounterFloat64(server.Connection(), "Key", "Bob", "George")
+
+		counters, err := value.MSet(1.5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(counters), gospec.Equals, 2)
+
+		counters, err = value.MAdd(0.25)
+		c.Expect(err, gospec.Equals, nil)
+		for _, counter := range counters {
+			c.Expect(counter, gospec.Equals, 1.75)
+		}
+
+		counters, err = value.MSub(0.5)
+		c.Expect(err, gospec.Equals, nil)
+		for _, counter := range counters {
+			c.Expect(counter, gospec.Equals, 1.25)
+		}
+
+		list, list_err := server.Connection().Cmd("HMGET", value.KEY, value.FIELDS).List()
+		c.Expect(list_err, gospec.Equals, nil)
+		for _, list_value := range list {
+			c.Expect(list_value, gospec.Equals, "1.25")
+		}
+	})
+
+	c.Specify("[RedisHashMFieldsCounterFloat64][MGetResults] Returns a result per field", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashMFieldsCounterFloat64(server.Connection(), "Hash", "Bob", "George", "Alex")
+		server.Connection().Cmd("HSET", "Hash", "Bob", "1.5")
+		server.Connection().Cmd("HSET", "Hash", "George", "Gary")
+
+		results, err := value.MGetResults()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(results), gospec.Equals, 3)
+		c.Expect(results[0], gospec.Equals, ResultFloat64{Key: "Bob", Value: 1.5, Exists: true})
+		c.Expect(results[1].Err, gospec.Satisfies, nil != results[1].Err)
+		c.Expect(results[2], gospec.Equals, ResultFloat64{Key: "Alex"})
+		c.Expect(*value.Cache.Value("Bob"), gospec.Equals, 1.5)
+
+		results, err = value.MAddResults(0.5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(results[0].Value, gospec.Equals, 2.0)
+		c.Expect(results[1].Err, gospec.Satisfies, nil != results[1].Err)
+		c.Expect(results[2].Value, gospec.Equals, 0.5)
+	})
 }
 
 func Benchmark_RedisHashMFieldsCounterFloat64_MMake(b *testing.B) {
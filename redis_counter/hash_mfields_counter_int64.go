@@ -1,6 +1,7 @@
 package redis_counter
 
 import "fmt"
+import "github.com/fzzy/radix/redis"
 import "github.com/gnagel/dog_pool/dog_pool"
 import . "github.com/gnagel/go_map_to_ptrs/map_to_ptrs"
 
@@ -9,21 +10,22 @@ type RedisHashMFieldsCounterInt64 struct {
 	KEY    string
 	FIELDS []string
 	Cache  MapStringToInt64Ptrs
+	Format *Formatter // nil for DefaultFormatter
 }
 
 // Make a new instance of RedisHashMFieldsCounterInt64
 func MakeRedisHashMFieldsCounterInt64(redis *dog_pool.RedisConnection, key string, fields ...string) (*RedisHashMFieldsCounterInt64, error) {
 	switch {
 	case nil == redis:
-		return nil, fmt.Errorf("Nil redis connection")
+		return nil, ErrNilConnection
 	case len(key) == 0:
-		return nil, fmt.Errorf("Empty redis key")
+		return nil, &ErrEmptyKey{Index: -1}
 	case len(fields) == 0:
-		return nil, fmt.Errorf("Empty redis fields")
+		return nil, ErrNoFields
 	default:
 		for i, field := range fields {
 			if len(field) == 0 {
-				return nil, fmt.Errorf("Empty redis field[%d]", i)
+				return nil, &ErrEmptyKey{Index: i, Field: true}
 			}
 		}
 
@@ -43,39 +45,53 @@ func (p *RedisHashMFieldsCounterInt64) CacheReset() {
 	}
 }
 
-// Format the values as a string; uses the cached "LastValues" field
+// Format the values as a string with "Format", in the order of FIELDS;
+// uses the cached "LastValues" field
 func (p *RedisHashMFieldsCounterInt64) String() string {
-	return fmt.Sprintf("%s[%s]", p.KEY, p.Cache.String())
+	f := formatterOrDefault(p.Format)
+	values := make([]string, len(p.FIELDS))
+	for i, field := range p.FIELDS {
+		values[i] = f.Int64(p.Cache.Value(field))
+	}
+	return f.formatFields(p.KEY, p.FIELDS, values)
 }
 
 // Get the value of the counters; saves the counter to "LastValues"
 func (p *RedisHashMFieldsCounterInt64) MInt64() ([]int64, error) {
-	return p.operationReturnsAmounts("HMGET")
+	return valuesOfResultsInt64(p.operationReturnsAmounts("HMGET"))
 }
 
 func (p *RedisHashMFieldsCounterInt64) MExists() ([]bool, error) {
 	p.CacheReset()
 
-	return p.Redis.HashFieldsExist(p.KEY, p.FIELDS...)
+	values, err := p.Redis.HashFieldsExist(p.KEY, p.FIELDS...)
+	return values, redisError(err, p.KEY, "")
 }
 
 func (p *RedisHashMFieldsCounterInt64) MDelete() error {
 	p.CacheReset()
 
 	reply := p.Redis.Cmd("HDEL", p.KEY, p.FIELDS)
-	return reply.Err
+	return redisError(reply.Err, p.KEY, "")
 }
 
 func (p *RedisHashMFieldsCounterInt64) MGet() ([]int64, error) {
 	return p.MInt64()
 }
 
+// Get the value of the counters and whether each field exists, so a
+// missing counter can be told from one holding 0; saves the counters to
+// "Cache"
+func (p *RedisHashMFieldsCounterInt64) MGetOptional() ([]int64, []bool, error) {
+	return optionalsOfResultsInt64(p.operationReturnsAmounts("HMGET"))
+}
+
 func (p *RedisHashMFieldsCounterInt64) MSet(amount int64) ([]int64, error) {
 	return p.operationReplacesAmounts(amount)
 }
 
 func (p *RedisHashMFieldsCounterInt64) MAdd(amount int64) ([]int64, error) {
-	return p.operationModifiesAmounts("HINCRBY", amount)
+	return valuesOfResultsInt64(p.operationModifiesAmounts("HINCRBY", amount))
 }
 
 func (p *RedisHashMFieldsCounterInt64) MSub(amount int64) ([]int64, error) {
@@ -90,36 +106,77 @@ func (p *RedisHashMFieldsCounterInt64) MDecrement() ([]int64, error) {
 	return p.MAdd(-1)
 }
 
+// Get the value of each counter, with a result per field instead of
+// failing on the first error; the error is only set when Redis can't be
+// reached
+func (p *RedisHashMFieldsCounterInt64) MGetResults() ([]ResultInt64, error) {
+	return p.operationReturnsAmounts("HMGET")
+}
+
+func (p *RedisHashMFieldsCounterInt64) MAddResults(amount int64) ([]ResultInt64, error) {
+	return p.operationModifiesAmounts("HINCRBY", amount)
+}
+
+func (p *RedisHashMFieldsCounterInt64) MSubResults(amount int64) ([]ResultInt64, error) {
+	return p.MAddResults(-1 * amount)
+}
+
+// Encode the key, fields and "Cache" as JSON; a missing counter is null
+func (p *RedisHashMFieldsCounterInt64) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key, fields and "Cache" from JSON
+func (p *RedisHashMFieldsCounterInt64) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotInt64, shapeFields)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisHashMFieldsCounterInt64) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key, fields and "Cache" in the binary encoding
+func (p *RedisHashMFieldsCounterInt64) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key, fields and "Cache" from the binary encoding
+func (p *RedisHashMFieldsCounterInt64) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotInt64, shapeFields)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
 //
 // Internal Helpers:
 //
 
-func (p *RedisHashMFieldsCounterInt64) operationReturnsAmounts(cmd string) ([]int64, error) {
+func (p *RedisHashMFieldsCounterInt64) operationReturnsAmounts(cmd string) ([]ResultInt64, error) {
 	p.CacheReset()
 
 	reply := p.Redis.Cmd(cmd, p.KEY, p.FIELDS)
 	switch {
 	case nil != reply.Err:
-		return nil, reply.Err
+		return nil, redisError(reply.Err, p.KEY, "")
 	default:
 		count := len(p.FIELDS)
-		values := make([]int64, count)
+		results := make([]ResultInt64, count)
 		for i, field := range p.FIELDS {
-			ptr, err := toInt64Ptr(reply.Elems[i])
-			switch {
-			case nil != err:
-				return nil, err
-			case nil != ptr:
-				p.Cache.Set(field, ptr)
-				values[i] = *ptr
-			}
+			results[i] = p.cacheResult(field, reply.Elems[i])
 		}
 
-		return values, nil
+		return results, nil
 	}
 }
 
-func (p *RedisHashMFieldsCounterInt64) operationReturnsAmount(cmd string) ([]int64, error) {
+func (p *RedisHashMFieldsCounterInt64) operationReturnsAmount(cmd string) ([]ResultInt64, error) {
 	p.CacheReset()
 
 	count := len(p.FIELDS)
@@ -132,25 +189,18 @@ func (p *RedisHashMFieldsCounterInt64) operationReturnsAmount(cmd string) ([]int
 
 	err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(p.Redis)
 	if err != nil {
-		return nil, err
+		return nil, redisError(err, "", "")
 	}
 
-	values := make([]int64, count)
+	results := make([]ResultInt64, count)
 	for i, field := range p.FIELDS {
-		ptr, err := toInt64Ptr(commands[i].Reply())
-		switch {
-		case nil != err:
-			return nil, err
-		case nil != ptr:
-			p.Cache.Set(field, ptr)
-			values[i] = *ptr
-		}
+		results[i] = p.cacheResult(field, commands[i].Reply())
 	}
 
-	return values, nil
+	return results, nil
 }
 
-func (p *RedisHashMFieldsCounterInt64) operationModifiesAmounts(cmd string, amount int64) ([]int64, error) {
+func (p *RedisHashMFieldsCounterInt64) operationModifiesAmounts(cmd string, amount int64) ([]ResultInt64, error) {
 	p.CacheReset()
 
 	count := len(p.FIELDS)
@@ -165,22 +215,15 @@ func (p *RedisHashMFieldsCounterInt64) operationModifiesAmounts(cmd string, amou
 
 	err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(p.Redis)
 	if err != nil {
-		return nil, err
+		return nil, redisError(err, "", "")
 	}
 
-	values := make([]int64, count)
+	results := make([]ResultInt64, count)
 	for i, field := range p.FIELDS {
-		ptr, err := toInt64Ptr(commands[i].Reply())
-		switch {
-		case nil != err:
-			return nil, err
-		case nil != ptr:
-			p.Cache.Set(field, ptr)
-			values[i] = *ptr
-		}
+		results[i] = p.cacheResult(field, commands[i].Reply())
 	}
 
-	return values, nil
+	return results, nil
 }
 
 func (p *RedisHashMFieldsCounterInt64) operationReplacesAmounts(amount int64) ([]int64, error) {
@@ -195,7 +238,7 @@ func (p *RedisHashMFieldsCounterInt64) operationReplacesAmounts(amount int64) ([
 
 	reply := p.Redis.Cmd("HMSET", p.KEY, buffer)
 	if nil != reply.Err {
-		return nil, reply.Err
+		return nil, redisError(reply.Err, p.KEY, "")
 	}
 
 	values := make([]int64, count)
@@ -206,3 +249,42 @@ func (p *RedisHashMFieldsCounterInt64) operationReplacesAmounts(amount int64) ([
 
 	return values, nil
 }
+
+// Parse the reply for one field into the cache
+func (p *RedisHashMFieldsCounterInt64) cacheResult(field string, reply *redis.Reply) ResultInt64 {
+	ptr, err := toInt64Ptr(reply, p.KEY, field)
+	p.Cache.Set(field, ptr)
+	return makeResultInt64(field, ptr, err)
+}
+
+// Identity and "Cache" of the counters, for their encodings
+func (p *RedisHashMFieldsCounterInt64) state() *counterState {
+	values := make([]*string, len(p.FIELDS))
+	for i, field := range p.FIELDS {
+		values[i] = int64Text(p.Cache.Value(field))
+	}
+	return &counterState{
+		Type:   SnapshotInt64,
+		Shape:  shapeFields,
+		Key:    p.KEY,
+		Names:  p.FIELDS,
+		Values: values,
+	}
+}
+
+func (p *RedisHashMFieldsCounterInt64) setState(s *counterState) error {
+	if len(s.Names) == 0 {
+		return ErrNoFields
+	}
+
+	cache := MakeMapStringToInt64Ptrs(len(s.Names))
+	for i, field := range s.Names {
+		value, err := textInt64(s.Values[i])
+		if nil != err {
+			return err
+		}
+		cache.Set(field, value)
+	}
+	p.KEY, p.FIELDS, p.Cache = s.Key, s.Names, cache
+	return nil
+}
@@ -377,6 +377,26 @@ func RedisHashMFieldsCounterInt64Specs(c gospec.Context) {
 		}
 	})
 
+	c.Specify("[RedisHashMFieldsCounterInt64][MGetOptional] Tells missing from zero", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashMFieldsCounterInt64(server.Connection(), "Hash", "Bob", "George")
+		server.Connection().Cmd("HSET", "Hash", "George", "0")
+
+		counters, found, err := value.MGetOptional()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(counters), gospec.Equals, 2)
+		c.Expect(counters[0], gospec.Equals, int64(0))
+		c.Expect(counters[1], gospec.Equals, int64(0))
+		c.Expect(len(found), gospec.Equals, 2)
+		c.Expect(found[0], gospec.Equals, false)
+		c.Expect(found[1], gospec.Equals, true)
+	})
 }
 
 func Benchmark_RedisHashMFieldsCounterInt64_MMake(b *testing.B) {
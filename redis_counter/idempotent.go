@@ -0,0 +1,145 @@
+package redis_counter
+
+import "fmt"
+import "strconv"
+import "strings"
+import "time"
+
+// How long each request ID is remembered after its idempotent add; a
+// request replayed later is counted again
+const DedupTTL = time.Hour
+
+// Prefix of the bookkeeping keys of each counter: a hash of the result of
+// each request ID, and a sorted set of when each request ID expires.
+// RedisKeyDiscovery, Export and the collectors skip the keys with it.
+// On Redis Cluster, the counter key needs a hash tag (e.g. "{hits}:bob")
+// so its bookkeeping keys map to the same slot.
+const DedupPrefix = "__dedup__:"
+
+// Whether "key" is reserved for bookkeeping rather than a counter
+func IsReservedKey(key string) bool {
+	return strings.HasPrefix(key, DedupPrefix)
+}
+
+// Add "amount" at most once per "request_id": the first call increments
+// the counter, and replays within DedupTTL return the result of the first
+// call without incrementing. Saves the result to "LastValue".
+func (p *RedisKeyCounterInt64) AddIdempotent(request_id string, amount int64) (int64, error) {
+	p.LastValue = nil
+	if len(request_id) == 0 {
+		return 0, fmt.Errorf("Empty request id")
+	}
+
+	reply := dedupIncrementScript.eval(p.Redis, dedupKeys(p.KEY), dedupNow(), DedupTTL.Milliseconds(), request_id, "GET", "INCRBY", amount)
+	ptr, err := toInt64Ptr(reply, p.KEY, "")
+	if nil != err {
+		return 0, err
+	}
+	p.LastValue = ptr
+	return valueOrZeroInt64(ptr), nil
+}
+
+// Add "amount" at most once per "request_id": the first call increments
+// the counter, and replays within DedupTTL return the result of the first
+// call without incrementing. Saves the result to "LastValue".
+func (p *RedisKeyCounterFloat64) AddIdempotent(request_id string, amount float64) (float64, error) {
+	p.LastValue = nil
+	if len(request_id) == 0 {
+		return 0, fmt.Errorf("Empty request id")
+	}
+
+	reply := dedupIncrementScript.eval(p.Redis, dedupKeys(p.KEY), dedupNow(), DedupTTL.Milliseconds(), request_id, "GET", "INCRBYFLOAT", amount)
+	ptr, err := toFloat64Ptr(reply, p.KEY, "")
+	if nil != err {
+		return 0, err
+	}
+	p.LastValue = ptr
+	return valueOrZeroFloat64(ptr), nil
+}
+
+// Add "amount" at most once per "request_id": the first call increments
+// the counter, and replays within DedupTTL return the result of the first
+// call without incrementing. Saves the result to "LastValue".
+func (p *RedisHashFieldCounterInt64) AddIdempotent(request_id string, amount int64) (int64, error) {
+	p.LastValue = nil
+	if len(request_id) == 0 {
+		return 0, fmt.Errorf("Empty request id")
+	}
+
+	reply := dedupIncrementScript.eval(p.Redis, dedupKeys(p.KEY), dedupNow(), DedupTTL.Milliseconds(), dedupMember(request_id, p.FIELD), "HGET", "HINCRBY", p.FIELD, amount)
+	ptr, err := toInt64Ptr(reply, p.KEY, p.FIELD)
+	if nil != err {
+		return 0, err
+	}
+	p.LastValue = ptr
+	return valueOrZeroInt64(ptr), nil
+}
+
+// Add "amount" at most once per "request_id": the first call increments
+// the counter, and replays within DedupTTL return the result of the first
+// call without incrementing. Saves the result to "LastValue".
+func (p *RedisHashFieldCounterFloat64) AddIdempotent(request_id string, amount float64) (float64, error) {
+	p.LastValue = nil
+	if len(request_id) == 0 {
+		return 0, fmt.Errorf("Empty request id")
+	}
+
+	reply := dedupIncrementScript.eval(p.Redis, dedupKeys(p.KEY), dedupNow(), DedupTTL.Milliseconds(), dedupMember(request_id, p.FIELD), "HGET", "HINCRBYFLOAT", p.FIELD, amount)
+	ptr, err := toFloat64Ptr(reply, p.KEY, p.FIELD)
+	if nil != err {
+		return 0, err
+	}
+	p.LastValue = ptr
+	return valueOrZeroFloat64(ptr), nil
+}
+
+//
+// Internal Helpers:
+//
+
+// Runs the increment ARGV[5] with the arguments ARGV[6:] on KEYS[1],
+// unless the hash KEYS[2] holds a result for the request ID ARGV[3]; a
+// replay returns that result instead. ARGV[1] is the time in
+// milliseconds, and each request ID expires ARGV[2] milliseconds after
+// its increment: the sorted set KEYS[3] scores the IDs by expiry, and up
+// to 100 expired IDs are dropped on each call. Both keys expire with the
+// last ID. The increment runs before HSET, so a failed increment doesn't
+// record the request. The result is read back with ARGV[4], as Lua would
+// round an integer reply past 2^53.
+var dedupIncrementScript = makeRedisScript(`
+local expired = redis.call('ZRANGEBYSCORE', KEYS[3], '-inf', ARGV[1], 'LIMIT', 0, 100)
+if #expired > 0 then
+	redis.call('HDEL', KEYS[2], unpack(expired))
+	redis.call('ZREM', KEYS[3], unpack(expired))
+end
+
+local result = redis.call('HGET', KEYS[2], ARGV[3])
+if result then
+	return result
+end
+
+redis.call(ARGV[5], KEYS[1], unpack(ARGV, 6))
+local value = redis.call(ARGV[4], KEYS[1], unpack(ARGV, 6, #ARGV - 1))
+redis.call('HSET', KEYS[2], ARGV[3], value)
+redis.call('ZADD', KEYS[3], tonumber(ARGV[1]) + tonumber(ARGV[2]), ARGV[3])
+redis.call('PEXPIRE', KEYS[2], ARGV[2])
+redis.call('PEXPIRE', KEYS[3], ARGV[2])
+return value
+`)
+
+// The counter key, and the result hash and expiry set of its request IDs;
+// the different tags keep the two apart for any counter key
+func dedupKeys(key string) []string {
+	return []string{key, DedupPrefix + "results:" + key, DedupPrefix + "expiry:" + key}
+}
+
+// Current time for dedupIncrementScript, in milliseconds
+func dedupNow() int64 {
+	return time.Now().UnixNano() / int64(time.Millisecond)
+}
+
+// Request ID of a hash for a request to "field"; the length
+// prefix keeps "a:b" + "c" apart from "a" + "b:c"
+func dedupMember(request_id, field string) string {
+	return strconv.Itoa(len(field)) + ":" + field + ":" + request_id
+}
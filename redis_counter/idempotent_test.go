@@ -0,0 +1,187 @@
+package redis_counter
+
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestIdempotentSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(IdempotentSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func IdempotentSpecs(c gospec.Context) {
+
+	c.Specify("[RedisKeyCounterInt64][AddIdempotent] Adds once per request", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterInt64(server.Connection(), "Bob")
+
+		_, err := value.AddIdempotent("", 1)
+		c.Expect(err.Error(), gospec.Equals, "Empty request id")
+
+		counter, err := value.AddIdempotent("request-1", 5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(5))
+		c.Expect(*value.LastValue, gospec.Equals, int64(5))
+
+		counter, err = value.AddIdempotent("request-2", 2)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(7))
+
+		// Replay returns the first result:
+		counter, err = value.AddIdempotent("request-1", 5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(5))
+		c.Expect(*value.LastValue, gospec.Equals, int64(5))
+
+		counter, _ = value.Get()
+		c.Expect(counter, gospec.Equals, int64(7))
+
+		results, _ := server.Connection().Cmd("HLEN", DedupPrefix+"results:Bob").Int64()
+		c.Expect(results, gospec.Equals, int64(2))
+
+		ttl, _ := server.Connection().Cmd("PTTL", DedupPrefix+"expiry:Bob").Int64()
+		c.Expect(ttl, gospec.Satisfies, ttl > 0 && ttl <= DedupTTL.Milliseconds())
+	})
+
+	c.Specify("[RedisKeyCounterInt64][AddIdempotent] Expires each request id on its own", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterInt64(server.Connection(), "Bob")
+		value.AddIdempotent("request-1", 5)
+		value.AddIdempotent("request-2", 2)
+
+		// Expire only "request-1":
+		server.Connection().Cmd("ZADD", DedupPrefix+"expiry:Bob", 0, "request-1")
+
+		counter, err := value.AddIdempotent("request-1", 5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(12))
+
+		counter, err = value.AddIdempotent("request-2", 2)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(7))
+
+		members, _ := server.Connection().Cmd("ZCARD", DedupPrefix+"expiry:Bob").Int64()
+		c.Expect(members, gospec.Equals, int64(2))
+	})
+
+	c.Specify("[RedisKeyCounterInt64][AddIdempotent] Keeps the dedup set out of aggregates", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		bob, _ := MakeRedisKeyCounterInt64(server.Connection(), "hits:bob")
+		gary, _ := MakeRedisKeyCounterInt64(server.Connection(), "hits:gary")
+		bob.AddIdempotent("request-1", 5)
+		bob.AddIdempotent("request-1", 5)
+		gary.Add(2)
+
+		discovery, _ := MakeRedisKeyDiscovery(server.Connection(), "*", 10)
+		sum, err := discovery.SumInt64()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(sum, gospec.Equals, int64(7))
+
+		keys, err := discovery.Keys()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(keys), gospec.Equals, 2)
+
+		c.Expect(IsReservedKey(DedupPrefix+"results:hits:bob"), gospec.Equals, true)
+		c.Expect(IsReservedKey("hits:bob"), gospec.Equals, false)
+	})
+
+	c.Specify("[RedisKeyCounterFloat64][AddIdempotent] Adds once per request", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterFloat64(server.Connection(), "Bob")
+
+		counter, err := value.AddIdempotent("request-1", 1.5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, 1.5)
+
+		counter, err = value.AddIdempotent("request-1", 1.5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, 1.5)
+
+		counter, _ = value.Get()
+		c.Expect(counter, gospec.Equals, 1.5)
+	})
+
+	c.Specify("[RedisHashFieldCounterInt64][AddIdempotent] Adds once per request", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		bob, _ := MakeRedisHashFieldCounterInt64(server.Connection(), "Hash", "Bob")
+		gary, _ := MakeRedisHashFieldCounterInt64(server.Connection(), "Hash", "Gary")
+
+		counter, err := bob.AddIdempotent("request-1", 3)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(3))
+
+		// Request IDs are per field:
+		counter, err = gary.AddIdempotent("request-1", 4)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(4))
+
+		counter, err = bob.AddIdempotent("request-2", 1)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(4))
+
+		counter, err = bob.AddIdempotent("request-1", 3)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(3))
+
+		counter, _ = bob.Get()
+		c.Expect(counter, gospec.Equals, int64(4))
+	})
+
+	c.Specify("[RedisHashFieldCounterFloat64][AddIdempotent] Adds once per request", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashFieldCounterFloat64(server.Connection(), "Hash", "Bob")
+
+		counter, err := value.AddIdempotent("request-1", 0.25)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, 0.25)
+
+		counter, err = value.AddIdempotent("request-1", 0.25)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, 0.25)
+
+		counter, _ = value.Get()
+		c.Expect(counter, gospec.Equals, 0.25)
+	})
+}
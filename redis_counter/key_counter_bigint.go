@@ -0,0 +1,184 @@
+package redis_counter
+
+import "fmt"
+import "math/big"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Counter of unbounded integers, stored as a decimal string and added to
+// atomically by a Lua script; for totals that outgrow an int64
+type RedisKeyCounterBigInt struct {
+	Redis     dog_pool.RedisClientInterface
+	KEY       string
+	LastValue *big.Int
+}
+
+// Make a new instance of RedisKeyCounterBigInt
+func MakeRedisKeyCounterBigInt(redis dog_pool.RedisClientInterface, key string) (*RedisKeyCounterBigInt, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	case len(key) == 0:
+		return nil, &ErrEmptyKey{Index: -1}
+	default:
+		return &RedisKeyCounterBigInt{redis, key, nil}, nil
+	}
+}
+
+// Format the value as a string; uses the cached "LastValue" field
+func (p *RedisKeyCounterBigInt) String() string {
+	switch p.LastValue {
+	case nil:
+		return fmt.Sprintf("%s = NaN", p.KEY)
+	default:
+		return fmt.Sprintf("%s = %s", p.KEY, p.LastValue)
+	}
+}
+
+// Get the value of the counter; saves the counter to "LastValue"
+func (p *RedisKeyCounterBigInt) BigInt() (*big.Int, error) {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("GET", p.KEY)
+	ptr, err := toBigIntPtr(reply, p.KEY, "")
+	if nil != err {
+		return new(big.Int), err
+	}
+	p.LastValue = ptr
+	return valueOrZeroBigInt(ptr), nil
+}
+
+func (p *RedisKeyCounterBigInt) Exists() (bool, error) {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("EXISTS", p.KEY)
+	if nil != reply.Err {
+		return false, redisError(reply.Err, p.KEY, "")
+	}
+
+	ok, err := reply.Int()
+	if err != nil {
+		return false, err
+	}
+
+	return ok == 1, nil
+}
+
+func (p *RedisKeyCounterBigInt) Delete() error {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("DEL", p.KEY)
+	return redisError(reply.Err, p.KEY, "")
+}
+
+func (p *RedisKeyCounterBigInt) Get() (*big.Int, error) {
+	return p.BigInt()
+}
+
+// Get the value of the counter and whether it exists, so a missing
+// counter can be told from one holding 0; saves the counter to "LastValue"
+func (p *RedisKeyCounterBigInt) GetOptional() (*big.Int, bool, error) {
+	value, err := p.BigInt()
+	return value, nil == err && nil != p.LastValue, err
+}
+
+func (p *RedisKeyCounterBigInt) Set(amount *big.Int) (*big.Int, error) {
+	p.LastValue = nil
+	if nil == amount {
+		return new(big.Int), fmt.Errorf("Nil amount")
+	}
+
+	reply := p.Redis.Cmd("SET", p.KEY, amount.String())
+	if nil != reply.Err {
+		return new(big.Int), redisError(reply.Err, p.KEY, "")
+	}
+	p.LastValue = new(big.Int).Set(amount)
+	return p.LastValue, nil
+}
+
+func (p *RedisKeyCounterBigInt) Add(amount *big.Int) (*big.Int, error) {
+	if nil == amount {
+		p.LastValue = nil
+		return new(big.Int), fmt.Errorf("Nil amount")
+	}
+	return p.operationModifiesAmount(amount.String())
+}
+
+func (p *RedisKeyCounterBigInt) Sub(amount *big.Int) (*big.Int, error) {
+	if nil == amount {
+		p.LastValue = nil
+		return new(big.Int), fmt.Errorf("Nil amount")
+	}
+	return p.operationModifiesAmount(new(big.Int).Neg(amount).String())
+}
+
+func (p *RedisKeyCounterBigInt) Increment() (*big.Int, error) {
+	return p.operationModifiesAmount("1")
+}
+
+func (p *RedisKeyCounterBigInt) Decrement() (*big.Int, error) {
+	return p.operationModifiesAmount("-1")
+}
+
+// Encode the key and "LastValue" as JSON; a missing counter is null
+func (p *RedisKeyCounterBigInt) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key and "LastValue" from JSON
+func (p *RedisKeyCounterBigInt) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotBigInt, shapeKey)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisKeyCounterBigInt) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key and "LastValue" in the binary encoding
+func (p *RedisKeyCounterBigInt) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key and "LastValue" from the binary encoding
+func (p *RedisKeyCounterBigInt) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotBigInt, shapeKey)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+//
+// Internal Helpers:
+//
+
+func (p *RedisKeyCounterBigInt) operationModifiesAmount(amount string) (*big.Int, error) {
+	p.LastValue = nil
+	reply := bigIntAddScript.eval(p.Redis, []string{p.KEY}, "", amount)
+	ptr, err := toBigIntPtr(reply, p.KEY, "")
+	if nil != err {
+		return new(big.Int), err
+	}
+	p.LastValue = ptr
+	return valueOrZeroBigInt(ptr), nil
+}
+
+// Identity and "LastValue" of the counter, for its encodings
+func (p *RedisKeyCounterBigInt) state() *counterState {
+	return &counterState{
+		Type:   SnapshotBigInt,
+		Shape:  shapeKey,
+		Key:    p.KEY,
+		Values: []*string{bigIntText(p.LastValue)},
+	}
+}
+
+func (p *RedisKeyCounterBigInt) setState(s *counterState) error {
+	value, err := textBigInt(s.Values[0])
+	if nil != err {
+		return err
+	}
+	p.KEY, p.LastValue = s.Key, value
+	return nil
+}
@@ -0,0 +1,164 @@
+package redis_counter
+
+import "errors"
+import "math/big"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisKeyCounterBigIntSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisKeyCounterBigIntSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisKeyCounterBigIntSpecs(c gospec.Context) {
+
+	c.Specify("[RedisKeyCounterBigInt][Make] Makes new instance", func() {
+		value, err := MakeRedisKeyCounterBigInt(nil, "")
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisKeyCounterBigInt(&dog_pool.RedisConnection{}, "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisKeyCounterBigInt(&dog_pool.RedisConnection{}, "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[RedisKeyCounterBigInt][String] Formats string", func() {
+		value, _ := MakeRedisKeyCounterBigInt(&dog_pool.RedisConnection{}, "Bob")
+		c.Expect(value.String(), gospec.Equals, "Bob = NaN")
+
+		value.LastValue, _ = new(big.Int).SetString("123456789012345678901234567890", 10)
+		c.Expect(value.String(), gospec.Equals, "Bob = 123456789012345678901234567890")
+	})
+
+	c.Specify("[RedisKeyCounterBigInt][Get] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterBigInt(server.Connection(), "Bob")
+
+		// Valid number:
+		server.Connection().Cmd("SET", "Bob", "-98765432109876543210")
+		counter, err := value.Get()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "-98765432109876543210")
+		c.Expect(value.LastValue.String(), gospec.Equals, "-98765432109876543210")
+
+		// Cache Miss
+		server.Connection().Cmd("DEL", "Bob")
+		counter, found, err := value.GetOptional()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(found, gospec.Equals, false)
+		c.Expect(counter.Sign(), gospec.Equals, 0)
+		c.Expect(value.LastValue, gospec.Satisfies, nil == value.LastValue)
+
+		// Parsing error:
+		server.Connection().Cmd("SET", "Bob", "Gary")
+		_, err = value.Get()
+		var nan_err *ErrNotANumber
+		c.Expect(errors.As(err, &nan_err), gospec.Equals, true)
+		c.Expect(nan_err.Raw, gospec.Equals, "Gary")
+		c.Expect(value.LastValue, gospec.Satisfies, nil == value.LastValue)
+	})
+
+	c.Specify("[RedisKeyCounterBigInt][Add] Adds past the int64 range", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterBigInt(server.Connection(), "Bob")
+
+		counter, err := value.Set(big.NewInt(9223372036854775807))
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "9223372036854775807")
+
+		counter, err = value.Increment()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "9223372036854775808")
+
+		amount, _ := new(big.Int).SetString("99999999999999999999", 10)
+		counter, err = value.Add(amount)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "109223372036854775807")
+
+		stored, _ := server.Connection().Cmd("GET", "Bob").Str()
+		c.Expect(stored, gospec.Equals, "109223372036854775807")
+
+		// Crosses zero:
+		amount, _ = new(big.Int).SetString("109223372036854775808", 10)
+		counter, err = value.Sub(amount)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "-1")
+
+		counter, err = value.Increment()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "0")
+
+		counter, err = value.Decrement()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "-1")
+
+		_, err = value.Add(nil)
+		c.Expect(err.Error(), gospec.Equals, "Nil amount")
+	})
+
+	c.Specify("[RedisKeyCounterBigInt][Add] Keeps the expiry", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterBigInt(server.Connection(), "Bob")
+		server.Connection().Cmd("SET", "Bob", "9999999", "EX", 100)
+
+		counter, err := value.Increment()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "10000000")
+
+		ttl, _ := server.Connection().Cmd("PTTL", "Bob").Int64()
+		c.Expect(ttl, gospec.Satisfies, ttl > 0)
+
+		// Not a number:
+		server.Connection().Cmd("SET", "Bob", "1.5")
+		_, err = value.Increment()
+		var nan_err *ErrNotANumber
+		c.Expect(errors.As(err, &nan_err), gospec.Equals, true)
+	})
+
+	c.Specify("[RedisKeyCounterBigInt][Delete] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterBigInt(server.Connection(), "Bob")
+		value.Increment()
+
+		ok, _ := value.Exists()
+		c.Expect(ok, gospec.Equals, true)
+		c.Expect(value.Delete(), gospec.Equals, nil)
+		ok, _ = value.Exists()
+		c.Expect(ok, gospec.Equals, false)
+	})
+}
@@ -0,0 +1,196 @@
+package redis_counter
+
+import "fmt"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Counter of decimals with SCALE digits after the decimal point, stored
+// as an integer number of 10^-SCALE units; e.g. at scale 2, 12.50 is
+// stored as 1250 and incremented with INCRBY, so it never rounds
+type RedisKeyCounterDecimal struct {
+	Redis     dog_pool.RedisClientInterface
+	KEY       string
+	SCALE     int
+	LastValue *Decimal
+	Format    *Formatter // nil for DefaultFormatter
+}
+
+// Make a new instance of RedisKeyCounterDecimal
+func MakeRedisKeyCounterDecimal(redis dog_pool.RedisClientInterface, key string, scale int) (*RedisKeyCounterDecimal, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	case len(key) == 0:
+		return nil, &ErrEmptyKey{Index: -1}
+	case scale < 0 || scale > MaxDecimalScale:
+		return nil, fmt.Errorf("Invalid decimal scale: %d", scale)
+	default:
+		return &RedisKeyCounterDecimal{redis, key, scale, nil, nil}, nil
+	}
+}
+
+// Format the value as a string with "Format"; uses the cached "LastValue" field
+func (p *RedisKeyCounterDecimal) String() string {
+	f := formatterOrDefault(p.Format)
+	return f.formatKey(p.KEY, f.Decimal(p.LastValue))
+}
+
+// Get the value of the counter; saves the counter to "LastValue"
+func (p *RedisKeyCounterDecimal) Decimal() (Decimal, error) {
+	return p.operationReturnsAmount("GET")
+}
+
+func (p *RedisKeyCounterDecimal) Exists() (bool, error) {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("EXISTS", p.KEY)
+	if nil != reply.Err {
+		return false, redisError(reply.Err, p.KEY, "")
+	}
+
+	ok, err := reply.Int()
+	if err != nil {
+		return false, err
+	}
+
+	return ok == 1, nil
+}
+
+func (p *RedisKeyCounterDecimal) Delete() error {
+	p.LastValue = nil
+	reply := p.Redis.Cmd("DEL", p.KEY)
+	return redisError(reply.Err, p.KEY, "")
+}
+
+func (p *RedisKeyCounterDecimal) Get() (Decimal, error) {
+	return p.Decimal()
+}
+
+// Get the value of the counter and whether it exists, so a missing
+// counter can be told from one holding 0; saves the counter to "LastValue"
+func (p *RedisKeyCounterDecimal) GetOptional() (Decimal, bool, error) {
+	value, err := p.Decimal()
+	return value, nil == err && nil != p.LastValue, err
+}
+
+// Set the counter to "amount", which must fit in SCALE decimal places
+func (p *RedisKeyCounterDecimal) Set(amount Decimal) (Decimal, error) {
+	return p.operationReplacesAmount("SET", amount)
+}
+
+// Add "amount", which must fit in SCALE decimal places
+func (p *RedisKeyCounterDecimal) Add(amount Decimal) (Decimal, error) {
+	return p.operationModifiesAmount("INCRBY", amount)
+}
+
+// Subtract "amount", which must fit in SCALE decimal places
+func (p *RedisKeyCounterDecimal) Sub(amount Decimal) (Decimal, error) {
+	return p.operationModifiesAmount("DECRBY", amount)
+}
+
+// Encode the key and "LastValue" as JSON; a missing counter is null
+func (p *RedisKeyCounterDecimal) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key and "LastValue" from JSON
+func (p *RedisKeyCounterDecimal) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotDecimal, shapeKey)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisKeyCounterDecimal) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key and "LastValue" in the binary encoding
+func (p *RedisKeyCounterDecimal) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key and "LastValue" from the binary encoding
+func (p *RedisKeyCounterDecimal) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotDecimal, shapeKey)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+//
+// Internal Helpers:
+//
+
+func (p *RedisKeyCounterDecimal) operationReturnsAmount(cmd string) (Decimal, error) {
+	p.LastValue = nil
+	reply := p.Redis.Cmd(cmd, p.KEY)
+	ptr, err := toInt64Ptr(reply, p.KEY, "")
+	switch {
+	case nil != err:
+		return Decimal{0, p.SCALE}, err
+	case nil != ptr:
+		p.LastValue = &Decimal{*ptr, p.SCALE}
+		return *p.LastValue, nil
+	default:
+		return Decimal{0, p.SCALE}, nil
+	}
+}
+
+func (p *RedisKeyCounterDecimal) operationModifiesAmount(cmd string, amount Decimal) (Decimal, error) {
+	p.LastValue = nil
+	amount, err := amount.Rescale(p.SCALE)
+	if nil != err {
+		return Decimal{0, p.SCALE}, err
+	}
+
+	reply := p.Redis.Cmd(cmd, p.KEY, amount.Units)
+	ptr, err := toInt64Ptr(reply, p.KEY, "")
+	switch {
+	case nil != err:
+		return Decimal{0, p.SCALE}, err
+	case nil != ptr:
+		p.LastValue = &Decimal{*ptr, p.SCALE}
+		return *p.LastValue, nil
+	default:
+		return Decimal{0, p.SCALE}, nil
+	}
+}
+
+func (p *RedisKeyCounterDecimal) operationReplacesAmount(cmd string, amount Decimal) (Decimal, error) {
+	p.LastValue = nil
+	amount, err := amount.Rescale(p.SCALE)
+	if nil != err {
+		return Decimal{0, p.SCALE}, err
+	}
+
+	reply := p.Redis.Cmd(cmd, p.KEY, amount.Units)
+	switch {
+	case nil != reply.Err:
+		return Decimal{0, p.SCALE}, redisError(reply.Err, p.KEY, "")
+	default:
+		p.LastValue = &amount
+		return amount, nil
+	}
+}
+
+// Identity and "LastValue" of the counter, for its encodings
+func (p *RedisKeyCounterDecimal) state() *counterState {
+	return &counterState{
+		Type:   SnapshotDecimal,
+		Shape:  shapeKey,
+		Key:    p.KEY,
+		Scale:  p.SCALE,
+		Values: []*string{decimalText(p.LastValue)},
+	}
+}
+
+func (p *RedisKeyCounterDecimal) setState(s *counterState) error {
+	value, err := textDecimal(s.Values[0], s.Scale)
+	if nil != err {
+		return err
+	}
+	p.KEY, p.SCALE, p.LastValue = s.Key, s.Scale, value
+	return nil
+}
@@ -0,0 +1,133 @@
+package redis_counter
+
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisKeyCounterDecimalSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisKeyCounterDecimalSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisKeyCounterDecimalSpecs(c gospec.Context) {
+
+	c.Specify("[RedisKeyCounterDecimal][Make] Makes new instance", func() {
+		value, err := MakeRedisKeyCounterDecimal(nil, "", 2)
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisKeyCounterDecimal(&dog_pool.RedisConnection{}, "", 2)
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisKeyCounterDecimal(&dog_pool.RedisConnection{}, "Bob", 19)
+		c.Expect(err.Error(), gospec.Equals, "Invalid decimal scale: 19")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisKeyCounterDecimal(&dog_pool.RedisConnection{}, "Bob", 2)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.SCALE, gospec.Equals, 2)
+	})
+
+	c.Specify("[RedisKeyCounterDecimal][String] Formats string", func() {
+		value, _ := MakeRedisKeyCounterDecimal(&dog_pool.RedisConnection{}, "Bob", 2)
+		value.LastValue = nil
+		c.Expect(value.String(), gospec.Equals, "Bob = NaN")
+
+		value.LastValue = &Decimal{1005, 2}
+		c.Expect(value.String(), gospec.Equals, "Bob = 10.05")
+	})
+
+	c.Specify("[RedisKeyCounterDecimal][Get] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterDecimal(server.Connection(), "Bob", 2)
+
+		// Valid number:
+		server.Connection().Cmd("SET", "Bob", "1250")
+		counter, err := value.Get()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, Decimal{1250, 2})
+		c.Expect(*value.LastValue, gospec.Equals, Decimal{1250, 2})
+
+		// Cache Miss
+		server.Connection().Cmd("DEL", "Bob")
+		counter, found, err := value.GetOptional()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(found, gospec.Equals, false)
+		c.Expect(counter, gospec.Equals, Decimal{0, 2})
+		c.Expect(value.LastValue, gospec.Satisfies, nil == value.LastValue)
+
+		// Parsing error:
+		server.Connection().Cmd("SET", "Bob", "12.50")
+		_, err = value.Get()
+		c.Expect(err, gospec.Satisfies, nil != err)
+		c.Expect(value.LastValue, gospec.Satisfies, nil == value.LastValue)
+	})
+
+	c.Specify("[RedisKeyCounterDecimal][Add] Adds exactly", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterDecimal(server.Connection(), "Bob", 2)
+
+		// 0.1 added ten times is exactly 1.00:
+		cent, _ := ParseDecimal("0.1")
+		for i := 0; i < 10; i++ {
+			value.Add(cent)
+		}
+		counter, err := value.Get()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.String(), gospec.Equals, "1.00")
+
+		counter, err = value.Sub(Decimal{25, 2})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, Decimal{75, 2})
+
+		stored, _ := server.Connection().Cmd("GET", "Bob").Str()
+		c.Expect(stored, gospec.Equals, "75")
+
+		// Amounts past the scale are refused:
+		_, err = value.Add(Decimal{1, 3})
+		c.Expect(err.Error(), gospec.Equals, "Decimal 0.001 has more than 2 decimal places")
+		counter, _ = value.Get()
+		c.Expect(counter, gospec.Equals, Decimal{75, 2})
+	})
+
+	c.Specify("[RedisKeyCounterDecimal][Set] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterDecimal(server.Connection(), "Bob", 4)
+
+		counter, err := value.Set(Decimal{-5, 1})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, Decimal{-5000, 4})
+		c.Expect(value.String(), gospec.Equals, "Bob = -0.5000")
+
+		ok, _ := value.Exists()
+		c.Expect(ok, gospec.Equals, true)
+		c.Expect(value.Delete(), gospec.Equals, nil)
+		ok, _ = value.Exists()
+		c.Expect(ok, gospec.Equals, false)
+	})
+}
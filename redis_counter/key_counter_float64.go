@@ -1,34 +1,31 @@
 package redis_counter
 
-import "fmt"
 import "github.com/gnagel/dog_pool/dog_pool"
 
 type RedisKeyCounterFloat64 struct {
 	Redis     dog_pool.RedisClientInterface
 	KEY       string
 	LastValue *float64
+	Precision int        // PrecisionIgnore, PrecisionWarn or PrecisionError
+	Format    *Formatter // nil for DefaultFormatter
 }
 
 // Make a new instance of RedisKeyCounterFloat64
 func MakeRedisKeyCounterFloat64(redis dog_pool.RedisClientInterface, key string) (*RedisKeyCounterFloat64, error) {
 	switch {
 	case nil == redis:
-		return nil, fmt.Errorf("Nil redis connection")
+		return nil, ErrNilConnection
 	case len(key) == 0:
-		return nil, fmt.Errorf("Empty redis key")
+		return nil, &ErrEmptyKey{Index: -1}
 	default:
-		return &RedisKeyCounterFloat64{redis, key, nil}, nil
+		return &RedisKeyCounterFloat64{redis, key, nil, PrecisionIgnore, nil}, nil
 	}
 }
 
-// Format the value as a string; uses the cached "LastValue" field
+// Format the value as a string with "Format"; uses the cached "LastValue" field
 func (p *RedisKeyCounterFloat64) String() string {
-	switch p.LastValue {
-	case nil:
-		return fmt.Sprintf("%s = NaN", p.KEY)
-	default:
-		return fmt.Sprintf("%s = %0.6f", p.KEY, *p.LastValue)
-	}
+	f := formatterOrDefault(p.Format)
+	return f.formatKey(p.KEY, f.Float64(p.LastValue))
 }
 
 // Get the value of the counter; saves the counter to "LastValue"
@@ -40,7 +37,7 @@ func (p *RedisKeyCounterFloat64) Exists() (bool, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd("EXISTS", p.KEY)
 	if nil != reply.Err {
-		return false, reply.Err
+		return false, redisError(reply.Err, p.KEY, "")
 	}
 
 	ok, err := reply.Int()
@@ -54,13 +51,20 @@ func (p *RedisKeyCounterFloat64) Exists() (bool, error) {
 func (p *RedisKeyCounterFloat64) Delete() error {
 	p.LastValue = nil
 	reply := p.Redis.Cmd("DEL", p.KEY)
-	return reply.Err
+	return redisError(reply.Err, p.KEY, "")
 }
 
 func (p *RedisKeyCounterFloat64) Get() (float64, error) {
 	return p.Float64()
 }
 
+// Get the value of the counter and whether it exists, so a missing
+// counter can be told from one holding 0; saves the counter to "LastValue"
+func (p *RedisKeyCounterFloat64) GetOptional() (float64, bool, error) {
+	value, err := p.Float64()
+	return value, nil == err && nil != p.LastValue, err
+}
+
 func (p *RedisKeyCounterFloat64) Set(amount float64) (float64, error) {
 	return p.operationReplacesAmount("SET", amount)
 }
@@ -81,6 +85,39 @@ func (p *RedisKeyCounterFloat64) Decrement() (float64, error) {
 	return p.Sub(1.0)
 }
 
+// Encode the key and "LastValue" as JSON; a missing counter is null
+func (p *RedisKeyCounterFloat64) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key and "LastValue" from JSON
+func (p *RedisKeyCounterFloat64) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotFloat64, shapeKey)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisKeyCounterFloat64) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key and "LastValue" in the binary encoding
+func (p *RedisKeyCounterFloat64) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key and "LastValue" from the binary encoding
+func (p *RedisKeyCounterFloat64) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotFloat64, shapeKey)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
 //
 // Internal Helpers:
 //
@@ -88,13 +125,13 @@ func (p *RedisKeyCounterFloat64) Decrement() (float64, error) {
 func (p *RedisKeyCounterFloat64) operationReturnsAmount(cmd string) (float64, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd(cmd, p.KEY)
-	ptr, err := toFloat64Ptr(reply)
+	ptr, err := toFloat64Ptr(reply, p.KEY, "")
 	switch {
 	case nil != err:
 		return 0, err
 	case nil != ptr:
 		p.LastValue = ptr
-		return *ptr, nil
+		return *ptr, checkPrecision(p.Precision, p.KEY, "", *ptr)
 	default:
 		return 0, nil
 	}
@@ -103,13 +140,13 @@ func (p *RedisKeyCounterFloat64) operationReturnsAmount(cmd string) (float64, er
 func (p *RedisKeyCounterFloat64) operationModifiesAmount(cmd string, amount float64) (float64, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd(cmd, p.KEY, amount)
-	ptr, err := toFloat64Ptr(reply)
+	ptr, err := toFloat64Ptr(reply, p.KEY, "")
 	switch {
 	case nil != err:
 		return 0, err
 	case nil != ptr:
 		p.LastValue = ptr
-		return *ptr, nil
+		return *ptr, checkPrecision(p.Precision, p.KEY, "", *ptr)
 	default:
 		return 0, nil
 	}
@@ -120,9 +157,28 @@ func (p *RedisKeyCounterFloat64) operationReplacesAmount(cmd string, amount floa
 	reply := p.Redis.Cmd(cmd, p.KEY, amount)
 	switch {
 	case nil != reply.Err:
-		return 0, reply.Err
+		return 0, redisError(reply.Err, p.KEY, "")
 	default:
 		p.LastValue = &amount
-		return amount, nil
+		return amount, checkPrecision(p.Precision, p.KEY, "", amount)
+	}
+}
+
+// Identity and "LastValue" of the counter, for its encodings
+func (p *RedisKeyCounterFloat64) state() *counterState {
+	return &counterState{
+		Type:   SnapshotFloat64,
+		Shape:  shapeKey,
+		Key:    p.KEY,
+		Values: []*string{float64Text(p.LastValue)},
+	}
+}
+
+func (p *RedisKeyCounterFloat64) setState(s *counterState) error {
+	value, err := textFloat64(s.Values[0])
+	if nil != err {
+		return err
 	}
+	p.KEY, p.LastValue = s.Key, value
+	return nil
 }
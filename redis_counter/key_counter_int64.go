@@ -1,34 +1,31 @@
 package redis_counter
 
-import "fmt"
 import "github.com/gnagel/dog_pool/dog_pool"
 
 type RedisKeyCounterInt64 struct {
 	Redis     dog_pool.RedisClientInterface
 	KEY       string
 	LastValue *int64
+	Overflow  int        // OverflowError, OverflowSaturate or OverflowWrap, for Add and Sub
+	Format    *Formatter // nil for DefaultFormatter
 }
 
 // Make a new instance of RedisKeyCounterInt64
 func MakeRedisKeyCounterInt64(redis dog_pool.RedisClientInterface, key string) (*RedisKeyCounterInt64, error) {
 	switch {
 	case nil == redis:
-		return nil, fmt.Errorf("Nil redis connection")
+		return nil, ErrNilConnection
 	case len(key) == 0:
-		return nil, fmt.Errorf("Empty redis key")
+		return nil, &ErrEmptyKey{Index: -1}
 	default:
-		return &RedisKeyCounterInt64{redis, key, nil}, nil
+		return &RedisKeyCounterInt64{redis, key, nil, OverflowError, nil}, nil
 	}
 }
 
-// Format the value as a string; uses the cached "LastValue" field
+// Format the value as a string with "Format"; uses the cached "LastValue" field
 func (p *RedisKeyCounterInt64) String() string {
-	switch p.LastValue {
-	case nil:
-		return fmt.Sprintf("%s = NaN", p.KEY)
-	default:
-		return fmt.Sprintf("%s = %d", p.KEY, *p.LastValue)
-	}
+	f := formatterOrDefault(p.Format)
+	return f.formatKey(p.KEY, f.Int64(p.LastValue))
 }
 
 // Get the value of the counter; saves the counter to "LastValue"
@@ -40,7 +37,7 @@ func (p *RedisKeyCounterInt64) Exists() (bool, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd("EXISTS", p.KEY)
 	if nil != reply.Err {
-		return false, reply.Err
+		return false, redisError(reply.Err, p.KEY, "")
 	}
 
 	ok, err := reply.Int()
@@ -54,31 +51,77 @@ func (p *RedisKeyCounterInt64) Exists() (bool, error) {
 func (p *RedisKeyCounterInt64) Delete() error {
 	p.LastValue = nil
 	reply := p.Redis.Cmd("DEL", p.KEY)
-	return reply.Err
+	return redisError(reply.Err, p.KEY, "")
 }
 
 func (p *RedisKeyCounterInt64) Get() (int64, error) {
 	return p.Int64()
 }
 
+// Get the value of the counter and whether it exists, so a missing
+// counter can be told from one holding 0; saves the counter to "LastValue"
+func (p *RedisKeyCounterInt64) GetOptional() (int64, bool, error) {
+	value, err := p.Int64()
+	return value, nil == err && nil != p.LastValue, err
+}
+
 func (p *RedisKeyCounterInt64) Set(amount int64) (int64, error) {
 	return p.operationReplacesAmount("SET", amount)
 }
 
 func (p *RedisKeyCounterInt64) Add(amount int64) (int64, error) {
+	if OverflowError != p.Overflow {
+		return p.operationOverflows(amount)
+	}
 	return p.operationModifiesAmount("INCRBY", amount)
 }
 
 func (p *RedisKeyCounterInt64) Sub(amount int64) (int64, error) {
+	if OverflowError != p.Overflow {
+		return p.operationOverflows(negateInt64(amount)...)
+	}
 	return p.operationModifiesAmount("DECRBY", amount)
 }
 
 func (p *RedisKeyCounterInt64) Increment() (int64, error) {
-	return p.operationReturnsAmount("INCR")
+	return p.Add(1)
 }
 
 func (p *RedisKeyCounterInt64) Decrement() (int64, error) {
-	return p.operationReturnsAmount("DECR")
+	return p.Sub(1)
+}
+
+// Encode the key and "LastValue" as JSON; a missing counter is null
+func (p *RedisKeyCounterInt64) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the key and "LastValue" from JSON
+func (p *RedisKeyCounterInt64) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotInt64, shapeKey)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisKeyCounterInt64) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the key and "LastValue" in the binary encoding
+func (p *RedisKeyCounterInt64) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the key and "LastValue" from the binary encoding
+func (p *RedisKeyCounterInt64) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotInt64, shapeKey)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
 }
 
 //
@@ -88,7 +131,7 @@ func (p *RedisKeyCounterInt64) Decrement() (int64, error) {
 func (p *RedisKeyCounterInt64) operationReturnsAmount(cmd string) (int64, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd(cmd, p.KEY)
-	ptr, err := toInt64Ptr(reply)
+	ptr, err := toInt64Ptr(reply, p.KEY, "")
 	switch {
 	case nil != err:
 		return 0, err
@@ -103,7 +146,22 @@ func (p *RedisKeyCounterInt64) operationReturnsAmount(cmd string) (int64, error)
 func (p *RedisKeyCounterInt64) operationModifiesAmount(cmd string, amount int64) (int64, error) {
 	p.LastValue = nil
 	reply := p.Redis.Cmd(cmd, p.KEY, amount)
-	ptr, err := toInt64Ptr(reply)
+	ptr, err := toInt64Ptr(reply, p.KEY, "")
+	switch {
+	case nil != err:
+		return 0, err
+	case nil != ptr:
+		p.LastValue = ptr
+		return *ptr, nil
+	default:
+		return 0, nil
+	}
+}
+
+func (p *RedisKeyCounterInt64) operationOverflows(amounts ...int64) (int64, error) {
+	p.LastValue = nil
+	reply := overflowIncrement(p.Redis, p.Overflow, p.KEY, "", amounts...)
+	ptr, err := toInt64Ptr(reply, p.KEY, "")
 	switch {
 	case nil != err:
 		return 0, err
@@ -120,9 +178,28 @@ func (p *RedisKeyCounterInt64) operationReplacesAmount(cmd string, amount int64)
 	reply := p.Redis.Cmd(cmd, p.KEY, amount)
 	switch {
 	case nil != reply.Err:
-		return 0, reply.Err
+		return 0, redisError(reply.Err, p.KEY, "")
 	default:
 		p.LastValue = &amount
 		return amount, nil
 	}
 }
+
+// Identity and "LastValue" of the counter, for its encodings
+func (p *RedisKeyCounterInt64) state() *counterState {
+	return &counterState{
+		Type:   SnapshotInt64,
+		Shape:  shapeKey,
+		Key:    p.KEY,
+		Values: []*string{int64Text(p.LastValue)},
+	}
+}
+
+func (p *RedisKeyCounterInt64) setState(s *counterState) error {
+	value, err := textInt64(s.Values[0])
+	if nil != err {
+		return err
+	}
+	p.KEY, p.LastValue = s.Key, value
+	return nil
+}
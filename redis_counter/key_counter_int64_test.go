@@ -265,6 +265,35 @@ func RedisKeyCounterInt64Specs(c gospec.Context) {
 		c.Expect(counter, gospec.Equals, int64(123-1))
 	})
 
+	c.Specify("[RedisKeyCounterInt64][GetOptional] Tells missing from zero", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterInt64(server.Connection(), "Bob")
+
+		// Missing:
+		counter, found, err := value.GetOptional()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(0))
+		c.Expect(found, gospec.Equals, false)
+
+		// Zero:
+		server.Connection().Cmd("SET", "Bob", "0")
+		counter, found, err = value.GetOptional()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(0))
+		c.Expect(found, gospec.Equals, true)
+
+		// Not a number:
+		server.Connection().Cmd("SET", "Bob", "Gary")
+		_, found, err = value.GetOptional()
+		c.Expect(err, gospec.Satisfies, nil != err)
+		c.Expect(found, gospec.Equals, false)
+	})
 }
 
 func Benchmark_RedisKeyCounterInt64_Make(b *testing.B) {
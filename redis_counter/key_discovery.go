@@ -0,0 +1,324 @@
+package redis_counter
+
+import "container/heap"
+import "fmt"
+import "sort"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Default number of keys per counter made by RedisKeyDiscovery
+const DiscoveryBatchSize = 100
+
+// Finds the counters with keys matching a pattern, using SCAN so Redis is
+// never blocked the way KEYS blocks it. The keys are passed on in
+// RedisMKeysCounterInt64/Float64 instances of at most BatchSize keys.
+// Keys created or deleted during the scan may or may not be found. Each
+// key is seen once, even when SCAN returns it more than once, so every
+// scan holds the set of keys it has seen: memory grows with the number
+// of matching keys.
+type RedisKeyDiscovery struct {
+	Redis     *dog_pool.RedisConnection
+	PATTERN   string
+	BatchSize int
+}
+
+// One counter, as returned by Max and TopN
+type CounterRankInt64 struct {
+	Key   string
+	Value int64
+}
+
+// One counter, as returned by Max and TopN
+type CounterRankFloat64 struct {
+	Key   string
+	Value float64
+}
+
+// Make a new instance of RedisKeyDiscovery
+func MakeRedisKeyDiscovery(redis *dog_pool.RedisConnection, pattern string, batch_size int) (*RedisKeyDiscovery, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	case len(pattern) == 0:
+		return nil, fmt.Errorf("Empty redis pattern")
+	case batch_size <= 0:
+		return nil, fmt.Errorf("Invalid batch size: %d", batch_size)
+	default:
+		return &RedisKeyDiscovery{redis, pattern, batch_size}, nil
+	}
+}
+
+// Every key matching the pattern
+func (p *RedisKeyDiscovery) Keys() ([]string, error) {
+	keys := []string{}
+	err := p.eachBatch(func(batch []string) error {
+		keys = append(keys, batch...)
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Call "fn" with a counter for each batch of keys; stops at the first
+// error from "fn"
+func (p *RedisKeyDiscovery) EachInt64(fn func(counter *RedisMKeysCounterInt64) error) error {
+	return p.eachBatch(func(keys []string) error {
+		counter, err := MakeRedisMKeysCounterInt64(p.Redis, keys...)
+		if nil != err {
+			return err
+		}
+		return fn(counter)
+	})
+}
+
+// Call "fn" with a counter for each batch of keys; stops at the first
+// error from "fn"
+func (p *RedisKeyDiscovery) EachFloat64(fn func(counter *RedisMKeysCounterFloat64) error) error {
+	return p.eachBatch(func(keys []string) error {
+		counter, err := MakeRedisMKeysCounterFloat64(p.Redis, keys...)
+		if nil != err {
+			return err
+		}
+		return fn(counter)
+	})
+}
+
+// Sum of every counter
+func (p *RedisKeyDiscovery) SumInt64() (int64, error) {
+	sum := int64(0)
+	err := p.eachValueInt64(func(key string, value int64) {
+		sum += value
+	})
+	return sum, err
+}
+
+// Sum of every counter
+func (p *RedisKeyDiscovery) SumFloat64() (float64, error) {
+	sum := float64(0)
+	err := p.eachValueFloat64(func(key string, value float64) {
+		sum += value
+	})
+	return sum, err
+}
+
+// Counter with the largest value, or nil when none were found
+func (p *RedisKeyDiscovery) MaxInt64() (*CounterRankInt64, error) {
+	top, err := p.TopNInt64(1)
+	if nil != err || len(top) == 0 {
+		return nil, err
+	}
+	return &top[0], nil
+}
+
+// Counter with the largest value, or nil when none were found
+func (p *RedisKeyDiscovery) MaxFloat64() (*CounterRankFloat64, error) {
+	top, err := p.TopNFloat64(1)
+	if nil != err || len(top) == 0 {
+		return nil, err
+	}
+	return &top[0], nil
+}
+
+// The "n" counters with the largest values, largest first; ties are
+// ordered by key. Only "n" values are held in memory, besides the keys
+// seen by the scan.
+func (p *RedisKeyDiscovery) TopNInt64(n int) ([]CounterRankInt64, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("Invalid n: %d", n)
+	}
+
+	top := &rankHeapInt64{}
+	err := p.eachValueInt64(func(key string, value int64) {
+		rank := CounterRankInt64{key, value}
+		switch {
+		case top.Len() < n:
+			heap.Push(top, rank)
+		case top.less(top.ranks[0], rank):
+			top.ranks[0] = rank
+			heap.Fix(top, 0)
+		}
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	sort.Slice(top.ranks, func(i, j int) bool { return top.less(top.ranks[j], top.ranks[i]) })
+	return top.ranks, nil
+}
+
+// The "n" counters with the largest values, largest first; ties are
+// ordered by key. Only "n" values are held in memory, besides the keys
+// seen by the scan.
+func (p *RedisKeyDiscovery) TopNFloat64(n int) ([]CounterRankFloat64, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("Invalid n: %d", n)
+	}
+
+	top := &rankHeapFloat64{}
+	err := p.eachValueFloat64(func(key string, value float64) {
+		rank := CounterRankFloat64{key, value}
+		switch {
+		case top.Len() < n:
+			heap.Push(top, rank)
+		case top.less(top.ranks[0], rank):
+			top.ranks[0] = rank
+			heap.Fix(top, 0)
+		}
+	})
+	if nil != err {
+		return nil, err
+	}
+
+	sort.Slice(top.ranks, func(i, j int) bool { return top.less(top.ranks[j], top.ranks[i]) })
+	return top.ranks, nil
+}
+
+//
+// Internal Helpers:
+//
+
+// Group the scanned keys into batches of BatchSize
+func (p *RedisKeyDiscovery) eachBatch(fn func(keys []string) error) error {
+	batch := make([]string, 0, p.BatchSize)
+	err := scanKeyBatches(p.Redis, p.PATTERN, p.BatchSize, func(keys []string) error {
+		for _, key := range keys {
+			batch = append(batch, key)
+			if len(batch) == p.BatchSize {
+				if err := fn(batch); nil != err {
+					return err
+				}
+				batch = make([]string, 0, p.BatchSize)
+			}
+		}
+		return nil
+	})
+	switch {
+	case nil != err:
+		return err
+	case len(batch) > 0:
+		return fn(batch)
+	default:
+		return nil
+	}
+}
+
+// Call "fn" with every counter that still exists
+func (p *RedisKeyDiscovery) eachValueInt64(fn func(key string, value int64)) error {
+	return p.EachInt64(func(counter *RedisMKeysCounterInt64) error {
+		if _, err := counter.MGet(); nil != err {
+			return err
+		}
+		for _, key := range counter.KEYS {
+			if ptr := counter.Cache.Value(key); nil != ptr {
+				fn(key, *ptr)
+			}
+		}
+		return nil
+	})
+}
+
+// Call "fn" with every counter that still exists
+func (p *RedisKeyDiscovery) eachValueFloat64(fn func(key string, value float64)) error {
+	return p.EachFloat64(func(counter *RedisMKeysCounterFloat64) error {
+		if _, err := counter.MGet(); nil != err {
+			return err
+		}
+		for _, key := range counter.KEYS {
+			if ptr := counter.Cache.Value(key); nil != ptr {
+				fn(key, *ptr)
+			}
+		}
+		return nil
+	})
+}
+
+// Min-heap of the largest counters seen so far
+type rankHeapInt64 struct {
+	ranks []CounterRankInt64
+}
+
+func (h *rankHeapInt64) less(a, b CounterRankInt64) bool {
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return a.Key > b.Key
+}
+
+func (h *rankHeapInt64) Len() int           { return len(h.ranks) }
+func (h *rankHeapInt64) Less(i, j int) bool { return h.less(h.ranks[i], h.ranks[j]) }
+func (h *rankHeapInt64) Swap(i, j int)      { h.ranks[i], h.ranks[j] = h.ranks[j], h.ranks[i] }
+func (h *rankHeapInt64) Push(x interface{}) { h.ranks = append(h.ranks, x.(CounterRankInt64)) }
+func (h *rankHeapInt64) Pop() interface{} {
+	last := h.ranks[len(h.ranks)-1]
+	h.ranks = h.ranks[:len(h.ranks)-1]
+	return last
+}
+
+// Min-heap of the largest counters seen so far
+type rankHeapFloat64 struct {
+	ranks []CounterRankFloat64
+}
+
+func (h *rankHeapFloat64) less(a, b CounterRankFloat64) bool {
+	if a.Value != b.Value {
+		return a.Value < b.Value
+	}
+	return a.Key > b.Key
+}
+
+func (h *rankHeapFloat64) Len() int           { return len(h.ranks) }
+func (h *rankHeapFloat64) Less(i, j int) bool { return h.less(h.ranks[i], h.ranks[j]) }
+func (h *rankHeapFloat64) Swap(i, j int)      { h.ranks[i], h.ranks[j] = h.ranks[j], h.ranks[i] }
+func (h *rankHeapFloat64) Push(x interface{}) { h.ranks = append(h.ranks, x.(CounterRankFloat64)) }
+func (h *rankHeapFloat64) Pop() interface{} {
+	last := h.ranks[len(h.ranks)-1]
+	h.ranks = h.ranks[:len(h.ranks)-1]
+	return last
+}
+
+// Call "fn" with each batch of keys returned by SCAN; keys SCAN returns
+// more than once are only passed once, and reserved keys are skipped.
+// Every consumer of SCAN dedupes here, with one set of keys per scan.
+func scanKeyBatches(redis *dog_pool.RedisConnection, match string, count int, fn func(keys []string) error) error {
+	seen := make(map[string]bool)
+	cursor := "0"
+	for {
+		reply := redis.Cmd("SCAN", cursor, "MATCH", match, "COUNT", count)
+		switch {
+		case nil != reply.Err:
+			return redisError(reply.Err, "", "")
+		case len(reply.Elems) != 2:
+			return fmt.Errorf("Invalid SCAN reply for %s", match)
+		}
+
+		next, err := reply.Elems[0].Str()
+		if nil != err {
+			return err
+		}
+
+		batch, err := reply.Elems[1].List()
+		if nil != err {
+			return err
+		}
+
+		keys := []string{}
+		for _, key := range batch {
+			if !seen[key] && !IsReservedKey(key) {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+
+		if len(keys) > 0 {
+			if err := fn(keys); nil != err {
+				return err
+			}
+		}
+
+		if next == "0" {
+			return nil
+		}
+		cursor = next
+	}
+}
@@ -0,0 +1,109 @@
+package redis_counter
+
+import "sort"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisKeyDiscoverySpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisKeyDiscoverySpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisKeyDiscoverySpecs(c gospec.Context) {
+
+	c.Specify("[RedisKeyDiscovery][Make] Makes new instance", func() {
+		value, err := MakeRedisKeyDiscovery(nil, "api:*", 10)
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisKeyDiscovery(&dog_pool.RedisConnection{}, "", 10)
+		c.Expect(err.Error(), gospec.Equals, "Empty redis pattern")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisKeyDiscovery(&dog_pool.RedisConnection{}, "api:*", 0)
+		c.Expect(err.Error(), gospec.Equals, "Invalid batch size: 0")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisKeyDiscovery(&dog_pool.RedisConnection{}, "api:*", DiscoveryBatchSize)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[RedisKeyDiscovery][Each] Batches the keys", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		server.Connection().Cmd("MSET", "api:hits:a", "1", "api:hits:b", "2", "api:hits:c", "3", "api:hits:d", "4", "api:hits:e", "5", "web:hits:a", "100")
+		value, _ := MakeRedisKeyDiscovery(server.Connection(), "api:hits:*", 2)
+
+		keys, err := value.Keys()
+		c.Expect(err, gospec.Equals, nil)
+		sort.Strings(keys)
+		c.Expect(keys, gospec.ContainsInOrder, gospec.Values("api:hits:a", "api:hits:b", "api:hits:c", "api:hits:d", "api:hits:e"))
+
+		sizes := []int{}
+		err = value.EachInt64(func(counter *RedisMKeysCounterInt64) error {
+			sizes = append(sizes, len(counter.KEYS))
+			return nil
+		})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(sizes, gospec.ContainsInOrder, gospec.Values(2, 2, 1))
+	})
+
+	c.Specify("[RedisKeyDiscovery][Aggregates] Sum, Max and TopN", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyDiscovery(server.Connection(), "api:hits:*", 2)
+
+		max, err := value.MaxInt64()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(max, gospec.Satisfies, nil == max)
+
+		server.Connection().Cmd("MSET", "api:hits:a", "1", "api:hits:b", "5", "api:hits:c", "3", "api:hits:d", "5", "api:hits:e", "2", "web:hits:a", "100")
+
+		sum, err := value.SumInt64()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(sum, gospec.Equals, int64(16))
+
+		max, err = value.MaxInt64()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(*max, gospec.Equals, CounterRankInt64{"api:hits:b", 5})
+
+		top, err := value.TopNInt64(3)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(top, gospec.ContainsInOrder, gospec.Values(CounterRankInt64{"api:hits:b", 5}, CounterRankInt64{"api:hits:d", 5}, CounterRankInt64{"api:hits:c", 3}))
+
+		_, err = value.TopNInt64(0)
+		c.Expect(err.Error(), gospec.Equals, "Invalid n: 0")
+
+		server.Connection().Cmd("SET", "api:hits:f", "0.5")
+		float_sum, err := value.SumFloat64()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(float_sum, gospec.Equals, 16.5)
+
+		float_top, err := value.TopNFloat64(10)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(float_top), gospec.Equals, 6)
+		c.Expect(float_top[5], gospec.Equals, CounterRankFloat64{"api:hits:f", 0.5})
+
+		// Parsing error:
+		_, err = value.SumInt64()
+		c.Expect(err, gospec.Satisfies, nil != err)
+	})
+}
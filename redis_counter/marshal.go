@@ -0,0 +1,510 @@
+package redis_counter
+
+import "bytes"
+import "encoding/binary"
+import "encoding/json"
+import "fmt"
+import "io"
+import "math"
+import "math/big"
+import "strconv"
+
+// The counters implement json.Marshaler and json.Unmarshaler,
+// encoding.TextMarshaler, and encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler, encoding their key and field names and the
+// cached values; a missing counter is kept apart from one holding 0:
+//
+//	{"type":"int64","key":"Bob","value":null}
+//	{"type":"float64","key":"Key","field":"Field","value":1.5}
+//	{"type":"decimal","key":"Bob","scale":2,"value":12.50}
+//	{"type":"int64","keys":["Bob","Gary"],"values":[123,null]}
+//	{"type":"int64","key":"Key","fields":["Bob","Gary"],"values":[0,null]}
+//
+// JSON has no infinities or NaN, so a float64 holding one is encoded as
+// the string "+Inf", "-Inf" or "NaN".
+//
+// The text is the String of the counter, with exact values. The Redis
+// connection and the options are not encoded; unmarshal into a counter
+// from its Make function to keep them, or set them afterwards.
+
+// Types of the counter values, as well as SnapshotInt64 and SnapshotFloat64
+const (
+	SnapshotDecimal = "decimal"
+	SnapshotBigInt  = "bigint"
+)
+
+// Version of the binary encoding
+const marshalVersion = 1
+
+//
+// Internal Helpers:
+//
+
+// What a counter is made of
+const (
+	shapeKey    = iota // KEY and its value
+	shapeField         // KEY, FIELD and its value
+	shapeKeys          // KEYS and their values
+	shapeFields        // KEY, FIELDS and their values
+)
+
+// Types in the binary encoding, by their index
+var marshalTypes = []string{SnapshotInt64, SnapshotFloat64, SnapshotDecimal, SnapshotBigInt}
+
+// Formatter of MarshalText; the values are already exact text
+var textFormatter = &Formatter{Missing: "NaN"}
+
+// Identity and cached values of a counter, shared by its encodings
+type counterState struct {
+	Type   string
+	Shape  int
+	Key    string    // Empty for shapeKeys
+	Names  []string  // FIELD, KEYS or FIELDS
+	Scale  int       // Decimal places of SnapshotDecimal
+	Values []*string // Exact text of each value; nil when missing
+}
+
+// JSON of a counterState; "value" is only set for a single counter
+type counterJSON struct {
+	Type   string            `json:"type"`
+	Key    string            `json:"key,omitempty"`
+	Field  string            `json:"field,omitempty"`
+	Keys   []string          `json:"keys,omitempty"`
+	Fields []string          `json:"fields,omitempty"`
+	Scale  int               `json:"scale,omitempty"`
+	Value  json.RawMessage   `json:"value,omitempty"`
+	Values []json.RawMessage `json:"values,omitempty"`
+}
+
+func (s *counterState) validate() error {
+	switch {
+	case s.Shape != shapeKeys && len(s.Key) == 0:
+		return &ErrEmptyKey{Index: -1}
+	case s.Shape == shapeKeys && len(s.Names) == 0:
+		return ErrNoKeys
+	case s.Type == SnapshotDecimal && (s.Scale < 0 || s.Scale > MaxDecimalScale):
+		return fmt.Errorf("Invalid decimal scale: %d", s.Scale)
+	}
+
+	count := len(s.Names)
+	switch s.Shape {
+	case shapeKey:
+		count = 1
+		if len(s.Names) != 0 {
+			return fmt.Errorf("Invalid counter: %d fields for a key", len(s.Names))
+		}
+	case shapeField:
+		if len(s.Names) != 1 {
+			return fmt.Errorf("Invalid counter: %d fields for a hash field", len(s.Names))
+		}
+	}
+
+	for i, name := range s.Names {
+		switch {
+		case len(name) == 0 && s.Shape == shapeKeys:
+			return &ErrEmptyKey{Index: i}
+		case len(name) == 0 && s.Shape == shapeField:
+			return &ErrEmptyKey{Index: -1, Field: true}
+		case len(name) == 0:
+			return &ErrEmptyKey{Index: i, Field: true}
+		}
+	}
+
+	if len(s.Values) != count {
+		return fmt.Errorf("Invalid counter: expected %d values, found %d", count, len(s.Values))
+	}
+	return nil
+}
+
+func (s *counterState) marshalJSON() ([]byte, error) {
+	values := make([]json.RawMessage, len(s.Values))
+	for i, value := range s.Values {
+		switch {
+		case nil == value:
+			values[i] = json.RawMessage("null")
+		case nonFiniteFloat64Text[*value]:
+			values[i] = json.RawMessage(strconv.Quote(*value))
+		default:
+			values[i] = json.RawMessage(*value)
+		}
+	}
+
+	doc := counterJSON{Type: s.Type, Key: s.Key}
+	if s.Type == SnapshotDecimal {
+		doc.Scale = s.Scale
+	}
+
+	switch s.Shape {
+	case shapeKey:
+		doc.Value = values[0]
+	case shapeField:
+		doc.Field, doc.Value = s.Names[0], values[0]
+	case shapeKeys:
+		doc.Keys, doc.Values = s.Names, values
+	case shapeFields:
+		doc.Fields, doc.Values = s.Names, values
+	}
+	return json.Marshal(doc)
+}
+
+// Decode the JSON of a counter of "value_type" with "shape"
+func unmarshalStateJSON(data []byte, value_type string, shape int) (*counterState, error) {
+	doc := counterJSON{}
+	if err := json.Unmarshal(data, &doc); nil != err {
+		return nil, err
+	}
+	if doc.Type != value_type {
+		return nil, fmt.Errorf("Invalid counter type: %q, expected %q", doc.Type, value_type)
+	}
+
+	s := &counterState{Type: doc.Type, Shape: shape, Key: doc.Key, Scale: doc.Scale}
+	raw := doc.Values
+	switch shape {
+	case shapeKey:
+		raw = []json.RawMessage{doc.Value}
+	case shapeField:
+		s.Names, raw = []string{doc.Field}, []json.RawMessage{doc.Value}
+	case shapeKeys:
+		s.Names = doc.Keys
+	case shapeFields:
+		s.Names = doc.Fields
+	}
+
+	s.Values = make([]*string, len(raw))
+	for i, value := range raw {
+		// A missing "value" is a missing counter, like null
+		if len(value) == 0 {
+			continue
+		}
+
+		text, err := s.unmarshalValueJSON(value)
+		if nil != err {
+			return nil, err
+		}
+		s.Values[i] = text
+	}
+	return s, s.validate()
+}
+
+// Decode one value: a number or null, or one of nonFiniteFloat64Text for
+// a float64
+func (s *counterState) unmarshalValueJSON(value json.RawMessage) (*string, error) {
+	var name string
+	if s.Type == SnapshotFloat64 && nil == json.Unmarshal(value, &name) && nonFiniteFloat64Text[name] {
+		return &name, nil
+	}
+
+	var number *json.Number
+	if err := json.Unmarshal(value, &number); nil != err {
+		return nil, fmt.Errorf("Invalid counter value: %s", value)
+	}
+	if nil == number {
+		return nil, nil
+	}
+
+	text := number.String()
+	switch s.Type {
+	case SnapshotInt64:
+		if _, err := strconv.ParseInt(text, 10, 64); nil != err {
+			return nil, fmt.Errorf("Invalid counter value: %s, expected an int64", text)
+		}
+	case SnapshotBigInt:
+		if _, ok := new(big.Int).SetString(text, 10); !ok {
+			return nil, fmt.Errorf("Invalid counter value: %s, expected an integer", text)
+		}
+	}
+	return &text, nil
+}
+
+// Format as the String of the counter
+func (s *counterState) marshalText() []byte {
+	f := textFormatter
+	values := make([]string, len(s.Values))
+	for i, value := range s.Values {
+		values[i] = f.Missing
+		if nil != value {
+			values[i] = *value
+		}
+	}
+
+	switch s.Shape {
+	case shapeKey:
+		return []byte(f.formatKey(s.Key, values[0]))
+	case shapeField:
+		return []byte(f.formatField(s.Key, s.Names[0], values[0]))
+	case shapeKeys:
+		return []byte(f.formatKeys(s.Names, values))
+	default:
+		return []byte(f.formatFields(s.Key, s.Names, values))
+	}
+}
+
+// Encode as:
+//
+//	version | type | shape | uvarint scale | key | uvarint count | names... | values...
+//
+// The key and each name are prefixed with their uvarint length. Each
+// value is a byte, 0 when missing or 1 when set and followed by a varint
+// for int64 and decimal units, 8 bytes for float64, or the uvarint length
+// and gob encoding of a big.Int.
+func (s *counterState) marshalBinary() ([]byte, error) {
+	index := 0
+	for index < len(marshalTypes) && marshalTypes[index] != s.Type {
+		index++
+	}
+
+	buffer := []byte{marshalVersion, byte(index), byte(s.Shape)}
+	buffer = binary.AppendUvarint(buffer, uint64(s.Scale))
+	buffer = appendString(buffer, s.Key)
+	buffer = binary.AppendUvarint(buffer, uint64(len(s.Names)))
+	for _, name := range s.Names {
+		buffer = appendString(buffer, name)
+	}
+
+	for _, value := range s.Values {
+		if nil == value {
+			buffer = append(buffer, 0)
+			continue
+		}
+
+		var err error
+		buffer = append(buffer, 1)
+		buffer, err = s.appendValue(buffer, *value)
+		if nil != err {
+			return nil, err
+		}
+	}
+	return buffer, nil
+}
+
+func (s *counterState) appendValue(buffer []byte, text string) ([]byte, error) {
+	switch s.Type {
+	case SnapshotInt64:
+		value, err := strconv.ParseInt(text, 10, 64)
+		return binary.AppendVarint(buffer, value), err
+
+	case SnapshotFloat64:
+		value, err := strconv.ParseFloat(text, 64)
+		return binary.BigEndian.AppendUint64(buffer, math.Float64bits(value)), err
+
+	case SnapshotDecimal:
+		value, err := textDecimal(&text, s.Scale)
+		if nil != err {
+			return buffer, err
+		}
+		return binary.AppendVarint(buffer, value.Units), nil
+
+	default:
+		value, err := textBigInt(&text)
+		if nil != err {
+			return buffer, err
+		}
+		gob, err := value.GobEncode()
+		return append(binary.AppendUvarint(buffer, uint64(len(gob))), gob...), err
+	}
+}
+
+// Decode the binary encoding of a counter of "value_type" with "shape"
+func unmarshalStateBinary(data []byte, value_type string, shape int) (*counterState, error) {
+	reader := bytes.NewReader(data)
+	header := make([]byte, 3)
+	if _, err := io.ReadFull(reader, header); nil != err {
+		return nil, fmt.Errorf("Invalid binary counter: %v", err)
+	}
+
+	switch {
+	case header[0] != marshalVersion:
+		return nil, fmt.Errorf("Invalid binary counter: version %d", header[0])
+	case int(header[1]) >= len(marshalTypes):
+		return nil, fmt.Errorf("Invalid binary counter: type %d", header[1])
+	case marshalTypes[header[1]] != value_type:
+		return nil, fmt.Errorf("Invalid counter type: %q, expected %q", marshalTypes[header[1]], value_type)
+	case int(header[2]) != shape:
+		return nil, fmt.Errorf("Invalid binary counter: shape %d, expected %d", header[2], shape)
+	}
+
+	s := &counterState{Type: value_type, Shape: shape}
+	scale, err := binary.ReadUvarint(reader)
+	if nil != err || scale > MaxDecimalScale {
+		return nil, fmt.Errorf("Invalid binary counter: scale")
+	}
+	s.Scale = int(scale)
+
+	if s.Key, err = readString(reader); nil != err {
+		return nil, err
+	}
+
+	// Every name takes at least one byte
+	count, err := binary.ReadUvarint(reader)
+	if nil != err || count > uint64(reader.Len()) {
+		return nil, fmt.Errorf("Invalid binary counter: name count")
+	}
+	s.Names = make([]string, count)
+	for i := range s.Names {
+		if s.Names[i], err = readString(reader); nil != err {
+			return nil, err
+		}
+	}
+
+	if shape == shapeKey || shape == shapeField {
+		count = 1
+	}
+	s.Values = make([]*string, count)
+	for i := range s.Values {
+		set, err := reader.ReadByte()
+		switch {
+		case nil != err || set > 1:
+			return nil, fmt.Errorf("Invalid binary counter: value %d", i)
+		case set == 0:
+			continue
+		}
+
+		if s.Values[i], err = s.readValue(reader); nil != err {
+			return nil, fmt.Errorf("Invalid binary counter: value %d", i)
+		}
+	}
+
+	if reader.Len() > 0 {
+		return nil, fmt.Errorf("Invalid binary counter: %d trailing bytes", reader.Len())
+	}
+	return s, s.validate()
+}
+
+func (s *counterState) readValue(reader *bytes.Reader) (*string, error) {
+	switch s.Type {
+	case SnapshotInt64:
+		value, err := binary.ReadVarint(reader)
+		return int64Text(&value), err
+
+	case SnapshotFloat64:
+		bits := make([]byte, 8)
+		_, err := io.ReadFull(reader, bits)
+		value := math.Float64frombits(binary.BigEndian.Uint64(bits))
+		return float64Text(&value), err
+
+	case SnapshotDecimal:
+		units, err := binary.ReadVarint(reader)
+		return decimalText(&Decimal{units, s.Scale}), err
+
+	default:
+		length, err := binary.ReadUvarint(reader)
+		if nil != err || length > uint64(reader.Len()) {
+			return nil, fmt.Errorf("Invalid big.Int length")
+		}
+		gob := make([]byte, length)
+		if _, err := io.ReadFull(reader, gob); nil != err {
+			return nil, err
+		}
+
+		value := new(big.Int)
+		if err := value.GobDecode(gob); nil != err {
+			return nil, err
+		}
+		return bigIntText(value), nil
+	}
+}
+
+func appendString(buffer []byte, value string) []byte {
+	buffer = binary.AppendUvarint(buffer, uint64(len(value)))
+	return append(buffer, value...)
+}
+
+func readString(reader *bytes.Reader) (string, error) {
+	length, err := binary.ReadUvarint(reader)
+	if nil != err || length > uint64(reader.Len()) {
+		return "", fmt.Errorf("Invalid binary counter: name")
+	}
+	value := make([]byte, length)
+	if _, err := io.ReadFull(reader, value); nil != err {
+		return "", fmt.Errorf("Invalid binary counter: name")
+	}
+	return string(value), nil
+}
+
+//
+// Values to and from their exact text; nil for a missing counter:
+//
+
+func int64Text(ptr *int64) *string {
+	if nil == ptr {
+		return nil
+	}
+	text := strconv.FormatInt(*ptr, 10)
+	return &text
+}
+
+func textInt64(text *string) (*int64, error) {
+	if nil == text {
+		return nil, nil
+	}
+	value, err := strconv.ParseInt(*text, 10, 64)
+	if nil != err {
+		return nil, &ErrNotANumber{Raw: *text, Err: err}
+	}
+	return &value, nil
+}
+
+// Text of the float64 values that aren't JSON numbers
+var nonFiniteFloat64Text = map[string]bool{"+Inf": true, "-Inf": true, "NaN": true}
+
+func float64Text(ptr *float64) *string {
+	if nil == ptr {
+		return nil
+	}
+	text := strconv.FormatFloat(*ptr, 'g', -1, 64)
+	return &text
+}
+
+func textFloat64(text *string) (*float64, error) {
+	if nil == text {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(*text, 64)
+	if nil != err {
+		return nil, &ErrNotANumber{Raw: *text, Err: err}
+	}
+	return &value, nil
+}
+
+func decimalText(ptr *Decimal) *string {
+	if nil == ptr {
+		return nil
+	}
+	text := ptr.String()
+	return &text
+}
+
+// Parse a decimal with at most "scale" decimal places, at "scale"
+func textDecimal(text *string, scale int) (*Decimal, error) {
+	if nil == text {
+		return nil, nil
+	}
+	value, err := ParseDecimal(*text)
+	if nil != err {
+		return nil, err
+	}
+	value, err = value.Rescale(scale)
+	if nil != err {
+		return nil, err
+	}
+	return &value, nil
+}
+
+func bigIntText(ptr *big.Int) *string {
+	if nil == ptr {
+		return nil
+	}
+	text := ptr.String()
+	return &text
+}
+
+func textBigInt(text *string) (*big.Int, error) {
+	if nil == text {
+		return nil, nil
+	}
+	value, ok := new(big.Int).SetString(*text, 10)
+	if !ok {
+		return nil, &ErrNotANumber{Raw: *text, Err: fmt.Errorf("invalid integer")}
+	}
+	return value, nil
+}
@@ -0,0 +1,271 @@
+package redis_counter
+
+import "encoding/json"
+import "math"
+import "math/big"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+import . "github.com/gnagel/go_map_to_ptrs/map_to_ptrs"
+
+func TestMarshalSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(MarshalSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func MarshalSpecs(c gospec.Context) {
+
+	c.Specify("[Marshal][KeyCounterInt64] Tells nil from zero", func() {
+		zero := int64(0)
+		for _, value := range []*int64{nil, &zero} {
+			counter := &RedisKeyCounterInt64{KEY: "Bob", LastValue: value}
+
+			data, err := json.Marshal(counter)
+			c.Expect(err, gospec.Equals, nil)
+
+			decoded := &RedisKeyCounterInt64{}
+			c.Expect(json.Unmarshal(data, decoded), gospec.Equals, nil)
+			c.Expect(decoded.KEY, gospec.Equals, "Bob")
+			c.Expect(decoded.String(), gospec.Equals, counter.String())
+
+			data, err = counter.MarshalBinary()
+			c.Expect(err, gospec.Equals, nil)
+
+			decoded = &RedisKeyCounterInt64{}
+			c.Expect(decoded.UnmarshalBinary(data), gospec.Equals, nil)
+			c.Expect(decoded.KEY, gospec.Equals, "Bob")
+			c.Expect(decoded.String(), gospec.Equals, counter.String())
+		}
+
+		data, _ := json.Marshal(&RedisKeyCounterInt64{KEY: "Bob"})
+		c.Expect(string(data), gospec.Equals, `{"type":"int64","key":"Bob","value":null}`)
+
+		data, _ = json.Marshal(&RedisKeyCounterInt64{KEY: "Bob", LastValue: &zero})
+		c.Expect(string(data), gospec.Equals, `{"type":"int64","key":"Bob","value":0}`)
+	})
+
+	c.Specify("[Marshal][KeyCounterInt64] Keeps connection and options", func() {
+		format := &Formatter{JSON: true}
+		counter := &RedisKeyCounterInt64{KEY: "Gary", Overflow: OverflowSaturate, Format: format}
+
+		err := json.Unmarshal([]byte(`{"type":"int64","key":"Bob","value":123}`), counter)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter.Overflow, gospec.Equals, OverflowSaturate)
+		c.Expect(counter.Format, gospec.Equals, format)
+		c.Expect(counter.String(), gospec.Equals, `{"Bob":123}`)
+	})
+
+	c.Specify("[Marshal][KeyCounterFloat64] Encodes exact values", func() {
+		tenth := 0.1
+		value := tenth + 0.2
+		counter := &RedisKeyCounterFloat64{KEY: "Bob", LastValue: &value}
+
+		data, err := json.Marshal(counter)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(string(data), gospec.Equals, `{"type":"float64","key":"Bob","value":0.30000000000000004}`)
+
+		text, err := counter.MarshalText()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(string(text), gospec.Equals, "Bob = 0.30000000000000004")
+
+		data, err = counter.MarshalBinary()
+		c.Expect(err, gospec.Equals, nil)
+
+		decoded := &RedisKeyCounterFloat64{}
+		c.Expect(decoded.UnmarshalBinary(data), gospec.Equals, nil)
+		c.Expect(*decoded.LastValue, gospec.Equals, value)
+
+		// Infinities and NaN are strings:
+		inf := math.Inf(-1)
+		counter.LastValue = &inf
+		data, err = json.Marshal(counter)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(string(data), gospec.Equals, `{"type":"float64","key":"Bob","value":"-Inf"}`)
+
+		c.Expect(json.Unmarshal(data, decoded), gospec.Equals, nil)
+		c.Expect(math.IsInf(*decoded.LastValue, -1), gospec.Equals, true)
+
+		nan := math.NaN()
+		keys := &RedisMKeysCounterFloat64{KEYS: []string{"Bob", "Gary"}, Cache: MakeMapStringToFloat64Ptrs(2)}
+		keys.Cache.Set("Bob", &nan)
+		data, err = json.Marshal(keys)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(string(data), gospec.Equals, `{"type":"float64","keys":["Bob","Gary"],"values":["NaN",null]}`)
+
+		err = json.Unmarshal([]byte(`{"type":"float64","key":"Bob","value":"Infinity"}`), decoded)
+		c.Expect(err.Error(), gospec.Equals, `Invalid counter value: "Infinity"`)
+	})
+
+	c.Specify("[Marshal][HashFieldCounterDecimal] Encodes the scale", func() {
+		value := Decimal{1250, 2}
+		counter := &RedisHashFieldCounterDecimal{KEY: "Key", FIELD: "Bob", SCALE: 2, LastValue: &value}
+
+		data, err := json.Marshal(counter)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(string(data), gospec.Equals, `{"type":"decimal","key":"Key","field":"Bob","scale":2,"value":12.50}`)
+
+		decoded := &RedisHashFieldCounterDecimal{}
+		c.Expect(json.Unmarshal(data, decoded), gospec.Equals, nil)
+		c.Expect(decoded.FIELD, gospec.Equals, "Bob")
+		c.Expect(decoded.SCALE, gospec.Equals, 2)
+		c.Expect(*decoded.LastValue, gospec.Equals, value)
+
+		data, err = counter.MarshalBinary()
+		c.Expect(err, gospec.Equals, nil)
+
+		decoded = &RedisHashFieldCounterDecimal{}
+		c.Expect(decoded.UnmarshalBinary(data), gospec.Equals, nil)
+		c.Expect(*decoded.LastValue, gospec.Equals, value)
+
+		err = json.Unmarshal([]byte(`{"type":"decimal","key":"Key","field":"Bob","scale":1,"value":12.55}`), decoded)
+		c.Expect(err.Error(), gospec.Equals, "Decimal 12.55 has more than 1 decimal places")
+	})
+
+	c.Specify("[Marshal][KeyCounterBigInt] Encodes unbounded integers", func() {
+		value, _ := new(big.Int).SetString("-123456789012345678901234567890", 10)
+		counter := &RedisKeyCounterBigInt{KEY: "Bob", LastValue: value}
+
+		data, err := json.Marshal(counter)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(string(data), gospec.Equals, `{"type":"bigint","key":"Bob","value":-123456789012345678901234567890}`)
+
+		decoded := &RedisKeyCounterBigInt{}
+		c.Expect(json.Unmarshal(data, decoded), gospec.Equals, nil)
+		c.Expect(decoded.LastValue.Cmp(value), gospec.Equals, 0)
+
+		data, err = counter.MarshalBinary()
+		c.Expect(err, gospec.Equals, nil)
+
+		decoded = &RedisKeyCounterBigInt{}
+		c.Expect(decoded.UnmarshalBinary(data), gospec.Equals, nil)
+		c.Expect(decoded.LastValue.Cmp(value), gospec.Equals, 0)
+	})
+
+	c.Specify("[Marshal][MKeysCounterInt64] Encodes the cache", func() {
+		zero := int64(0)
+		counter := &RedisMKeysCounterInt64{KEYS: []string{"Bob", "Gary"}, Cache: MakeMapStringToInt64Ptrs(2)}
+		counter.Cache.Set("Bob", &zero)
+
+		data, err := json.Marshal(counter)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(string(data), gospec.Equals, `{"type":"int64","keys":["Bob","Gary"],"values":[0,null]}`)
+
+		text, err := counter.MarshalText()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(string(text), gospec.Equals, "Bob = 0, Gary = NaN")
+
+		decoded := &RedisMKeysCounterInt64{}
+		c.Expect(json.Unmarshal(data, decoded), gospec.Equals, nil)
+		c.Expect(decoded.String(), gospec.Equals, "Bob = 0, Gary = NaN")
+
+		data, err = counter.MarshalBinary()
+		c.Expect(err, gospec.Equals, nil)
+
+		decoded = &RedisMKeysCounterInt64{}
+		c.Expect(decoded.UnmarshalBinary(data), gospec.Equals, nil)
+		c.Expect(decoded.String(), gospec.Equals, "Bob = 0, Gary = NaN")
+	})
+
+	c.Specify("[Marshal][MKeysCounterBigInt] Encodes the cache", func() {
+		counter := &RedisMKeysCounterBigInt{KEYS: []string{"Bob", "Gary"}, Cache: map[string]*big.Int{"Gary": big.NewInt(7)}}
+
+		data, err := counter.MarshalBinary()
+		c.Expect(err, gospec.Equals, nil)
+
+		decoded := &RedisMKeysCounterBigInt{}
+		c.Expect(decoded.UnmarshalBinary(data), gospec.Equals, nil)
+		c.Expect(decoded.String(), gospec.Equals, "Bob = NaN, Gary = 7")
+	})
+
+	c.Specify("[Marshal][HashMFieldsCounterFloat64] Encodes the cache", func() {
+		value := 1.5
+		counter := &RedisHashMFieldsCounterFloat64{KEY: "Key", FIELDS: []string{"Bob", "Gary"}, Cache: MakeMapStringToFloat64Ptrs(2)}
+		counter.Cache.Set("Gary", &value)
+
+		data, err := json.Marshal(counter)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(string(data), gospec.Equals, `{"type":"float64","key":"Key","fields":["Bob","Gary"],"values":[null,1.5]}`)
+
+		decoded := &RedisHashMFieldsCounterFloat64{}
+		c.Expect(json.Unmarshal(data, decoded), gospec.Equals, nil)
+		c.Expect(decoded.String(), gospec.Equals, "Key[Bob = NaN, Gary = 1.500000]")
+
+		err = json.Unmarshal([]byte(`{"type":"float64","key":"Key"}`), decoded)
+		c.Expect(err, gospec.Equals, ErrNoFields)
+	})
+
+	c.Specify("[Marshal][HashCounterInt64] Keeps the discovered fields in order", func() {
+		one := int64(1)
+		counter := &RedisHashCounterInt64{KEY: "Key", Cache: MakeMapStringToInt64Ptrs(0)}
+		counter.cacheSet("Gary", &one)
+		counter.cacheSet("Bob", nil)
+
+		data, err := counter.MarshalBinary()
+		c.Expect(err, gospec.Equals, nil)
+
+		decoded := &RedisHashCounterInt64{}
+		c.Expect(decoded.UnmarshalBinary(data), gospec.Equals, nil)
+		c.Expect(decoded.String(), gospec.Equals, "Key[Gary = 1, Bob = NaN]")
+
+		data, err = json.Marshal(&RedisHashCounterInt64{KEY: "Key"})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(string(data), gospec.Equals, `{"type":"int64","key":"Key"}`)
+
+		c.Expect(json.Unmarshal(data, decoded), gospec.Equals, nil)
+		c.Expect(decoded.String(), gospec.Equals, "Key[]")
+	})
+
+	c.Specify("[Marshal][Unmarshal] Rejects invalid counters", func() {
+		counter := &RedisKeyCounterInt64{}
+
+		err := json.Unmarshal([]byte(`{"type":"float64","key":"Bob","value":1}`), counter)
+		c.Expect(err.Error(), gospec.Equals, `Invalid counter type: "float64", expected "int64"`)
+
+		err = json.Unmarshal([]byte(`{"type":"int64","value":1}`), counter)
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key")
+
+		err = json.Unmarshal([]byte(`{"type":"int64","key":"Bob","value":1.5}`), counter)
+		c.Expect(err.Error(), gospec.Equals, "Invalid counter value: 1.5, expected an int64")
+
+		err = json.Unmarshal([]byte(`{"type":"int64","key":"Bob","value":1e3}`), counter)
+		c.Expect(err.Error(), gospec.Equals, "Invalid counter value: 1e3, expected an int64")
+
+		err = json.Unmarshal([]byte(`{"type":"bigint","key":"Bob","value":1e3}`), &RedisKeyCounterBigInt{})
+		c.Expect(err.Error(), gospec.Equals, "Invalid counter value: 1e3, expected an integer")
+
+		err = json.Unmarshal([]byte(`{"type":"int64","key":"Bob","value":"x"}`), counter)
+		c.Expect(err.Error(), gospec.Equals, `Invalid counter value: "x"`)
+
+		err = json.Unmarshal([]byte(`{"type":"int64","keys":["Bob",""],"values":[1,2]}`), &RedisMKeysCounterInt64{})
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key[1]")
+
+		err = json.Unmarshal([]byte(`{"type":"int64","keys":["Bob"],"values":[1,2]}`), &RedisMKeysCounterInt64{})
+		c.Expect(err.Error(), gospec.Equals, "Invalid counter: expected 1 values, found 2")
+
+		value := int64(1)
+		data, _ := (&RedisKeyCounterInt64{KEY: "Bob", LastValue: &value}).MarshalBinary()
+
+		err = counter.UnmarshalBinary(data[:len(data)-1])
+		c.Expect(err.Error(), gospec.Equals, "Invalid binary counter: value 0")
+
+		err = counter.UnmarshalBinary(append(data, 0))
+		c.Expect(err.Error(), gospec.Equals, "Invalid binary counter: 1 trailing bytes")
+
+		err = (&RedisKeyCounterFloat64{}).UnmarshalBinary(data)
+		c.Expect(err.Error(), gospec.Equals, `Invalid counter type: "int64", expected "float64"`)
+
+		err = (&RedisHashFieldCounterInt64{}).UnmarshalBinary(data)
+		c.Expect(err.Error(), gospec.Equals, "Invalid binary counter: shape 0, expected 1")
+
+		data[0] = 9
+		err = counter.UnmarshalBinary(data)
+		c.Expect(err.Error(), gospec.Equals, "Invalid binary counter: version 9")
+
+		c.Expect(counter.KEY, gospec.Equals, "")
+		c.Expect(counter.LastValue, gospec.Satisfies, nil == counter.LastValue)
+	})
+}
@@ -0,0 +1,239 @@
+package redis_counter
+
+import "fmt"
+import "math/big"
+import "strings"
+import "github.com/fzzy/radix/redis"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Counters of unbounded integers at KEYS, read with MGET and added to in
+// one batch of Lua scripts; see RedisKeyCounterBigInt
+type RedisMKeysCounterBigInt struct {
+	Redis *dog_pool.RedisConnection
+	KEYS  []string
+	Cache map[string]*big.Int
+}
+
+// Make a new instance of RedisMKeysCounterBigInt
+func MakeRedisMKeysCounterBigInt(redis *dog_pool.RedisConnection, keys ...string) (*RedisMKeysCounterBigInt, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	case len(keys) == 0:
+		return nil, ErrNoKeys
+	default:
+		for i, key := range keys {
+			if len(key) == 0 {
+				return nil, &ErrEmptyKey{Index: i}
+			}
+		}
+
+		p := &RedisMKeysCounterBigInt{
+			Redis: redis,
+			KEYS:  keys,
+			Cache: make(map[string]*big.Int, len(keys)),
+		}
+		p.CacheReset()
+		return p, nil
+	}
+}
+
+// Clear the contents of the cache
+func (p *RedisMKeysCounterBigInt) CacheReset() {
+	for _, key := range p.KEYS {
+		p.Cache[key] = nil
+	}
+}
+
+// Format the values as a string
+func (p *RedisMKeysCounterBigInt) String() string {
+	values := make([]string, len(p.KEYS))
+	for i, key := range p.KEYS {
+		switch value := p.Cache[key]; value {
+		case nil:
+			values[i] = fmt.Sprintf("%s = NaN", key)
+		default:
+			values[i] = fmt.Sprintf("%s = %s", key, value)
+		}
+	}
+	return strings.Join(values, ", ")
+}
+
+// Get the value of the counters; saves the counters to "Cache"
+func (p *RedisMKeysCounterBigInt) MBigInt() ([]*big.Int, error) {
+	return p.operationReturnsAmounts()
+}
+
+func (p *RedisMKeysCounterBigInt) MExists() ([]bool, error) {
+	p.CacheReset()
+
+	values, err := p.Redis.KeysExist(p.KEYS...)
+	return values, redisError(err, "", "")
+}
+
+func (p *RedisMKeysCounterBigInt) MDelete() error {
+	p.CacheReset()
+
+	reply := p.Redis.Cmd("DEL", p.KEYS)
+	return redisError(reply.Err, "", "")
+}
+
+func (p *RedisMKeysCounterBigInt) MGet() ([]*big.Int, error) {
+	return p.MBigInt()
+}
+
+func (p *RedisMKeysCounterBigInt) MSet(amount *big.Int) ([]*big.Int, error) {
+	p.CacheReset()
+	if nil == amount {
+		return nil, fmt.Errorf("Nil amount")
+	}
+
+	buffer := make([]string, 0, len(p.KEYS)*2)
+	for _, key := range p.KEYS {
+		buffer = append(buffer, key, amount.String())
+	}
+
+	reply := p.Redis.Cmd("MSET", buffer)
+	if nil != reply.Err {
+		return nil, redisError(reply.Err, "", "")
+	}
+
+	values := make([]*big.Int, len(p.KEYS))
+	for i, key := range p.KEYS {
+		values[i] = new(big.Int).Set(amount)
+		p.Cache[key] = values[i]
+	}
+	return values, nil
+}
+
+func (p *RedisMKeysCounterBigInt) MAdd(amount *big.Int) ([]*big.Int, error) {
+	if nil == amount {
+		p.CacheReset()
+		return nil, fmt.Errorf("Nil amount")
+	}
+	return p.operationModifiesAmounts(amount.String())
+}
+
+func (p *RedisMKeysCounterBigInt) MSub(amount *big.Int) ([]*big.Int, error) {
+	if nil == amount {
+		p.CacheReset()
+		return nil, fmt.Errorf("Nil amount")
+	}
+	return p.operationModifiesAmounts(new(big.Int).Neg(amount).String())
+}
+
+func (p *RedisMKeysCounterBigInt) MIncrement() ([]*big.Int, error) {
+	return p.operationModifiesAmounts("1")
+}
+
+func (p *RedisMKeysCounterBigInt) MDecrement() ([]*big.Int, error) {
+	return p.operationModifiesAmounts("-1")
+}
+
+// Encode the keys and "Cache" as JSON; a missing counter is null
+func (p *RedisMKeysCounterBigInt) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the keys and "Cache" from JSON
+func (p *RedisMKeysCounterBigInt) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotBigInt, shapeKeys)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisMKeysCounterBigInt) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the keys and "Cache" in the binary encoding
+func (p *RedisMKeysCounterBigInt) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the keys and "Cache" from the binary encoding
+func (p *RedisMKeysCounterBigInt) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotBigInt, shapeKeys)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+//
+// Internal Helpers:
+//
+
+func (p *RedisMKeysCounterBigInt) operationReturnsAmounts() ([]*big.Int, error) {
+	p.CacheReset()
+
+	reply := p.Redis.Cmd("MGET", p.KEYS)
+	if nil != reply.Err {
+		return nil, redisError(reply.Err, "", "")
+	}
+	return p.cacheReplies(reply.Elems)
+}
+
+func (p *RedisMKeysCounterBigInt) operationModifiesAmounts(amount string) ([]*big.Int, error) {
+	p.CacheReset()
+
+	replies, err := bigIntAddScript.evalBatch(p.Redis, p.KEYS, "", amount)
+	if nil != err {
+		return nil, redisError(err, "", "")
+	}
+	return p.cacheReplies(replies)
+}
+
+// Parse the reply for each key into the cache, even after an error;
+// returns the first error
+func (p *RedisMKeysCounterBigInt) cacheReplies(replies []*redis.Reply) ([]*big.Int, error) {
+	if len(replies) != len(p.KEYS) {
+		return nil, fmt.Errorf("Invalid reply: expected %d values, found %d", len(p.KEYS), len(replies))
+	}
+
+	var first_err error
+	values := make([]*big.Int, len(p.KEYS))
+	for i, key := range p.KEYS {
+		ptr, err := toBigIntPtr(replies[i], key, "")
+		if nil != err && nil == first_err {
+			first_err = err
+		}
+		p.Cache[key] = ptr
+		values[i] = valueOrZeroBigInt(ptr)
+	}
+
+	if nil != first_err {
+		return nil, first_err
+	}
+	return values, nil
+}
+
+// Identity and "Cache" of the counters, for their encodings
+func (p *RedisMKeysCounterBigInt) state() *counterState {
+	values := make([]*string, len(p.KEYS))
+	for i, key := range p.KEYS {
+		values[i] = bigIntText(p.Cache[key])
+	}
+	return &counterState{
+		Type:   SnapshotBigInt,
+		Shape:  shapeKeys,
+		Names:  p.KEYS,
+		Values: values,
+	}
+}
+
+func (p *RedisMKeysCounterBigInt) setState(s *counterState) error {
+	cache := make(map[string]*big.Int, len(s.Names))
+	for i, key := range s.Names {
+		value, err := textBigInt(s.Values[i])
+		if nil != err {
+			return err
+		}
+		cache[key] = value
+	}
+	p.KEYS, p.Cache = s.Names, cache
+	return nil
+}
@@ -0,0 +1,105 @@
+package redis_counter
+
+import "math/big"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisMKeysCounterBigIntSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisMKeysCounterBigIntSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisMKeysCounterBigIntSpecs(c gospec.Context) {
+
+	c.Specify("[RedisMKeysCounterBigInt][Make] Makes new instance", func() {
+		value, err := MakeRedisMKeysCounterBigInt(nil)
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisMKeysCounterBigInt(&dog_pool.RedisConnection{})
+		c.Expect(err.Error(), gospec.Equals, "Empty redis keys")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisMKeysCounterBigInt(&dog_pool.RedisConnection{}, "Bob", "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key[1]")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisMKeysCounterBigInt(&dog_pool.RedisConnection{}, "Bob", "Gary")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.String(), gospec.Equals, "Bob = NaN, Gary = NaN")
+	})
+
+	c.Specify("[RedisMKeysCounterBigInt][MAdd] Adds to every key in one batch", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisMKeysCounterBigInt(server.Connection(), "Bob", "Gary")
+		server.Connection().Cmd("SET", "Bob", "18446744073709551615")
+
+		amount, _ := new(big.Int).SetString("18446744073709551616", 10)
+		counters, err := value.MAdd(amount)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(counters), gospec.Equals, 2)
+		c.Expect(counters[0].String(), gospec.Equals, "36893488147419103231")
+		c.Expect(counters[1].String(), gospec.Equals, "18446744073709551616")
+		c.Expect(value.Cache["Gary"].String(), gospec.Equals, "18446744073709551616")
+
+		counters, err = value.MSub(amount)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counters[0].String(), gospec.Equals, "18446744073709551615")
+		c.Expect(counters[1].String(), gospec.Equals, "0")
+
+		counters, err = value.MDecrement()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counters[1].String(), gospec.Equals, "-1")
+
+		// The cache is filled even when a key fails:
+		server.Connection().Cmd("SET", "Gary", "abc")
+		_, err = value.MIncrement()
+		c.Expect(err, gospec.Satisfies, nil != err)
+		c.Expect(value.Cache["Bob"].String(), gospec.Equals, "18446744073709551615")
+		c.Expect(value.Cache["Gary"], gospec.Satisfies, nil == value.Cache["Gary"])
+	})
+
+	c.Specify("[RedisMKeysCounterBigInt][MGet] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisMKeysCounterBigInt(server.Connection(), "Bob", "Gary")
+
+		counters, err := value.MSet(big.NewInt(7))
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counters[1].Int64(), gospec.Equals, int64(7))
+
+		server.Connection().Cmd("DEL", "Gary")
+		counters, err = value.MGet()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counters[0].Int64(), gospec.Equals, int64(7))
+		c.Expect(counters[1].Sign(), gospec.Equals, 0)
+		c.Expect(value.String(), gospec.Equals, "Bob = 7, Gary = NaN")
+
+		exists, err := value.MExists()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(exists[0], gospec.Equals, true)
+		c.Expect(exists[1], gospec.Equals, false)
+
+		c.Expect(value.MDelete(), gospec.Equals, nil)
+		exists, _ = value.MExists()
+		c.Expect(exists[0], gospec.Equals, false)
+	})
+}
@@ -1,26 +1,28 @@
 package redis_counter
 
-import "fmt"
+import "strconv"
+import "github.com/fzzy/radix/redis"
 import "github.com/gnagel/dog_pool/dog_pool"
 import . "github.com/gnagel/go_map_to_ptrs/map_to_ptrs"
 
 type RedisMKeysCounterFloat64 struct {
-	Redis *dog_pool.RedisConnection
-	KEYS  []string
-	Cache MapStringToFloat64Ptrs
+	Redis  *dog_pool.RedisConnection
+	KEYS   []string
+	Cache  MapStringToFloat64Ptrs
+	Format *Formatter // nil for DefaultFormatter
 }
 
 // Make a new instance of RedisMKeysCounterFloat64
 func MakeRedisMKeysCounterFloat64(redis *dog_pool.RedisConnection, keys ...string) (*RedisMKeysCounterFloat64, error) {
 	switch {
 	case nil == redis:
-		return nil, fmt.Errorf("Nil redis connection")
+		return nil, ErrNilConnection
 	case len(keys) == 0:
-		return nil, fmt.Errorf("Empty redis keys")
+		return nil, ErrNoKeys
 	default:
 		for i, key := range keys {
 			if len(key) == 0 {
-				return nil, fmt.Errorf("Empty redis key[%d]", i)
+				return nil, &ErrEmptyKey{Index: i}
 			}
 		}
 
@@ -41,58 +43,119 @@ func (p *RedisMKeysCounterFloat64) CacheReset() {
 	}
 }
 
-// Format the values as a string
+// Format the values as a string with "Format", in the order of KEYS
 func (p *RedisMKeysCounterFloat64) String() string {
-	return p.Cache.String()
+	f := formatterOrDefault(p.Format)
+	values := make([]string, len(p.KEYS))
+	for i, key := range p.KEYS {
+		values[i] = f.Float64(p.Cache.Value(key))
+	}
+	return f.formatKeys(p.KEYS, values)
 }
 
 // Get the value of the counters; saves the counter to "LastValues"
 func (p *RedisMKeysCounterFloat64) MFloat64() ([]float64, error) {
-	return p.operationReturnsAmounts("MGET")
+	return valuesOfResultsFloat64(p.operationReturnsAmounts("MGET"))
 }
 
 func (p *RedisMKeysCounterFloat64) MExists() ([]bool, error) {
 	p.CacheReset()
 
-	return p.Redis.KeysExist(p.KEYS...)
+	values, err := p.Redis.KeysExist(p.KEYS...)
+	return values, redisError(err, "", "")
 }
 
 func (p *RedisMKeysCounterFloat64) MDelete() error {
 	p.CacheReset()
 
 	reply := p.Redis.Cmd("DEL", p.KEYS)
-	return reply.Err
+	return redisError(reply.Err, "", "")
 }
 
 func (p *RedisMKeysCounterFloat64) MGet() ([]float64, error) {
 	return p.MFloat64()
 }
 
+// Get the value of the counters and whether each key exists, so a
+// missing counter can be told from one holding 0; saves the counters to
+// "Cache"
+func (p *RedisMKeysCounterFloat64) MGetOptional() ([]float64, []bool, error) {
+	return optionalsOfResultsFloat64(p.operationReturnsAmounts("MGET"))
+}
+
 func (p *RedisMKeysCounterFloat64) MSet(amount float64) ([]float64, error) {
 	return p.operationReplacesAmounts(amount)
 }
 
 func (p *RedisMKeysCounterFloat64) MAdd(amount float64) ([]float64, error) {
-	return p.operationModifiesAmounts("INCRBY", amount)
+	return valuesOfResultsFloat64(p.operationModifiesAmounts("INCRBYFLOAT", amount))
 }
 
 func (p *RedisMKeysCounterFloat64) MSub(amount float64) ([]float64, error) {
-	return p.operationModifiesAmounts("DECRBY", amount)
+	return p.MAdd(-1 * amount)
 }
 
 func (p *RedisMKeysCounterFloat64) MIncrement() ([]float64, error) {
-	return p.operationReturnsAmount("INCR")
+	return p.MAdd(1)
 }
 
 func (p *RedisMKeysCounterFloat64) MDecrement() ([]float64, error) {
-	return p.operationReturnsAmount("DECR")
+	return p.MAdd(-1)
+}
+
+// Get the value of each counter, with a result per key instead of
+// failing on the first error; the error is only set when Redis can't be
+// reached
+func (p *RedisMKeysCounterFloat64) MGetResults() ([]ResultFloat64, error) {
+	return p.operationReturnsAmounts("MGET")
+}
+
+func (p *RedisMKeysCounterFloat64) MAddResults(amount float64) ([]ResultFloat64, error) {
+	return p.operationModifiesAmounts("INCRBYFLOAT", amount)
+}
+
+func (p *RedisMKeysCounterFloat64) MSubResults(amount float64) ([]ResultFloat64, error) {
+	return p.MAddResults(-1 * amount)
+}
+
+// Encode the keys and "Cache" as JSON; a missing counter is null
+func (p *RedisMKeysCounterFloat64) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the keys and "Cache" from JSON
+func (p *RedisMKeysCounterFloat64) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotFloat64, shapeKeys)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisMKeysCounterFloat64) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the keys and "Cache" in the binary encoding
+func (p *RedisMKeysCounterFloat64) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the keys and "Cache" from the binary encoding
+func (p *RedisMKeysCounterFloat64) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotFloat64, shapeKeys)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
 }
 
 //
 // Internal Helpers:
 //
 
-func (p *RedisMKeysCounterFloat64) operationReturnsAmounts(cmd string) ([]float64, error) {
+func (p *RedisMKeysCounterFloat64) operationReturnsAmounts(cmd string) ([]ResultFloat64, error) {
 	p.CacheReset()
 
 	count := len(p.KEYS)
@@ -100,25 +163,18 @@ func (p *RedisMKeysCounterFloat64) operationReturnsAmounts(cmd string) ([]float6
 	reply := p.Redis.Cmd(cmd, p.KEYS)
 	switch {
 	case nil != reply.Err:
-		return nil, reply.Err
+		return nil, redisError(reply.Err, "", "")
 	default:
-		values := make([]float64, count)
+		results := make([]ResultFloat64, count)
 		for i, key := range p.KEYS {
-			ptr, err := toFloat64Ptr(reply.Elems[i])
-			switch {
-			case nil != err:
-				return nil, err
-			case nil != ptr:
-				p.Cache.Set(key, ptr)
-				values[i] = *ptr
-			}
+			results[i] = p.cacheResult(key, reply.Elems[i])
 		}
 
-		return values, nil
+		return results, nil
 	}
 }
 
-func (p *RedisMKeysCounterFloat64) operationReturnsAmount(cmd string) ([]float64, error) {
+func (p *RedisMKeysCounterFloat64) operationReturnsAmount(cmd string) ([]ResultFloat64, error) {
 	p.CacheReset()
 
 	count := len(p.KEYS)
@@ -131,29 +187,22 @@ func (p *RedisMKeysCounterFloat64) operationReturnsAmount(cmd string) ([]float64
 
 	err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(p.Redis)
 	if err != nil {
-		return nil, err
+		return nil, redisError(err, "", "")
 	}
 
-	values := make([]float64, count)
+	results := make([]ResultFloat64, count)
 	for i, key := range p.KEYS {
-		ptr, err := toFloat64Ptr(commands[i].Reply())
-		switch {
-		case nil != err:
-			return nil, err
-		case nil != ptr:
-			p.Cache.Set(key, ptr)
-			values[i] = *ptr
-		}
+		results[i] = p.cacheResult(key, commands[i].Reply())
 	}
 
-	return values, nil
+	return results, nil
 }
 
-func (p *RedisMKeysCounterFloat64) operationModifiesAmounts(cmd string, amount float64) ([]float64, error) {
+func (p *RedisMKeysCounterFloat64) operationModifiesAmounts(cmd string, amount float64) ([]ResultFloat64, error) {
 	p.CacheReset()
 
 	count := len(p.KEYS)
-	amount_bytes := []byte(fmt.Sprintf("%d", amount))
+	amount_bytes := []byte(strconv.FormatFloat(amount, 'f', -1, 64))
 	commands := make([]*dog_pool.RedisBatchCommand, count)
 	for i, key := range p.KEYS {
 		commands[i] = dog_pool.MakeRedisBatchCommand(cmd)
@@ -163,22 +212,15 @@ func (p *RedisMKeysCounterFloat64) operationModifiesAmounts(cmd string, amount f
 
 	err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(p.Redis)
 	if err != nil {
-		return nil, err
+		return nil, redisError(err, "", "")
 	}
 
-	values := make([]float64, count)
+	results := make([]ResultFloat64, count)
 	for i, key := range p.KEYS {
-		ptr, err := toFloat64Ptr(commands[i].Reply())
-		switch {
-		case nil != err:
-			return nil, err
-		case nil != ptr:
-			p.Cache.Set(key, ptr)
-			values[i] = *ptr
-		}
+		results[i] = p.cacheResult(key, commands[i].Reply())
 	}
 
-	return values, nil
+	return results, nil
 }
 
 func (p *RedisMKeysCounterFloat64) operationReplacesAmounts(amount float64) ([]float64, error) {
@@ -186,7 +228,7 @@ func (p *RedisMKeysCounterFloat64) operationReplacesAmounts(amount float64) ([]f
 
 	count := len(p.KEYS)
 
-	amount_bytes := []byte(fmt.Sprintf("%d", amount))
+	amount_bytes := []byte(strconv.FormatFloat(amount, 'f', -1, 64))
 	buffer := make([][]byte, len(p.KEYS)*2)[0:0]
 	for _, key := range p.KEYS {
 		buffer = append(buffer, []byte(key), amount_bytes)
@@ -194,7 +236,7 @@ func (p *RedisMKeysCounterFloat64) operationReplacesAmounts(amount float64) ([]f
 
 	reply := p.Redis.Cmd("MSET", buffer)
 	if nil != reply.Err {
-		return nil, reply.Err
+		return nil, redisError(reply.Err, "", "")
 	}
 
 	values := make([]float64, count)
@@ -205,3 +247,37 @@ func (p *RedisMKeysCounterFloat64) operationReplacesAmounts(amount float64) ([]f
 
 	return values, nil
 }
+
+// Parse the reply for one key into the cache
+func (p *RedisMKeysCounterFloat64) cacheResult(key string, reply *redis.Reply) ResultFloat64 {
+	ptr, err := toFloat64Ptr(reply, key, "")
+	p.Cache.Set(key, ptr)
+	return makeResultFloat64(key, ptr, err)
+}
+
+// Identity and "Cache" of the counters, for their encodings
+func (p *RedisMKeysCounterFloat64) state() *counterState {
+	values := make([]*string, len(p.KEYS))
+	for i, key := range p.KEYS {
+		values[i] = float64Text(p.Cache.Value(key))
+	}
+	return &counterState{
+		Type:   SnapshotFloat64,
+		Shape:  shapeKeys,
+		Names:  p.KEYS,
+		Values: values,
+	}
+}
+
+func (p *RedisMKeysCounterFloat64) setState(s *counterState) error {
+	cache := MakeMapStringToFloat64Ptrs(len(s.Names))
+	for i, key := range s.Names {
+		value, err := textFloat64(s.Values[i])
+		if nil != err {
+			return err
+		}
+		cache.Set(key, value)
+	}
+	p.KEYS, p.Cache = s.Names, cache
+	return nil
+}
@@ -370,6 +370,59 @@ func RedisMKeysCounterFloat64Specs(c gospec.Context) {
 		}
 	})
 
+	c.Specify("[RedisMKeysCounterFloat64][MAdd] Adds fractional amounts", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisMKeysCounterFloat64(server.Connection(), "Bob", "George")
+
+		counters, err := value.MSet(1.5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(counters), gospec.Equals, 2)
+
+		counters, err = value.MAdd(0.25)
+		c.Expect(err, gospec.Equals, nil)
+		for _, counter := range counters {
+			c.Expect(counter, gospec.Equals, 1.75)
+		}
+
+		counters, err = value.MSub(0.5)
+		c.Expect(err, gospec.Equals, nil)
+		for _, counter := range counters {
+			c.Expect(counter, gospec.Equals, 1.25)
+		}
+
+		list, list_err := server.Connection().Cmd("MGET", value.KEYS).List()
+		c.Expect(list_err, gospec.Equals, nil)
+		for _, list_value := range list {
+			c.Expect(list_value, gospec.Equals, "1.25")
+		}
+	})
+
+	c.Specify("[RedisMKeysCounterFloat64][MGetOptional] Tells missing from zero", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisMKeysCounterFloat64(server.Connection(), "Bob", "George")
+		server.Connection().Cmd("SET", "Bob", "0")
+
+		counters, found, err := value.MGetOptional()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(counters), gospec.Equals, 2)
+		c.Expect(counters[0], gospec.Equals, float64(0))
+		c.Expect(counters[1], gospec.Equals, float64(0))
+		c.Expect(len(found), gospec.Equals, 2)
+		c.Expect(found[0], gospec.Equals, true)
+		c.Expect(found[1], gospec.Equals, false)
+	})
 }
 
 func Benchmark_RedisMKeysCounterFloat64_MMake(b *testing.B) {
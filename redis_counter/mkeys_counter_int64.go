@@ -1,26 +1,28 @@
 package redis_counter
 
 import "fmt"
+import "github.com/fzzy/radix/redis"
 import "github.com/gnagel/dog_pool/dog_pool"
 import . "github.com/gnagel/go_map_to_ptrs/map_to_ptrs"
 
 type RedisMKeysCounterInt64 struct {
-	Redis *dog_pool.RedisConnection
-	KEYS  []string
-	Cache MapStringToInt64Ptrs
+	Redis  *dog_pool.RedisConnection
+	KEYS   []string
+	Cache  MapStringToInt64Ptrs
+	Format *Formatter // nil for DefaultFormatter
 }
 
 // Make a new instance of RedisMKeysCounterInt64
 func MakeRedisMKeysCounterInt64(redis *dog_pool.RedisConnection, keys ...string) (*RedisMKeysCounterInt64, error) {
 	switch {
 	case nil == redis:
-		return nil, fmt.Errorf("Nil redis connection")
+		return nil, ErrNilConnection
 	case len(keys) == 0:
-		return nil, fmt.Errorf("Empty redis keys")
+		return nil, ErrNoKeys
 	default:
 		for i, key := range keys {
 			if len(key) == 0 {
-				return nil, fmt.Errorf("Empty redis key[%d]", i)
+				return nil, &ErrEmptyKey{Index: i}
 			}
 		}
 
@@ -41,58 +43,119 @@ func (p *RedisMKeysCounterInt64) CacheReset() {
 	}
 }
 
-// Format the values as a string
+// Format the values as a string with "Format", in the order of KEYS
 func (p *RedisMKeysCounterInt64) String() string {
-	return p.Cache.String()
+	f := formatterOrDefault(p.Format)
+	values := make([]string, len(p.KEYS))
+	for i, key := range p.KEYS {
+		values[i] = f.Int64(p.Cache.Value(key))
+	}
+	return f.formatKeys(p.KEYS, values)
 }
 
 // Get the value of the counters; saves the counter to "LastValues"
 func (p *RedisMKeysCounterInt64) MInt64() ([]int64, error) {
-	return p.operationReturnsAmounts("MGET")
+	return valuesOfResultsInt64(p.operationReturnsAmounts("MGET"))
 }
 
 func (p *RedisMKeysCounterInt64) MExists() ([]bool, error) {
 	p.CacheReset()
 
-	return p.Redis.KeysExist(p.KEYS...)
+	values, err := p.Redis.KeysExist(p.KEYS...)
+	return values, redisError(err, "", "")
 }
 
 func (p *RedisMKeysCounterInt64) MDelete() error {
 	p.CacheReset()
 
 	reply := p.Redis.Cmd("DEL", p.KEYS)
-	return reply.Err
+	return redisError(reply.Err, "", "")
 }
 
 func (p *RedisMKeysCounterInt64) MGet() ([]int64, error) {
 	return p.MInt64()
 }
 
+// Get the value of the counters and whether each key exists, so a
+// missing counter can be told from one holding 0; saves the counters to
+// "Cache"
+func (p *RedisMKeysCounterInt64) MGetOptional() ([]int64, []bool, error) {
+	return optionalsOfResultsInt64(p.operationReturnsAmounts("MGET"))
+}
+
 func (p *RedisMKeysCounterInt64) MSet(amount int64) ([]int64, error) {
 	return p.operationReplacesAmounts(amount)
 }
 
 func (p *RedisMKeysCounterInt64) MAdd(amount int64) ([]int64, error) {
-	return p.operationModifiesAmounts("INCRBY", amount)
+	return valuesOfResultsInt64(p.operationModifiesAmounts("INCRBY", amount))
 }
 
 func (p *RedisMKeysCounterInt64) MSub(amount int64) ([]int64, error) {
-	return p.operationModifiesAmounts("DECRBY", amount)
+	return valuesOfResultsInt64(p.operationModifiesAmounts("DECRBY", amount))
 }
 
 func (p *RedisMKeysCounterInt64) MIncrement() ([]int64, error) {
-	return p.operationReturnsAmount("INCR")
+	return valuesOfResultsInt64(p.operationReturnsAmount("INCR"))
 }
 
 func (p *RedisMKeysCounterInt64) MDecrement() ([]int64, error) {
-	return p.operationReturnsAmount("DECR")
+	return valuesOfResultsInt64(p.operationReturnsAmount("DECR"))
+}
+
+// Get the value of each counter, with a result per key instead of
+// failing on the first error; the error is only set when Redis can't be
+// reached
+func (p *RedisMKeysCounterInt64) MGetResults() ([]ResultInt64, error) {
+	return p.operationReturnsAmounts("MGET")
+}
+
+func (p *RedisMKeysCounterInt64) MAddResults(amount int64) ([]ResultInt64, error) {
+	return p.operationModifiesAmounts("INCRBY", amount)
+}
+
+func (p *RedisMKeysCounterInt64) MSubResults(amount int64) ([]ResultInt64, error) {
+	return p.operationModifiesAmounts("DECRBY", amount)
+}
+
+// Encode the keys and "Cache" as JSON; a missing counter is null
+func (p *RedisMKeysCounterInt64) MarshalJSON() ([]byte, error) {
+	return p.state().marshalJSON()
+}
+
+// Decode the keys and "Cache" from JSON
+func (p *RedisMKeysCounterInt64) UnmarshalJSON(data []byte) error {
+	state, err := unmarshalStateJSON(data, SnapshotInt64, shapeKeys)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
+}
+
+// Format as String, with exact values
+func (p *RedisMKeysCounterInt64) MarshalText() ([]byte, error) {
+	return p.state().marshalText(), nil
+}
+
+// Encode the keys and "Cache" in the binary encoding
+func (p *RedisMKeysCounterInt64) MarshalBinary() ([]byte, error) {
+	return p.state().marshalBinary()
+}
+
+// Decode the keys and "Cache" from the binary encoding
+func (p *RedisMKeysCounterInt64) UnmarshalBinary(data []byte) error {
+	state, err := unmarshalStateBinary(data, SnapshotInt64, shapeKeys)
+	if nil != err {
+		return err
+	}
+	return p.setState(state)
 }
 
 //
 // Internal Helpers:
 //
 
-func (p *RedisMKeysCounterInt64) operationReturnsAmounts(cmd string) ([]int64, error) {
+func (p *RedisMKeysCounterInt64) operationReturnsAmounts(cmd string) ([]ResultInt64, error) {
 	p.CacheReset()
 
 	count := len(p.KEYS)
@@ -100,25 +163,18 @@ func (p *RedisMKeysCounterInt64) operationReturnsAmounts(cmd string) ([]int64, e
 	reply := p.Redis.Cmd(cmd, p.KEYS)
 	switch {
 	case nil != reply.Err:
-		return nil, reply.Err
+		return nil, redisError(reply.Err, "", "")
 	default:
-		values := make([]int64, count)
+		results := make([]ResultInt64, count)
 		for i, key := range p.KEYS {
-			ptr, err := toInt64Ptr(reply.Elems[i])
-			switch {
-			case nil != err:
-				return nil, err
-			case nil != ptr:
-				p.Cache.Set(key, ptr)
-				values[i] = *ptr
-			}
+			results[i] = p.cacheResult(key, reply.Elems[i])
 		}
 
-		return values, nil
+		return results, nil
 	}
 }
 
-func (p *RedisMKeysCounterInt64) operationReturnsAmount(cmd string) ([]int64, error) {
+func (p *RedisMKeysCounterInt64) operationReturnsAmount(cmd string) ([]ResultInt64, error) {
 	p.CacheReset()
 
 	count := len(p.KEYS)
@@ -131,25 +187,18 @@ func (p *RedisMKeysCounterInt64) operationReturnsAmount(cmd string) ([]int64, er
 
 	err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(p.Redis)
 	if err != nil {
-		return nil, err
+		return nil, redisError(err, "", "")
 	}
 
-	values := make([]int64, count)
+	results := make([]ResultInt64, count)
 	for i, key := range p.KEYS {
-		ptr, err := toInt64Ptr(commands[i].Reply())
-		switch {
-		case nil != err:
-			return nil, err
-		case nil != ptr:
-			p.Cache.Set(key, ptr)
-			values[i] = *ptr
-		}
+		results[i] = p.cacheResult(key, commands[i].Reply())
 	}
 
-	return values, nil
+	return results, nil
 }
 
-func (p *RedisMKeysCounterInt64) operationModifiesAmounts(cmd string, amount int64) ([]int64, error) {
+func (p *RedisMKeysCounterInt64) operationModifiesAmounts(cmd string, amount int64) ([]ResultInt64, error) {
 	p.CacheReset()
 
 	count := len(p.KEYS)
@@ -163,22 +212,15 @@ func (p *RedisMKeysCounterInt64) operationModifiesAmounts(cmd string, amount int
 
 	err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(p.Redis)
 	if err != nil {
-		return nil, err
+		return nil, redisError(err, "", "")
 	}
 
-	values := make([]int64, count)
+	results := make([]ResultInt64, count)
 	for i, key := range p.KEYS {
-		ptr, err := toInt64Ptr(commands[i].Reply())
-		switch {
-		case nil != err:
-			return nil, err
-		case nil != ptr:
-			p.Cache.Set(key, ptr)
-			values[i] = *ptr
-		}
+		results[i] = p.cacheResult(key, commands[i].Reply())
 	}
 
-	return values, nil
+	return results, nil
 }
 
 func (p *RedisMKeysCounterInt64) operationReplacesAmounts(amount int64) ([]int64, error) {
@@ -194,7 +236,7 @@ func (p *RedisMKeysCounterInt64) operationReplacesAmounts(amount int64) ([]int64
 
 	reply := p.Redis.Cmd("MSET", buffer)
 	if nil != reply.Err {
-		return nil, reply.Err
+		return nil, redisError(reply.Err, "", "")
 	}
 
 	values := make([]int64, count)
@@ -205,3 +247,37 @@ func (p *RedisMKeysCounterInt64) operationReplacesAmounts(amount int64) ([]int64
 
 	return values, nil
 }
+
+// Parse the reply for one key into the cache
+func (p *RedisMKeysCounterInt64) cacheResult(key string, reply *redis.Reply) ResultInt64 {
+	ptr, err := toInt64Ptr(reply, key, "")
+	p.Cache.Set(key, ptr)
+	return makeResultInt64(key, ptr, err)
+}
+
+// Identity and "Cache" of the counters, for their encodings
+func (p *RedisMKeysCounterInt64) state() *counterState {
+	values := make([]*string, len(p.KEYS))
+	for i, key := range p.KEYS {
+		values[i] = int64Text(p.Cache.Value(key))
+	}
+	return &counterState{
+		Type:   SnapshotInt64,
+		Shape:  shapeKeys,
+		Names:  p.KEYS,
+		Values: values,
+	}
+}
+
+func (p *RedisMKeysCounterInt64) setState(s *counterState) error {
+	cache := MakeMapStringToInt64Ptrs(len(s.Names))
+	for i, key := range s.Names {
+		value, err := textInt64(s.Values[i])
+		if nil != err {
+			return err
+		}
+		cache.Set(key, value)
+	}
+	p.KEYS, p.Cache = s.Names, cache
+	return nil
+}
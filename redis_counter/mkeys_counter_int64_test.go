@@ -370,6 +370,47 @@ func RedisMKeysCounterInt64Specs(c gospec.Context) {
 		}
 	})
 
+	c.Specify("[RedisMKeysCounterInt64][MGetResults] Returns a result per key", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisMKeysCounterInt64(server.Connection(), "Bob", "George", "Alex")
+		server.Connection().Cmd("SET", "Bob", "123")
+		server.Connection().Cmd("SET", "George", "Gary")
+
+		results, err := value.MGetResults()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(results), gospec.Equals, 3)
+		c.Expect(results[0], gospec.Equals, ResultInt64{Key: "Bob", Value: 123, Exists: true})
+		c.Expect(results[1].Key, gospec.Equals, "George")
+		c.Expect(results[1].Exists, gospec.Equals, false)
+		c.Expect(results[1].Err, gospec.Satisfies, nil != results[1].Err)
+		c.Expect(results[2], gospec.Equals, ResultInt64{Key: "Alex"})
+
+		// The cache has every valid key:
+		c.Expect(*value.Cache.Value("Bob"), gospec.Equals, int64(123))
+
+		// The values fail on the first error, after caching every key:
+		counters, err := value.MGet()
+		c.Expect(err, gospec.Satisfies, nil != err)
+		c.Expect(counters, gospec.Satisfies, nil == counters)
+		c.Expect(*value.Cache.Value("Bob"), gospec.Equals, int64(123))
+
+		results, err = value.MAddResults(2)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(results[0], gospec.Equals, ResultInt64{Key: "Bob", Value: 125, Exists: true})
+		c.Expect(results[1].Err, gospec.Satisfies, nil != results[1].Err)
+		c.Expect(results[2], gospec.Equals, ResultInt64{Key: "Alex", Value: 2, Exists: true})
+
+		results, err = value.MSubResults(1)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(results[0].Value, gospec.Equals, int64(124))
+		c.Expect(results[2].Value, gospec.Equals, int64(1))
+	})
 }
 
 func Benchmark_RedisMKeysCounterInt64_MMake(b *testing.B) {
@@ -0,0 +1,339 @@
+package redis_counter
+
+import "fmt"
+import "strings"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Label values for the dimensions of a Namespace
+type Labels map[string]string
+
+// Builds keys and hash fields from labels, so every caller formats them
+// the same way:
+//
+//	{HashTag}:Prefix:dimension1:dimension2    (key)
+//	field1:field2                             (hash field)
+//
+// Values are joined in the order of Dimensions and FieldDimensions, and
+// may not contain the separator, so every key parses back to its labels.
+// The hash tag is optional; when set every key of the namespace maps to
+// the same Redis Cluster slot.
+type Namespace struct {
+	Redis           *dog_pool.RedisConnection
+	Prefix          string
+	Separator       string
+	HashTag         string
+	Dimensions      []string
+	FieldDimensions []string
+}
+
+// Make a new instance of Namespace; set HashTag and FieldDimensions on
+// the result when needed
+func MakeNamespace(redis *dog_pool.RedisConnection, prefix, separator string, dimensions ...string) (*Namespace, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	case len(separator) == 0:
+		return nil, fmt.Errorf("Empty separator")
+	case len(prefix) == 0 && len(dimensions) == 0:
+		return nil, fmt.Errorf("Empty prefix and dimensions")
+	default:
+		if err := validateDimensions(dimensions); nil != err {
+			return nil, err
+		}
+		return &Namespace{Redis: redis, Prefix: prefix, Separator: separator, Dimensions: dimensions}, nil
+	}
+}
+
+// Build the key for the labels of Dimensions; other labels are ignored
+func (p *Namespace) Key(labels Labels) (string, error) {
+	if err := p.validate(); nil != err {
+		return "", err
+	}
+
+	parts := []string{}
+	if len(p.HashTag) > 0 {
+		parts = append(parts, "{"+p.HashTag+"}")
+	}
+	if len(p.Prefix) > 0 {
+		parts = append(parts, p.Prefix)
+	}
+
+	values, err := p.values(p.Dimensions, labels)
+	if nil != err {
+		return "", err
+	}
+	return strings.Join(append(parts, values...), p.Separator), nil
+}
+
+// Build the hash field for the labels of FieldDimensions; other labels
+// are ignored
+func (p *Namespace) Field(labels Labels) (string, error) {
+	if len(p.FieldDimensions) == 0 {
+		return "", fmt.Errorf("Empty field dimensions")
+	}
+	if err := validateDimensions(p.FieldDimensions); nil != err {
+		return "", err
+	}
+
+	values, err := p.values(p.FieldDimensions, labels)
+	if nil != err {
+		return "", err
+	}
+	return strings.Join(values, p.Separator), nil
+}
+
+// Build the key and the hash field for the labels
+func (p *Namespace) KeyField(labels Labels) (string, string, error) {
+	key, err := p.Key(labels)
+	if nil != err {
+		return "", "", err
+	}
+
+	field, err := p.Field(labels)
+	if nil != err {
+		return "", "", err
+	}
+	return key, field, nil
+}
+
+// Parse the labels of Dimensions back out of a key
+func (p *Namespace) ParseKey(key string) (Labels, error) {
+	if err := p.validate(); nil != err {
+		return nil, err
+	}
+
+	// Everything before the dimensions:
+	head := []string{}
+	if len(p.HashTag) > 0 {
+		head = append(head, "{"+p.HashTag+"}")
+	}
+	if len(p.Prefix) > 0 {
+		head = append(head, p.Prefix)
+	}
+
+	rest := key
+	if len(head) > 0 {
+		start := strings.Join(head, p.Separator)
+		if len(p.Dimensions) > 0 {
+			start += p.Separator
+		}
+		if !strings.HasPrefix(key, start) {
+			return nil, fmt.Errorf("Key not in namespace: %s", key)
+		}
+		rest = key[len(start):]
+	}
+
+	labels, err := p.parse(p.Dimensions, rest)
+	if nil != err {
+		return nil, fmt.Errorf("Invalid key: %s", key)
+	}
+	return labels, nil
+}
+
+// Parse the labels of FieldDimensions back out of a hash field
+func (p *Namespace) ParseField(field string) (Labels, error) {
+	if len(p.FieldDimensions) == 0 {
+		return nil, fmt.Errorf("Empty field dimensions")
+	}
+
+	labels, err := p.parse(p.FieldDimensions, field)
+	if nil != err {
+		return nil, fmt.Errorf("Invalid field: %s", field)
+	}
+	return labels, nil
+}
+
+// Parse the labels of both Dimensions and FieldDimensions
+func (p *Namespace) ParseKeyField(key, field string) (Labels, error) {
+	labels, err := p.ParseKey(key)
+	if nil != err {
+		return nil, err
+	}
+
+	field_labels, err := p.ParseField(field)
+	if nil != err {
+		return nil, err
+	}
+
+	for name, value := range field_labels {
+		labels[name] = value
+	}
+	return labels, nil
+}
+
+func (p *Namespace) KeyCounterInt64(labels Labels) (*RedisKeyCounterInt64, error) {
+	key, err := p.Key(labels)
+	if nil != err {
+		return nil, err
+	}
+	return MakeRedisKeyCounterInt64(p.Redis, key)
+}
+
+func (p *Namespace) KeyCounterFloat64(labels Labels) (*RedisKeyCounterFloat64, error) {
+	key, err := p.Key(labels)
+	if nil != err {
+		return nil, err
+	}
+	return MakeRedisKeyCounterFloat64(p.Redis, key)
+}
+
+func (p *Namespace) HashFieldCounterInt64(labels Labels) (*RedisHashFieldCounterInt64, error) {
+	key, field, err := p.KeyField(labels)
+	if nil != err {
+		return nil, err
+	}
+	return MakeRedisHashFieldCounterInt64(p.Redis, key, field)
+}
+
+func (p *Namespace) HashFieldCounterFloat64(labels Labels) (*RedisHashFieldCounterFloat64, error) {
+	key, field, err := p.KeyField(labels)
+	if nil != err {
+		return nil, err
+	}
+	return MakeRedisHashFieldCounterFloat64(p.Redis, key, field)
+}
+
+func (p *Namespace) MKeysCounterInt64(labels ...Labels) (*RedisMKeysCounterInt64, error) {
+	keys, err := p.keys(labels)
+	if nil != err {
+		return nil, err
+	}
+	return MakeRedisMKeysCounterInt64(p.Redis, keys...)
+}
+
+func (p *Namespace) MKeysCounterFloat64(labels ...Labels) (*RedisMKeysCounterFloat64, error) {
+	keys, err := p.keys(labels)
+	if nil != err {
+		return nil, err
+	}
+	return MakeRedisMKeysCounterFloat64(p.Redis, keys...)
+}
+
+// Every labels must build the same key, with different fields
+func (p *Namespace) HashMFieldsCounterInt64(labels ...Labels) (*RedisHashMFieldsCounterInt64, error) {
+	key, fields, err := p.keyFields(labels)
+	if nil != err {
+		return nil, err
+	}
+	return MakeRedisHashMFieldsCounterInt64(p.Redis, key, fields...)
+}
+
+// Every labels must build the same key, with different fields
+func (p *Namespace) HashMFieldsCounterFloat64(labels ...Labels) (*RedisHashMFieldsCounterFloat64, error) {
+	key, fields, err := p.keyFields(labels)
+	if nil != err {
+		return nil, err
+	}
+	return MakeRedisHashMFieldsCounterFloat64(p.Redis, key, fields...)
+}
+
+func (p *Namespace) HashMeter(labels Labels) (*RedisHashMeter, error) {
+	key, err := p.Key(labels)
+	if nil != err {
+		return nil, err
+	}
+	return MakeRedisHashMeter(p.Redis, key)
+}
+
+//
+// Internal Helpers:
+//
+
+func validateDimensions(dimensions []string) error {
+	seen := make(map[string]bool)
+	for i, name := range dimensions {
+		switch {
+		case len(name) == 0:
+			return fmt.Errorf("Empty dimension[%d]", i)
+		case seen[name]:
+			return fmt.Errorf("Duplicate dimension: %s", name)
+		}
+		seen[name] = true
+	}
+	return nil
+}
+
+func (p *Namespace) validate() error {
+	switch {
+	case len(p.Separator) == 0:
+		return fmt.Errorf("Empty separator")
+	case strings.ContainsAny(p.HashTag, "{}"):
+		return fmt.Errorf("Invalid hash tag: %s", p.HashTag)
+	default:
+		return validateDimensions(p.Dimensions)
+	}
+}
+
+// Values of the labels, in the order of the dimensions
+func (p *Namespace) values(dimensions []string, labels Labels) ([]string, error) {
+	values := make([]string, len(dimensions))
+	for i, name := range dimensions {
+		value, ok := labels[name]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("Missing label: %s", name)
+		case len(value) == 0:
+			return nil, fmt.Errorf("Empty label: %s", name)
+		case strings.Contains(value, p.Separator):
+			return nil, fmt.Errorf("Label %s contains the separator: %s", name, value)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+func (p *Namespace) parse(dimensions []string, value string) (Labels, error) {
+	if len(dimensions) == 0 {
+		if len(value) > 0 {
+			return nil, fmt.Errorf("Unexpected value: %s", value)
+		}
+		return Labels{}, nil
+	}
+
+	values := strings.Split(value, p.Separator)
+	if len(values) != len(dimensions) {
+		return nil, fmt.Errorf("Expected %d values, found %d", len(dimensions), len(values))
+	}
+
+	labels := make(Labels, len(dimensions))
+	for i, name := range dimensions {
+		if len(values[i]) == 0 {
+			return nil, fmt.Errorf("Empty label: %s", name)
+		}
+		labels[name] = values[i]
+	}
+	return labels, nil
+}
+
+func (p *Namespace) keys(labels []Labels) ([]string, error) {
+	keys := make([]string, len(labels))
+	for i, labels := range labels {
+		key, err := p.Key(labels)
+		if nil != err {
+			return nil, fmt.Errorf("Labels[%d]: %w", i, err)
+		}
+		keys[i] = key
+	}
+	return keys, nil
+}
+
+func (p *Namespace) keyFields(labels []Labels) (string, []string, error) {
+	if len(labels) == 0 {
+		return "", nil, fmt.Errorf("Empty labels")
+	}
+
+	key := ""
+	fields := make([]string, len(labels))
+	for i, labels := range labels {
+		labels_key, field, err := p.KeyField(labels)
+		switch {
+		case nil != err:
+			return "", nil, fmt.Errorf("Labels[%d]: %w", i, err)
+		case i > 0 && labels_key != key:
+			return "", nil, fmt.Errorf("Labels[%d]: Different key: %s", i, labels_key)
+		}
+		key = labels_key
+		fields[i] = field
+	}
+	return key, fields, nil
+}
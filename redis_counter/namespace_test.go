@@ -0,0 +1,150 @@
+package redis_counter
+
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestNamespaceSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(NamespaceSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func NamespaceSpecs(c gospec.Context) {
+
+	c.Specify("[Namespace][Make] Makes new instance", func() {
+		value, err := MakeNamespace(nil, "stats", ":")
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeNamespace(&dog_pool.RedisConnection{}, "stats", "")
+		c.Expect(err.Error(), gospec.Equals, "Empty separator")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeNamespace(&dog_pool.RedisConnection{}, "", ":")
+		c.Expect(err.Error(), gospec.Equals, "Empty prefix and dimensions")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeNamespace(&dog_pool.RedisConnection{}, "stats", ":", "host", "")
+		c.Expect(err.Error(), gospec.Equals, "Empty dimension[1]")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeNamespace(&dog_pool.RedisConnection{}, "stats", ":", "host", "host")
+		c.Expect(err.Error(), gospec.Equals, "Duplicate dimension: host")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeNamespace(&dog_pool.RedisConnection{}, "stats", ":", "host", "region")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[Namespace][Key] Builds keys in dimension order", func() {
+		value, _ := MakeNamespace(&dog_pool.RedisConnection{}, "stats", ":", "region", "host")
+
+		key, err := value.Key(Labels{"host": "web1", "region": "us", "other": "ignored"})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(key, gospec.Equals, "stats:us:web1")
+
+		value.HashTag = "app"
+		key, err = value.Key(Labels{"host": "web1", "region": "us"})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(key, gospec.Equals, "{app}:stats:us:web1")
+
+		_, err = value.Key(Labels{"host": "web1"})
+		c.Expect(err.Error(), gospec.Equals, "Missing label: region")
+
+		_, err = value.Key(Labels{"host": "web1", "region": ""})
+		c.Expect(err.Error(), gospec.Equals, "Empty label: region")
+
+		_, err = value.Key(Labels{"host": "web:1", "region": "us"})
+		c.Expect(err.Error(), gospec.Equals, "Label host contains the separator: web:1")
+
+		value.HashTag = "{app}"
+		_, err = value.Key(Labels{"host": "web1", "region": "us"})
+		c.Expect(err.Error(), gospec.Equals, "Invalid hash tag: {app}")
+	})
+
+	c.Specify("[Namespace][Field] Builds hash fields", func() {
+		value, _ := MakeNamespace(&dog_pool.RedisConnection{}, "stats", "|", "region")
+
+		_, err := value.Field(Labels{"status": "200"})
+		c.Expect(err.Error(), gospec.Equals, "Empty field dimensions")
+
+		value.FieldDimensions = []string{"method", "status"}
+		key, field, err := value.KeyField(Labels{"region": "us", "status": "200", "method": "GET"})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(key, gospec.Equals, "stats|us")
+		c.Expect(field, gospec.Equals, "GET|200")
+	})
+
+	c.Specify("[Namespace][Parse] Parses keys and fields back", func() {
+		value, _ := MakeNamespace(&dog_pool.RedisConnection{}, "app:stats", ":", "region", "host")
+		value.HashTag = "app"
+		value.FieldDimensions = []string{"status"}
+
+		labels, err := value.ParseKeyField("{app}:app:stats:us:web1", "200")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(labels, gospec.Equals, Labels{"region": "us", "host": "web1", "status": "200"})
+
+		key, field, _ := value.KeyField(labels)
+		c.Expect(key, gospec.Equals, "{app}:app:stats:us:web1")
+		c.Expect(field, gospec.Equals, "200")
+
+		_, err = value.ParseKey("{app}:other:us:web1")
+		c.Expect(err.Error(), gospec.Equals, "Key not in namespace: {app}:other:us:web1")
+
+		_, err = value.ParseKey("{app}:app:stats:us")
+		c.Expect(err.Error(), gospec.Equals, "Invalid key: {app}:app:stats:us")
+
+		_, err = value.ParseKey("{app}:app:stats:us::web1")
+		c.Expect(err.Error(), gospec.Equals, "Invalid key: {app}:app:stats:us::web1")
+
+		_, err = value.ParseField("200:404")
+		c.Expect(err.Error(), gospec.Equals, "Invalid field: 200:404")
+	})
+
+	c.Specify("[Namespace][Counters] Makes counters bound to the namespace", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeNamespace(server.Connection(), "stats", ":", "host")
+		value.FieldDimensions = []string{"status"}
+
+		key, err := value.KeyCounterInt64(Labels{"host": "web1"})
+		c.Expect(err, gospec.Equals, nil)
+		key.Add(5)
+
+		keys, err := value.MKeysCounterFloat64(Labels{"host": "web1"}, Labels{"host": "web2"})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(keys.KEYS, gospec.ContainsInOrder, gospec.Values("stats:web1", "stats:web2"))
+		amounts, _ := keys.MGet()
+		c.Expect(amounts, gospec.ContainsInOrder, gospec.Values(float64(5), float64(0)))
+
+		_, err = value.MKeysCounterInt64(Labels{"host": "web1"}, Labels{})
+		c.Expect(err.Error(), gospec.Equals, "Labels[1]: Missing label: host")
+
+		field, err := value.HashFieldCounterInt64(Labels{"host": "web3", "status": "200"})
+		c.Expect(err, gospec.Equals, nil)
+		field.Add(3)
+
+		counter, _ := server.Connection().Cmd("HGET", "stats:web3", "200").Int64()
+		c.Expect(counter, gospec.Equals, int64(3))
+
+		fields, err := value.HashMFieldsCounterInt64(Labels{"host": "web3", "status": "200"}, Labels{"host": "web3", "status": "500"})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(fields.KEY, gospec.Equals, "stats:web3")
+		c.Expect(fields.FIELDS, gospec.ContainsInOrder, gospec.Values("200", "500"))
+
+		_, err = value.HashMFieldsCounterInt64(Labels{"host": "web3", "status": "200"}, Labels{"host": "web2", "status": "200"})
+		c.Expect(err.Error(), gospec.Equals, "Labels[1]: Different key: stats:web2")
+	})
+}
@@ -0,0 +1,90 @@
+package otel_exporter
+
+import "encoding/json"
+import "fmt"
+import "go.opentelemetry.io/otel/attribute"
+
+// Attribute sets are stored as the hash field of each data point,
+// encoded as a JSON list sorted by key so the same set always maps to
+// the same field
+type encodedAttribute struct {
+	Key   string          `json:"k"`
+	Type  string          `json:"t"`
+	Value json.RawMessage `json:"v"`
+}
+
+func encodeAttributes(set attribute.Set) (string, error) {
+	attrs := make([]encodedAttribute, 0, set.Len())
+	iter := set.Iter()
+	for iter.Next() {
+		kv := iter.Attribute()
+		value, err := json.Marshal(kv.Value.AsInterface())
+		if nil != err {
+			return "", err
+		}
+		attrs = append(attrs, encodedAttribute{string(kv.Key), kv.Value.Type().String(), value})
+	}
+
+	bytes, err := json.Marshal(attrs)
+	if nil != err {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func decodeAttributes(field string) (attribute.Set, error) {
+	attrs := []encodedAttribute{}
+	if err := json.Unmarshal([]byte(field), &attrs); nil != err {
+		return attribute.Set{}, err
+	}
+
+	kvs := make([]attribute.KeyValue, len(attrs))
+	for i, attr := range attrs {
+		kv, err := decodeAttribute(attr)
+		if nil != err {
+			return attribute.Set{}, err
+		}
+		kvs[i] = kv
+	}
+	return attribute.NewSet(kvs...), nil
+}
+
+func decodeAttribute(attr encodedAttribute) (attribute.KeyValue, error) {
+	key := attribute.Key(attr.Key)
+	switch attr.Type {
+	case "BOOL":
+		var value bool
+		err := json.Unmarshal(attr.Value, &value)
+		return key.Bool(value), err
+	case "INT64":
+		var value int64
+		err := json.Unmarshal(attr.Value, &value)
+		return key.Int64(value), err
+	case "FLOAT64":
+		var value float64
+		err := json.Unmarshal(attr.Value, &value)
+		return key.Float64(value), err
+	case "STRING":
+		var value string
+		err := json.Unmarshal(attr.Value, &value)
+		return key.String(value), err
+	case "BOOLSLICE":
+		var value []bool
+		err := json.Unmarshal(attr.Value, &value)
+		return key.BoolSlice(value), err
+	case "INT64SLICE":
+		var value []int64
+		err := json.Unmarshal(attr.Value, &value)
+		return key.Int64Slice(value), err
+	case "FLOAT64SLICE":
+		var value []float64
+		err := json.Unmarshal(attr.Value, &value)
+		return key.Float64Slice(value), err
+	case "STRINGSLICE":
+		var value []string
+		err := json.Unmarshal(attr.Value, &value)
+		return key.StringSlice(value), err
+	default:
+		return attribute.KeyValue{}, fmt.Errorf("Invalid attribute type %s for %s", attr.Type, attr.Key)
+	}
+}
@@ -0,0 +1,285 @@
+package otel_exporter
+
+import "context"
+import "encoding/json"
+import "fmt"
+import "strconv"
+import "sync"
+import "time"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+import "go.opentelemetry.io/otel/attribute"
+import "go.opentelemetry.io/otel/sdk/instrumentation"
+import sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+import "go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+// Redis layout, relative to the exporter's PREFIX:
+//
+//	PREFIX:meta:NAME         HASH of the instrument's kind, unit, bounds, ...
+//	PREFIX:instrument:NAME   HASH of attribute set => value
+//
+// The instruments are found by scanning for their meta hashes. Histogram
+// fields are prefixed with the component they store, e.g. "count|ATTRS",
+// "sum|ATTRS" and "bucket:3|ATTRS".
+func metaKey(prefix, name string) string {
+	return prefix + ":meta:" + name
+}
+
+func dataKey(prefix, name string) string {
+	return prefix + ":instrument:" + name
+}
+
+// Exports OpenTelemetry metrics into Redis hashes.
+// Every export adds its deltas to RedisHashFieldCounterInt64/Float64
+// counters in one pipeline, so many processes exporting the same
+// instrument share one logical metric.
+type RedisMetricsExporter struct {
+	Redis  *dog_pool.RedisConnection
+	PREFIX string
+
+	mutex    sync.Mutex
+	shutdown bool
+}
+
+// Make a new instance of RedisMetricsExporter
+func MakeRedisMetricsExporter(redis *dog_pool.RedisConnection, prefix string) (*RedisMetricsExporter, error) {
+	switch {
+	case nil == redis:
+		return nil, redis_counter.ErrNilConnection
+	case len(prefix) == 0:
+		return nil, fmt.Errorf("Empty redis prefix")
+	default:
+		return &RedisMetricsExporter{Redis: redis, PREFIX: prefix}, nil
+	}
+}
+
+// Implements sdkmetric.Exporter; every instrument is exported as deltas
+func (p *RedisMetricsExporter) Temporality(sdkmetric.InstrumentKind) metricdata.Temporality {
+	return metricdata.DeltaTemporality
+}
+
+// Implements sdkmetric.Exporter
+func (p *RedisMetricsExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+// Implements sdkmetric.Exporter
+func (p *RedisMetricsExporter) Export(ctx context.Context, metrics *metricdata.ResourceMetrics) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.shutdown {
+		return sdkmetric.ErrExporterShutdown
+	}
+
+	batch := &exportBatch{redis: p.Redis, pipeline: &redis_counter.RedisPipeline{Redis: p.Redis}}
+	now := time.Now()
+	for _, scope := range metrics.ScopeMetrics {
+		for _, metric := range scope.Metrics {
+			if err := batch.addMetric(p.PREFIX, scope.Scope, metric, now); nil != err {
+				return err
+			}
+		}
+	}
+	return batch.exec()
+}
+
+// Implements sdkmetric.Exporter; nothing is buffered
+func (p *RedisMetricsExporter) ForceFlush(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// Implements sdkmetric.Exporter
+func (p *RedisMetricsExporter) Shutdown(ctx context.Context) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.shutdown = true
+	return ctx.Err()
+}
+
+//
+// Internal Helpers:
+//
+
+// Meta data commands and data point counters of one export
+type exportBatch struct {
+	redis    *dog_pool.RedisConnection
+	meta     []*dog_pool.RedisBatchCommand
+	pipeline *redis_counter.RedisPipeline
+	results  []*redis_counter.PipelineResult
+}
+
+func makeCommand(cmd string, args ...string) *dog_pool.RedisBatchCommand {
+	command := dog_pool.MakeRedisBatchCommand(cmd)
+	for _, arg := range args {
+		command.WriteStringArg(arg)
+	}
+	return command
+}
+
+// Send the meta data, then the data points, so a producer never finds
+// data points of an unknown kind
+func (b *exportBatch) exec() error {
+	if len(b.meta) == 0 {
+		return nil
+	}
+
+	if err := dog_pool.RedisBatchCommands(b.meta).ExecuteBatch(b.redis); nil != err {
+		return err
+	}
+	for _, command := range b.meta {
+		if reply := command.Reply(); nil != reply.Err {
+			return reply.Err
+		}
+	}
+
+	if err := b.pipeline.Exec(); nil != err {
+		return err
+	}
+	for _, result := range b.results {
+		if err := result.Err(); nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *exportBatch) addInt64(key, field string, value int64, set bool) error {
+	counter, err := redis_counter.MakeRedisHashFieldCounterInt64(b.redis, key, field)
+	if nil != err {
+		return err
+	}
+
+	if set {
+		b.results = append(b.results, b.pipeline.SetInt64(counter, value))
+	} else {
+		b.results = append(b.results, b.pipeline.AddInt64(counter, value))
+	}
+	return nil
+}
+
+func (b *exportBatch) addFloat64(key, field string, value float64, set bool) error {
+	counter, err := redis_counter.MakeRedisHashFieldCounterFloat64(b.redis, key, field)
+	if nil != err {
+		return err
+	}
+
+	if set {
+		b.results = append(b.results, b.pipeline.SetFloat64(counter, value))
+	} else {
+		b.results = append(b.results, b.pipeline.AddFloat64(counter, value))
+	}
+	return nil
+}
+
+func (b *exportBatch) addMetric(prefix string, scope instrumentation.Scope, metric metricdata.Metrics, now time.Time) error {
+	key := dataKey(prefix, metric.Name)
+	meta := metaKey(prefix, metric.Name)
+	b.meta = append(b.meta,
+		makeCommand("HSETNX", meta, "start", strconv.FormatInt(now.UnixNano(), 10)),
+		makeCommand("HMSET", meta,
+			"scope", scope.Name,
+			"scope_version", scope.Version,
+			"description", metric.Description,
+			"unit", metric.Unit,
+		),
+	)
+
+	switch data := metric.Data.(type) {
+	case metricdata.Sum[int64]:
+		b.meta = append(b.meta, makeCommand("HMSET", meta, "kind", "sum", "number", "int64", "monotonic", strconv.FormatBool(data.IsMonotonic)))
+		for _, point := range data.DataPoints {
+			field, err := encodeAttributes(point.Attributes)
+			if nil != err {
+				return err
+			}
+			if err := b.addInt64(key, field, point.Value, false); nil != err {
+				return err
+			}
+		}
+
+	case metricdata.Sum[float64]:
+		b.meta = append(b.meta, makeCommand("HMSET", meta, "kind", "sum", "number", "float64", "monotonic", strconv.FormatBool(data.IsMonotonic)))
+		for _, point := range data.DataPoints {
+			field, err := encodeAttributes(point.Attributes)
+			if nil != err {
+				return err
+			}
+			if err := b.addFloat64(key, field, point.Value, false); nil != err {
+				return err
+			}
+		}
+
+	case metricdata.Gauge[int64]:
+		b.meta = append(b.meta, makeCommand("HMSET", meta, "kind", "gauge", "number", "int64"))
+		for _, point := range data.DataPoints {
+			field, err := encodeAttributes(point.Attributes)
+			if nil != err {
+				return err
+			}
+			if err := b.addInt64(key, field, point.Value, true); nil != err {
+				return err
+			}
+		}
+
+	case metricdata.Gauge[float64]:
+		b.meta = append(b.meta, makeCommand("HMSET", meta, "kind", "gauge", "number", "float64"))
+		for _, point := range data.DataPoints {
+			field, err := encodeAttributes(point.Attributes)
+			if nil != err {
+				return err
+			}
+			if err := b.addFloat64(key, field, point.Value, true); nil != err {
+				return err
+			}
+		}
+
+	case metricdata.Histogram[int64]:
+		b.meta = append(b.meta, makeCommand("HMSET", meta, "kind", "histogram", "number", "int64"))
+		for _, point := range data.DataPoints {
+			if err := b.addHistogram(key, meta, point.Attributes, point.Bounds, point.BucketCounts, point.Count, float64(point.Sum)); nil != err {
+				return err
+			}
+		}
+
+	case metricdata.Histogram[float64]:
+		b.meta = append(b.meta, makeCommand("HMSET", meta, "kind", "histogram", "number", "float64"))
+		for _, point := range data.DataPoints {
+			if err := b.addHistogram(key, meta, point.Attributes, point.Bounds, point.BucketCounts, point.Count, point.Sum); nil != err {
+				return err
+			}
+		}
+
+	default:
+		return fmt.Errorf("Unsupported aggregation %T for %s", metric.Data, metric.Name)
+	}
+
+	return nil
+}
+
+func (b *exportBatch) addHistogram(key, meta string, attrs attribute.Set, bounds []float64, buckets []uint64, count uint64, sum float64) error {
+	field, err := encodeAttributes(attrs)
+	if nil != err {
+		return err
+	}
+
+	bounds_json, err := json.Marshal(bounds)
+	if nil != err {
+		return err
+	}
+	b.meta = append(b.meta, makeCommand("HSET", meta, "bounds", string(bounds_json)))
+
+	if err := b.addInt64(key, "count|"+field, int64(count), false); nil != err {
+		return err
+	}
+	if err := b.addFloat64(key, "sum|"+field, sum, false); nil != err {
+		return err
+	}
+	for i, bucket := range buckets {
+		if err := b.addInt64(key, fmt.Sprintf("bucket:%d|%s", i, field), int64(bucket), false); nil != err {
+			return err
+		}
+	}
+	return nil
+}
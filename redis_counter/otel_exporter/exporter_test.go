@@ -0,0 +1,145 @@
+package otel_exporter
+
+import "context"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+import "go.opentelemetry.io/otel/attribute"
+import "go.opentelemetry.io/otel/metric"
+import sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+import "go.opentelemetry.io/otel/sdk/metric/metricdata"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisMetricsExporterSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisMetricsExporterSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+// Record the same measurements from a separate MeterProvider,
+// like a separate process sharing the metrics in Redis
+func recordMeasurements(exporter *RedisMetricsExporter, requests int64, balance, latency float64) error {
+	ctx := context.Background()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exporter)))
+	meter := provider.Meter("api", metric.WithInstrumentationVersion("1.0"))
+	route := metric.WithAttributes(attribute.String("route", "/login"))
+
+	counter, _ := meter.Int64Counter("requests", metric.WithUnit("{request}"))
+	counter.Add(ctx, requests, route)
+
+	updown, _ := meter.Float64UpDownCounter("balance")
+	updown.Add(ctx, balance, route)
+
+	histogram, _ := meter.Float64Histogram("latency", metric.WithExplicitBucketBoundaries(1, 5))
+	histogram.Record(ctx, latency, route)
+
+	if err := provider.ForceFlush(ctx); nil != err {
+		return err
+	}
+	return provider.Shutdown(ctx)
+}
+
+func RedisMetricsExporterSpecs(c gospec.Context) {
+
+	c.Specify("[RedisMetricsExporter][Make] Makes new instance", func() {
+		value, err := MakeRedisMetricsExporter(nil, "")
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisMetricsExporter(&dog_pool.RedisConnection{}, "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis prefix")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisMetricsExporter(&dog_pool.RedisConnection{}, "otel")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[RedisMetricsExporter][Temporality] Exports deltas", func() {
+		value, _ := MakeRedisMetricsExporter(&dog_pool.RedisConnection{}, "otel")
+		c.Expect(value.Temporality(sdkmetric.InstrumentKindCounter), gospec.Equals, metricdata.DeltaTemporality)
+		c.Expect(value.Temporality(sdkmetric.InstrumentKindUpDownCounter), gospec.Equals, metricdata.DeltaTemporality)
+		c.Expect(value.Temporality(sdkmetric.InstrumentKindHistogram), gospec.Equals, metricdata.DeltaTemporality)
+	})
+
+	c.Specify("[RedisMetricsExporter][Shutdown] Rejects exports", func() {
+		value, _ := MakeRedisMetricsExporter(&dog_pool.RedisConnection{}, "otel")
+		err := value.Shutdown(context.Background())
+		c.Expect(err, gospec.Equals, nil)
+
+		err = value.Export(context.Background(), &metricdata.ResourceMetrics{})
+		c.Expect(err, gospec.Equals, sdkmetric.ErrExporterShutdown)
+	})
+
+	c.Specify("[RedisMetricsExporter][Export] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		exporter1, _ := MakeRedisMetricsExporter(server.Connection(), "otel")
+		exporter2, _ := MakeRedisMetricsExporter(server.Connection(), "otel")
+
+		err := recordMeasurements(exporter1, 2, 1.5, 0.5)
+		c.Expect(err, gospec.Equals, nil)
+
+		err = recordMeasurements(exporter2, 3, -0.5, 7)
+		c.Expect(err, gospec.Equals, nil)
+
+		discovery, _ := redis_counter.MakeRedisKeyDiscovery(server.Connection(), "otel:meta:*", 10)
+		names, _ := discovery.Keys()
+		c.Expect(len(names), gospec.Equals, 3)
+
+		field := `[{"k":"route","t":"STRING","v":"/login"}]`
+		requests, _ := server.Connection().Cmd("HGET", "otel:instrument:requests", field).Int64()
+		c.Expect(requests, gospec.Equals, int64(5))
+
+		balance, _ := server.Connection().Cmd("HGET", "otel:instrument:balance", field).Str()
+		c.Expect(balance, gospec.Equals, "1")
+
+		count, _ := server.Connection().Cmd("HGET", "otel:instrument:latency", "count|"+field).Int64()
+		c.Expect(count, gospec.Equals, int64(2))
+
+		kind, _ := server.Connection().Cmd("HGET", "otel:meta:requests", "kind").Str()
+		c.Expect(kind, gospec.Equals, "sum")
+
+		unit, _ := server.Connection().Cmd("HGET", "otel:meta:requests", "unit").Str()
+		c.Expect(unit, gospec.Equals, "{request}")
+	})
+}
+
+func Benchmark_RedisMetricsExporter_Export(b *testing.B) {
+	logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+	server, err := dog_pool.StartRedisServer(&logger)
+	if nil != err {
+		panic(err)
+	}
+	defer server.Close()
+
+	value, _ := MakeRedisMetricsExporter(server.Connection(), "otel")
+	metrics := &metricdata.ResourceMetrics{
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Metrics: []metricdata.Metrics{{
+				Name: "requests",
+				Data: metricdata.Sum[int64]{
+					DataPoints:  []metricdata.DataPoint[int64]{{Attributes: attribute.NewSet(attribute.String("route", "/login")), Value: 1}},
+					Temporality: metricdata.DeltaTemporality,
+					IsMonotonic: true,
+				},
+			}},
+		}},
+	}
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		value.Export(context.Background(), metrics)
+	}
+}
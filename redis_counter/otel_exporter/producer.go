@@ -0,0 +1,322 @@
+package otel_exporter
+
+import "context"
+import "encoding/json"
+import "fmt"
+import "sort"
+import "strconv"
+import "strings"
+import "time"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+import "go.opentelemetry.io/otel/sdk/instrumentation"
+import "go.opentelemetry.io/otel/sdk/metric/metricdata"
+
+// Reads the metrics written by RedisMetricsExporter back as cumulative
+// data points; register it with sdkmetric.WithProducer to re-export the
+// shared metrics from a single process
+type RedisMetricsProducer struct {
+	Redis  *dog_pool.RedisConnection
+	PREFIX string
+}
+
+// Make a new instance of RedisMetricsProducer
+func MakeRedisMetricsProducer(redis *dog_pool.RedisConnection, prefix string) (*RedisMetricsProducer, error) {
+	switch {
+	case nil == redis:
+		return nil, redis_counter.ErrNilConnection
+	case len(prefix) == 0:
+		return nil, fmt.Errorf("Empty redis prefix")
+	default:
+		return &RedisMetricsProducer{redis, prefix}, nil
+	}
+}
+
+// Implements sdkmetric.Producer
+func (p *RedisMetricsProducer) Produce(ctx context.Context) ([]metricdata.ScopeMetrics, error) {
+	prefix := metaKey(p.PREFIX, "")
+	discovery, err := redis_counter.MakeRedisKeyDiscovery(p.Redis, globEscaper.Replace(prefix)+"*", redis_counter.DiscoveryBatchSize)
+	if nil != err {
+		return nil, err
+	}
+
+	keys, err := discovery.Keys()
+	if nil != err {
+		return nil, err
+	}
+
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = strings.TrimPrefix(key, prefix)
+	}
+	sort.Strings(names)
+
+	now := time.Now()
+	scopes := []metricdata.ScopeMetrics{}
+	indexes := make(map[instrumentation.Scope]int)
+	for _, name := range names {
+		if err := ctx.Err(); nil != err {
+			return nil, err
+		}
+
+		scope, metric, err := p.readMetric(name, now)
+		if nil != err {
+			return nil, err
+		}
+
+		i, ok := indexes[scope]
+		if !ok {
+			i = len(scopes)
+			indexes[scope] = i
+			scopes = append(scopes, metricdata.ScopeMetrics{Scope: scope})
+		}
+		scopes[i].Metrics = append(scopes[i].Metrics, metric)
+	}
+
+	return scopes, nil
+}
+
+//
+// Internal Helpers:
+//
+
+// Escapes the glob characters of a key for SCAN MATCH
+var globEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
+
+func (p *RedisMetricsProducer) readMeta(name string) (map[string]string, error) {
+	reply := p.Redis.Cmd("HGETALL", metaKey(p.PREFIX, name))
+	if nil != reply.Err {
+		return nil, reply.Err
+	}
+	return reply.Hash()
+}
+
+func (p *RedisMetricsProducer) readInt64s(name, match string) (map[string]int64, error) {
+	counter, err := redis_counter.MakeRedisHashCounterInt64(p.Redis, dataKey(p.PREFIX, name))
+	if nil != err {
+		return nil, err
+	}
+	if len(match) == 0 {
+		return counter.GetAll()
+	}
+
+	values := make(map[string]int64)
+	err = counter.Iterate(match, redis_counter.DiscoveryBatchSize, func(field string, value int64) error {
+		values[field] = value
+		return nil
+	})
+	return values, err
+}
+
+func (p *RedisMetricsProducer) readFloat64s(name, match string) (map[string]float64, error) {
+	counter, err := redis_counter.MakeRedisHashCounterFloat64(p.Redis, dataKey(p.PREFIX, name))
+	if nil != err {
+		return nil, err
+	}
+	if len(match) == 0 {
+		return counter.GetAll()
+	}
+
+	values := make(map[string]float64)
+	err = counter.Iterate(match, redis_counter.DiscoveryBatchSize, func(field string, value float64) error {
+		values[field] = value
+		return nil
+	})
+	return values, err
+}
+
+func (p *RedisMetricsProducer) readMetric(name string, now time.Time) (instrumentation.Scope, metricdata.Metrics, error) {
+	scope := instrumentation.Scope{}
+	metric := metricdata.Metrics{Name: name}
+
+	meta, err := p.readMeta(name)
+	if nil != err {
+		return scope, metric, err
+	}
+
+	scope.Name = meta["scope"]
+	scope.Version = meta["scope_version"]
+	metric.Description = meta["description"]
+	metric.Unit = meta["unit"]
+
+	start := now
+	if nanos, err := strconv.ParseInt(meta["start"], 10, 64); nil == err {
+		start = time.Unix(0, nanos)
+	}
+
+	monotonic := meta["monotonic"] == "true"
+	switch meta["kind"] + "/" + meta["number"] {
+	case "sum/int64":
+		points, err := p.readInt64Points(name, start, now)
+		metric.Data = metricdata.Sum[int64]{DataPoints: points, Temporality: metricdata.CumulativeTemporality, IsMonotonic: monotonic}
+		return scope, metric, err
+
+	case "sum/float64":
+		points, err := p.readFloat64Points(name, start, now)
+		metric.Data = metricdata.Sum[float64]{DataPoints: points, Temporality: metricdata.CumulativeTemporality, IsMonotonic: monotonic}
+		return scope, metric, err
+
+	case "gauge/int64":
+		points, err := p.readInt64Points(name, start, now)
+		metric.Data = metricdata.Gauge[int64]{DataPoints: points}
+		return scope, metric, err
+
+	case "gauge/float64":
+		points, err := p.readFloat64Points(name, start, now)
+		metric.Data = metricdata.Gauge[float64]{DataPoints: points}
+		return scope, metric, err
+
+	case "histogram/int64", "histogram/float64":
+		bounds := []float64{}
+		if err := json.Unmarshal([]byte(meta["bounds"]), &bounds); nil != err {
+			return scope, metric, err
+		}
+
+		points, err := p.readHistogramPoints(name, bounds, start, now)
+		if nil != err {
+			return scope, metric, err
+		}
+
+		if meta["number"] == "float64" {
+			metric.Data = metricdata.Histogram[float64]{DataPoints: points, Temporality: metricdata.CumulativeTemporality}
+			return scope, metric, nil
+		}
+
+		int_points := make([]metricdata.HistogramDataPoint[int64], len(points))
+		for i, point := range points {
+			int_points[i] = metricdata.HistogramDataPoint[int64]{
+				Attributes:   point.Attributes,
+				StartTime:    point.StartTime,
+				Time:         point.Time,
+				Count:        point.Count,
+				Bounds:       point.Bounds,
+				BucketCounts: point.BucketCounts,
+				Sum:          int64(point.Sum),
+			}
+		}
+		metric.Data = metricdata.Histogram[int64]{DataPoints: int_points, Temporality: metricdata.CumulativeTemporality}
+		return scope, metric, nil
+
+	default:
+		return scope, metric, fmt.Errorf("Invalid metric kind %s/%s for %s", meta["kind"], meta["number"], name)
+	}
+}
+
+func (p *RedisMetricsProducer) readInt64Points(name string, start, now time.Time) ([]metricdata.DataPoint[int64], error) {
+	values, err := p.readInt64s(name, "")
+	if nil != err {
+		return nil, err
+	}
+
+	fields := sortedInt64Fields(values)
+	points := make([]metricdata.DataPoint[int64], len(fields))
+	for i, field := range fields {
+		attrs, err := decodeAttributes(field)
+		if nil != err {
+			return nil, err
+		}
+		points[i] = metricdata.DataPoint[int64]{Attributes: attrs, StartTime: start, Time: now, Value: values[field]}
+	}
+	return points, nil
+}
+
+func (p *RedisMetricsProducer) readFloat64Points(name string, start, now time.Time) ([]metricdata.DataPoint[float64], error) {
+	values, err := p.readFloat64s(name, "")
+	if nil != err {
+		return nil, err
+	}
+
+	fields := sortedFloat64Fields(values)
+	points := make([]metricdata.DataPoint[float64], len(fields))
+	for i, field := range fields {
+		attrs, err := decodeAttributes(field)
+		if nil != err {
+			return nil, err
+		}
+		points[i] = metricdata.DataPoint[float64]{Attributes: attrs, StartTime: start, Time: now, Value: values[field]}
+	}
+	return points, nil
+}
+
+// The counts and buckets are read as int64 and the sums as float64
+func (p *RedisMetricsProducer) readHistogramPoints(name string, bounds []float64, start, now time.Time) ([]metricdata.HistogramDataPoint[float64], error) {
+	counts, err := p.readInt64s(name, "count|*")
+	if nil != err {
+		return nil, err
+	}
+
+	buckets, err := p.readInt64s(name, "bucket:*")
+	if nil != err {
+		return nil, err
+	}
+
+	sums, err := p.readFloat64s(name, "sum|*")
+	if nil != err {
+		return nil, err
+	}
+
+	fields := append(append(sortedInt64Fields(counts), sortedInt64Fields(buckets)...), sortedFloat64Fields(sums)...)
+	sort.Strings(fields)
+
+	points := []metricdata.HistogramDataPoint[float64]{}
+	indexes := make(map[string]int)
+	for _, field := range fields {
+		component, attrs_field, _ := strings.Cut(field, "|")
+
+		i, ok := indexes[attrs_field]
+		if !ok {
+			attrs, err := decodeAttributes(attrs_field)
+			if nil != err {
+				return nil, err
+			}
+
+			i = len(points)
+			indexes[attrs_field] = i
+			points = append(points, metricdata.HistogramDataPoint[float64]{
+				Attributes:   attrs,
+				StartTime:    start,
+				Time:         now,
+				Bounds:       bounds,
+				BucketCounts: make([]uint64, len(bounds)+1),
+			})
+		}
+		point := &points[i]
+
+		switch {
+		case component == "count":
+			if counts[field] < 0 {
+				return nil, fmt.Errorf("Invalid histogram count %s = %d", field, counts[field])
+			}
+			point.Count = uint64(counts[field])
+
+		case component == "sum":
+			point.Sum = sums[field]
+
+		default:
+			bucket, err := strconv.Atoi(strings.TrimPrefix(component, "bucket:"))
+			if nil != err || bucket < 0 || bucket >= len(point.BucketCounts) || buckets[field] < 0 {
+				return nil, fmt.Errorf("Invalid histogram bucket %s", field)
+			}
+			point.BucketCounts[bucket] = uint64(buckets[field])
+		}
+	}
+	return points, nil
+}
+
+func sortedInt64Fields(values map[string]int64) []string {
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+func sortedFloat64Fields(values map[string]float64) []string {
+	fields := make([]string, 0, len(values))
+	for field := range values {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
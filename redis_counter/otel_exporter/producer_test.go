@@ -0,0 +1,113 @@
+package otel_exporter
+
+import "context"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "go.opentelemetry.io/otel/attribute"
+import "go.opentelemetry.io/otel/sdk/metric/metricdata"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisMetricsProducerSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisMetricsProducerSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisMetricsProducerSpecs(c gospec.Context) {
+
+	c.Specify("[RedisMetricsProducer][Make] Makes new instance", func() {
+		value, err := MakeRedisMetricsProducer(nil, "")
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisMetricsProducer(&dog_pool.RedisConnection{}, "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis prefix")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisMetricsProducer(&dog_pool.RedisConnection{}, "otel")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[RedisMetricsProducer][Produce] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisMetricsProducer(server.Connection(), "otel")
+
+		// Cache Miss
+		scopes, err := value.Produce(context.Background())
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(scopes), gospec.Equals, 0)
+
+		exporter, _ := MakeRedisMetricsExporter(server.Connection(), "otel")
+		recordMeasurements(exporter, 2, 1.5, 0.5)
+		recordMeasurements(exporter, 3, -0.5, 7)
+
+		scopes, err = value.Produce(context.Background())
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(scopes), gospec.Equals, 1)
+		c.Expect(scopes[0].Scope.Name, gospec.Equals, "api")
+		c.Expect(scopes[0].Scope.Version, gospec.Equals, "1.0")
+
+		// Sorted by instrument name:
+		metrics := scopes[0].Metrics
+		c.Expect(len(metrics), gospec.Equals, 3)
+		route := attribute.NewSet(attribute.String("route", "/login"))
+
+		c.Expect(metrics[0].Name, gospec.Equals, "balance")
+		balance := metrics[0].Data.(metricdata.Sum[float64])
+		c.Expect(balance.Temporality, gospec.Equals, metricdata.CumulativeTemporality)
+		c.Expect(balance.IsMonotonic, gospec.Equals, false)
+		c.Expect(len(balance.DataPoints), gospec.Equals, 1)
+		c.Expect(balance.DataPoints[0].Attributes.Equals(&route), gospec.Equals, true)
+		c.Expect(balance.DataPoints[0].Value, gospec.Equals, float64(1))
+
+		c.Expect(metrics[1].Name, gospec.Equals, "latency")
+		latency := metrics[1].Data.(metricdata.Histogram[float64])
+		c.Expect(len(latency.DataPoints), gospec.Equals, 1)
+		c.Expect(latency.DataPoints[0].Count, gospec.Equals, uint64(2))
+		c.Expect(latency.DataPoints[0].Sum, gospec.Equals, float64(7.5))
+		c.Expect(latency.DataPoints[0].Bounds, gospec.ContainsExactly, []float64{1, 5})
+		c.Expect(latency.DataPoints[0].BucketCounts, gospec.ContainsExactly, []uint64{1, 0, 1})
+
+		c.Expect(metrics[2].Name, gospec.Equals, "requests")
+		c.Expect(metrics[2].Unit, gospec.Equals, "{request}")
+		requests := metrics[2].Data.(metricdata.Sum[int64])
+		c.Expect(requests.IsMonotonic, gospec.Equals, true)
+		c.Expect(len(requests.DataPoints), gospec.Equals, 1)
+		c.Expect(requests.DataPoints[0].Attributes.Equals(&route), gospec.Equals, true)
+		c.Expect(requests.DataPoints[0].Value, gospec.Equals, int64(5))
+	})
+
+	c.Specify("[RedisMetricsProducer][Produce] Parsing error", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisMetricsProducer(server.Connection(), "otel")
+
+		server.Connection().Cmd("HMSET", "otel:meta:requests", "kind", "sum", "number", "int64")
+		server.Connection().Cmd("HSET", "otel:instrument:requests", "[]", "Gary")
+		scopes, err := value.Produce(context.Background())
+		c.Expect(err, gospec.Satisfies, nil != err)
+		c.Expect(len(scopes), gospec.Equals, 0)
+
+		server.Connection().Cmd("HSET", "otel:meta:requests", "kind", "Gary")
+		scopes, err = value.Produce(context.Background())
+		c.Expect(err.Error(), gospec.Equals, "Invalid metric kind Gary/int64 for requests")
+		c.Expect(len(scopes), gospec.Equals, 0)
+	})
+}
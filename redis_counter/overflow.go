@@ -0,0 +1,142 @@
+package redis_counter
+
+import "math"
+import "github.com/alecthomas/log4go"
+import "github.com/fzzy/radix/redis"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// How an int64 counter handles an increment past the int64 range, set in
+// the "Overflow" field of RedisKeyCounterInt64 or RedisHashFieldCounterInt64.
+// It applies to their Add, Sub, Increment and Decrement; AddIdempotent,
+// the transaction and pipeline operations, and the multi-key and
+// multi-field counters always return ErrOverflow.
+const (
+	// Return ErrOverflow and leave the counter unchanged
+	OverflowError = iota
+	// Stop at math.MaxInt64 or math.MinInt64
+	OverflowSaturate
+	// Wrap around, as int64 arithmetic does in Go
+	OverflowWrap
+)
+
+// Largest magnitude up to which a float64 holds every integer, so a
+// float64 counter can still count by 1
+const MaxExactFloat64 = 1 << 53
+
+// How a float64 counter handles a value past ±MaxExactFloat64, set in its
+// "Precision" field
+const (
+	// Return the value
+	PrecisionIgnore = iota
+	// Return the value, and pass an *ErrInexact to PrecisionWarning
+	PrecisionWarn
+	// Return the value and an *ErrInexact
+	PrecisionError
+)
+
+// Called for the counters set to PrecisionWarn; logs the error to the
+// global log4go logger by default
+var PrecisionWarning = func(err error) {
+	log4go.Warn("%s", err)
+}
+
+//
+// Internal Helpers:
+//
+
+// Run the increments "amounts" in order on the int64 counter at "key", or
+// at "field" of the hash at "key", saturating or wrapping on overflow;
+// the reply is the final value
+func overflowIncrement(client dog_pool.RedisClientInterface, overflow int, key, field string, amounts ...int64) *redis.Reply {
+	mode := "wrap"
+	if OverflowSaturate == overflow {
+		mode = "saturate"
+	}
+
+	// Each increment is followed by what replaces it on overflow: the bound
+	// to saturate at, or increments that add or subtract 2^64 in steps
+	// that fit in an int64:
+	args := []interface{}{mode, field}
+	for _, amount := range amounts {
+		switch {
+		case OverflowSaturate == overflow && amount >= 0:
+			args = append(args, amount, int64(math.MaxInt64), 0, 0)
+		case OverflowSaturate == overflow:
+			args = append(args, amount, int64(math.MinInt64), 0, 0)
+		case amount >= 0:
+			args = append(args, amount, int64(math.MinInt64), amount+math.MinInt64, 0)
+		default:
+			args = append(args, amount, int64(math.MaxInt64), 1, amount+math.MaxInt64+1)
+		}
+	}
+	return overflowIncrementScript.eval(client, []string{key}, args...)
+}
+
+// Increments that subtract "amount"; -math.MinInt64 doesn't fit in an
+// int64, so it is subtracted in two steps
+func negateInt64(amount int64) []int64 {
+	if math.MinInt64 == amount {
+		return []int64{math.MaxInt64, 1}
+	}
+	return []int64{-amount}
+}
+
+// Runs the increments in ARGV[3:], in groups of an increment and three
+// replacements, on KEYS[1] or on the hash field ARGV[2]. On overflow the
+// counter is set to the first replacement when ARGV[1] is "saturate",
+// else the three replacements are added. Returns the value as a string,
+// since Lua numbers lose precision past 2^53.
+var overflowIncrementScript = makeRedisScript(`
+local function incr(amount)
+	if ARGV[2] == '' then
+		return redis.pcall('INCRBY', KEYS[1], amount)
+	end
+	return redis.pcall('HINCRBY', KEYS[1], ARGV[2], amount)
+end
+
+for i = 3, #ARGV, 4 do
+	local reply = incr(ARGV[i])
+	if type(reply) == 'table' and reply.err then
+		if not string.find(reply.err, 'overflow') then
+			return reply
+		end
+
+		if ARGV[1] == 'saturate' and ARGV[2] == '' then
+			local ttl = redis.call('PTTL', KEYS[1])
+			redis.call('SET', KEYS[1], ARGV[i+1])
+			if ttl > 0 then
+				redis.call('PEXPIRE', KEYS[1], ttl)
+			end
+		elseif ARGV[1] == 'saturate' then
+			redis.call('HSET', KEYS[1], ARGV[2], ARGV[i+1])
+		else
+			for j = i+1, i+3 do
+				reply = incr(ARGV[j])
+				if type(reply) == 'table' and reply.err then
+					return reply
+				end
+			end
+		end
+	end
+end
+
+if ARGV[2] == '' then
+	return redis.call('GET', KEYS[1])
+end
+return redis.call('HGET', KEYS[1], ARGV[2])
+`)
+
+// Check "value" of the float64 counter at "key" and "field" against
+// MaxExactFloat64
+func checkPrecision(precision int, key, field string, value float64) error {
+	if PrecisionIgnore == precision || math.Abs(value) <= MaxExactFloat64 {
+		return nil
+	}
+
+	err := &ErrInexact{Key: key, Field: field, Value: value}
+	if PrecisionWarn == precision {
+		PrecisionWarning(err)
+		return nil
+	}
+	return err
+}
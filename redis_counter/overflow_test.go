@@ -0,0 +1,189 @@
+package redis_counter
+
+import "errors"
+import "math"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestOverflowSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(OverflowSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func OverflowSpecs(c gospec.Context) {
+
+	c.Specify("[RedisKeyCounterInt64][OverflowError] Returns ErrOverflow", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterInt64(server.Connection(), "Bob")
+		c.Expect(value.Overflow, gospec.Equals, OverflowError)
+		value.Set(math.MaxInt64)
+
+		_, err := value.Increment()
+		c.Expect(errors.Is(err, ErrOverflow), gospec.Equals, true)
+
+		counter, _ := value.Get()
+		c.Expect(counter, gospec.Equals, int64(math.MaxInt64))
+	})
+
+	c.Specify("[RedisKeyCounterInt64][OverflowSaturate] Stops at the bounds", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterInt64(server.Connection(), "Bob")
+		value.Overflow = OverflowSaturate
+		value.Set(math.MaxInt64 - 1)
+		server.Connection().Cmd("EXPIRE", "Bob", 100)
+
+		counter, err := value.Add(5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(math.MaxInt64))
+		c.Expect(*value.LastValue, gospec.Equals, int64(math.MaxInt64))
+
+		// The expiry is kept:
+		ttl, _ := server.Connection().Cmd("PTTL", "Bob").Int64()
+		c.Expect(ttl, gospec.Satisfies, ttl > 0)
+
+		value.Set(math.MinInt64 + 1)
+		counter, err = value.Sub(5)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(math.MinInt64))
+
+		counter, err = value.Sub(math.MinInt64)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(0))
+
+		// Not an overflow:
+		server.Connection().Cmd("SET", "Bob", "abc")
+		_, err = value.Increment()
+		var nan_err *ErrNotANumber
+		c.Expect(errors.As(err, &nan_err), gospec.Equals, true)
+	})
+
+	c.Specify("[RedisKeyCounterInt64][OverflowWrap] Wraps around", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterInt64(server.Connection(), "Bob")
+		value.Overflow = OverflowWrap
+		value.Set(math.MaxInt64)
+
+		counter, err := value.Increment()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(math.MinInt64))
+
+		counter, err = value.Decrement()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(math.MaxInt64))
+
+		value.Set(0)
+		counter, err = value.Sub(math.MinInt64)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(math.MinInt64))
+
+		counter, _ = value.Get()
+		c.Expect(counter, gospec.Equals, int64(math.MinInt64))
+	})
+
+	c.Specify("[RedisHashFieldCounterInt64][Overflow] Saturates and wraps", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		bob, _ := MakeRedisHashFieldCounterInt64(server.Connection(), "Hash", "Bob")
+		bob.Overflow = OverflowSaturate
+		bob.Set(math.MaxInt64 - 1)
+
+		counter, err := bob.Add(math.MaxInt64)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(math.MaxInt64))
+
+		gary, _ := MakeRedisHashFieldCounterInt64(server.Connection(), "Hash", "Gary")
+		gary.Overflow = OverflowWrap
+		gary.Set(math.MinInt64)
+
+		counter, err = gary.Sub(2)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, int64(math.MaxInt64-1))
+	})
+
+	c.Specify("[RedisKeyCounterFloat64][Precision] Checks the exact integer range", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisKeyCounterFloat64(server.Connection(), "Bob")
+		c.Expect(value.Precision, gospec.Equals, PrecisionIgnore)
+
+		counter, err := value.Set(MaxExactFloat64 + 2)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(counter, gospec.Equals, float64(MaxExactFloat64+2))
+
+		value.Precision = PrecisionError
+		value.Set(MaxExactFloat64)
+		counter, err = value.Add(2)
+		c.Expect(counter, gospec.Equals, float64(MaxExactFloat64+2))
+		c.Expect(*value.LastValue, gospec.Equals, float64(MaxExactFloat64+2))
+		var inexact_err *ErrInexact
+		c.Expect(errors.As(err, &inexact_err), gospec.Equals, true)
+		c.Expect(inexact_err.Key, gospec.Equals, "Bob")
+
+		// Warnings don't fail the operation:
+		warnings := []error{}
+		warning := PrecisionWarning
+		PrecisionWarning = func(err error) { warnings = append(warnings, err) }
+		defer func() { PrecisionWarning = warning }()
+
+		value.Precision = PrecisionWarn
+		_, err = value.Sub(MaxExactFloat64 * 4)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(warnings), gospec.Equals, 1)
+
+		_, err = value.Set(1)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(warnings), gospec.Equals, 1)
+	})
+
+	c.Specify("[RedisHashFieldCounterFloat64][Precision] Checks the exact integer range", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		value, _ := MakeRedisHashFieldCounterFloat64(server.Connection(), "Hash", "Bob")
+		value.Precision = PrecisionError
+
+		_, err := value.Add(-MaxExactFloat64 * 2)
+		var inexact_err *ErrInexact
+		c.Expect(errors.As(err, &inexact_err), gospec.Equals, true)
+		c.Expect(err.Error(), gospec.Equals, "Inexact float64 counter: Hash[Bob] = -1.8014398509481984e+16 is past 2^53")
+	})
+}
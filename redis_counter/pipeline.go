@@ -0,0 +1,263 @@
+package redis_counter
+
+import "errors"
+import "fmt"
+import "strconv"
+import "github.com/fzzy/radix/redis"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Returned by a PipelineResult before its pipeline is executed
+var ErrPipelineNotExecuted = errors.New("Pipeline not executed")
+
+// Queues operations on counters of any type and sends them to Redis in
+// one round trip:
+//
+//	pipeline, _ := MakeRedisPipeline(redis)
+//	hits := pipeline.AddInt64(hits_counter, 1)
+//	load := pipeline.Get(load_counter)
+//	err := pipeline.Exec()
+//	total, err := hits.Int64()
+//
+// Unlike RedisTransaction the operations are not atomic, and each one
+// succeeds or fails on its own; Exec only fails when Redis can't be
+// reached. The results are also saved to each counter's LastValue or
+// Cache.
+type RedisPipeline struct {
+	Redis *dog_pool.RedisConnection
+
+	queued []*pipelineOp
+}
+
+// Result of one queued operation, available after Exec
+type PipelineResult struct {
+	executed bool
+	values   bool
+	replies  []*redis.Reply
+	err      error
+}
+
+// Make a new instance of RedisPipeline
+func MakeRedisPipeline(redis *dog_pool.RedisConnection) (*RedisPipeline, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	default:
+		return &RedisPipeline{Redis: redis}, nil
+	}
+}
+
+// Number of queued operations
+func (p *RedisPipeline) Len() int {
+	return len(p.queued)
+}
+
+func (p *RedisPipeline) Get(counter TransactionCounter) *PipelineResult {
+	return p.queue(counter, opGet, "")
+}
+
+func (p *RedisPipeline) Delete(counter TransactionCounter) *PipelineResult {
+	return p.queue(counter, opDelete, "")
+}
+
+func (p *RedisPipeline) SetInt64(counter TransactionCounter, amount int64) *PipelineResult {
+	return p.queue(counter, opSet, strconv.FormatInt(amount, 10))
+}
+
+func (p *RedisPipeline) AddInt64(counter TransactionCounter, amount int64) *PipelineResult {
+	return p.queue(counter, opAdd, strconv.FormatInt(amount, 10))
+}
+
+func (p *RedisPipeline) SubInt64(counter TransactionCounter, amount int64) *PipelineResult {
+	return p.queue(counter, opSub, strconv.FormatInt(amount, 10))
+}
+
+func (p *RedisPipeline) SetFloat64(counter TransactionCounter, amount float64) *PipelineResult {
+	return p.queue(counter, opSet, strconv.FormatFloat(amount, 'f', -1, 64))
+}
+
+func (p *RedisPipeline) AddFloat64(counter TransactionCounter, amount float64) *PipelineResult {
+	return p.queue(counter, opAdd, strconv.FormatFloat(amount, 'f', -1, 64))
+}
+
+func (p *RedisPipeline) SubFloat64(counter TransactionCounter, amount float64) *PipelineResult {
+	return p.queue(counter, opSub, strconv.FormatFloat(amount, 'f', -1, 64))
+}
+
+// Send the queued operations and empty the queue; returns an error only
+// when the pipeline couldn't be sent, in which case every result has
+// that error
+func (p *RedisPipeline) Exec() error {
+	queued := p.queued
+	p.queued = nil
+
+	commands := []*dog_pool.RedisBatchCommand{}
+	for _, queued := range queued {
+		for _, op := range queued.ops {
+			command := dog_pool.MakeRedisBatchCommand(op.args[0])
+			for _, arg := range op.args[1:] {
+				command.WriteStringArg(arg)
+			}
+			commands = append(commands, command)
+		}
+	}
+
+	if len(commands) == 0 {
+		return nil
+	}
+
+	if err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(p.Redis); nil != err {
+		err = redisError(err, "", "")
+		for _, queued := range queued {
+			queued.result.executed = true
+			queued.result.err = err
+		}
+		return err
+	}
+
+	i := 0
+	for _, queued := range queued {
+		result := queued.result
+		result.executed = true
+		for _, op := range queued.ops {
+			reply := commands[i].Reply()
+			i++
+
+			result.replies = append(result.replies, reply)
+			if err := op.apply(reply); nil != err && nil == result.err {
+				result.err = err
+			}
+		}
+	}
+	return nil
+}
+
+// Error of the operation; an operation on a multi counter returns the
+// first error of its commands
+func (p *PipelineResult) Err() error {
+	if !p.executed {
+		return ErrPipelineNotExecuted
+	}
+	return p.err
+}
+
+// Value of the counter, or 0 when it is missing
+func (p *PipelineResult) Int64() (int64, error) {
+	values, err := p.Int64s()
+	if nil != err || len(values) == 0 {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+// Values of the counters, in the order of the counter's keys or fields;
+// 0 for missing counters
+func (p *PipelineResult) Int64s() ([]int64, error) {
+	values := []int64{}
+	err := p.eachReply(func(reply *redis.Reply) error {
+		ptr, err := toInt64Ptr(reply, "", "")
+		if nil != err {
+			return err
+		}
+		values = append(values, valueOrZeroInt64(ptr))
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return values, nil
+}
+
+// Value of the counter, or 0 when it is missing
+func (p *PipelineResult) Float64() (float64, error) {
+	values, err := p.Float64s()
+	if nil != err || len(values) == 0 {
+		return 0, err
+	}
+	return values[0], nil
+}
+
+// Values of the counters, in the order of the counter's keys or fields;
+// 0 for missing counters
+func (p *PipelineResult) Float64s() ([]float64, error) {
+	values := []float64{}
+	err := p.eachReply(func(reply *redis.Reply) error {
+		ptr, err := toFloat64Ptr(reply, "", "")
+		if nil != err {
+			return err
+		}
+		values = append(values, valueOrZeroFloat64(ptr))
+		return nil
+	})
+	if nil != err {
+		return nil, err
+	}
+	return values, nil
+}
+
+//
+// Internal Helpers:
+//
+
+// The commands of one queued operation, and its result
+type pipelineOp struct {
+	ops    []*transactionOp
+	result *PipelineResult
+}
+
+func (p *RedisPipeline) queue(counter TransactionCounter, op int, amount string) *PipelineResult {
+	result := &PipelineResult{values: op != opSet && op != opDelete}
+	if nil == counter {
+		result.executed, result.err = true, fmt.Errorf("Nil counter")
+		return result
+	}
+
+	ops, err := counter.transactionOps(op, amount)
+	if nil != err {
+		result.executed, result.err = true, err
+		return result
+	}
+
+	p.queued = append(p.queued, &pipelineOp{ops, result})
+	return result
+}
+
+// Call "fn" with each value replied to the operation; MGET and HMGET
+// replies are expanded. Set and Delete don't reply with values.
+func (p *PipelineResult) eachReply(fn func(reply *redis.Reply) error) error {
+	switch err := p.Err(); {
+	case nil != err:
+		return err
+	case !p.values:
+		return fmt.Errorf("Operation has no value")
+	}
+
+	for _, reply := range p.replies {
+		switch reply.Type {
+		case redis.MultiReply:
+			for _, elem := range reply.Elems {
+				if err := fn(elem); nil != err {
+					return err
+				}
+			}
+		default:
+			if err := fn(reply); nil != err {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func valueOrZeroInt64(ptr *int64) int64 {
+	if nil == ptr {
+		return 0
+	}
+	return *ptr
+}
+
+func valueOrZeroFloat64(ptr *float64) float64 {
+	if nil == ptr {
+		return 0
+	}
+	return *ptr
+}
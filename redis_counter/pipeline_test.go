@@ -0,0 +1,93 @@
+package redis_counter
+
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisPipelineSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisPipelineSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisPipelineSpecs(c gospec.Context) {
+
+	c.Specify("[RedisPipeline][Make] Makes new instance", func() {
+		value, err := MakeRedisPipeline(nil)
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisPipeline(&dog_pool.RedisConnection{})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[RedisPipeline][Queue] Returns pending results", func() {
+		value, _ := MakeRedisPipeline(&dog_pool.RedisConnection{})
+		counter, _ := MakeRedisKeyCounterInt64(&dog_pool.RedisConnection{}, "Bob")
+
+		result := value.AddInt64(counter, 1)
+		c.Expect(result.Err(), gospec.Equals, ErrPipelineNotExecuted)
+		c.Expect(value.Len(), gospec.Equals, 1)
+
+		// Invalid operations fail without being queued:
+		result = value.AddFloat64(counter, 1.5)
+		c.Expect(result.Err().Error(), gospec.Equals, "Invalid int64 amount: 1.5")
+		c.Expect(value.Len(), gospec.Equals, 1)
+	})
+
+	c.Specify("[RedisPipeline][Exec] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		redis := server.Connection()
+		redis.Cmd("SET", "Name", "Gary")
+
+		hits, _ := MakeRedisKeyCounterInt64(redis, "Hits")
+		load, _ := MakeRedisHashFieldCounterFloat64(redis, "Hash", "Load")
+		regions, _ := MakeRedisMKeysCounterInt64(redis, "us", "uk")
+		name, _ := MakeRedisKeyCounterInt64(redis, "Name")
+
+		value, _ := MakeRedisPipeline(redis)
+		hits_result := value.AddInt64(hits, 5)
+		load_result := value.SetFloat64(load, 0.5)
+		regions_result := value.AddInt64(regions, 2)
+		name_result := value.AddInt64(name, 1)
+		get_result := value.Get(regions)
+
+		err := value.Exec()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.Len(), gospec.Equals, 0)
+
+		amount, err := hits_result.Int64()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(amount, gospec.Equals, int64(5))
+		c.Expect(*hits.LastValue, gospec.Equals, int64(5))
+
+		c.Expect(load_result.Err(), gospec.Equals, nil)
+		c.Expect(*load.LastValue, gospec.Equals, 0.5)
+		_, err = load_result.Float64()
+		c.Expect(err.Error(), gospec.Equals, "Operation has no value")
+
+		amounts, err := regions_result.Int64s()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(amounts, gospec.ContainsInOrder, gospec.Values(int64(2), int64(2)))
+
+		amounts, err = get_result.Int64s()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(amounts, gospec.ContainsInOrder, gospec.Values(int64(2), int64(2)))
+
+		// One failed operation doesn't fail the others:
+		c.Expect(name_result.Err(), gospec.Satisfies, nil != name_result.Err())
+		c.Expect(name.LastValue, gospec.Satisfies, nil == name.LastValue)
+	})
+}
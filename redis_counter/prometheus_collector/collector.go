@@ -0,0 +1,296 @@
+package prometheus_collector
+
+import "fmt"
+import "regexp"
+import "sync"
+import "time"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+import "github.com/prometheus/client_golang/prometheus"
+
+// Default number of keys or fields read per MGET/HMGET call
+const DefaultBatchSize = 100
+
+// Describes how a set of counters is exported.
+// Named groups in "Labels" are matched against each key (or hash field)
+// and become the label values of the exported metric; keys that don't
+// match are not exported. A metric without labels exports one counter.
+type MetricOpts struct {
+	Name, Help string
+	Type       prometheus.ValueType
+	Labels     *regexp.Regexp
+}
+
+// Exports Redis counters as Prometheus metrics.
+// The counters are read in batched MGET/HMGET calls on every scrape.
+type RedisCounterCollector struct {
+	Redis     *dog_pool.RedisConnection
+	Namespace string
+	BatchSize int
+
+	mutex          sync.Mutex
+	metrics        map[string]*metricDesc
+	sources        []*counterSource
+	scrapeDuration *prometheus.Desc
+	scrapeErrors   *prometheus.Desc
+	errors         float64
+}
+
+// Make a new instance of RedisCounterCollector
+func MakeRedisCounterCollector(redis *dog_pool.RedisConnection, namespace string) (*RedisCounterCollector, error) {
+	switch {
+	case nil == redis:
+		return nil, fmt.Errorf("Nil redis connection")
+	default:
+		return &RedisCounterCollector{
+			Redis:     redis,
+			Namespace: namespace,
+			BatchSize: DefaultBatchSize,
+			metrics:   make(map[string]*metricDesc),
+			scrapeDuration: prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "", "scrape_duration_seconds"),
+				"Time spent reading the counters from Redis.",
+				nil, nil,
+			),
+			scrapeErrors: prometheus.NewDesc(
+				prometheus.BuildFQName(namespace, "", "scrape_errors_total"),
+				"Number of counter sets that could not be read from Redis.",
+				nil, nil,
+			),
+		}, nil
+	}
+}
+
+// Export a fixed list of key counters
+func (p *RedisCounterCollector) AddKeys(opts MetricOpts, keys ...string) error {
+	switch {
+	case len(keys) == 0:
+		return fmt.Errorf("Empty redis keys")
+	default:
+		for i, key := range keys {
+			if len(key) == 0 {
+				return fmt.Errorf("Empty redis key[%d]", i)
+			}
+		}
+
+		return p.addSource(opts, &counterSource{names: keys})
+	}
+}
+
+// Export a fixed list of hash field counters
+func (p *RedisCounterCollector) AddHashFields(opts MetricOpts, key string, fields ...string) error {
+	switch {
+	case len(key) == 0:
+		return fmt.Errorf("Empty redis key")
+	case len(fields) == 0:
+		return fmt.Errorf("Empty redis fields")
+	default:
+		for i, field := range fields {
+			if len(field) == 0 {
+				return fmt.Errorf("Empty redis field[%d]", i)
+			}
+		}
+
+		return p.addSource(opts, &counterSource{key: key, names: fields})
+	}
+}
+
+// Export every key counter matching the SCAN pattern; the keys are
+// discovered again on every scrape
+func (p *RedisCounterCollector) AddPattern(opts MetricOpts, match string) error {
+	switch {
+	case len(match) == 0:
+		return fmt.Errorf("Empty redis pattern")
+	default:
+		return p.addSource(opts, &counterSource{match: match})
+	}
+}
+
+// Implements prometheus.Collector
+func (p *RedisCounterCollector) Describe(ch chan<- *prometheus.Desc) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for _, metric := range p.metrics {
+		ch <- metric.desc
+	}
+	ch <- p.scrapeDuration
+	ch <- p.scrapeErrors
+}
+
+// Implements prometheus.Collector
+func (p *RedisCounterCollector) Collect(ch chan<- prometheus.Metric) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	start := time.Now()
+	for _, source := range p.sources {
+		if err := p.collectSource(source, ch); nil != err {
+			p.errors++
+		}
+	}
+
+	ch <- prometheus.MustNewConstMetric(p.scrapeDuration, prometheus.GaugeValue, time.Since(start).Seconds())
+	ch <- prometheus.MustNewConstMetric(p.scrapeErrors, prometheus.CounterValue, p.errors)
+}
+
+//
+// Internal Helpers:
+//
+
+type metricDesc struct {
+	desc   *prometheus.Desc
+	opts   MetricOpts
+	labels []string
+}
+
+type counterSource struct {
+	metric *metricDesc
+	key    string
+	names  []string
+	match  string
+}
+
+func (p *RedisCounterCollector) addSource(opts MetricOpts, source *counterSource) error {
+	if len(opts.Name) == 0 {
+		return fmt.Errorf("Empty metric name")
+	}
+
+	labels := []string{}
+	if nil != opts.Labels {
+		for i, name := range opts.Labels.SubexpNames()[1:] {
+			if len(name) == 0 {
+				return fmt.Errorf("Unnamed label group[%d] in %s", i, opts.Labels)
+			}
+			labels = append(labels, name)
+		}
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	// Without labels every counter is the same series, and Gather fails
+	// on a series collected twice:
+	name := prometheus.BuildFQName(p.Namespace, "", opts.Name)
+	metric, ok := p.metrics[name]
+	switch {
+	case len(labels) == 0 && (len(source.match) > 0 || len(source.names) > 1):
+		return fmt.Errorf("Metric %s needs labels to export more than one counter", name)
+	case !ok:
+		metric = &metricDesc{prometheus.NewDesc(name, opts.Help, labels, nil), opts, labels}
+		p.metrics[name] = metric
+	case metric.opts.Type != opts.Type || fmt.Sprint(metric.labels) != fmt.Sprint(labels):
+		return fmt.Errorf("Metric %s already registered with different type or labels", name)
+	case len(labels) == 0:
+		return fmt.Errorf("Metric %s needs labels to export more than one counter", name)
+	}
+
+	source.metric = metric
+	p.sources = append(p.sources, source)
+	return nil
+}
+
+func (p *RedisCounterCollector) collectSource(source *counterSource, ch chan<- prometheus.Metric) error {
+	names := source.names
+	if len(source.match) > 0 {
+		keys, err := p.discoverKeys(source.match)
+		if nil != err {
+			return err
+		}
+		names = keys
+	}
+
+	size := p.batchSize()
+	for start := 0; start < len(names); start += size {
+		end := start + size
+		if end > len(names) {
+			end = len(names)
+		}
+
+		batch := names[start:end]
+		values, err := p.readBatch(source.key, batch)
+		if nil != err {
+			return err
+		}
+
+		for i, name := range batch {
+			if nil == values[i] {
+				continue
+			}
+
+			labels, ok := source.metric.labelValues(name)
+			if !ok {
+				continue
+			}
+
+			metric, err := prometheus.NewConstMetric(source.metric.desc, source.metric.opts.Type, *values[i], labels...)
+			if nil != err {
+				return err
+			}
+			ch <- metric
+		}
+	}
+
+	return nil
+}
+
+// Read a batch of key counters, or hash field counters when "key" is set;
+// missing counters are returned as nil
+func (p *RedisCounterCollector) readBatch(key string, names []string) ([]*float64, error) {
+	values := make([]*float64, len(names))
+	switch {
+	case len(key) == 0:
+		counter, err := redis_counter.MakeRedisMKeysCounterFloat64(p.Redis, names...)
+		if nil != err {
+			return nil, err
+		}
+		if _, err := counter.MGet(); nil != err {
+			return nil, err
+		}
+		for i, name := range names {
+			values[i] = counter.Cache.Value(name)
+		}
+
+	default:
+		counter, err := redis_counter.MakeRedisHashMFieldsCounterFloat64(p.Redis, key, names...)
+		if nil != err {
+			return nil, err
+		}
+		if _, err := counter.MGet(); nil != err {
+			return nil, err
+		}
+		for i, name := range names {
+			values[i] = counter.Cache.Value(name)
+		}
+	}
+
+	return values, nil
+}
+
+func (p *RedisCounterCollector) batchSize() int {
+	if p.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+	return p.BatchSize
+}
+
+func (p *metricDesc) labelValues(name string) ([]string, bool) {
+	if nil == p.opts.Labels {
+		return nil, true
+	}
+
+	match := p.opts.Labels.FindStringSubmatch(name)
+	if nil == match {
+		return nil, false
+	}
+	return match[1:], true
+}
+
+// Keys matching the SCAN pattern, each listed once
+func (p *RedisCounterCollector) discoverKeys(match string) ([]string, error) {
+	discovery, err := redis_counter.MakeRedisKeyDiscovery(p.Redis, match, p.batchSize())
+	if nil != err {
+		return nil, err
+	}
+	return discovery.Keys()
+}
@@ -0,0 +1,170 @@
+package prometheus_collector
+
+import "regexp"
+import "strings"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/prometheus/client_golang/prometheus"
+import "github.com/prometheus/client_golang/prometheus/testutil"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisCounterCollectorSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisCounterCollectorSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisCounterCollectorSpecs(c gospec.Context) {
+
+	c.Specify("[RedisCounterCollector][Make] Makes new instance", func() {
+		value, err := MakeRedisCounterCollector(nil, "api")
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisCounterCollector(&dog_pool.RedisConnection{}, "api")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value, gospec.Satisfies, nil != value)
+	})
+
+	c.Specify("[RedisCounterCollector][Add] Validates counter sets", func() {
+		value, _ := MakeRedisCounterCollector(&dog_pool.RedisConnection{}, "api")
+		opts := MetricOpts{Name: "hits", Help: "Hits", Type: prometheus.CounterValue}
+
+		err := value.AddKeys(opts)
+		c.Expect(err.Error(), gospec.Equals, "Empty redis keys")
+
+		err = value.AddKeys(opts, "Bob", "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key[1]")
+
+		err = value.AddHashFields(opts, "", "Field")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key")
+
+		err = value.AddHashFields(opts, "Bob")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis fields")
+
+		err = value.AddPattern(opts, "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis pattern")
+
+		err = value.AddKeys(MetricOpts{Labels: regexp.MustCompile(`(.*)`)}, "Bob")
+		c.Expect(err.Error(), gospec.Equals, "Empty metric name")
+
+		err = value.AddKeys(MetricOpts{Name: "hits", Labels: regexp.MustCompile(`(.*)`)}, "Bob")
+		c.Expect(err.Error(), gospec.Equals, "Unnamed label group[0] in (.*)")
+
+		err = value.AddKeys(opts, "Bob")
+		c.Expect(err, gospec.Equals, nil)
+
+		opts.Type = prometheus.GaugeValue
+		err = value.AddKeys(opts, "Gary")
+		c.Expect(err.Error(), gospec.Equals, "Metric api_hits already registered with different type or labels")
+
+		// One series per metric without labels:
+		opts.Type = prometheus.CounterValue
+		err = value.AddKeys(opts, "Gary")
+		c.Expect(err.Error(), gospec.Equals, "Metric api_hits needs labels to export more than one counter")
+
+		err = value.AddKeys(MetricOpts{Name: "logins", Type: prometheus.CounterValue}, "Bob", "Gary")
+		c.Expect(err.Error(), gospec.Equals, "Metric api_logins needs labels to export more than one counter")
+
+		err = value.AddPattern(MetricOpts{Name: "logins", Type: prometheus.CounterValue}, "api:*")
+		c.Expect(err.Error(), gospec.Equals, "Metric api_logins needs labels to export more than one counter")
+	})
+
+	c.Specify("[RedisCounterCollector][Collect] Redis Operation", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		server.Connection().Cmd("SET", "api:hits:login", "3")
+		server.Connection().Cmd("SET", "api:hits:logout", "1")
+		server.Connection().Cmd("SET", "api:errors:login", "2")
+		server.Connection().Cmd("HSET", "api:latency", "login", "0.25")
+
+		// The keys are listed so the parsing error below fails the first batch:
+		value, _ := MakeRedisCounterCollector(server.Connection(), "api")
+		value.BatchSize = 1
+		value.AddKeys(MetricOpts{
+			Name:   "hits",
+			Help:   "Hits per endpoint",
+			Type:   prometheus.CounterValue,
+			Labels: regexp.MustCompile(`^api:hits:(?P<endpoint>.+)$`),
+		}, "api:hits:login", "api:hits:logout")
+		value.AddPattern(MetricOpts{
+			Name:   "errors",
+			Help:   "Errors per endpoint",
+			Type:   prometheus.CounterValue,
+			Labels: regexp.MustCompile(`^api:errors:(?P<endpoint>.+)$`),
+		}, "api:errors:*")
+		value.AddHashFields(MetricOpts{
+			Name:   "latency_seconds",
+			Help:   "Latency per endpoint",
+			Type:   prometheus.GaugeValue,
+			Labels: regexp.MustCompile(`^(?P<endpoint>.+)$`),
+		}, "api:latency", "login", "Missing")
+
+		expected := `
+# HELP api_errors Errors per endpoint
+# TYPE api_errors counter
+api_errors{endpoint="login"} 2
+# HELP api_hits Hits per endpoint
+# TYPE api_hits counter
+api_hits{endpoint="login"} 3
+api_hits{endpoint="logout"} 1
+# HELP api_latency_seconds Latency per endpoint
+# TYPE api_latency_seconds gauge
+api_latency_seconds{endpoint="login"} 0.25
+# HELP api_scrape_errors_total Number of counter sets that could not be read from Redis.
+# TYPE api_scrape_errors_total counter
+api_scrape_errors_total 0
+`
+		err := testutil.CollectAndCompare(value, strings.NewReader(expected), "api_errors", "api_hits", "api_latency_seconds", "api_scrape_errors_total")
+		c.Expect(err, gospec.Equals, nil)
+
+		// Parsing error:
+		server.Connection().Cmd("SET", "api:hits:login", "Gary")
+		expected = `
+# HELP api_latency_seconds Latency per endpoint
+# TYPE api_latency_seconds gauge
+api_latency_seconds{endpoint="login"} 0.25
+# HELP api_scrape_errors_total Number of counter sets that could not be read from Redis.
+# TYPE api_scrape_errors_total counter
+api_scrape_errors_total 1
+`
+		err = testutil.CollectAndCompare(value, strings.NewReader(expected), "api_hits", "api_latency_seconds", "api_scrape_errors_total")
+		c.Expect(err, gospec.Equals, nil)
+	})
+}
+
+func Benchmark_RedisCounterCollector_Collect(b *testing.B) {
+	logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+	server, err := dog_pool.StartRedisServer(&logger)
+	if nil != err {
+		panic(err)
+	}
+	defer server.Close()
+
+	server.Connection().Cmd("SET", "api:hits:login", "3")
+	server.Connection().Cmd("SET", "api:hits:logout", "1")
+
+	value, _ := MakeRedisCounterCollector(server.Connection(), "api")
+	value.AddKeys(MetricOpts{
+		Name:   "hits",
+		Help:   "Hits",
+		Type:   prometheus.CounterValue,
+		Labels: regexp.MustCompile(`^api:hits:(?P<endpoint>.+)$`),
+	}, "api:hits:login", "api:hits:logout")
+
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		testutil.CollectAndCount(value)
+	}
+}
@@ -0,0 +1,67 @@
+package redis_counter
+
+import "crypto/sha1"
+import "encoding/hex"
+import "strings"
+import "github.com/fzzy/radix/redis"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Lua script that is run with EVALSHA, falling back to EVAL when the
+// server has not cached the script yet
+type redisScript struct {
+	source string
+	sha1   string
+}
+
+func makeRedisScript(source string) *redisScript {
+	sum := sha1.Sum([]byte(source))
+	return &redisScript{source, hex.EncodeToString(sum[:])}
+}
+
+func (s *redisScript) eval(client dog_pool.RedisClientInterface, keys []string, args ...interface{}) *redis.Reply {
+	buffer := make([]interface{}, len(keys)+len(args)+1)[0:0]
+	buffer = append(buffer, len(keys))
+	for _, key := range keys {
+		buffer = append(buffer, key)
+	}
+	buffer = append(buffer, args...)
+
+	reply := client.Cmd("EVALSHA", append([]interface{}{s.sha1}, buffer...)...)
+	if nil != reply.Err && strings.HasPrefix(reply.Err.Error(), "NOSCRIPT") {
+		reply = client.Cmd("EVAL", append([]interface{}{s.source}, buffer...)...)
+	}
+	return reply
+}
+
+// Run the script once for each of "keys" with the same "args", in one
+// batch; calls the server refused with NOSCRIPT are run again with EVAL
+func (s *redisScript) evalBatch(conn *dog_pool.RedisConnection, keys []string, args ...string) ([]*redis.Reply, error) {
+	commands := make([]*dog_pool.RedisBatchCommand, len(keys))
+	for i, key := range keys {
+		commands[i] = dog_pool.MakeRedisBatchCommand("EVALSHA")
+		commands[i].WriteStringArg(s.sha1)
+		commands[i].WriteStringArg("1")
+		commands[i].WriteStringArg(key)
+		for _, arg := range args {
+			commands[i].WriteStringArg(arg)
+		}
+	}
+
+	if err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(conn); nil != err {
+		return nil, err
+	}
+
+	eval_args := make([]interface{}, len(args))
+	for i, arg := range args {
+		eval_args[i] = arg
+	}
+
+	replies := make([]*redis.Reply, len(keys))
+	for i, command := range commands {
+		replies[i] = command.Reply()
+		if nil != replies[i].Err && strings.HasPrefix(replies[i].Err.Error(), "NOSCRIPT") {
+			replies[i] = s.eval(conn, keys[i:i+1], eval_args...)
+		}
+	}
+	return replies, nil
+}
@@ -3,10 +3,12 @@ package redis_counter
 import "strconv"
 import "github.com/fzzy/radix/redis"
 
-func toFloat64Ptr(reply *redis.Reply) (*float64, error) {
+// Parse the reply for the counter at "key" and "field" (empty for a key
+// counter); nil for a missing counter
+func toFloat64Ptr(reply *redis.Reply, key, field string) (*float64, error) {
 	switch {
 	case nil != reply.Err:
-		return nil, reply.Err
+		return nil, redisError(reply.Err, key, field)
 
 	case redis.NilReply == reply.Type:
 		return nil, nil
@@ -19,12 +21,12 @@ func toFloat64Ptr(reply *redis.Reply) (*float64, error) {
 	default:
 		str, str_err := reply.Str()
 		if nil != str_err {
-			return nil, str_err
+			return nil, &ErrNotANumber{Key: key, Field: field, Err: str_err}
 		}
 
 		value, err := strconv.ParseFloat(str, 64)
 		if nil != err {
-			return nil, err
+			return nil, &ErrNotANumber{Key: key, Field: field, Raw: str, Err: err}
 		}
 
 		return &value, nil
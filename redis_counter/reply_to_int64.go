@@ -1,19 +1,35 @@
 package redis_counter
 
+import "strconv"
 import "github.com/fzzy/radix/redis"
 
-func toInt64Ptr(reply *redis.Reply) (*int64, error) {
+// Parse the reply for the counter at "key" and "field" (empty for a key
+// counter); nil for a missing counter
+func toInt64Ptr(reply *redis.Reply, key, field string) (*int64, error) {
 	switch {
 	case nil != reply.Err:
-		return nil, reply.Err
+		return nil, redisError(reply.Err, key, field)
 
 	case redis.NilReply == reply.Type:
 		return nil, nil
 
-	default:
+	case redis.IntegerReply == reply.Type:
 		value, err := reply.Int64()
 		if nil != err {
-			return nil, err
+			return nil, &ErrNotANumber{Key: key, Field: field, Err: err}
+		}
+
+		return &value, nil
+
+	default:
+		str, str_err := reply.Str()
+		if nil != str_err {
+			return nil, &ErrNotANumber{Key: key, Field: field, Err: str_err}
+		}
+
+		value, err := strconv.ParseInt(str, 10, 64)
+		if nil != err {
+			return nil, &ErrNotANumber{Key: key, Field: field, Raw: str, Err: err}
 		}
 
 		return &value, nil
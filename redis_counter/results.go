@@ -0,0 +1,101 @@
+package redis_counter
+
+// Result for one key of a multi-key counter, or one field of a
+// multi-field counter
+type ResultInt64 struct {
+	Key    string // Key, or field for a multi-field counter
+	Value  int64  // 0 when the counter is missing or Err is set
+	Exists bool
+	Err    error
+}
+
+// Result for one key of a multi-key counter, or one field of a
+// multi-field counter
+type ResultFloat64 struct {
+	Key    string // Key, or field for a multi-field counter
+	Value  float64
+	Exists bool
+	Err    error
+}
+
+//
+// Internal Helpers:
+//
+
+func makeResultInt64(key string, ptr *int64, err error) ResultInt64 {
+	result := ResultInt64{Key: key, Err: err}
+	if nil != ptr {
+		result.Value, result.Exists = *ptr, true
+	}
+	return result
+}
+
+func makeResultFloat64(key string, ptr *float64, err error) ResultFloat64 {
+	result := ResultFloat64{Key: key, Err: err}
+	if nil != ptr {
+		result.Value, result.Exists = *ptr, true
+	}
+	return result
+}
+
+// Values of the results, or the first error
+func valuesOfResultsInt64(results []ResultInt64, err error) ([]int64, error) {
+	if nil != err {
+		return nil, err
+	}
+
+	values := make([]int64, len(results))
+	for i, result := range results {
+		if nil != result.Err {
+			return nil, result.Err
+		}
+		values[i] = result.Value
+	}
+	return values, nil
+}
+
+// Values of the results, or the first error
+func valuesOfResultsFloat64(results []ResultFloat64, err error) ([]float64, error) {
+	if nil != err {
+		return nil, err
+	}
+
+	values := make([]float64, len(results))
+	for i, result := range results {
+		if nil != result.Err {
+			return nil, result.Err
+		}
+		values[i] = result.Value
+	}
+	return values, nil
+}
+
+// Values of the results and whether each counter exists, or the first
+// error
+func optionalsOfResultsInt64(results []ResultInt64, err error) ([]int64, []bool, error) {
+	values, err := valuesOfResultsInt64(results, err)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	found := make([]bool, len(results))
+	for i, result := range results {
+		found[i] = result.Exists
+	}
+	return values, found, nil
+}
+
+// Values of the results and whether each counter exists, or the first
+// error
+func optionalsOfResultsFloat64(results []ResultFloat64, err error) ([]float64, []bool, error) {
+	values, err := valuesOfResultsFloat64(results, err)
+	if nil != err {
+		return nil, nil, err
+	}
+
+	found := make([]bool, len(results))
+	for i, result := range results {
+		found[i] = result.Exists
+	}
+	return values, found, nil
+}
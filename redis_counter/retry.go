@@ -0,0 +1,204 @@
+package redis_counter
+
+import "errors"
+import "fmt"
+import "math/rand"
+import "net"
+import "strings"
+import "time"
+import "github.com/fzzy/radix/redis"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Default settings of a RetryPolicy
+const RetryMaxAttempts = 3
+const RetryBaseDelay = 50 * time.Millisecond
+const RetryMaxDelay = 2 * time.Second
+const RetryJitter = 0.5
+
+// Retries operations that failed with a transient error, waiting
+// BaseDelay, then twice as long for each retry up to MaxDelay. Jitter is
+// the fraction of each delay that is random, so clients that failed
+// together don't retry together.
+//
+// An increment that timed out or lost its connection may already have
+// been applied, so retrying it could count it twice. Do is for
+// idempotent operations (Get, Set, Delete, MGet) and retries every
+// transient error; DoIncrement is for everything else and only retries
+// when the command was never run:
+//
+//	policy, _ := MakeRetryPolicy(3, 50*time.Millisecond, time.Second)
+//	err := policy.DoIncrement(func() (err error) {
+//		_, err = counters.MAdd(1)
+//		return
+//	})
+//
+// Use a RetryClient to retry every command of a counter instead.
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      float64
+	Retryable   func(err error) bool // Defaults to IsRetryable
+	Sleep       func(delay time.Duration)
+}
+
+// Retries the commands sent by counters; pass it to the constructors in
+// place of the connection:
+//
+//	client, _ := MakeRetryClient(redis, policy)
+//	counter, _ := MakeRedisKeyCounterInt64(client, "hits")
+//
+// Reads and writes that replace a value are retried with Policy.Do, and
+// increments, scripts and every other command with Policy.DoIncrement.
+//
+// The multi-key and multi-field counters take a *dog_pool.RedisConnection
+// for its batch commands, so they can't use a RetryClient; wrap their
+// calls in Policy.Do or Policy.DoIncrement instead.
+type RetryClient struct {
+	Redis  dog_pool.RedisClientInterface
+	Policy *RetryPolicy
+}
+
+// Make a new instance of RetryPolicy
+func MakeRetryPolicy(max_attempts int, base_delay, max_delay time.Duration) (*RetryPolicy, error) {
+	switch {
+	case max_attempts < 1:
+		return nil, fmt.Errorf("Invalid max attempts: %d", max_attempts)
+	case base_delay < 0:
+		return nil, fmt.Errorf("Invalid base delay: %s", base_delay)
+	case max_delay < base_delay:
+		return nil, fmt.Errorf("Invalid max delay: %s", max_delay)
+	default:
+		return &RetryPolicy{
+			MaxAttempts: max_attempts,
+			BaseDelay:   base_delay,
+			MaxDelay:    max_delay,
+			Jitter:      RetryJitter,
+		}, nil
+	}
+}
+
+// Make a new instance of RetryClient
+func MakeRetryClient(redis dog_pool.RedisClientInterface, policy *RetryPolicy) (*RetryClient, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	case nil == policy:
+		return nil, fmt.Errorf("Nil retry policy")
+	default:
+		return &RetryClient{redis, policy}, nil
+	}
+}
+
+// Whether the error is transient: the connection failed, closed or timed
+// out, or Redis replied LOADING, TRYAGAIN, CLUSTERDOWN or MASTERDOWN.
+// Other errors, such as ErrNotANumber or ErrTransactionConflict, would
+// fail the same way again.
+func IsRetryable(err error) bool {
+	var conn_err *ErrConnection
+	return errors.As(err, &conn_err) || isNetworkError(err) || isRejected(err)
+}
+
+// Delay before the "retry"-th retry, starting at 1
+func (p *RetryPolicy) Delay(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		delay -= time.Duration(float64(delay) * p.Jitter * rand.Float64())
+	}
+	return delay
+}
+
+// Run an idempotent operation, retrying every transient error; returns
+// the error of the last attempt
+func (p *RetryPolicy) Do(fn func() error) error {
+	return p.operationRetries(true, fn)
+}
+
+// Run an operation that must not be applied twice, retrying only the
+// errors where the command was never run: Redis rejected it, or the
+// connection couldn't be opened
+func (p *RetryPolicy) DoIncrement(fn func() error) error {
+	return p.operationRetries(false, fn)
+}
+
+func (p *RetryClient) Cmd(cmd string, args ...interface{}) *redis.Reply {
+	var reply *redis.Reply
+	do := p.Policy.DoIncrement
+	if idempotentCommands[strings.ToUpper(cmd)] {
+		do = p.Policy.Do
+	}
+
+	do(func() error {
+		reply = p.Redis.Cmd(cmd, args...)
+		return reply.Err
+	})
+	return reply
+}
+
+//
+// Internal Helpers:
+//
+
+// Commands that can run twice without changing the result
+var idempotentCommands = map[string]bool{
+	"GET": true, "MGET": true, "HGET": true, "HMGET": true, "HGETALL": true, "HLEN": true,
+	"EXISTS": true, "HEXISTS": true, "TYPE": true, "TTL": true, "PTTL": true, "SCAN": true, "HSCAN": true,
+	"SET": true, "MSET": true, "HSET": true, "HMSET": true, "DEL": true, "HDEL": true,
+}
+
+// Error replies for commands Redis refused to run
+var rejectedReplies = []string{"LOADING", "TRYAGAIN", "CLUSTERDOWN", "MASTERDOWN"}
+
+func isRejected(err error) bool {
+	var cmd_err *redis.CmdError
+	if !errors.As(err, &cmd_err) {
+		return false
+	}
+
+	message := cmd_err.Error()
+	for _, prefix := range rejectedReplies {
+		if strings.HasPrefix(message, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// The connection couldn't be opened, so no command was sent
+func isUnsent(err error) bool {
+	var op_err *net.OpError
+	return errors.As(err, &op_err) && op_err.Op == "dial"
+}
+
+func (p *RetryPolicy) operationRetries(idempotent bool, fn func() error) error {
+	retryable := p.Retryable
+	if nil == retryable {
+		retryable = IsRetryable
+	}
+	sleep := p.Sleep
+	if nil == sleep {
+		sleep = time.Sleep
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		switch {
+		case nil == err:
+			return nil
+		case attempt >= p.MaxAttempts:
+			return err
+		case !retryable(err):
+			return err
+		case !idempotent && !isRejected(err) && !isUnsent(err):
+			return err
+		}
+		sleep(p.Delay(attempt))
+	}
+}
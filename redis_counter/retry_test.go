@@ -0,0 +1,169 @@
+package redis_counter
+
+import "errors"
+import "io"
+import "net"
+import "time"
+import "github.com/fzzy/radix/redis"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRetryPolicySpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RetryPolicySpecs)
+	gospec.MainGoTest(r, t)
+}
+
+// Replies with the queued errors, then with 1
+type flakyClient struct {
+	errs  []error
+	calls []string
+}
+
+func (p *flakyClient) Cmd(cmd string, args ...interface{}) *redis.Reply {
+	p.calls = append(p.calls, cmd)
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return &redis.Reply{Type: redis.ErrorReply, Err: err}
+	}
+	return &redis.Reply{Type: redis.IntegerReply}
+}
+
+func RetryPolicySpecs(c gospec.Context) {
+	loading := &redis.CmdError{Err: errors.New("LOADING Redis is loading the dataset in memory")}
+	dial := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	reset := &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}
+
+	c.Specify("[RetryPolicy][Make] Makes new instance", func() {
+		policy, err := MakeRetryPolicy(0, time.Millisecond, time.Second)
+		c.Expect(err.Error(), gospec.Equals, "Invalid max attempts: 0")
+		c.Expect(policy, gospec.Satisfies, nil == policy)
+
+		policy, err = MakeRetryPolicy(3, time.Second, time.Millisecond)
+		c.Expect(err.Error(), gospec.Equals, "Invalid max delay: 1ms")
+		c.Expect(policy, gospec.Satisfies, nil == policy)
+
+		policy, err = MakeRetryPolicy(3, time.Millisecond, time.Second)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(policy.Jitter, gospec.Equals, RetryJitter)
+	})
+
+	c.Specify("[RetryPolicy][Delay] Backs off exponentially up to MaxDelay", func() {
+		policy, _ := MakeRetryPolicy(10, 10*time.Millisecond, 50*time.Millisecond)
+		policy.Jitter = 0
+		c.Expect(policy.Delay(1), gospec.Equals, 10*time.Millisecond)
+		c.Expect(policy.Delay(2), gospec.Equals, 20*time.Millisecond)
+		c.Expect(policy.Delay(3), gospec.Equals, 40*time.Millisecond)
+		c.Expect(policy.Delay(4), gospec.Equals, 50*time.Millisecond)
+
+		policy.Jitter = 0.5
+		for i := 0; i < 100; i++ {
+			delay := policy.Delay(1)
+			c.Expect(delay, gospec.Satisfies, delay > 5*time.Millisecond && delay <= 10*time.Millisecond)
+		}
+	})
+
+	c.Specify("[RetryPolicy][IsRetryable] Classifies errors", func() {
+		c.Expect(IsRetryable(loading), gospec.Equals, true)
+		c.Expect(IsRetryable(&redis.CmdError{Err: errors.New("TRYAGAIN Multiple keys request during rehashing of slot")}), gospec.Equals, true)
+		c.Expect(IsRetryable(&redis.CmdError{Err: errors.New("CLUSTERDOWN The cluster is down")}), gospec.Equals, true)
+		c.Expect(IsRetryable(io.EOF), gospec.Equals, true)
+		c.Expect(IsRetryable(reset), gospec.Equals, true)
+		c.Expect(IsRetryable(&redis.CmdError{Err: errors.New("WRONGTYPE Operation against a key holding the wrong kind of value")}), gospec.Equals, false)
+		c.Expect(IsRetryable(nil), gospec.Equals, false)
+
+		// Deterministic failures:
+		c.Expect(IsRetryable(&ErrInexact{Key: "Bob", Value: 1 << 54}), gospec.Equals, false)
+		c.Expect(IsRetryable(ErrTransactionConflict), gospec.Equals, false)
+		c.Expect(IsRetryable(&ErrNotANumber{Key: "Bob", Raw: "abc"}), gospec.Equals, false)
+		c.Expect(IsRetryable(errors.New("Nil amount")), gospec.Equals, false)
+	})
+
+	c.Specify("[RetryPolicy][Do] Retries transient errors", func() {
+		policy, _ := MakeRetryPolicy(3, time.Millisecond, time.Millisecond)
+		delays := []time.Duration{}
+		policy.Sleep = func(delay time.Duration) { delays = append(delays, delay) }
+
+		errs := []error{reset, loading}
+		err := policy.Do(func() error {
+			if len(errs) == 0 {
+				return nil
+			}
+			err := errs[0]
+			errs = errs[1:]
+			return err
+		})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(delays), gospec.Equals, 2)
+
+		// Gives up after MaxAttempts:
+		attempts := 0
+		err = policy.Do(func() error {
+			attempts++
+			return reset
+		})
+		c.Expect(err, gospec.Equals, error(reset))
+		c.Expect(attempts, gospec.Equals, 3)
+
+		// Returns deterministic failures at once:
+		attempts = 0
+		err = policy.Do(func() error {
+			attempts++
+			return ErrTransactionConflict
+		})
+		c.Expect(err, gospec.Equals, ErrTransactionConflict)
+		c.Expect(attempts, gospec.Equals, 1)
+	})
+
+	c.Specify("[RetryPolicy][DoIncrement] Never retries an increment that may have been applied", func() {
+		policy, _ := MakeRetryPolicy(3, 0, 0)
+
+		attempts := 0
+		err := policy.DoIncrement(func() error {
+			attempts++
+			return reset
+		})
+		c.Expect(err, gospec.Equals, error(reset))
+		c.Expect(attempts, gospec.Equals, 1)
+
+		attempts = 0
+		err = policy.DoIncrement(func() error {
+			attempts++
+			if attempts == 1 {
+				return dial
+			}
+			if attempts == 2 {
+				return loading
+			}
+			return nil
+		})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(attempts, gospec.Equals, 3)
+	})
+
+	c.Specify("[RetryClient][Cmd] Retries by command", func() {
+		policy, _ := MakeRetryPolicy(3, 0, 0)
+		flaky := &flakyClient{errs: []error{reset}}
+		client, _ := MakeRetryClient(flaky, policy)
+
+		counter, _ := MakeRedisKeyCounterInt64(client, "Bob")
+		_, err := counter.Get()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(flaky.calls), gospec.Equals, 2)
+
+		flaky.errs, flaky.calls = []error{reset}, nil
+		_, err = counter.Add(1)
+		c.Expect(errors.Is(err, reset), gospec.Equals, true)
+		c.Expect(len(flaky.calls), gospec.Equals, 1)
+
+		flaky.errs, flaky.calls = []error{loading}, nil
+		_, err = counter.Add(1)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(flaky.calls), gospec.Equals, 2)
+	})
+}
@@ -0,0 +1,582 @@
+package redis_counter
+
+import "encoding/csv"
+import "encoding/json"
+import "fmt"
+import "io"
+import "math"
+import "sort"
+import "strconv"
+import "github.com/fzzy/radix/redis"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Formats for Export and Import
+const (
+	SnapshotJSONLines = "jsonl"
+	SnapshotCSV       = "csv"
+)
+
+// Kinds of Redis values holding counters
+const (
+	SnapshotString = "string"
+	SnapshotHash   = "hash"
+)
+
+// Types of the counter values
+const (
+	SnapshotInt64   = "int64"
+	SnapshotFloat64 = "float64"
+)
+
+// Keys read per SCAN call, and entries restored per pipeline
+const SnapshotBatchSize = 500
+
+// How Import treats counters that already exist in Redis
+type RestoreMode int
+
+const (
+	// Replace the existing values; a hash is replaced as a whole, so fields
+	// that aren't in the snapshot are deleted
+	RestoreOverwrite RestoreMode = iota
+	// Add the snapshot values to the existing values
+	RestoreMerge
+	// Keep the existing values, only restore the missing counters
+	RestoreSkipExisting
+)
+
+// One counter in a snapshot: a string key, or one field of a hash key.
+// TTL is in milliseconds, 0 when the key doesn't expire.
+type SnapshotEntry struct {
+	Key   string      `json:"key"`
+	Kind  string      `json:"kind"`
+	Field string      `json:"field,omitempty"`
+	Type  string      `json:"type"`
+	Value json.Number `json:"value"`
+	TTL   int64       `json:"ttl_ms,omitempty"`
+}
+
+// Counts from Export and Import
+type SnapshotStats struct {
+	// Distinct keys written
+	Keys int
+	// Counters written, one per string key or hash field
+	Entries int
+	// Export: values that aren't numbers; Import: counters that already existed
+	Skipped int
+}
+
+var snapshotCSVHeader = []string{"key", "kind", "field", "type", "value", "ttl_ms"}
+
+// Write every string and hash counter with a key matching "pattern" to
+// "w". Values that aren't numbers, and keys of other types, are skipped.
+func Export(redis *dog_pool.RedisConnection, w io.Writer, format, pattern string) (SnapshotStats, error) {
+	stats := SnapshotStats{}
+	switch {
+	case nil == redis:
+		return stats, ErrNilConnection
+	case len(pattern) == 0:
+		return stats, fmt.Errorf("Empty redis pattern")
+	}
+
+	writer, err := makeSnapshotWriter(w, format)
+	if nil != err {
+		return stats, err
+	}
+
+	err = scanKeyBatches(redis, pattern, SnapshotBatchSize, func(keys []string) error {
+		entries, skipped, err := exportKeys(redis, keys)
+		if nil != err {
+			return err
+		}
+
+		stats.Skipped += skipped
+		last := ""
+		for _, entry := range entries {
+			if err := writer.write(entry); nil != err {
+				return err
+			}
+			if entry.Key != last {
+				stats.Keys++
+			}
+			last = entry.Key
+			stats.Entries++
+		}
+		return nil
+	})
+	if nil != err {
+		return stats, err
+	}
+
+	return stats, writer.flush()
+}
+
+// Restore the counters written by Export; see RestoreMode for how
+// existing counters are treated. The TTL is restored on the keys created
+// by the import, and on every key with RestoreOverwrite.
+func Import(redis *dog_pool.RedisConnection, r io.Reader, format string, mode RestoreMode) (SnapshotStats, error) {
+	stats := SnapshotStats{}
+	switch {
+	case nil == redis:
+		return stats, ErrNilConnection
+	case mode < RestoreOverwrite || mode > RestoreSkipExisting:
+		return stats, fmt.Errorf("Invalid restore mode: %d", mode)
+	}
+
+	reader, err := makeSnapshotReader(r, format)
+	if nil != err {
+		return stats, err
+	}
+
+	seen := make(map[string]bool)
+	batch := make([]SnapshotEntry, 0, SnapshotBatchSize)
+	for line := 1; ; line++ {
+		entry, err := reader.read()
+		switch {
+		case io.EOF == err:
+			return stats, importEntries(redis, batch, mode, seen, &stats)
+		case nil != err:
+			return stats, err
+		}
+
+		if err := validateSnapshotEntry(entry); nil != err {
+			return stats, fmt.Errorf("Invalid snapshot entry[%d]: %s", line, err)
+		}
+
+		batch = append(batch, *entry)
+		if len(batch) == SnapshotBatchSize {
+			if err := importEntries(redis, batch, mode, seen, &stats); nil != err {
+				return stats, err
+			}
+			batch = batch[0:0]
+		}
+	}
+}
+
+//
+// Internal Helpers:
+//
+
+// Type of a counter value and the value as a JSON number, or false when
+// it isn't a number
+func snapshotType(value string) (string, json.Number, bool) {
+	if number, err := strconv.ParseInt(value, 10, 64); nil == err {
+		return SnapshotInt64, json.Number(strconv.FormatInt(number, 10)), true
+	}
+	if number, err := strconv.ParseFloat(value, 64); nil == err && !math.IsInf(number, 0) && !math.IsNaN(number) {
+		return SnapshotFloat64, json.Number(strconv.FormatFloat(number, 'g', -1, 64)), true
+	}
+	return "", "", false
+}
+
+// Read the type, TTL and values of the keys; returns the entries and the
+// number of values that were skipped
+func exportKeys(conn *dog_pool.RedisConnection, keys []string) ([]SnapshotEntry, int, error) {
+	commands := make([]*dog_pool.RedisBatchCommand, len(keys)*2)
+	for i, key := range keys {
+		commands[i*2] = dog_pool.MakeRedisBatchCommand("TYPE")
+		commands[i*2].WriteStringArg(key)
+		commands[i*2+1] = dog_pool.MakeRedisBatchCommand("PTTL")
+		commands[i*2+1].WriteStringArg(key)
+	}
+
+	err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(conn)
+	if nil != err {
+		return nil, 0, redisError(err, "", "")
+	}
+
+	kinds := make([]string, len(keys))
+	ttls := make([]int64, len(keys))
+	values := make([]*dog_pool.RedisBatchCommand, len(keys))
+	reads := []*dog_pool.RedisBatchCommand{}
+	for i, key := range keys {
+		kind, err := commands[i*2].Reply().Str()
+		if nil != err {
+			return nil, 0, err
+		}
+
+		ttl, err := commands[i*2+1].Reply().Int64()
+		if nil != err {
+			return nil, 0, err
+		}
+
+		switch kind {
+		case SnapshotString:
+			values[i] = dog_pool.MakeRedisBatchCommand("GET")
+		case SnapshotHash:
+			values[i] = dog_pool.MakeRedisBatchCommand("HGETALL")
+		default:
+			// Other types, or deleted since the SCAN:
+			continue
+		}
+		values[i].WriteStringArg(key)
+		reads = append(reads, values[i])
+
+		kinds[i] = kind
+		if ttl > 0 {
+			ttls[i] = ttl
+		}
+	}
+
+	if len(reads) == 0 {
+		return nil, 0, nil
+	}
+
+	err = dog_pool.RedisBatchCommands(reads).ExecuteBatch(conn)
+	if nil != err {
+		return nil, 0, redisError(err, "", "")
+	}
+
+	entries := []SnapshotEntry{}
+	skipped := 0
+	add := func(key, kind, field, value string, ttl int64) {
+		// Written back from the parsed value, as Redis accepts numbers
+		// like "+5" and ".5" that aren't valid JSON:
+		value_type, number, ok := snapshotType(value)
+		if !ok {
+			skipped++
+			return
+		}
+		entries = append(entries, SnapshotEntry{key, kind, field, value_type, number, ttl})
+	}
+
+	for i, key := range keys {
+		if nil == values[i] {
+			continue
+		}
+
+		reply := values[i].Reply()
+		switch {
+		case nil != reply.Err:
+			return nil, 0, redisError(reply.Err, "", "")
+
+		case redis.NilReply == reply.Type:
+			// Deleted since the TYPE:
+			continue
+
+		case kinds[i] == SnapshotString:
+			value, err := reply.Str()
+			if nil != err {
+				return nil, 0, err
+			}
+			add(key, SnapshotString, "", value, ttls[i])
+
+		default:
+			fields, err := reply.Hash()
+			if nil != err {
+				return nil, 0, err
+			}
+
+			names := make([]string, 0, len(fields))
+			for field := range fields {
+				names = append(names, field)
+			}
+			sort.Strings(names)
+
+			for _, field := range names {
+				add(key, SnapshotHash, field, fields[field], ttls[i])
+			}
+		}
+	}
+
+	return entries, skipped, nil
+}
+
+func validateSnapshotEntry(entry *SnapshotEntry) error {
+	switch {
+	case len(entry.Key) == 0:
+		return &ErrEmptyKey{Index: -1}
+	case entry.Kind != SnapshotString && entry.Kind != SnapshotHash:
+		return fmt.Errorf("Invalid kind: %s", entry.Kind)
+	case entry.Kind == SnapshotString && len(entry.Field) > 0:
+		return fmt.Errorf("Unexpected field for string key: %s", entry.Key)
+	case entry.TTL < 0:
+		return fmt.Errorf("Invalid ttl: %d", entry.TTL)
+	}
+
+	switch entry.Type {
+	case SnapshotInt64:
+		if _, err := strconv.ParseInt(entry.Value.String(), 10, 64); nil != err {
+			return fmt.Errorf("Invalid int64 value: %s", entry.Value)
+		}
+	case SnapshotFloat64:
+		if _, err := strconv.ParseFloat(entry.Value.String(), 64); nil != err {
+			return fmt.Errorf("Invalid float64 value: %s", entry.Value)
+		}
+	default:
+		return fmt.Errorf("Invalid type: %s", entry.Type)
+	}
+	return nil
+}
+
+// Write one batch of entries in a single pipeline
+func importEntries(redis *dog_pool.RedisConnection, entries []SnapshotEntry, mode RestoreMode, seen map[string]bool, stats *SnapshotStats) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	// Distinct keys, in the order they were first seen:
+	keys := []string{}
+	ttls := make(map[string]int64)
+	fields := make(map[string][]string)
+	for _, entry := range entries {
+		if _, ok := ttls[entry.Key]; !ok {
+			keys = append(keys, entry.Key)
+		}
+		ttls[entry.Key] = entry.TTL
+		if entry.Kind == SnapshotHash {
+			fields[entry.Key] = append(fields[entry.Key], entry.Field)
+		}
+	}
+
+	existed := make(map[string]bool)
+	if mode != RestoreOverwrite {
+		oks, err := redis.KeysExist(keys...)
+		if nil != err {
+			return err
+		}
+		for i, key := range keys {
+			existed[key] = oks[i]
+		}
+	}
+
+	if mode == RestoreSkipExisting {
+		kept, err := skipExistingEntries(redis, entries, existed, fields)
+		if nil != err {
+			return err
+		}
+		stats.Skipped += len(entries) - len(kept)
+		entries = kept
+	}
+
+	return writeEntries(redis, entries, keys, ttls, existed, mode, seen, stats)
+}
+
+// Drop the string keys and hash fields that already exist
+func skipExistingEntries(redis *dog_pool.RedisConnection, entries []SnapshotEntry, existed map[string]bool, fields map[string][]string) ([]SnapshotEntry, error) {
+	existing_fields := make(map[string]map[string]bool)
+	for key, names := range fields {
+		if !existed[key] {
+			continue
+		}
+
+		oks, err := redis.HashFieldsExist(key, names...)
+		if nil != err {
+			return nil, err
+		}
+
+		existing_fields[key] = make(map[string]bool)
+		for i, name := range names {
+			existing_fields[key][name] = oks[i]
+		}
+	}
+
+	kept := []SnapshotEntry{}
+	for _, entry := range entries {
+		switch {
+		case entry.Kind == SnapshotString && existed[entry.Key]:
+		case entry.Kind == SnapshotHash && existing_fields[entry.Key][entry.Field]:
+		default:
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
+}
+
+func writeEntries(redis *dog_pool.RedisConnection, entries []SnapshotEntry, keys []string, ttls map[string]int64, existed map[string]bool, mode RestoreMode, seen map[string]bool, stats *SnapshotStats) error {
+	commands := []*dog_pool.RedisBatchCommand{}
+	written := make(map[string]bool)
+
+	switch mode {
+	case RestoreMerge:
+		for _, entry := range entries {
+			var command *dog_pool.RedisBatchCommand
+			switch {
+			case entry.Kind == SnapshotString && entry.Type == SnapshotInt64:
+				command = dog_pool.MakeRedisBatchCommand("INCRBY")
+			case entry.Kind == SnapshotString:
+				command = dog_pool.MakeRedisBatchCommand("INCRBYFLOAT")
+			case entry.Type == SnapshotInt64:
+				command = dog_pool.MakeRedisBatchCommand("HINCRBY")
+			default:
+				command = dog_pool.MakeRedisBatchCommand("HINCRBYFLOAT")
+			}
+			command.WriteStringArg(entry.Key)
+			if entry.Kind == SnapshotHash {
+				command.WriteStringArg(entry.Field)
+			}
+			command.WriteStringArg(entry.Value.String())
+			commands = append(commands, command)
+			written[entry.Key] = true
+		}
+
+	default:
+		var mset *dog_pool.RedisBatchCommand
+		hmsets := make(map[string]*dog_pool.RedisBatchCommand)
+		for _, entry := range entries {
+			switch entry.Kind {
+			case SnapshotString:
+				if nil == mset {
+					mset = dog_pool.MakeRedisBatchCommand("MSET")
+					commands = append(commands, mset)
+				}
+				mset.WriteStringArg(entry.Key)
+				mset.WriteStringArg(entry.Value.String())
+
+			default:
+				hmset, ok := hmsets[entry.Key]
+				if !ok && mode == RestoreOverwrite && !seen[entry.Key] {
+					// First batch of the hash in this import:
+					del := dog_pool.MakeRedisBatchCommand("DEL")
+					del.WriteStringArg(entry.Key)
+					commands = append(commands, del)
+				}
+				if !ok {
+					hmset = dog_pool.MakeRedisBatchCommand("HMSET")
+					hmset.WriteStringArg(entry.Key)
+					hmsets[entry.Key] = hmset
+					commands = append(commands, hmset)
+				}
+				hmset.WriteStringArg(entry.Field)
+				hmset.WriteStringArg(entry.Value.String())
+			}
+			written[entry.Key] = true
+		}
+	}
+
+	for _, key := range keys {
+		if written[key] && ttls[key] > 0 && !existed[key] {
+			command := dog_pool.MakeRedisBatchCommand("PEXPIRE")
+			command.WriteStringArg(key)
+			command.WriteStringArg(strconv.FormatInt(ttls[key], 10))
+			commands = append(commands, command)
+		}
+	}
+
+	if len(commands) > 0 {
+		err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(redis)
+		if nil != err {
+			return redisError(err, "", "")
+		}
+		for _, command := range commands {
+			if err := command.Reply().Err; nil != err {
+				return redisError(err, "", "")
+			}
+		}
+	}
+
+	for _, key := range keys {
+		if written[key] && !seen[key] {
+			seen[key] = true
+			stats.Keys++
+		}
+	}
+	stats.Entries += len(entries)
+	return nil
+}
+
+// Writes entries in one of the snapshot formats
+type snapshotWriter interface {
+	write(entry SnapshotEntry) error
+	flush() error
+}
+
+// Reads entries in one of the snapshot formats; returns io.EOF at the end
+type snapshotReader interface {
+	read() (*SnapshotEntry, error)
+}
+
+func makeSnapshotWriter(w io.Writer, format string) (snapshotWriter, error) {
+	switch format {
+	case SnapshotJSONLines:
+		return &jsonSnapshotWriter{json.NewEncoder(w)}, nil
+	case SnapshotCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write(snapshotCSVHeader); nil != err {
+			return nil, err
+		}
+		return &csvSnapshotWriter{writer}, nil
+	default:
+		return nil, fmt.Errorf("Invalid snapshot format: %s", format)
+	}
+}
+
+func makeSnapshotReader(r io.Reader, format string) (snapshotReader, error) {
+	switch format {
+	case SnapshotJSONLines:
+		decoder := json.NewDecoder(r)
+		decoder.UseNumber()
+		return &jsonSnapshotReader{decoder}, nil
+	case SnapshotCSV:
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = len(snapshotCSVHeader)
+		header, err := reader.Read()
+		switch {
+		case io.EOF == err:
+			return &csvSnapshotReader{reader}, nil
+		case nil != err:
+			return nil, err
+		}
+		for i, name := range snapshotCSVHeader {
+			if header[i] != name {
+				return nil, fmt.Errorf("Invalid snapshot header: %v", header)
+			}
+		}
+		return &csvSnapshotReader{reader}, nil
+	default:
+		return nil, fmt.Errorf("Invalid snapshot format: %s", format)
+	}
+}
+
+type jsonSnapshotWriter struct {
+	encoder *json.Encoder
+}
+
+func (p *jsonSnapshotWriter) write(entry SnapshotEntry) error {
+	return p.encoder.Encode(entry)
+}
+
+func (p *jsonSnapshotWriter) flush() error {
+	return nil
+}
+
+type csvSnapshotWriter struct {
+	writer *csv.Writer
+}
+
+func (p *csvSnapshotWriter) write(entry SnapshotEntry) error {
+	return p.writer.Write([]string{entry.Key, entry.Kind, entry.Field, entry.Type, entry.Value.String(), strconv.FormatInt(entry.TTL, 10)})
+}
+
+func (p *csvSnapshotWriter) flush() error {
+	p.writer.Flush()
+	return p.writer.Error()
+}
+
+type jsonSnapshotReader struct {
+	decoder *json.Decoder
+}
+
+func (p *jsonSnapshotReader) read() (*SnapshotEntry, error) {
+	entry := &SnapshotEntry{}
+	if err := p.decoder.Decode(entry); nil != err {
+		return nil, err
+	}
+	return entry, nil
+}
+
+type csvSnapshotReader struct {
+	reader *csv.Reader
+}
+
+func (p *csvSnapshotReader) read() (*SnapshotEntry, error) {
+	record, err := p.reader.Read()
+	if nil != err {
+		return nil, err
+	}
+
+	ttl, err := strconv.ParseInt(record[5], 10, 64)
+	if nil != err {
+		return nil, fmt.Errorf("Invalid ttl: %s", record[5])
+	}
+	return &SnapshotEntry{record[0], record[1], record[2], record[3], json.Number(record[4]), ttl}, nil
+}
@@ -0,0 +1,179 @@
+package redis_counter
+
+import "bytes"
+import "strings"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestSnapshotSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(SnapshotSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func SnapshotSpecs(c gospec.Context) {
+
+	c.Specify("[Snapshot][Make] Validates arguments", func() {
+		_, err := Export(nil, &bytes.Buffer{}, SnapshotJSONLines, "*")
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+
+		_, err = Export(&dog_pool.RedisConnection{}, &bytes.Buffer{}, SnapshotJSONLines, "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis pattern")
+
+		_, err = Export(&dog_pool.RedisConnection{}, &bytes.Buffer{}, "xml", "*")
+		c.Expect(err.Error(), gospec.Equals, "Invalid snapshot format: xml")
+
+		_, err = Import(nil, strings.NewReader(""), SnapshotJSONLines, RestoreOverwrite)
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+
+		_, err = Import(&dog_pool.RedisConnection{}, strings.NewReader(""), SnapshotJSONLines, RestoreMode(9))
+		c.Expect(err.Error(), gospec.Equals, "Invalid restore mode: 9")
+
+		_, err = Import(&dog_pool.RedisConnection{}, strings.NewReader(`{"key":"Bob","kind":"string","type":"int64","value":1.5}`), SnapshotJSONLines, RestoreOverwrite)
+		c.Expect(err.Error(), gospec.Equals, "Invalid snapshot entry[1]: Invalid int64 value: 1.5")
+
+		_, err = Import(&dog_pool.RedisConnection{}, strings.NewReader("key,value\n"), SnapshotCSV, RestoreOverwrite)
+		c.Expect(err, gospec.Satisfies, nil != err)
+	})
+
+	c.Specify("[Snapshot][Export] Writes JSON Lines and CSV", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		redis := server.Connection()
+		redis.Cmd("SET", "stats:Bob", "123")
+		redis.Cmd("SET", "stats:Gary", "1.5")
+		redis.Cmd("PEXPIRE", "stats:Gary", "60000")
+		redis.Cmd("SET", "stats:Name", "Gary")
+		redis.Cmd("HMSET", "stats:Hash", "B", "2", "A", "1", "C", "Bob")
+		redis.Cmd("LPUSH", "stats:List", "1")
+		redis.Cmd("SET", "other:Bob", "1")
+
+		var buffer bytes.Buffer
+		stats, err := Export(redis, &buffer, SnapshotJSONLines, "stats:*")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(stats.Keys, gospec.Equals, 3)
+		c.Expect(stats.Entries, gospec.Equals, 4)
+		c.Expect(stats.Skipped, gospec.Equals, 2)
+
+		lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+		c.Expect(len(lines), gospec.Equals, 4)
+		c.Expect(lines, gospec.Contains, `{"key":"stats:Bob","kind":"string","type":"int64","value":123}`)
+		c.Expect(lines, gospec.Contains, `{"key":"stats:Hash","kind":"hash","field":"A","type":"int64","value":1}`)
+		c.Expect(lines, gospec.Contains, `{"key":"stats:Hash","kind":"hash","field":"B","type":"int64","value":2}`)
+		c.Expect(buffer.String(), gospec.Satisfies, strings.Contains(buffer.String(), `{"key":"stats:Gary","kind":"string","type":"float64","value":1.5,"ttl_ms":`))
+
+		// Numbers Redis accepts that aren't valid JSON:
+		redis.Cmd("SET", "odd:Bob", "+5")
+		redis.Cmd("SET", "odd:Gary", ".5")
+		buffer.Reset()
+		stats, err = Export(redis, &buffer, SnapshotJSONLines, "odd:*")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(stats.Entries, gospec.Equals, 2)
+		c.Expect(buffer.String(), gospec.Satisfies, strings.Contains(buffer.String(), `{"key":"odd:Bob","kind":"string","type":"int64","value":5}`))
+		c.Expect(buffer.String(), gospec.Satisfies, strings.Contains(buffer.String(), `{"key":"odd:Gary","kind":"string","type":"float64","value":0.5}`))
+
+		buffer.Reset()
+		_, err = Export(redis, &buffer, SnapshotCSV, "stats:Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(buffer.String(), gospec.Equals, "key,kind,field,type,value,ttl_ms\nstats:Bob,string,,int64,123,0\n")
+	})
+
+	c.Specify("[Snapshot][Import] Restores with each mode", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		redis := server.Connection()
+		snapshot := "key,kind,field,type,value,ttl_ms\n" +
+			"Bob,string,,int64,10,0\n" +
+			"Gary,string,,float64,1.5,60000\n" +
+			"Hash,hash,A,int64,3,0\n" +
+			"Hash,hash,B,int64,4,0\n"
+
+		// Restore into an empty database:
+		stats, err := Import(redis, strings.NewReader(snapshot), SnapshotCSV, RestoreOverwrite)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(stats, gospec.Equals, SnapshotStats{Keys: 3, Entries: 4})
+
+		value, _ := redis.Cmd("GET", "Bob").Str()
+		c.Expect(value, gospec.Equals, "10")
+		ttl, _ := redis.Cmd("PTTL", "Gary").Int64()
+		c.Expect(ttl, gospec.Satisfies, ttl > 0 && ttl <= 60000)
+
+		// Add to the existing values:
+		stats, err = Import(redis, strings.NewReader(snapshot), SnapshotCSV, RestoreMerge)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(stats, gospec.Equals, SnapshotStats{Keys: 3, Entries: 4})
+
+		value, _ = redis.Cmd("GET", "Bob").Str()
+		c.Expect(value, gospec.Equals, "20")
+		value, _ = redis.Cmd("GET", "Gary").Str()
+		c.Expect(value, gospec.Equals, "3")
+		value, _ = redis.Cmd("HGET", "Hash", "B").Str()
+		c.Expect(value, gospec.Equals, "8")
+
+		// Keep the existing values:
+		redis.Cmd("DEL", "Bob")
+		redis.Cmd("HDEL", "Hash", "A")
+		stats, err = Import(redis, strings.NewReader(snapshot), SnapshotCSV, RestoreSkipExisting)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(stats, gospec.Equals, SnapshotStats{Keys: 2, Entries: 2, Skipped: 2})
+
+		value, _ = redis.Cmd("GET", "Bob").Str()
+		c.Expect(value, gospec.Equals, "10")
+		value, _ = redis.Cmd("HGET", "Hash", "A").Str()
+		c.Expect(value, gospec.Equals, "3")
+		value, _ = redis.Cmd("HGET", "Hash", "B").Str()
+		c.Expect(value, gospec.Equals, "8")
+
+		// Overwrite the existing values, replacing whole hashes:
+		redis.Cmd("HSET", "Hash", "C", "9")
+		stats, err = Import(redis, strings.NewReader(snapshot), SnapshotCSV, RestoreOverwrite)
+		c.Expect(err, gospec.Equals, nil)
+		value, _ = redis.Cmd("HGET", "Hash", "B").Str()
+		c.Expect(value, gospec.Equals, "4")
+		exists, _ := redis.Cmd("HEXISTS", "Hash", "C").Int()
+		c.Expect(exists, gospec.Equals, 0)
+	})
+
+	c.Specify("[Snapshot][Round Trip] Export then Import", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		redis := server.Connection()
+		redis.Cmd("SET", "Bob", "-7")
+		redis.Cmd("HMSET", "Hash", "A", "0.25", "B", "9")
+
+		var buffer bytes.Buffer
+		_, err := Export(redis, &buffer, SnapshotJSONLines, "*")
+		c.Expect(err, gospec.Equals, nil)
+
+		redis.Cmd("FLUSHDB")
+		stats, err := Import(redis, &buffer, SnapshotJSONLines, RestoreOverwrite)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(stats, gospec.Equals, SnapshotStats{Keys: 2, Entries: 3})
+
+		value, _ := redis.Cmd("GET", "Bob").Str()
+		c.Expect(value, gospec.Equals, "-7")
+		value, _ = redis.Cmd("HGET", "Hash", "A").Str()
+		c.Expect(value, gospec.Equals, "0.25")
+	})
+}
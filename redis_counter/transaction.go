@@ -0,0 +1,650 @@
+package redis_counter
+
+import "errors"
+import "fmt"
+import "math"
+import "strconv"
+import "strings"
+import "github.com/fzzy/radix/redis"
+import "github.com/gnagel/dog_pool/dog_pool"
+
+// Default number of times a transaction is retried after a conflict
+const TransactionMaxRetries = 3
+
+// Returned when the watched keys kept changing for every retry
+var ErrTransactionConflict = errors.New("Transaction aborted: watched keys changed")
+
+// Implemented by every counter type, so its operations can be queued in
+// a RedisTransaction
+type TransactionCounter interface {
+	transactionOps(op int, amount string) ([]*transactionOp, error)
+}
+
+// Runs operations on counters of any type atomically with MULTI/EXEC:
+//
+//	tx, _ := MakeRedisTransaction(redis)
+//	tx.AddInt64(hits, 1)
+//	tx.SubInt64(stock, 1)
+//	tx.SetFloat64(gauge, 0.5)
+//	err := tx.Exec()
+//
+// The results are saved to each counter's LastValue or Cache. When WATCHES
+// is set the keys are watched before the transaction, and it is retried
+// up to MaxRetries times if one of them changes; use ExecFunc to re-read
+// the counters and queue the operations again on every attempt.
+type RedisTransaction struct {
+	Redis      *dog_pool.RedisConnection
+	WATCHES    []string
+	MaxRetries int
+
+	ops []*transactionOp
+}
+
+// Make a new instance of RedisTransaction
+func MakeRedisTransaction(redis *dog_pool.RedisConnection, watches ...string) (*RedisTransaction, error) {
+	switch {
+	case nil == redis:
+		return nil, ErrNilConnection
+	default:
+		for i, key := range watches {
+			if len(key) == 0 {
+				return nil, &ErrEmptyKey{Index: i}
+			}
+		}
+
+		return &RedisTransaction{Redis: redis, WATCHES: watches, MaxRetries: TransactionMaxRetries}, nil
+	}
+}
+
+// Number of queued commands
+func (p *RedisTransaction) Len() int {
+	return len(p.ops)
+}
+
+// Remove the queued operations
+func (p *RedisTransaction) Reset() {
+	p.ops = nil
+}
+
+func (p *RedisTransaction) Get(counter TransactionCounter) error {
+	return p.queue(counter, opGet, "")
+}
+
+func (p *RedisTransaction) Delete(counter TransactionCounter) error {
+	return p.queue(counter, opDelete, "")
+}
+
+func (p *RedisTransaction) SetInt64(counter TransactionCounter, amount int64) error {
+	return p.queue(counter, opSet, strconv.FormatInt(amount, 10))
+}
+
+func (p *RedisTransaction) AddInt64(counter TransactionCounter, amount int64) error {
+	return p.queue(counter, opAdd, strconv.FormatInt(amount, 10))
+}
+
+func (p *RedisTransaction) SubInt64(counter TransactionCounter, amount int64) error {
+	return p.queue(counter, opSub, strconv.FormatInt(amount, 10))
+}
+
+func (p *RedisTransaction) SetFloat64(counter TransactionCounter, amount float64) error {
+	return p.queue(counter, opSet, strconv.FormatFloat(amount, 'f', -1, 64))
+}
+
+func (p *RedisTransaction) AddFloat64(counter TransactionCounter, amount float64) error {
+	return p.queue(counter, opAdd, strconv.FormatFloat(amount, 'f', -1, 64))
+}
+
+func (p *RedisTransaction) SubFloat64(counter TransactionCounter, amount float64) error {
+	return p.queue(counter, opSub, strconv.FormatFloat(amount, 'f', -1, 64))
+}
+
+// Run the queued operations in one MULTI/EXEC; the queue is kept, so the
+// same operations run again on the next call
+func (p *RedisTransaction) Exec() error {
+	return p.operationExecutes(nil)
+}
+
+// Watch WATCHES, call "build" to read the counters and queue the
+// operations, then run them in one MULTI/EXEC. "build" is called again
+// for each retry, with an empty queue.
+func (p *RedisTransaction) ExecFunc(build func(tx *RedisTransaction) error) error {
+	if nil == build {
+		return fmt.Errorf("Nil build function")
+	}
+	return p.operationExecutes(build)
+}
+
+//
+// Internal Helpers:
+//
+
+const (
+	opGet = iota
+	opSet
+	opAdd
+	opSub
+	opDelete
+)
+
+// One queued command, and how to save its reply to the counter
+type transactionOp struct {
+	args  []string
+	apply func(reply *redis.Reply) error
+}
+
+func (p *RedisTransaction) queue(counter TransactionCounter, op int, amount string) error {
+	if nil == counter {
+		return fmt.Errorf("Nil counter")
+	}
+
+	ops, err := counter.transactionOps(op, amount)
+	if nil != err {
+		return err
+	}
+	p.ops = append(p.ops, ops...)
+	return nil
+}
+
+func (p *RedisTransaction) operationExecutes(build func(tx *RedisTransaction) error) error {
+	for attempt := 0; ; attempt++ {
+		if len(p.WATCHES) > 0 {
+			if reply := p.Redis.Cmd("WATCH", p.WATCHES); nil != reply.Err {
+				return redisError(reply.Err, "", "")
+			}
+		}
+
+		if nil != build {
+			p.Reset()
+			if err := build(p); nil != err {
+				p.unwatch()
+				return err
+			}
+		}
+
+		if len(p.ops) == 0 {
+			p.unwatch()
+			return nil
+		}
+
+		commands := make([]*dog_pool.RedisBatchCommand, 0, len(p.ops)+2)
+		commands = append(commands, dog_pool.MakeRedisBatchCommand("MULTI"))
+		for _, op := range p.ops {
+			command := dog_pool.MakeRedisBatchCommand(op.args[0])
+			for _, arg := range op.args[1:] {
+				command.WriteStringArg(arg)
+			}
+			commands = append(commands, command)
+		}
+		commands = append(commands, dog_pool.MakeRedisBatchCommand("EXEC"))
+
+		err := dog_pool.RedisBatchCommands(commands).ExecuteBatch(p.Redis)
+		if nil != err {
+			p.unwatch()
+			return redisError(err, "", "")
+		}
+
+		reply := commands[len(commands)-1].Reply()
+		switch {
+		case nil != reply.Err && strings.HasPrefix(reply.Err.Error(), "EXECABORT"):
+			// A command failed to queue and would fail again; return its error:
+			for _, command := range commands[1 : len(commands)-1] {
+				if err := command.Reply().Err; nil != err {
+					return redisError(err, "", "")
+				}
+			}
+			return redisError(reply.Err, "", "")
+
+		case nil != reply.Err:
+			return redisError(reply.Err, "", "")
+
+		case redis.NilReply == reply.Type && attempt < p.MaxRetries:
+			continue
+
+		case redis.NilReply == reply.Type:
+			return ErrTransactionConflict
+
+		case len(reply.Elems) != len(p.ops):
+			return fmt.Errorf("Invalid EXEC reply: expected %d replies, found %d", len(p.ops), len(reply.Elems))
+		}
+
+		// MULTI/EXEC doesn't roll back, so save every result and return
+		// the first error:
+		var first_err error
+		for i, op := range p.ops {
+			if err := op.apply(reply.Elems[i]); nil != err && nil == first_err {
+				first_err = err
+			}
+		}
+		return first_err
+	}
+}
+
+func (p *RedisTransaction) unwatch() {
+	if len(p.WATCHES) > 0 {
+		p.Redis.Cmd("UNWATCH")
+	}
+}
+
+func validateInt64Amount(amount string) error {
+	if _, err := strconv.ParseInt(amount, 10, 64); nil != err {
+		return fmt.Errorf("Invalid int64 amount: %s", amount)
+	}
+	return nil
+}
+
+func negateInt64Amount(amount string) (string, error) {
+	value, err := strconv.ParseInt(amount, 10, 64)
+	switch {
+	case nil != err:
+		return "", fmt.Errorf("Invalid int64 amount: %s", amount)
+	case value == math.MinInt64:
+		return "", fmt.Errorf("Invalid int64 amount: %s can't be negated", amount)
+	default:
+		return strconv.FormatInt(-value, 10), nil
+	}
+}
+
+func negateFloat64Amount(amount string) (string, error) {
+	value, err := strconv.ParseFloat(amount, 64)
+	switch {
+	case nil != err:
+		return "", fmt.Errorf("Invalid float64 amount: %s", amount)
+	case value == 0:
+		return "0", nil
+	default:
+		return strconv.FormatFloat(-value, 'f', -1, 64), nil
+	}
+}
+
+// Save the reply to "last"
+func applyInt64(last **int64, key, field string) func(reply *redis.Reply) error {
+	return func(reply *redis.Reply) error {
+		ptr, err := toInt64Ptr(reply, key, field)
+		*last = ptr
+		return err
+	}
+}
+
+// Save the reply to "last"
+func applyFloat64(last **float64, key, field string) func(reply *redis.Reply) error {
+	return func(reply *redis.Reply) error {
+		ptr, err := toFloat64Ptr(reply, key, field)
+		*last = ptr
+		return err
+	}
+}
+
+// Save "amount" to "last" once the command succeeds
+func applyReplacedInt64(last **int64, amount string) func(reply *redis.Reply) error {
+	return func(reply *redis.Reply) error {
+		*last = nil
+		if nil != reply.Err {
+			return redisError(reply.Err, "", "")
+		}
+		value, _ := strconv.ParseInt(amount, 10, 64)
+		*last = &value
+		return nil
+	}
+}
+
+// Save "amount" to "last" once the command succeeds
+func applyReplacedFloat64(last **float64, amount string) func(reply *redis.Reply) error {
+	return func(reply *redis.Reply) error {
+		*last = nil
+		if nil != reply.Err {
+			return redisError(reply.Err, "", "")
+		}
+		value, _ := strconv.ParseFloat(amount, 64)
+		*last = &value
+		return nil
+	}
+}
+
+// Clear "last" once the command succeeds
+func applyDeleted(reset func()) func(reply *redis.Reply) error {
+	return func(reply *redis.Reply) error {
+		reset()
+		return redisError(reply.Err, "", "")
+	}
+}
+
+func (p *RedisKeyCounterInt64) transactionOps(op int, amount string) ([]*transactionOp, error) {
+	if op == opSet || op == opAdd || op == opSub {
+		if err := validateInt64Amount(amount); nil != err {
+			return nil, err
+		}
+	}
+
+	switch op {
+	case opGet:
+		return []*transactionOp{{[]string{"GET", p.KEY}, applyInt64(&p.LastValue, p.KEY, "")}}, nil
+	case opSet:
+		return []*transactionOp{{[]string{"SET", p.KEY, amount}, applyReplacedInt64(&p.LastValue, amount)}}, nil
+	case opAdd:
+		return []*transactionOp{{[]string{"INCRBY", p.KEY, amount}, applyInt64(&p.LastValue, p.KEY, "")}}, nil
+	case opSub:
+		return []*transactionOp{{[]string{"DECRBY", p.KEY, amount}, applyInt64(&p.LastValue, p.KEY, "")}}, nil
+	default:
+		return []*transactionOp{{[]string{"DEL", p.KEY}, applyDeleted(func() { p.LastValue = nil })}}, nil
+	}
+}
+
+func (p *RedisKeyCounterFloat64) transactionOps(op int, amount string) ([]*transactionOp, error) {
+	switch op {
+	case opGet:
+		return []*transactionOp{{[]string{"GET", p.KEY}, applyFloat64(&p.LastValue, p.KEY, "")}}, nil
+	case opSet:
+		return []*transactionOp{{[]string{"SET", p.KEY, amount}, applyReplacedFloat64(&p.LastValue, amount)}}, nil
+	case opAdd:
+		return []*transactionOp{{[]string{"INCRBYFLOAT", p.KEY, amount}, applyFloat64(&p.LastValue, p.KEY, "")}}, nil
+	case opSub:
+		negated, err := negateFloat64Amount(amount)
+		if nil != err {
+			return nil, err
+		}
+		return []*transactionOp{{[]string{"INCRBYFLOAT", p.KEY, negated}, applyFloat64(&p.LastValue, p.KEY, "")}}, nil
+	default:
+		return []*transactionOp{{[]string{"DEL", p.KEY}, applyDeleted(func() { p.LastValue = nil })}}, nil
+	}
+}
+
+func (p *RedisHashFieldCounterInt64) transactionOps(op int, amount string) ([]*transactionOp, error) {
+	if op == opSet || op == opAdd || op == opSub {
+		if err := validateInt64Amount(amount); nil != err {
+			return nil, err
+		}
+	}
+
+	switch op {
+	case opGet:
+		return []*transactionOp{{[]string{"HGET", p.KEY, p.FIELD}, applyInt64(&p.LastValue, p.KEY, p.FIELD)}}, nil
+	case opSet:
+		return []*transactionOp{{[]string{"HSET", p.KEY, p.FIELD, amount}, applyReplacedInt64(&p.LastValue, amount)}}, nil
+	case opAdd:
+		return []*transactionOp{{[]string{"HINCRBY", p.KEY, p.FIELD, amount}, applyInt64(&p.LastValue, p.KEY, p.FIELD)}}, nil
+	case opSub:
+		negated, err := negateInt64Amount(amount)
+		if nil != err {
+			return nil, err
+		}
+		return []*transactionOp{{[]string{"HINCRBY", p.KEY, p.FIELD, negated}, applyInt64(&p.LastValue, p.KEY, p.FIELD)}}, nil
+	default:
+		return []*transactionOp{{[]string{"HDEL", p.KEY, p.FIELD}, applyDeleted(func() { p.LastValue = nil })}}, nil
+	}
+}
+
+func (p *RedisHashFieldCounterFloat64) transactionOps(op int, amount string) ([]*transactionOp, error) {
+	switch op {
+	case opGet:
+		return []*transactionOp{{[]string{"HGET", p.KEY, p.FIELD}, applyFloat64(&p.LastValue, p.KEY, p.FIELD)}}, nil
+	case opSet:
+		return []*transactionOp{{[]string{"HSET", p.KEY, p.FIELD, amount}, applyReplacedFloat64(&p.LastValue, amount)}}, nil
+	case opAdd:
+		return []*transactionOp{{[]string{"HINCRBYFLOAT", p.KEY, p.FIELD, amount}, applyFloat64(&p.LastValue, p.KEY, p.FIELD)}}, nil
+	case opSub:
+		negated, err := negateFloat64Amount(amount)
+		if nil != err {
+			return nil, err
+		}
+		return []*transactionOp{{[]string{"HINCRBYFLOAT", p.KEY, p.FIELD, negated}, applyFloat64(&p.LastValue, p.KEY, p.FIELD)}}, nil
+	default:
+		return []*transactionOp{{[]string{"HDEL", p.KEY, p.FIELD}, applyDeleted(func() { p.LastValue = nil })}}, nil
+	}
+}
+
+func (p *RedisMKeysCounterInt64) transactionOps(op int, amount string) ([]*transactionOp, error) {
+	if op == opSet || op == opAdd || op == opSub {
+		if err := validateInt64Amount(amount); nil != err {
+			return nil, err
+		}
+	}
+
+	switch op {
+	case opGet:
+		apply := func(reply *redis.Reply) error {
+			p.CacheReset()
+			if nil != reply.Err {
+				return redisError(reply.Err, "", "")
+			}
+			for i, key := range p.KEYS {
+				ptr, err := toInt64Ptr(reply.Elems[i], key, "")
+				if nil != err {
+					return err
+				}
+				p.Cache.Set(key, ptr)
+			}
+			return nil
+		}
+		return []*transactionOp{{append([]string{"MGET"}, p.KEYS...), apply}}, nil
+
+	case opSet:
+		args := []string{"MSET"}
+		for _, key := range p.KEYS {
+			args = append(args, key, amount)
+		}
+		apply := func(reply *redis.Reply) error {
+			p.CacheReset()
+			if nil != reply.Err {
+				return redisError(reply.Err, "", "")
+			}
+			value, _ := strconv.ParseInt(amount, 10, 64)
+			for _, key := range p.KEYS {
+				p.Cache.Set(key, &value)
+			}
+			return nil
+		}
+		return []*transactionOp{{args, apply}}, nil
+
+	case opAdd, opSub:
+		cmd := "INCRBY"
+		if op == opSub {
+			cmd = "DECRBY"
+		}
+		ops := make([]*transactionOp, len(p.KEYS))
+		for i, key := range p.KEYS {
+			key := key
+			apply := func(reply *redis.Reply) error {
+				ptr, err := toInt64Ptr(reply, key, "")
+				p.Cache.Set(key, ptr)
+				return err
+			}
+			ops[i] = &transactionOp{[]string{cmd, key, amount}, apply}
+		}
+		return ops, nil
+
+	default:
+		return []*transactionOp{{append([]string{"DEL"}, p.KEYS...), applyDeleted(p.CacheReset)}}, nil
+	}
+}
+
+func (p *RedisMKeysCounterFloat64) transactionOps(op int, amount string) ([]*transactionOp, error) {
+	switch op {
+	case opGet:
+		apply := func(reply *redis.Reply) error {
+			p.CacheReset()
+			if nil != reply.Err {
+				return redisError(reply.Err, "", "")
+			}
+			for i, key := range p.KEYS {
+				ptr, err := toFloat64Ptr(reply.Elems[i], key, "")
+				if nil != err {
+					return err
+				}
+				p.Cache.Set(key, ptr)
+			}
+			return nil
+		}
+		return []*transactionOp{{append([]string{"MGET"}, p.KEYS...), apply}}, nil
+
+	case opSet:
+		args := []string{"MSET"}
+		for _, key := range p.KEYS {
+			args = append(args, key, amount)
+		}
+		apply := func(reply *redis.Reply) error {
+			p.CacheReset()
+			if nil != reply.Err {
+				return redisError(reply.Err, "", "")
+			}
+			value, _ := strconv.ParseFloat(amount, 64)
+			for _, key := range p.KEYS {
+				p.Cache.Set(key, &value)
+			}
+			return nil
+		}
+		return []*transactionOp{{args, apply}}, nil
+
+	case opAdd, opSub:
+		if op == opSub {
+			negated, err := negateFloat64Amount(amount)
+			if nil != err {
+				return nil, err
+			}
+			amount = negated
+		}
+		ops := make([]*transactionOp, len(p.KEYS))
+		for i, key := range p.KEYS {
+			key := key
+			apply := func(reply *redis.Reply) error {
+				ptr, err := toFloat64Ptr(reply, key, "")
+				p.Cache.Set(key, ptr)
+				return err
+			}
+			ops[i] = &transactionOp{[]string{"INCRBYFLOAT", key, amount}, apply}
+		}
+		return ops, nil
+
+	default:
+		return []*transactionOp{{append([]string{"DEL"}, p.KEYS...), applyDeleted(p.CacheReset)}}, nil
+	}
+}
+
+func (p *RedisHashMFieldsCounterInt64) transactionOps(op int, amount string) ([]*transactionOp, error) {
+	if op == opSet || op == opAdd || op == opSub {
+		if err := validateInt64Amount(amount); nil != err {
+			return nil, err
+		}
+	}
+
+	switch op {
+	case opGet:
+		apply := func(reply *redis.Reply) error {
+			p.CacheReset()
+			if nil != reply.Err {
+				return redisError(reply.Err, "", "")
+			}
+			for i, field := range p.FIELDS {
+				ptr, err := toInt64Ptr(reply.Elems[i], p.KEY, field)
+				if nil != err {
+					return err
+				}
+				p.Cache.Set(field, ptr)
+			}
+			return nil
+		}
+		return []*transactionOp{{append([]string{"HMGET", p.KEY}, p.FIELDS...), apply}}, nil
+
+	case opSet:
+		args := []string{"HMSET", p.KEY}
+		for _, field := range p.FIELDS {
+			args = append(args, field, amount)
+		}
+		apply := func(reply *redis.Reply) error {
+			p.CacheReset()
+			if nil != reply.Err {
+				return redisError(reply.Err, "", "")
+			}
+			value, _ := strconv.ParseInt(amount, 10, 64)
+			for _, field := range p.FIELDS {
+				p.Cache.Set(field, &value)
+			}
+			return nil
+		}
+		return []*transactionOp{{args, apply}}, nil
+
+	case opAdd, opSub:
+		if op == opSub {
+			negated, err := negateInt64Amount(amount)
+			if nil != err {
+				return nil, err
+			}
+			amount = negated
+		}
+		ops := make([]*transactionOp, len(p.FIELDS))
+		for i, field := range p.FIELDS {
+			field := field
+			apply := func(reply *redis.Reply) error {
+				ptr, err := toInt64Ptr(reply, p.KEY, field)
+				p.Cache.Set(field, ptr)
+				return err
+			}
+			ops[i] = &transactionOp{[]string{"HINCRBY", p.KEY, field, amount}, apply}
+		}
+		return ops, nil
+
+	default:
+		return []*transactionOp{{append([]string{"HDEL", p.KEY}, p.FIELDS...), applyDeleted(p.CacheReset)}}, nil
+	}
+}
+
+func (p *RedisHashMFieldsCounterFloat64) transactionOps(op int, amount string) ([]*transactionOp, error) {
+	switch op {
+	case opGet:
+		apply := func(reply *redis.Reply) error {
+			p.CacheReset()
+			if nil != reply.Err {
+				return redisError(reply.Err, "", "")
+			}
+			for i, field := range p.FIELDS {
+				ptr, err := toFloat64Ptr(reply.Elems[i], p.KEY, field)
+				if nil != err {
+					return err
+				}
+				p.Cache.Set(field, ptr)
+			}
+			return nil
+		}
+		return []*transactionOp{{append([]string{"HMGET", p.KEY}, p.FIELDS...), apply}}, nil
+
+	case opSet:
+		args := []string{"HMSET", p.KEY}
+		for _, field := range p.FIELDS {
+			args = append(args, field, amount)
+		}
+		apply := func(reply *redis.Reply) error {
+			p.CacheReset()
+			if nil != reply.Err {
+				return redisError(reply.Err, "", "")
+			}
+			value, _ := strconv.ParseFloat(amount, 64)
+			for _, field := range p.FIELDS {
+				p.Cache.Set(field, &value)
+			}
+			return nil
+		}
+		return []*transactionOp{{args, apply}}, nil
+
+	case opAdd, opSub:
+		if op == opSub {
+			negated, err := negateFloat64Amount(amount)
+			if nil != err {
+				return nil, err
+			}
+			amount = negated
+		}
+		ops := make([]*transactionOp, len(p.FIELDS))
+		for i, field := range p.FIELDS {
+			field := field
+			apply := func(reply *redis.Reply) error {
+				ptr, err := toFloat64Ptr(reply, p.KEY, field)
+				p.Cache.Set(field, ptr)
+				return err
+			}
+			ops[i] = &transactionOp{[]string{"HINCRBYFLOAT", p.KEY, field, amount}, apply}
+		}
+		return ops, nil
+
+	default:
+		return []*transactionOp{{append([]string{"HDEL", p.KEY}, p.FIELDS...), applyDeleted(p.CacheReset)}}, nil
+	}
+}
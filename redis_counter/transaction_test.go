@@ -0,0 +1,143 @@
+package redis_counter
+
+import "math"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestRedisTransactionSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(RedisTransactionSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func RedisTransactionSpecs(c gospec.Context) {
+
+	c.Specify("[RedisTransaction][Make] Makes new instance", func() {
+		value, err := MakeRedisTransaction(nil)
+		c.Expect(err.Error(), gospec.Equals, "Nil redis connection")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisTransaction(&dog_pool.RedisConnection{}, "Bob", "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key[1]")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeRedisTransaction(&dog_pool.RedisConnection{}, "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.MaxRetries, gospec.Equals, TransactionMaxRetries)
+	})
+
+	c.Specify("[RedisTransaction][Queue] Validates the operations", func() {
+		value, _ := MakeRedisTransaction(&dog_pool.RedisConnection{})
+		counter, _ := MakeRedisKeyCounterInt64(&dog_pool.RedisConnection{}, "Bob")
+		mcounter, _ := MakeRedisHashMFieldsCounterFloat64(&dog_pool.RedisConnection{}, "Hash", "A", "B")
+
+		c.Expect(value.AddFloat64(counter, 1.5).Error(), gospec.Equals, "Invalid int64 amount: 1.5")
+		c.Expect(value.Get(nil).Error(), gospec.Equals, "Nil counter")
+		c.Expect(value.AddFloat64(counter, 2), gospec.Equals, nil)
+		c.Expect(value.AddInt64(mcounter, 2), gospec.Equals, nil)
+		c.Expect(value.Len(), gospec.Equals, 3)
+
+		value.Reset()
+		c.Expect(value.Len(), gospec.Equals, 0)
+
+		// Negated amounts:
+		field, _ := MakeRedisHashFieldCounterInt64(&dog_pool.RedisConnection{}, "Hash", "A")
+		c.Expect(value.SubInt64(field, math.MinInt64).Error(), gospec.Equals, "Invalid int64 amount: -9223372036854775808 can't be negated")
+		c.Expect(value.SubInt64(field, -5), gospec.Equals, nil)
+		c.Expect(value.SubFloat64(mcounter, 0), gospec.Equals, nil)
+		c.Expect(value.ops[0].args, gospec.Equals, []string{"HINCRBY", "Hash", "A", "5"})
+		c.Expect(value.ops[1].args, gospec.Equals, []string{"HINCRBYFLOAT", "Hash", "A", "0"})
+	})
+
+	c.Specify("[RedisTransaction][Exec] Applies mixed counter types atomically", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		redis := server.Connection()
+		redis.Cmd("HSET", "Hash", "Stock", "10")
+
+		hits, _ := MakeRedisKeyCounterInt64(redis, "Hits")
+		stock, _ := MakeRedisHashFieldCounterInt64(redis, "Hash", "Stock")
+		gauge, _ := MakeRedisKeyCounterFloat64(redis, "Gauge")
+		regions, _ := MakeRedisMKeysCounterInt64(redis, "us", "uk")
+
+		value, _ := MakeRedisTransaction(redis)
+		value.AddInt64(hits, 5)
+		value.SubInt64(stock, 3)
+		value.SetFloat64(gauge, 0.5)
+		value.AddInt64(regions, 2)
+		value.Get(regions)
+
+		err := value.Exec()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(*hits.LastValue, gospec.Equals, int64(5))
+		c.Expect(*stock.LastValue, gospec.Equals, int64(7))
+		c.Expect(*gauge.LastValue, gospec.Equals, 0.5)
+		c.Expect(*regions.Cache.Value("uk"), gospec.Equals, int64(2))
+
+		counter, _ := redis.Cmd("HGET", "Hash", "Stock").Int64()
+		c.Expect(counter, gospec.Equals, int64(7))
+
+		// Errors inside EXEC don't roll back the other commands:
+		redis.Cmd("SET", "Hash", "Bob")
+		err = value.Exec()
+		c.Expect(err, gospec.Satisfies, nil != err)
+		c.Expect(*hits.LastValue, gospec.Equals, int64(10))
+		c.Expect(stock.LastValue, gospec.Satisfies, nil == stock.LastValue)
+	})
+
+	c.Specify("[RedisTransaction][ExecFunc] Retries when a watched key changes", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		redis := server.Connection()
+		other := &dog_pool.RedisConnection{Url: redis.Url, Logger: &logger}
+		defer other.Close()
+		redis.Cmd("SET", "Bob", "10")
+
+		counter, _ := MakeRedisKeyCounterInt64(redis, "Bob")
+		value, _ := MakeRedisTransaction(redis, "Bob")
+
+		// Double the counter, while another client increments it once:
+		attempts := 0
+		err := value.ExecFunc(func(tx *RedisTransaction) error {
+			attempts++
+			amount, err := counter.Get()
+			if nil != err {
+				return err
+			}
+			if attempts == 1 {
+				other.Cmd("INCR", "Bob")
+			}
+			return tx.SetInt64(counter, amount*2)
+		})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(attempts, gospec.Equals, 2)
+		c.Expect(*counter.LastValue, gospec.Equals, int64(22))
+
+		// Give up after MaxRetries:
+		value.MaxRetries = 0
+		err = value.ExecFunc(func(tx *RedisTransaction) error {
+			other.Cmd("INCR", "Bob")
+			return tx.AddInt64(counter, 1)
+		})
+		c.Expect(err, gospec.Equals, ErrTransactionConflict)
+
+		amount, _ := redis.Cmd("GET", "Bob").Int64()
+		c.Expect(amount, gospec.Equals, int64(23))
+	})
+}
@@ -0,0 +1,86 @@
+package wal_journal
+
+import "fmt"
+import "math"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+
+// Journals the deltas of the counter at KEY, or at FIELD of the hash at
+// KEY when FIELD is set; a Replayer applies them to Redis
+type JournalCounterInt64 struct {
+	Journal *Journal
+	KEY     string
+	FIELD   string
+}
+
+// Make a new instance of JournalCounterInt64; "field" is empty for a key
+// counter
+func MakeJournalCounterInt64(journal *Journal, key, field string) (*JournalCounterInt64, error) {
+	switch {
+	case nil == journal:
+		return nil, fmt.Errorf("Nil journal")
+	case len(key) == 0:
+		return nil, &redis_counter.ErrEmptyKey{Index: -1}
+	default:
+		return &JournalCounterInt64{journal, key, field}, nil
+	}
+}
+
+func (p *JournalCounterInt64) Add(amount int64) error {
+	_, err := p.Journal.Append(Entry{Key: p.KEY, Field: p.FIELD, Int64: amount})
+	return err
+}
+
+// Journals an add of -amount; math.MinInt64 can't be negated, so it is
+// rejected rather than journaled as an add of itself
+func (p *JournalCounterInt64) Sub(amount int64) error {
+	if math.MinInt64 == amount {
+		return fmt.Errorf("Invalid int64 amount: %d can't be negated", amount)
+	}
+	return p.Add(-amount)
+}
+
+func (p *JournalCounterInt64) Increment() error {
+	return p.Add(1)
+}
+
+func (p *JournalCounterInt64) Decrement() error {
+	return p.Add(-1)
+}
+
+// Journals the deltas of the counter at KEY, or at FIELD of the hash at
+// KEY when FIELD is set; a Replayer applies them to Redis
+type JournalCounterFloat64 struct {
+	Journal *Journal
+	KEY     string
+	FIELD   string
+}
+
+// Make a new instance of JournalCounterFloat64; "field" is empty for a key
+// counter
+func MakeJournalCounterFloat64(journal *Journal, key, field string) (*JournalCounterFloat64, error) {
+	switch {
+	case nil == journal:
+		return nil, fmt.Errorf("Nil journal")
+	case len(key) == 0:
+		return nil, &redis_counter.ErrEmptyKey{Index: -1}
+	default:
+		return &JournalCounterFloat64{journal, key, field}, nil
+	}
+}
+
+func (p *JournalCounterFloat64) Add(amount float64) error {
+	_, err := p.Journal.Append(Entry{Key: p.KEY, Field: p.FIELD, IsFloat: true, Float64: amount})
+	return err
+}
+
+func (p *JournalCounterFloat64) Sub(amount float64) error {
+	return p.Add(-amount)
+}
+
+func (p *JournalCounterFloat64) Increment() error {
+	return p.Add(1)
+}
+
+func (p *JournalCounterFloat64) Decrement() error {
+	return p.Add(-1)
+}
@@ -0,0 +1,172 @@
+package wal_journal
+
+import "fmt"
+import "os"
+import "path/filepath"
+import "sync"
+
+// Default size at which a segment is closed and a new one started
+const DefaultSegmentSize = 64 * 1024 * 1024
+
+// Append-only log of counter deltas, kept in segment files in Dir:
+//
+//	journal, _ := MakeJournal("/var/lib/counters", 0)
+//	hits, _ := MakeJournalCounterInt64(journal, "hits", "")
+//	err := hits.Add(1)
+//
+// Each record has a CRC-32 checksum, so a record torn by a crash is
+// detected and dropped when the journal is reopened. Records are
+// addressed by their byte offset in the whole journal; segments are
+// named after the offset of their first record.
+//
+// Appends are written to the file without fsync, which survives a
+// process restart; call Sync to survive a power loss as well.
+type Journal struct {
+	Dir         string
+	SegmentSize int64
+
+	mutex  sync.Mutex
+	file   *os.File
+	base   int64
+	size   int64
+	buffer []byte
+}
+
+// Make a new instance of Journal, creating "dir" if needed; continues
+// after the last valid record of an existing journal. "segment_size" is
+// 0 for DefaultSegmentSize.
+func MakeJournal(dir string, segment_size int64) (*Journal, error) {
+	switch {
+	case len(dir) == 0:
+		return nil, fmt.Errorf("Empty journal dir")
+	case segment_size < 0:
+		return nil, fmt.Errorf("Invalid segment size: %d", segment_size)
+	case segment_size == 0:
+		segment_size = DefaultSegmentSize
+	}
+
+	if err := os.MkdirAll(dir, 0755); nil != err {
+		return nil, err
+	}
+
+	bases, err := segmentBases(dir)
+	if nil != err {
+		return nil, err
+	}
+
+	p := &Journal{Dir: dir, SegmentSize: segment_size}
+	if len(bases) == 0 {
+		return p, p.openSegment(0)
+	}
+
+	// Drop a torn record at the end of the last segment:
+	last := bases[len(bases)-1]
+	size, err := validSize(segmentPath(dir, last))
+	if nil != err {
+		return nil, err
+	}
+	if err := os.Truncate(segmentPath(dir, last), size); nil != err {
+		return nil, err
+	}
+
+	if err := p.openSegment(last); nil != err {
+		return nil, err
+	}
+	p.size = size
+	return p, nil
+}
+
+// Offset after the last record
+func (p *Journal) Offset() int64 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return p.base + p.size
+}
+
+// Append a delta for the counter at "key" and "field" (empty for a key
+// counter); returns the offset after the record
+func (p *Journal) Append(entry Entry) (int64, error) {
+	if err := entry.validate(); nil != err {
+		return 0, err
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if nil == p.file {
+		return 0, fmt.Errorf("Journal closed")
+	}
+
+	if p.size >= p.SegmentSize {
+		if err := p.rotate(); nil != err {
+			return 0, err
+		}
+	}
+
+	p.buffer = appendRecord(p.buffer[:0], entry)
+	if n, err := p.file.Write(p.buffer); nil != err {
+		// Cut the torn record, or the records appended after it would be
+		// dropped with it on reopen; when that fails, stop appending:
+		if n > 0 {
+			if trunc_err := p.file.Truncate(p.size); nil != trunc_err {
+				p.file.Close()
+				p.file = nil
+			}
+		}
+		return 0, err
+	}
+	p.size += int64(len(p.buffer))
+	return p.base + p.size, nil
+}
+
+// Flush the appended records to disk
+func (p *Journal) Sync() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if nil == p.file {
+		return fmt.Errorf("Journal closed")
+	}
+	return p.file.Sync()
+}
+
+func (p *Journal) Close() error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if nil == p.file {
+		return nil
+	}
+	err := p.file.Close()
+	p.file = nil
+	return err
+}
+
+//
+// Internal Helpers:
+//
+
+func segmentPath(dir string, base int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%020d.wal", base))
+}
+
+func (p *Journal) openSegment(base int64) error {
+	file, err := os.OpenFile(segmentPath(p.Dir, base), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if nil != err {
+		return err
+	}
+	p.file, p.base, p.size = file, base, 0
+	return nil
+}
+
+// Close the current segment and start a new one at the current offset
+func (p *Journal) rotate() error {
+	if err := p.file.Sync(); nil != err {
+		return err
+	}
+	if err := p.file.Close(); nil != err {
+		return err
+	}
+	return p.openSegment(p.base + p.size)
+}
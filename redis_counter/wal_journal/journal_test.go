@@ -0,0 +1,176 @@
+package wal_journal
+
+import "errors"
+import "math"
+import "os"
+import "path/filepath"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestJournalSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(JournalSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func JournalSpecs(c gospec.Context) {
+
+	c.Specify("[Journal][Make] Makes new instance", func() {
+		dir, _ := os.MkdirTemp("", "journal")
+		defer os.RemoveAll(dir)
+
+		value, err := MakeJournal("", 0)
+		c.Expect(err.Error(), gospec.Equals, "Empty journal dir")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeJournal(dir, -1)
+		c.Expect(err.Error(), gospec.Equals, "Invalid segment size: -1")
+
+		value, err = MakeJournal(dir, 0)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.SegmentSize, gospec.Equals, int64(DefaultSegmentSize))
+		c.Expect(value.Offset(), gospec.Equals, int64(0))
+		value.Close()
+	})
+
+	c.Specify("[JournalCounter][Make] Makes new instance", func() {
+		dir, _ := os.MkdirTemp("", "journal")
+		defer os.RemoveAll(dir)
+		journal, _ := MakeJournal(dir, 0)
+		defer journal.Close()
+
+		value, err := MakeJournalCounterInt64(nil, "Bob", "")
+		c.Expect(err.Error(), gospec.Equals, "Nil journal")
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeJournalCounterInt64(journal, "", "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key")
+
+		value, err = MakeJournalCounterInt64(journal, "Bob", "")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.KEY, gospec.Equals, "Bob")
+
+		float, err := MakeJournalCounterFloat64(journal, "Hash", "Bob")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(float.FIELD, gospec.Equals, "Bob")
+	})
+
+	c.Specify("[Journal][Append] Reads back the appended deltas", func() {
+		dir, _ := os.MkdirTemp("", "journal")
+		defer os.RemoveAll(dir)
+		journal, _ := MakeJournal(dir, 0)
+		defer journal.Close()
+
+		bob, _ := MakeJournalCounterInt64(journal, "Bob", "")
+		load, _ := MakeJournalCounterFloat64(journal, "Hash", "Bob")
+		c.Expect(bob.Add(5), gospec.Equals, nil)
+		c.Expect(bob.Decrement(), gospec.Equals, nil)
+		c.Expect(load.Sub(0.5), gospec.Equals, nil)
+		c.Expect(bob.Sub(math.MinInt64).Error(), gospec.Equals, "Invalid int64 amount: -9223372036854775808 can't be negated")
+
+		entries, next, err := ReadJournal(dir, 0, 10)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(next, gospec.Equals, journal.Offset())
+		c.Expect(len(entries), gospec.Equals, 3)
+		c.Expect(entries[0], gospec.Equals, Entry{Key: "Bob", Int64: 5})
+		c.Expect(entries[1], gospec.Equals, Entry{Key: "Bob", Int64: -1})
+		c.Expect(entries[2], gospec.Equals, Entry{Key: "Hash", Field: "Bob", IsFloat: true, Float64: -0.5})
+
+		// Reads from an offset, up to the limit:
+		entries, next2, err := ReadJournal(dir, 0, 1)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(entries), gospec.Equals, 1)
+
+		entries, next2, err = ReadJournal(dir, next2, 10)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(entries), gospec.Equals, 2)
+		c.Expect(next2, gospec.Equals, next)
+	})
+
+	c.Specify("[Journal][Append] Rotates segments", func() {
+		dir, _ := os.MkdirTemp("", "journal")
+		defer os.RemoveAll(dir)
+		journal, _ := MakeJournal(dir, 64)
+		defer journal.Close()
+
+		bob, _ := MakeJournalCounterInt64(journal, "Bob", "")
+		for i := 0; i < 10; i++ {
+			bob.Increment()
+		}
+
+		bases, _ := segmentBases(dir)
+		c.Expect(len(bases), gospec.Satisfies, len(bases) > 1)
+
+		entries, next, err := ReadJournal(dir, 0, 100)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(entries), gospec.Equals, 10)
+		c.Expect(next, gospec.Equals, journal.Offset())
+
+		// Segments before an offset are removed, except the last one:
+		c.Expect(removeSegments(dir, next), gospec.Equals, nil)
+		bases, _ = segmentBases(dir)
+		c.Expect(len(bases), gospec.Equals, 1)
+
+		entries, _, err = ReadJournal(dir, 0, 100)
+		c.Expect(errors.Is(err, ErrCorruptJournal), gospec.Equals, true)
+
+		entries, _, err = ReadJournal(dir, next, 100)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(entries), gospec.Equals, 0)
+	})
+
+	c.Specify("[Journal][Make] Drops a torn record at the end", func() {
+		dir, _ := os.MkdirTemp("", "journal")
+		defer os.RemoveAll(dir)
+		journal, _ := MakeJournal(dir, 0)
+
+		bob, _ := MakeJournalCounterInt64(journal, "Bob", "")
+		bob.Add(1)
+		offset := journal.Offset()
+		bob.Add(2)
+		journal.Close()
+
+		// Cut the last record short:
+		os.Truncate(segmentPath(dir, 0), offset+5)
+		entries, next, err := ReadJournal(dir, 0, 10)
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(len(entries), gospec.Equals, 1)
+		c.Expect(next, gospec.Equals, offset)
+
+		journal, err = MakeJournal(dir, 0)
+		c.Expect(err, gospec.Equals, nil)
+		defer journal.Close()
+		c.Expect(journal.Offset(), gospec.Equals, offset)
+
+		bob.Journal = journal
+		bob.Add(3)
+		entries, _, _ = ReadJournal(dir, 0, 10)
+		c.Expect(len(entries), gospec.Equals, 2)
+		c.Expect(entries[1].Int64, gospec.Equals, int64(3))
+	})
+
+	c.Specify("[Journal][Read] Fails on a corrupt record before the end", func() {
+		dir, _ := os.MkdirTemp("", "journal")
+		defer os.RemoveAll(dir)
+		journal, _ := MakeJournal(dir, 32)
+		defer journal.Close()
+
+		bob, _ := MakeJournalCounterInt64(journal, "Bob", "")
+		for i := 0; i < 4; i++ {
+			bob.Increment()
+		}
+
+		// Flip a byte of the delta in the first segment:
+		path := filepath.Join(dir, "00000000000000000000.wal")
+		data, _ := os.ReadFile(path)
+		data[len(data)-1] ^= 0xff
+		os.WriteFile(path, data, 0644)
+
+		_, _, err := ReadJournal(dir, 0, 10)
+		c.Expect(errors.Is(err, ErrCorruptJournal), gospec.Equals, true)
+	})
+}
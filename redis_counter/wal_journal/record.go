@@ -0,0 +1,290 @@
+package wal_journal
+
+import "bufio"
+import "encoding/binary"
+import "errors"
+import "fmt"
+import "hash/crc32"
+import "io"
+import "math"
+import "os"
+import "path/filepath"
+import "sort"
+import "strconv"
+import "strings"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+
+// Returned when a record before the end of the journal fails its
+// checksum, or a segment is missing
+var ErrCorruptJournal = errors.New("Corrupt journal")
+
+// One delta for the counter at Key (and Field, for a hash field counter)
+type Entry struct {
+	Key, Field string
+	IsFloat    bool
+	Int64      int64
+	Float64    float64
+}
+
+// Read up to "limit" entries after "offset"; returns the entries and the
+// offset after the last one. A torn record at the end of the last segment
+// is the end of the journal.
+func ReadJournal(dir string, offset int64, limit int) ([]Entry, int64, error) {
+	bases, err := segmentBases(dir)
+	switch {
+	case nil != err:
+		return nil, offset, err
+	case offset < 0:
+		return nil, offset, fmt.Errorf("Invalid journal offset: %d", offset)
+	case limit <= 0:
+		return nil, offset, fmt.Errorf("Invalid limit: %d", limit)
+	case len(bases) == 0 && offset == 0:
+		return nil, offset, nil
+	case len(bases) == 0 || offset < bases[0]:
+		return nil, offset, fmt.Errorf("%w: offset %d was removed", ErrCorruptJournal, offset)
+	}
+
+	// The segment holding "offset":
+	index := sort.Search(len(bases), func(i int) bool { return bases[i] > offset }) - 1
+
+	entries := []Entry{}
+	for ; index < len(bases) && len(entries) < limit; index++ {
+		last := index == len(bases)-1
+		entries, offset, err = readSegment(segmentPath(dir, bases[index]), bases[index], offset, entries, limit, last)
+		if nil != err {
+			return nil, offset, err
+		}
+
+		if !last && len(entries) < limit && offset != bases[index+1] {
+			return nil, offset, fmt.Errorf("%w: segment %d ends at %d, not %d", ErrCorruptJournal, bases[index], offset, bases[index+1])
+		}
+	}
+	return entries, offset, nil
+}
+
+//
+// Internal Helpers:
+//
+
+const (
+	kindInt64   = 0
+	kindFloat64 = 1
+)
+
+// Length and CRC-32 of the payload
+const headerSize = 8
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// A record that fails its checksum or can't be decoded
+var errBadRecord = errors.New("Bad journal record")
+
+func (e Entry) validate() error {
+	switch {
+	case len(e.Key) == 0:
+		return &redis_counter.ErrEmptyKey{Index: -1}
+	case len(e.Key)+len(e.Field) > maxNameSize:
+		return fmt.Errorf("Key and field longer than %d bytes", maxNameSize)
+	case e.IsFloat && (math.IsNaN(e.Float64) || math.IsInf(e.Float64, 0)):
+		return fmt.Errorf("Invalid float64 amount: %v", e.Float64)
+	default:
+		return nil
+	}
+}
+
+// Append the record of "entry" to "buffer":
+//
+//	uint32 payload length | uint32 CRC-32C of payload | payload
+//
+// The payload is the kind byte, the key and field each prefixed with
+// their uvarint length, and the 8 byte delta.
+func appendRecord(buffer []byte, entry Entry) []byte {
+	start := len(buffer)
+	buffer = append(buffer, make([]byte, headerSize)...)
+
+	kind, bits := byte(kindInt64), uint64(entry.Int64)
+	if entry.IsFloat {
+		kind, bits = kindFloat64, math.Float64bits(entry.Float64)
+	}
+	buffer = append(buffer, kind)
+	buffer = binary.AppendUvarint(buffer, uint64(len(entry.Key)))
+	buffer = append(buffer, entry.Key...)
+	buffer = binary.AppendUvarint(buffer, uint64(len(entry.Field)))
+	buffer = append(buffer, entry.Field...)
+	buffer = binary.BigEndian.AppendUint64(buffer, bits)
+
+	payload := buffer[start+headerSize:]
+	binary.BigEndian.PutUint32(buffer[start:], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buffer[start+4:], crc32.Checksum(payload, castagnoli))
+	return buffer
+}
+
+// Read the next record; returns io.EOF at the end of the file, and
+// errBadRecord for a torn or corrupt record
+func readRecord(reader *bufio.Reader) (Entry, int64, error) {
+	header := make([]byte, headerSize)
+	if n, err := io.ReadFull(reader, header); nil != err {
+		if n == 0 && err == io.EOF {
+			return Entry{}, 0, io.EOF
+		}
+		return Entry{}, 0, errBadRecord
+	}
+
+	length := binary.BigEndian.Uint32(header)
+	if length > maxPayloadSize {
+		return Entry{}, 0, errBadRecord
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); nil != err {
+		return Entry{}, 0, errBadRecord
+	}
+	if crc32.Checksum(payload, castagnoli) != binary.BigEndian.Uint32(header[4:]) {
+		return Entry{}, 0, errBadRecord
+	}
+
+	entry, ok := decodePayload(payload)
+	if !ok {
+		return Entry{}, 0, errBadRecord
+	}
+	return entry, int64(headerSize + length), nil
+}
+
+// Longest key and field of a record, so a corrupt length can't allocate
+// more than this
+const maxNameSize = 1024 * 1024
+
+// Kind byte, two uvarint lengths of up to 10 bytes, the key and field,
+// and the delta
+const maxPayloadSize = 1 + 10 + 10 + maxNameSize + 8
+
+func decodePayload(payload []byte) (Entry, bool) {
+	if len(payload) < 1 {
+		return Entry{}, false
+	}
+	kind, rest := payload[0], payload[1:]
+
+	key, rest, ok := decodeString(rest)
+	if !ok {
+		return Entry{}, false
+	}
+	field, rest, ok := decodeString(rest)
+	if !ok || len(rest) != 8 {
+		return Entry{}, false
+	}
+
+	bits := binary.BigEndian.Uint64(rest)
+	switch kind {
+	case kindInt64:
+		return Entry{Key: key, Field: field, Int64: int64(bits)}, true
+	case kindFloat64:
+		return Entry{Key: key, Field: field, IsFloat: true, Float64: math.Float64frombits(bits)}, true
+	default:
+		return Entry{}, false
+	}
+}
+
+func decodeString(buffer []byte) (string, []byte, bool) {
+	length, n := binary.Uvarint(buffer)
+	if n <= 0 || length > uint64(len(buffer)-n) {
+		return "", nil, false
+	}
+	end := n + int(length)
+	return string(buffer[n:end]), buffer[end:], true
+}
+
+// Read the records of the segment at "path" from "offset" into "entries";
+// errBadRecord is the end of the last segment and corruption in any other
+func readSegment(path string, base, offset int64, entries []Entry, limit int, last bool) ([]Entry, int64, error) {
+	file, err := os.Open(path)
+	if nil != err {
+		return entries, offset, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	switch {
+	case nil != err:
+		return entries, offset, err
+	case offset-base > info.Size():
+		return entries, offset, fmt.Errorf("%w: offset %d is past the end of segment %d", ErrCorruptJournal, offset, base)
+	}
+
+	if _, err := file.Seek(offset-base, io.SeekStart); nil != err {
+		return entries, offset, err
+	}
+
+	reader := bufio.NewReader(file)
+	for len(entries) < limit {
+		entry, size, err := readRecord(reader)
+		switch {
+		case err == io.EOF:
+			return entries, offset, nil
+		case err == errBadRecord && last:
+			return entries, offset, nil
+		case err == errBadRecord:
+			return entries, offset, fmt.Errorf("%w: bad record at offset %d", ErrCorruptJournal, offset)
+		case nil != err:
+			return entries, offset, err
+		}
+		entries = append(entries, entry)
+		offset += size
+	}
+	return entries, offset, nil
+}
+
+// Size of the valid records at the start of the segment at "path"
+func validSize(path string) (int64, error) {
+	file, err := os.Open(path)
+	if nil != err {
+		return 0, err
+	}
+	defer file.Close()
+
+	size := int64(0)
+	reader := bufio.NewReader(file)
+	for {
+		_, n, err := readRecord(reader)
+		switch {
+		case err == io.EOF || err == errBadRecord:
+			return size, nil
+		case nil != err:
+			return size, err
+		}
+		size += n
+	}
+}
+
+// Sorted base offsets of the segments in "dir"
+func segmentBases(dir string) ([]int64, error) {
+	names, err := filepath.Glob(filepath.Join(dir, "*.wal"))
+	if nil != err {
+		return nil, err
+	}
+
+	bases := make([]int64, 0, len(names))
+	for _, name := range names {
+		base, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(name), ".wal"), 10, 64)
+		if nil != err {
+			continue
+		}
+		bases = append(bases, base)
+	}
+	sort.Slice(bases, func(i, j int) bool { return bases[i] < bases[j] })
+	return bases, nil
+}
+
+// Remove the segments that end at or before "offset"; the last segment is
+// kept, since the journal appends to it
+func removeSegments(dir string, offset int64) error {
+	bases, err := segmentBases(dir)
+	if nil != err {
+		return err
+	}
+
+	for i := 0; i+1 < len(bases) && bases[i+1] <= offset; i++ {
+		if err := os.Remove(segmentPath(dir, bases[i])); nil != err {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package wal_journal
+
+import "fmt"
+import "math"
+import "sort"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+
+// Default number of records applied per transaction
+const DefaultBatchSize = 1000
+
+// Drains a Journal into Redis:
+//
+//	replayer, _ := MakeReplayer(redis, "/var/lib/counters", "counters:journal:ack")
+//	applied, err := replayer.Drain()
+//
+// Records are read in batches of BatchSize, the deltas of each counter
+// are summed, and counters with the same delta are incremented together
+// through the multi-key and multi-field counters. The increments and the
+// new offset, stored at AckKey, are written in one MULTI/EXEC with AckKey
+// watched, so a batch is applied once even with several replayers or
+// after a crash. Segments before the acknowledged offset are removed.
+//
+// MULTI/EXEC doesn't roll back, so a delta Redis rejects (e.g. for a key
+// holding a string) is acknowledged with the rest of its batch, and its
+// error returned.
+type Replayer struct {
+	Redis     *dog_pool.RedisConnection
+	Dir       string
+	AckKey    string
+	BatchSize int
+}
+
+// Make a new instance of Replayer
+func MakeReplayer(redis *dog_pool.RedisConnection, dir, ack_key string) (*Replayer, error) {
+	switch {
+	case nil == redis:
+		return nil, redis_counter.ErrNilConnection
+	case len(dir) == 0:
+		return nil, fmt.Errorf("Empty journal dir")
+	case len(ack_key) == 0:
+		return nil, &redis_counter.ErrEmptyKey{Index: -1}
+	default:
+		return &Replayer{Redis: redis, Dir: dir, AckKey: ack_key, BatchSize: DefaultBatchSize}, nil
+	}
+}
+
+// Offset of the journal applied to Redis
+func (p *Replayer) Acked() (int64, error) {
+	ack, err := redis_counter.MakeRedisKeyCounterInt64(p.Redis, p.AckKey)
+	if nil != err {
+		return 0, err
+	}
+	return ack.Get()
+}
+
+// Apply the records after the acknowledged offset, until the end of the
+// journal; returns the number of records applied
+func (p *Replayer) Drain() (int, error) {
+	applied := 0
+	for {
+		n, err := p.drainBatch()
+		applied += n
+		if nil != err || n == 0 {
+			return applied, err
+		}
+	}
+}
+
+//
+// Internal Helpers:
+//
+
+// Key of the summed deltas
+type counterKey struct {
+	key, field string
+	is_float   bool
+}
+
+// Apply one batch; returns the number of records applied
+func (p *Replayer) drainBatch() (int, error) {
+	tx, err := redis_counter.MakeRedisTransaction(p.Redis, p.AckKey)
+	if nil != err {
+		return 0, err
+	}
+	ack, err := redis_counter.MakeRedisKeyCounterInt64(p.Redis, p.AckKey)
+	if nil != err {
+		return 0, err
+	}
+
+	// Re-read for each attempt, since another replayer may have moved the
+	// acknowledged offset:
+	var entries []Entry
+	var next int64
+	err = tx.ExecFunc(func(tx *redis_counter.RedisTransaction) error {
+		offset, err := ack.Get()
+		if nil != err {
+			return err
+		}
+
+		entries, next, err = ReadJournal(p.Dir, offset, p.batchSize())
+		if nil != err || len(entries) == 0 {
+			return err
+		}
+
+		if err := p.queueDeltas(tx, entries); nil != err {
+			return err
+		}
+		return tx.SetInt64(ack, next)
+	})
+
+	// The offset is saved to the ack counter once the batch is applied:
+	if len(entries) == 0 || nil == ack.LastValue || *ack.LastValue != next {
+		return 0, err
+	}
+
+	if remove_err := removeSegments(p.Dir, next); nil == err {
+		err = remove_err
+	}
+	return len(entries), err
+}
+
+func (p *Replayer) batchSize() int {
+	if p.BatchSize > 0 {
+		return p.BatchSize
+	}
+	return DefaultBatchSize
+}
+
+// Sum the deltas of each counter, and queue one increment for each group
+// of counters with the same delta; a sum that would overflow is queued as
+// it is, and a new sum started
+func (p *Replayer) queueDeltas(tx *redis_counter.RedisTransaction, entries []Entry) error {
+	deltas := map[counterKey]*Entry{}
+	sums := []*Entry{}
+	for _, entry := range entries {
+		key := counterKey{entry.Key, entry.Field, entry.IsFloat}
+		sum, ok := deltas[key]
+		if !ok || sumOverflows(sum, entry) {
+			sum = &Entry{Key: entry.Key, Field: entry.Field, IsFloat: entry.IsFloat}
+			deltas[key] = sum
+			sums = append(sums, sum)
+		}
+		sum.Int64 += entry.Int64
+		sum.Float64 += entry.Float64
+	}
+
+	// Groups of keys, or of fields of one hash, by delta:
+	type group struct {
+		key      string
+		is_float bool
+		int64    int64
+		float64  float64
+	}
+	groups := map[group][]string{}
+	group_order := []group{}
+	for _, sum := range sums {
+		g := group{key: sum.Key, is_float: sum.IsFloat}
+		name := sum.Key
+		if len(sum.Field) > 0 {
+			name = sum.Field
+		} else {
+			g.key = ""
+		}
+
+		g.int64, g.float64 = sum.Int64, sum.Float64
+		if g.int64 == 0 && g.float64 == 0 {
+			continue
+		}
+
+		if _, ok := groups[g]; !ok {
+			group_order = append(group_order, g)
+		}
+		groups[g] = append(groups[g], name)
+	}
+
+	for _, g := range group_order {
+		names := groups[g]
+		sort.Strings(names)
+
+		var counter redis_counter.TransactionCounter
+		var err error
+		switch {
+		case len(g.key) == 0 && g.is_float:
+			counter, err = redis_counter.MakeRedisMKeysCounterFloat64(p.Redis, names...)
+		case len(g.key) == 0:
+			counter, err = redis_counter.MakeRedisMKeysCounterInt64(p.Redis, names...)
+		case g.is_float:
+			counter, err = redis_counter.MakeRedisHashMFieldsCounterFloat64(p.Redis, g.key, names...)
+		default:
+			counter, err = redis_counter.MakeRedisHashMFieldsCounterInt64(p.Redis, g.key, names...)
+		}
+		if nil != err {
+			return err
+		}
+
+		if g.is_float {
+			err = tx.AddFloat64(counter, g.float64)
+		} else {
+			err = tx.AddInt64(counter, g.int64)
+		}
+		if nil != err {
+			return err
+		}
+	}
+	return nil
+}
+
+// Whether adding the delta of "entry" to "sum" would overflow
+func sumOverflows(sum *Entry, entry Entry) bool {
+	switch {
+	case entry.IsFloat:
+		return math.IsInf(sum.Float64+entry.Float64, 0)
+	case entry.Int64 > 0:
+		return sum.Int64 > math.MaxInt64-entry.Int64
+	default:
+		return sum.Int64 < math.MinInt64-entry.Int64
+	}
+}
@@ -0,0 +1,111 @@
+package wal_journal
+
+import "math"
+import "os"
+import "github.com/alecthomas/log4go"
+import "github.com/gnagel/dog_pool/dog_pool"
+import "github.com/gnagel/go_redis_counter/redis_counter"
+import "testing"
+import "github.com/orfjackal/gospec/src/gospec"
+
+func TestReplayerSpecs(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in benchmark mode.")
+		return
+	}
+	r := gospec.NewRunner()
+	r.AddSpec(ReplayerSpecs)
+	gospec.MainGoTest(r, t)
+}
+
+func ReplayerSpecs(c gospec.Context) {
+
+	c.Specify("[Replayer][Make] Makes new instance", func() {
+		value, err := MakeReplayer(nil, "dir", "ack")
+		c.Expect(err, gospec.Equals, redis_counter.ErrNilConnection)
+		c.Expect(value, gospec.Satisfies, nil == value)
+
+		value, err = MakeReplayer(&dog_pool.RedisConnection{}, "", "ack")
+		c.Expect(err.Error(), gospec.Equals, "Empty journal dir")
+
+		value, err = MakeReplayer(&dog_pool.RedisConnection{}, "dir", "")
+		c.Expect(err.Error(), gospec.Equals, "Empty redis key")
+
+		value, err = MakeReplayer(&dog_pool.RedisConnection{}, "dir", "ack")
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(value.BatchSize, gospec.Equals, DefaultBatchSize)
+	})
+
+	c.Specify("[Replayer][queueDeltas] Splits sums that would overflow", func() {
+		value, _ := MakeReplayer(&dog_pool.RedisConnection{}, "dir", "ack")
+		tx, _ := redis_counter.MakeRedisTransaction(&dog_pool.RedisConnection{})
+
+		err := value.queueDeltas(tx, []Entry{
+			{Key: "hits", Int64: math.MaxInt64},
+			{Key: "hits", Int64: math.MaxInt64},
+			{Key: "misses", Int64: 1},
+			{Key: "misses", Int64: 2},
+		})
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(tx.Len(), gospec.Equals, 3)
+	})
+
+	c.Specify("[Replayer][Drain] Applies each delta once", func() {
+		logger := log4go.NewDefaultLogger(log4go.CRITICAL)
+		server, server_err := dog_pool.StartRedisServer(&logger)
+		if nil != server_err {
+			panic(server_err)
+		}
+		defer server.Close()
+
+		dir, _ := os.MkdirTemp("", "journal")
+		defer os.RemoveAll(dir)
+		journal, _ := MakeJournal(dir, 64)
+		defer journal.Close()
+
+		bob, _ := MakeJournalCounterInt64(journal, "Bob", "")
+		gary, _ := MakeJournalCounterInt64(journal, "Gary", "")
+		load, _ := MakeJournalCounterFloat64(journal, "Hash", "web1")
+		for i := 0; i < 3; i++ {
+			bob.Increment()
+			gary.Increment()
+		}
+		load.Add(0.5)
+		load.Add(0.25)
+
+		replayer, _ := MakeReplayer(server.Connection(), dir, "journal:ack")
+		replayer.BatchSize = 4
+
+		applied, err := replayer.Drain()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(applied, gospec.Equals, 8)
+
+		value, _ := server.Connection().Cmd("GET", "Bob").Int64()
+		c.Expect(value, gospec.Equals, int64(3))
+		value, _ = server.Connection().Cmd("GET", "Gary").Int64()
+		c.Expect(value, gospec.Equals, int64(3))
+		float, _ := server.Connection().Cmd("HGET", "Hash", "web1").Str()
+		c.Expect(float, gospec.Equals, "0.75")
+
+		acked, err := replayer.Acked()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(acked, gospec.Equals, journal.Offset())
+
+		// The acknowledged segments are removed:
+		bases, _ := segmentBases(dir)
+		c.Expect(len(bases), gospec.Equals, 1)
+
+		// Draining again applies only the new deltas:
+		bob.Add(10)
+		applied, err = replayer.Drain()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(applied, gospec.Equals, 1)
+
+		applied, err = replayer.Drain()
+		c.Expect(err, gospec.Equals, nil)
+		c.Expect(applied, gospec.Equals, 0)
+
+		value, _ = server.Connection().Cmd("GET", "Bob").Int64()
+		c.Expect(value, gospec.Equals, int64(13))
+	})
+}